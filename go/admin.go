@@ -0,0 +1,119 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"strings"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// ShowSessions lists the sessions graphd knows with SHOW SESSIONS, one row per session,
+// the columns depend on the version of graphd, e.g. SessionId, UserName, SpaceName, CreateTime.
+// The error matches ErrNoPermission if the user is not allowed to list them,
+// other failures on the server side are returned as an *ExecutionError.
+func (session *Session) ShowSessions() (*ResultSet, error) {
+	resp, err := session.Execute("SHOW SESSIONS")
+	if resp == nil {
+		return nil, err
+	}
+	if err := adminError("list the sessions", resp.GetResponse()); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// KillSession kills the session of the ID with KILL SESSION, e.g. one leaked by another client.
+// The error matches ErrNoPermission if the user is not allowed to kill it,
+// other failures on the server side, such as an unknown ID, are returned as an *ExecutionError.
+func (session *Session) KillSession(id int64) error {
+	resp, err := session.Execute(fmt.Sprintf("KILL SESSION %d", id))
+	if resp == nil {
+		return err
+	}
+	return adminError(fmt.Sprintf("kill session %d", id), resp.GetResponse())
+}
+
+// Kill the running query of the session whose text is stmt, the plan ID is looked up with SHOW QUERIES.
+// It runs on another connection since the one of the session is closed when its query is aborted.
+func (pool *ConnectionPool) killQuery(sessionID int64, stmt string) {
+	conn, err := pool.getIdleConn()
+	if err != nil {
+		pool.log.Warn(fmt.Sprintf("Failed to kill the query of session %d, %s", sessionID, err.Error()))
+		return
+	}
+	defer pool.release(conn)
+	resp, err := conn.execute(sessionID, "SHOW QUERIES")
+	if err == nil {
+		err = CheckResponse(resp)
+	}
+	if err != nil {
+		pool.log.Warn(fmt.Sprintf("Failed to find the query of session %d to kill, %s", sessionID, err.Error()))
+		return
+	}
+	queries := newResultSet(resp)
+	for i := 0; i < queries.GetRowSize(); i++ {
+		planID, ok := runningQueryPlan(queries, i, sessionID, stmt)
+		if !ok {
+			continue
+		}
+		resp, err := conn.execute(sessionID, fmt.Sprintf("KILL QUERY (session=%d, plan=%d)", sessionID, planID))
+		if err == nil {
+			err = CheckResponse(resp)
+		}
+		if err != nil {
+			pool.log.Warn(fmt.Sprintf("Failed to kill the query %d of session %d, %s", planID, sessionID, err.Error()))
+		}
+	}
+}
+
+// Return the plan ID of the row of SHOW QUERIES if it is the query of the session whose text is stmt
+func runningQueryPlan(queries *ResultSet, index int, sessionID int64, stmt string) (int64, bool) {
+	record, err := queries.GetRowValuesByIndex(index)
+	if err != nil {
+		return 0, false
+	}
+	id, err := record.GetValueByColName("SessionID")
+	if err != nil {
+		return 0, false
+	}
+	plan, err := record.GetValueByColName("ExecutionPlanID")
+	if err != nil {
+		return 0, false
+	}
+	query, err := record.GetValueByColName("Query")
+	if err != nil {
+		return 0, false
+	}
+	if rowSession, _ := id.AsInt(); rowSession != sessionID {
+		return 0, false
+	}
+	if text, _ := query.AsString(); strings.TrimSpace(text) != strings.TrimSpace(stmt) {
+		return 0, false
+	}
+	planID, err := plan.AsInt()
+	return planID, err == nil
+}
+
+// Build the error of an administration statement, nil if it succeeded.
+// It matches ErrNoPermission if graphd reports the user has no permission.
+func adminError(action string, resp *graph.ExecutionResponse) error {
+	err := CheckResponse(resp)
+	if err == nil {
+		return nil
+	}
+	var kind error
+	if resp.GetErrorCode() == graph.ErrorCode_E_BAD_PERMISSION {
+		kind = ErrNoPermission
+	}
+	return &kindError{
+		kind: kind,
+		msg:  fmt.Sprintf("Failed to %s, error: %s", action, resp.GetErrorMsg()),
+		err:  err,
+	}
+}
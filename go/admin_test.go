@@ -0,0 +1,142 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestSession_Admin(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		switch stmt {
+		case "SHOW SESSIONS":
+			return &graph.ExecutionResponse{
+				ErrorCode: graph.ErrorCode_SUCCEEDED,
+				Data: &nebula.DataSet{
+					ColumnNames: [][]byte{[]byte("SessionId")},
+					Rows:        []*nebula.Row{{Values: []*nebula.Value{{IVal: &sessionID}}}},
+				},
+			}, nil
+		case "KILL SESSION 42":
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+		default:
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR, ErrorMsg: []byte("Session not found")}, nil
+		}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	sessions, err := session.ShowSessions()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"SessionId"}, sessions.GetColNames())
+		record, err := sessions.GetRowValuesByIndex(0)
+		if assert.NoError(t, err) {
+			value, _ := record.GetValueByIndex(0)
+			id, _ := value.AsInt()
+			assert.Equal(t, session.sessionID, id)
+		}
+	}
+	assert.NoError(t, session.KillSession(42))
+	err = session.KillSession(43)
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_EXECUTION_ERROR, execErr.ErrorCode)
+	}
+	assert.False(t, errors.Is(err, ErrNoPermission))
+
+	service.QueueErrorCodes(graph.ErrorCode_E_BAD_PERMISSION, graph.ErrorCode_E_BAD_PERMISSION)
+	_, err = session.ShowSessions()
+	assert.True(t, errors.Is(err, ErrNoPermission))
+	err = session.KillSession(42)
+	assert.True(t, errors.Is(err, ErrNoPermission))
+	assert.Contains(t, err.Error(), "Failed to kill session 42")
+}
+
+func TestSession_KillQueryOnCancel(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	shown := make(chan int64, 1)
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		switch stmt {
+		case "GO FROM 1 OVER follow":
+			time.Sleep(300 * time.Millisecond)
+		case "SHOW QUERIES":
+			shown <- sessionID
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.KillQueryOnCancel = true
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = session.ExecuteWithContext(ctx, "GO FROM 1 OVER follow")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	select {
+	case id := <-shown:
+		assert.Equal(t, session.sessionID, id)
+	case <-time.After(2 * time.Second):
+		t.Fatal("The query to kill is not looked up")
+	}
+}
+
+func TestRunningQueryPlan(t *testing.T) {
+	// The string values could not be sent by the fake service, the matching is tested on a local result
+	row := func(session, plan int64, query string) *nebula.Row {
+		return &nebula.Row{Values: []*nebula.Value{{IVal: &session}, {IVal: &plan}, {SVal: []byte(query)}}}
+	}
+	queries := newResultSet(&graph.ExecutionResponse{
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("SessionID"), []byte("ExecutionPlanID"), []byte("Query")},
+			Rows: []*nebula.Row{
+				row(101, 7, "GO FROM 1 OVER follow"),
+				row(100, 8, "SHOW QUERIES"),
+				row(100, 9, " GO FROM 1 OVER follow "),
+			},
+		},
+	})
+	var plans []int64
+	for i := 0; i < queries.GetRowSize(); i++ {
+		if plan, ok := runningQueryPlan(queries, i, 100, "GO FROM 1 OVER follow"); ok {
+			plans = append(plans, plan)
+		}
+	}
+	assert.Equal(t, []int64{9}, plans)
+	_, ok := runningQueryPlan(newResultSet(&graph.ExecutionResponse{}), 0, 100, "GO FROM 1 OVER follow")
+	assert.False(t, ok)
+}
@@ -0,0 +1,119 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"time"
+)
+
+// The default time an open circuit skips its host
+const defaultCircuitBreakerCooldown = 30 * time.Second
+
+// CircuitState is the state of the circuit breaker of a host, see PoolConfig.CircuitBreakerThreshold
+type CircuitState int
+
+const (
+	// CircuitClosed means the host is used as usual
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the host failed CircuitBreakerThreshold times in a row, it is skipped until the cooldown ends
+	CircuitOpen
+	// CircuitHalfOpen means the cooldown has ended, the next connection to the host tests if it recovered:
+	// the circuit closes if it succeeds and opens again if it fails
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(state))
+	}
+}
+
+// HostStats is a snapshot of the status of a host tracked by the pool
+type HostStats struct {
+	Host HostAddress
+	Tier HostTier
+	// Whether the last connection to the host succeeded, the unhealthy hosts are used only if no host is healthy
+	Healthy bool
+	// The last error which made the host unhealthy, nil if it is healthy or the reason is unknown
+	LastError error
+	// Number of connections opened to the host
+	Connections int
+	Circuit     CircuitState
+	// Number of failures to open a connection to the host since the last success
+	ConsecutiveFailures int
+	// The time the cooldown of an open circuit ends, zero value if the circuit is closed
+	CircuitOpenUntil time.Time
+}
+
+// HostStats returns the status of every host of the pool, in the order of the addresses
+func (pool *ConnectionPool) HostStats() []HostStats {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	now := time.Now()
+	stats := make([]HostStats, 0, len(pool.addresses))
+	for _, address := range pool.addresses {
+		status := pool.hosts[address]
+		stats = append(stats, HostStats{
+			Host:                address,
+			Tier:                status.tier,
+			Healthy:             status.healthy,
+			LastError:           status.lastErr,
+			Connections:         status.workload,
+			Circuit:             status.circuitState(now),
+			ConsecutiveFailures: status.failures,
+			CircuitOpenUntil:    status.openUntil,
+		})
+	}
+	return stats
+}
+
+// Return the state of the circuit of the host
+func (status *hostStatus) circuitState(now time.Time) CircuitState {
+	switch {
+	case status.openUntil.IsZero():
+		return CircuitClosed
+	case now.Before(status.openUntil):
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+// Count the result of opening a connection to the host, the circuit of the host closes if it succeeds
+// and opens once it fails CircuitBreakerThreshold times in a row. Must be called with the lock held.
+func (pool *ConnectionPool) observeOpen(host HostAddress, err error) {
+	status, ok := pool.hosts[host]
+	if !ok {
+		return
+	}
+	if err == nil {
+		if !status.openUntil.IsZero() {
+			pool.log.Info(fmt.Sprintf("The circuit of host %s:%d is closed", host.Host, host.Port))
+		}
+		status.failures = 0
+		status.openUntil = time.Time{}
+		return
+	}
+	status.failures++
+	threshold := pool.conf.CircuitBreakerThreshold
+	if threshold == 0 || status.failures < threshold {
+		return
+	}
+	// A failed test of a half-open circuit opens it again as well
+	cooldown := pool.conf.getCircuitBreakerCooldown()
+	status.openUntil = time.Now().Add(cooldown)
+	pool.log.Warn(fmt.Sprintf("The circuit of host %s:%d is open for %s after %d consecutive failures, error: %s",
+		host.Host, host.Port, cooldown, status.failures, err.Error()))
+}
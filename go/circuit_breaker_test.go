@@ -0,0 +1,94 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestPool_CircuitBreaker(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	var down, dials int32
+	conf := GetDefaultConf()
+	conf.CircuitBreakerThreshold = 2
+	conf.CircuitBreakerCooldown = 200 * time.Millisecond
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	stats := pool.HostStats()
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, CircuitClosed, stats[0].Circuit)
+	}
+
+	// The circuit opens after two failures in a row, the host is not dialed then
+	atomic.StoreInt32(&down, 1)
+	for i := 0; i < 2; i++ {
+		_, err = pool.GetConnection()
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+	stats = pool.HostStats()
+	assert.Equal(t, CircuitOpen, stats[0].Circuit)
+	assert.Equal(t, 2, stats[0].ConsecutiveFailures)
+	assert.False(t, stats[0].Healthy)
+	dialed := atomic.LoadInt32(&dials)
+	_, err = pool.GetConnection()
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	pool.probeUnhealthyHosts()
+	assert.Equal(t, dialed, atomic.LoadInt32(&dials))
+
+	// A failed test of the half-open circuit opens it again
+	time.Sleep(conf.CircuitBreakerCooldown)
+	assert.Equal(t, CircuitHalfOpen, pool.HostStats()[0].Circuit)
+	_, err = pool.GetConnection()
+	assert.False(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, dialed+1, atomic.LoadInt32(&dials))
+	stats = pool.HostStats()
+	assert.Equal(t, CircuitOpen, stats[0].Circuit)
+	assert.Equal(t, 3, stats[0].ConsecutiveFailures)
+
+	// A successful test closes it
+	time.Sleep(conf.CircuitBreakerCooldown)
+	atomic.StoreInt32(&down, 0)
+	conn, err := pool.GetConnection()
+	if assert.NoError(t, err) {
+		pool.Release(conn)
+	}
+	stats = pool.HostStats()
+	assert.Equal(t, CircuitClosed, stats[0].Circuit)
+	assert.Equal(t, 0, stats[0].ConsecutiveFailures)
+	assert.True(t, stats[0].CircuitOpenUntil.IsZero())
+	assert.True(t, stats[0].Healthy)
+	assert.Equal(t, 1, stats[0].Connections)
+}
+
+func TestCircuitState_String(t *testing.T) {
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+	assert.Equal(t, "CircuitState(5)", CircuitState(5).String())
+}
@@ -16,8 +16,6 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
-
-	"github.com/vesoft-inc/nebula-clients/go/nebula/graph"
 )
 
 const (
@@ -70,7 +68,7 @@ func TestConnection(t *testing.T) {
 	hostAdress := HostAddress{Host: address, Port: port}
 
 	conn := newConnection(hostAdress)
-	err := conn.open(hostAdress, testPoolConfig.TimeOut)
+	err := conn.open(hostAdress, testPoolConfig)
 	if err != nil {
 		t.Fatalf("Fail to open connection, address: %s, port: %d, %s", address, port, err.Error())
 	}
@@ -90,24 +88,23 @@ func TestConnection(t *testing.T) {
 		return
 	}
 
-	checkResp(t, "show hosts", resp)
+	checkResp(t, "show hosts", newResultSet(resp))
 
 	resp, err = conn.execute(sessionID, "CREATE SPACE client_test(partition_num=1024, replica_factor=1);")
 	if err != nil {
 		t.Error(err.Error())
 		return
 	}
-	checkResp(t, "create space", resp)
+	checkResp(t, "create space", newResultSet(resp))
 	resp, err = conn.execute(sessionID, "DROP SPACE client_test;")
 	if err != nil {
 		t.Error(err.Error())
 		return
 	}
-	checkResp(t, "drop space", resp)
+	checkResp(t, "drop space", newResultSet(resp))
 
-	res := conn.ping()
-	if res != true {
-		t.Error("Connectin ping failed")
+	if err := conn.ping(0); err != nil {
+		t.Errorf("Connectin ping failed, %s", err.Error())
 		return
 	}
 
@@ -171,6 +168,8 @@ func TestPool_MultiHosts(t *testing.T) {
 		IdleTime:        0 * time.Millisecond,
 		MaxConnPoolSize: 3,
 		MinConnPoolSize: 1,
+		// Fail at once instead of waiting for a released connection
+		AcquireTimeout: -1,
 	}
 
 	// Initialize connectin pool
@@ -318,7 +317,10 @@ func TestLoadbalancer(t *testing.T) {
 	if assert.Equal(t, len(sessionList), 999) {
 		t.Logf("Expected total sessions: 999, Actual value: %d", len(sessionList))
 	}
-	// TODO: check work load of each host
+	// Check work load of each host
+	for _, host := range hostList {
+		assert.Equal(t, 333, pool.getServerWorkload(host))
+	}
 	for i := 0; i < len(sessionList); i++ {
 		sessionList[i].Release()
 	}
@@ -351,6 +353,7 @@ func TestReconnect(t *testing.T) {
 		sessionList = append(sessionList, session)
 	}
 
+	oldSessionID := sessionList[0].sessionID
 	// Send query to server periodically
 	for i := 0; i < timeoutConfig.MaxConnPoolSize; i++ {
 		time.Sleep(1 * time.Second)
@@ -375,10 +378,9 @@ func TestReconnect(t *testing.T) {
 		return
 	}
 
-	// This assertion will pass only when reconnection happens
-	if assert.Equal(t, resp.GetErrorCode(), graph.ErrorCode_E_SESSION_INVALID) {
-		t.Logf("Expected error: E_SESSION_INVALID")
-	}
+	// The session signs in again on the host it is reconnected to
+	assert.True(t, resp.IsSucceeded())
+	assert.NotEqual(t, oldSessionID, sessionList[0].sessionID)
 
 	startContainer(t, "nebula-docker-compose_graphd_1")
 	startContainer(t, "nebula-docker-compose_graphd2_1")
@@ -400,8 +402,8 @@ func TestIpLookup(t *testing.T) {
 }
 
 // Method used to check execution response
-func checkResp(t *testing.T, prefix string, err *graph.ExecutionResponse) {
-	if IsError(err) {
+func checkResp(t *testing.T, prefix string, err *ResultSet) {
+	if !err.IsSucceeded() {
 		t.Errorf("%s, ErrorCode: %v, ErrorMsg: %s", prefix, err.GetErrorCode(), err.GetErrorMsg())
 	}
 }
@@ -0,0 +1,198 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig is what LoadPoolConfig reads from a config file
+type FileConfig struct {
+	Hosts    []HostAddress
+	Username string
+	Password string
+	// GetDefaultConf with the values set in the file
+	PoolConfig PoolConfig
+}
+
+// The layout of a config file, the same keys are used by YAML and JSON
+type configFileForm struct {
+	Hosts           []string       `json:"hosts" yaml:"hosts"`
+	Username        string         `json:"username" yaml:"username"`
+	Password        string         `json:"password" yaml:"password"`
+	Space           string         `json:"space" yaml:"space"`
+	VIDType         string         `json:"vid_type" yaml:"vid_type"`
+	TimeOut         string         `json:"timeout" yaml:"timeout"`
+	ConnTimeOut     string         `json:"conn_timeout" yaml:"conn_timeout"`
+	ExecTimeOut     string         `json:"exec_timeout" yaml:"exec_timeout"`
+	IdleTime        string         `json:"idle_time" yaml:"idle_time"`
+	MaxConnLifetime string         `json:"max_conn_lifetime" yaml:"max_conn_lifetime"`
+	AcquireTimeout  string         `json:"acquire_timeout" yaml:"acquire_timeout"`
+	MaxConnPoolSize *int           `json:"max_conn_pool_size" yaml:"max_conn_pool_size"`
+	MinConnPoolSize *int           `json:"min_conn_pool_size" yaml:"min_conn_pool_size"`
+	TLS             *configFileTLS `json:"tls" yaml:"tls"`
+}
+
+type configFileTLS struct {
+	CAFile   string `json:"ca_file" yaml:"ca_file"`
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+}
+
+// LoadPoolConfig reads the hosts, the credentials and the pool config from a YAML file, .yaml or .yml,
+// or a JSON file, .json, e.g. in YAML:
+//
+//	hosts: ["127.0.0.1:9669", "[::1]:9670"]   required, parsed by ParseHostAddress
+//	username: root                            required
+//	password: nebula
+//	space: nba                                PoolConfig.SpaceName
+//	vid_type: int64                           PoolConfig.VIDType, string or int64
+//	timeout: 3s                               PoolConfig.TimeOut, a duration parsed by time.ParseDuration
+//	conn_timeout: 1s                          PoolConfig.ConnTimeOut
+//	exec_timeout: 500ms                       PoolConfig.ExecTimeOut
+//	idle_time: 1m                             PoolConfig.IdleTime
+//	max_conn_lifetime: 1h                     PoolConfig.MaxConnLifetime
+//	acquire_timeout: 5s                       PoolConfig.AcquireTimeout
+//	max_conn_pool_size: 20                    PoolConfig.MaxConnPoolSize
+//	min_conn_pool_size: 2                     PoolConfig.MinConnPoolSize
+//	tls:                                      PoolConfig.SslConfig, see GetDefaultSSLConfig
+//	  ca_file: /etc/nebula/ca.pem
+//	  cert_file: /etc/nebula/client.pem       optional with key_file
+//	  key_file: /etc/nebula/client.key
+//
+// The fields of unset keys keep the values of GetDefaultConf. An unknown key is an error,
+// and the error of invalid values lists every one of them.
+func LoadPoolConfig(path string) (*FileConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the config file %s, error: %s", path, err.Error())
+	}
+	var form configFileForm
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&form)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&form)
+	default:
+		return nil, fmt.Errorf("Failed to read the config file %s: unknown extension %q, expect .yaml, .yml or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the config file %s, error: %s", path, err.Error())
+	}
+	fileConf, err := form.toConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the config file %s: %s", path, err.Error())
+	}
+	return fileConf, nil
+}
+
+// Convert the parsed file to the config, the error lists every missing or invalid entry
+func (form configFileForm) toConfig() (*FileConfig, error) {
+	fileConf := &FileConfig{Username: form.Username, Password: form.Password, PoolConfig: GetDefaultConf()}
+	conf := &fileConf.PoolConfig
+	var invalid []string
+
+	if len(form.Hosts) == 0 {
+		invalid = append(invalid, "hosts: required")
+	}
+	for _, host := range form.Hosts {
+		address, err := ParseHostAddress(host)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("hosts: %s", err.Error()))
+			continue
+		}
+		fileConf.Hosts = append(fileConf.Hosts, address)
+	}
+	if form.Username == "" {
+		invalid = append(invalid, "username: required")
+	}
+	conf.SpaceName = form.Space
+	switch strings.ToLower(form.VIDType) {
+	case "", "string", "fixed_string":
+		conf.VIDType = VIDTypeString
+	case "int64", "int":
+		conf.VIDType = VIDTypeInt64
+	default:
+		invalid = append(invalid, fmt.Sprintf("vid_type: %q is not string or int64", form.VIDType))
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"timeout", form.TimeOut, &conf.TimeOut},
+		{"conn_timeout", form.ConnTimeOut, &conf.ConnTimeOut},
+		{"exec_timeout", form.ExecTimeOut, &conf.ExecTimeOut},
+		{"idle_time", form.IdleTime, &conf.IdleTime},
+		{"max_conn_lifetime", form.MaxConnLifetime, &conf.MaxConnLifetime},
+		{"acquire_timeout", form.AcquireTimeout, &conf.AcquireTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration < 0 {
+			invalid = append(invalid, fmt.Sprintf("%s: %q is not a non-negative duration", d.name, d.value))
+			continue
+		}
+		*d.field = duration
+	}
+	sizes := []struct {
+		name  string
+		value *int
+		field *int
+	}{
+		{"max_conn_pool_size", form.MaxConnPoolSize, &conf.MaxConnPoolSize},
+		{"min_conn_pool_size", form.MinConnPoolSize, &conf.MinConnPoolSize},
+	}
+	for _, s := range sizes {
+		if s.value == nil {
+			continue
+		}
+		if *s.value < 0 {
+			invalid = append(invalid, fmt.Sprintf("%s: %d is not a non-negative integer", s.name, *s.value))
+			continue
+		}
+		*s.field = *s.value
+	}
+	if conf.MinConnPoolSize > conf.MaxConnPoolSize {
+		invalid = append(invalid, fmt.Sprintf("min_conn_pool_size: %d is larger than max_conn_pool_size %d",
+			conf.MinConnPoolSize, conf.MaxConnPoolSize))
+	}
+
+	if form.TLS != nil {
+		tls := form.TLS
+		if tls.CAFile == "" {
+			invalid = append(invalid, "tls.ca_file: required")
+		} else if (tls.CertFile == "") != (tls.KeyFile == "") {
+			invalid = append(invalid, "tls: cert_file and key_file must be set together")
+		} else if sslConfig, err := GetDefaultSSLConfig(tls.CAFile, tls.CertFile, tls.KeyFile); err != nil {
+			invalid = append(invalid, fmt.Sprintf("tls: %s", err.Error()))
+		} else {
+			conf.SslConfig = sslConfig
+		}
+	}
+
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid entries %s", strings.Join(invalid, "; "))
+	}
+	return fileConf, nil
+}
@@ -0,0 +1,100 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPoolConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nebula-conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCert(t, dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	yamlPath := write("nebula.yaml", fmt.Sprintf(`
+hosts: ["127.0.0.1:9669", "[::1]:9670"]
+username: root
+password: nebula
+space: nba
+vid_type: int64
+timeout: 3s
+exec_timeout: 500ms
+max_conn_pool_size: 20
+tls:
+  ca_file: %s
+  cert_file: %s
+  key_file: %s
+`, certPath, certPath, keyPath))
+	jsonPath := write("nebula.json", `{"hosts": ["127.0.0.1:9669", "[::1]:9670"], "username": "root", "password": "nebula",
+		"space": "nba", "vid_type": "int64", "timeout": "3s", "exec_timeout": "500ms", "max_conn_pool_size": 20}`)
+	for _, path := range []string{yamlPath, jsonPath} {
+		conf, err := LoadPoolConfig(path)
+		if !assert.NoError(t, err, path) {
+			continue
+		}
+		assert.Equal(t, []HostAddress{{Host: "127.0.0.1", Port: 9669}, {Host: "::1", Port: 9670}}, conf.Hosts)
+		assert.Equal(t, "root", conf.Username)
+		assert.Equal(t, "nebula", conf.Password)
+		assert.Equal(t, "nba", conf.PoolConfig.SpaceName)
+		assert.Equal(t, VIDTypeInt64, conf.PoolConfig.VIDType)
+		assert.Equal(t, 3*time.Second, conf.PoolConfig.TimeOut)
+		assert.Equal(t, 500*time.Millisecond, conf.PoolConfig.ExecTimeOut)
+		assert.Equal(t, 20, conf.PoolConfig.MaxConnPoolSize)
+		// The unset ones keep the defaults
+		assert.Equal(t, GetDefaultConf().MinConnPoolSize, conf.PoolConfig.MinConnPoolSize)
+		assert.Equal(t, GetDefaultConf().IdleTime, conf.PoolConfig.IdleTime)
+	}
+	conf, err := LoadPoolConfig(yamlPath)
+	if assert.NoError(t, err) && assert.NotNil(t, conf.PoolConfig.SslConfig) {
+		assert.Len(t, conf.PoolConfig.SslConfig.Certificates, 1)
+	}
+
+	_, err = LoadPoolConfig(write("bad.yml", `
+hosts: ["localhost"]
+timeout: "3"
+min_conn_pool_size: -1
+vid_type: uuid
+tls:
+  ca_file: /nonexistent/ca.pem
+`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "hosts: Failed to parse address")
+		assert.Contains(t, err.Error(), "username: required")
+		assert.Contains(t, err.Error(), `timeout: "3" is not a non-negative duration`)
+		assert.Contains(t, err.Error(), "min_conn_pool_size: -1 is not a non-negative integer")
+		assert.Contains(t, err.Error(), `vid_type: "uuid" is not string or int64`)
+		assert.Contains(t, err.Error(), "tls: Failed to read CA certificate /nonexistent/ca.pem")
+	}
+	_, err = LoadPoolConfig(write("unknown.json", `{"hosts": ["127.0.0.1:9669"], "username": "root", "passwd": "x"}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unknown field "passwd"`)
+	}
+	_, err = LoadPoolConfig(write("sizes.yaml", "hosts: [\"127.0.0.1:9669\"]\nusername: root\nmax_conn_pool_size: many\n"))
+	assert.Error(t, err)
+	_, err = LoadPoolConfig(write("nebula.toml", ""))
+	assert.EqualError(t, err, fmt.Sprintf(`Failed to read the config file %s: unknown extension ".toml", expect .yaml, .yml or .json`,
+		filepath.Join(dir, "nebula.toml")))
+	_, err = LoadPoolConfig(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -7,32 +7,329 @@
 package nebula
 
 import (
+	"crypto/tls"
+	"net"
 	"time"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
 )
 
+// The default size of the buffer of the buffered transport
+const defaultBufferSize = 128 << 10
+
+// The default number of connections opened at the same time while a pool is initialized
+const defaultInitParallelism = 8
+
+// The socket timeout of the pings of the pool when no timeout is set, so a host which never answers could not hang them
+const defaultPingTimeout = 3 * time.Second
+
 type PoolConfig struct {
 	// Socket timeout and Socket connection timeout, unit: seconds
+	// It is used as ConnTimeOut and ExecTimeOut when they are not set
 	TimeOut time.Duration
+	// The timeout to establish a connection, 0 value means TimeOut is used
+	ConnTimeOut time.Duration
+	// The socket timeout of every RPC, e.g. executing a query, 0 value means TimeOut is used
+	ExecTimeOut time.Duration
 	// The idleTime of the connection, unit: seconds
 	// If connection's idle time is longer than idleTime, it will be delete
 	// 0 value means the connection will not expire
 	IdleTime time.Duration
-	// The max connections in pool for all addresses
+	// The max time a connection could be reused since it is opened, it is closed after that
+	// instead of being handed out, so connections dropped by firewalls are not reused
+	// 0 value means the connection will not expire
+	MaxConnLifetime time.Duration
+	// The max connections in pool for all addresses, see ConnectionPool.Resize to change it at runtime
 	MaxConnPoolSize int
 	// The min connections in pool for all addresses
 	MinConnPoolSize int
+	// The max connections opened at the same time while the pool is initialized with MinConnPoolSize connections
+	// 0 value means the default of 8 is used
+	InitParallelism int
+	// The max time GetConnection and GetSession wait for a connection to be released when the pool is full,
+	// ErrPoolExhausted is returned after that. It is separate from ConnTimeOut and ExecTimeOut.
+	// 0 value means they wait until a connection is released, negative value means they fail at once with ErrPoolFull
+	AcquireTimeout time.Duration
+	// The max queries running at the same time through all sessions of the pool, 0 value means no limit
+	// A query waits for a running one to finish once the limit is reached, it gives up when its context is done
+	MaxConcurrentQueries int
+	// The max queries started per second through all sessions of the pool, 0 value means no limit
+	// Unlike MaxConcurrentQueries it caps the throughput rather than the parallelism: a query waits for its turn
+	// once the rate is exceeded, it gives up when its context is done. See ConnectionPool.RateLimiterStats.
+	QueriesPerSecond float64
+	// The max queries started at once after the pool has been idle for a while, 0 value means 1
+	// It is only used if QueriesPerSecond is set.
+	QueryBurst int
+	// The max results of Session.ExecuteCached kept by the pool, the least recently used one is evicted first
+	// 0 value means the cache is disabled and ExecuteCached always executes the statement
+	ResultCacheSize int
+	// How long a result is cached, 0 value means it is kept until it is evicted
+	ResultCacheTTL time.Duration
+	// Drop the cached results of the space once a session of the pool executes a statement which is not read-only
+	// in it, see IsReadOnlyStatement. The writes of other clients are never seen by the cache.
+	ResultCacheInvalidateOnWrite bool
+	// How long a space a session of the pool has been switched to is known to be valid for the user,
+	// so SessionPoolConfig.ValidateSpace does not sign in a session to validate it again. The space is
+	// forgotten once switching to it fails with ErrSpaceNotFound. 0 value means the spaces are always validated.
+	ValidSpaceCacheTTL time.Duration
+	// The size of the buffer of the buffered transport, unit: byte
+	// 0 value means the default size of 128KB is used
+	BufferSize int
+	// Compress the payloads on the wire with zlib
+	// Only enable it if graphd is built to accept zlib transport, otherwise no RPC could succeed
+	UseCompression bool
+	// The thrift protocol of the RPCs, ProtocolBinary by default
+	// A connection is verified by a round trip on open if another protocol is chosen
+	Protocol Protocol
+	// The thrift transport of the RPCs, TransportBuffered by default
+	// A connection is verified by a round trip on open if another transport is chosen
+	Transport TransportType
+	// The headers sent with every message of every connection, e.g. routing metadata for a header-aware proxy
+	// Only used by TransportHeader
+	TransportHeaders map[string]string
+	// The max size in bytes of a frame of the framed transport, a larger response frame fails with ErrResponseTooLarge
+	// 0 value means the default of 2GB, far beyond the 16MB thrift allows by default
+	MaxFrameSize int
+	// The max size in bytes of a response, a larger one is aborted with ErrResponseTooLarge
+	// The size is counted after decompression, 0 value means unlimited
+	MaxResponseBytes int64
+	// Add the statement to the errors returned by executing it, e.g. a timeout, so they tell which statement failed
+	// It is off by default since statements may carry sensitive data, errors.Is and errors.As still reach the cause
+	IncludeStatementInError bool
+	// The max bytes of the statement added to an error by IncludeStatementInError, a longer one is truncated
+	// 0 value means the default of 256 bytes
+	StatementInErrorMaxLen int
+	// The space every session is switched to once it is created, empty value means the default space
+	// GetSession fails if the space could not be used
+	SpaceName string
+	// The VID type of SpaceName, used to decode vertex IDs in results, VIDTypeString by default
+	VIDType VIDType
+	// The max times to reopen a broken transport to the same host and retry the statement
+	// 0 value means the statement will not be retried on the same host
+	// Only the statements classified as idempotent are retried, see IdempotencyClassifier
+	MaxRetries int
+	// Tell if a statement is safe to execute again after the transport broke while it was running,
+	// nil value means IsReadOnlyStatement is used. Session.ExecuteIdempotent skips it.
+	IdempotencyClassifier func(stmt string) bool
+	// The policy to retry a query failing with a retriable error code, the zero value disables it
+	RetryPolicy RetryPolicy
+	// Retry the statement once on another host if graphd reports the leader has changed,
+	// the host is not given new connections for a few seconds then
+	RetryOnLeaderChange bool
+	// Sign in again with the same user and retry the statement once if graphd reports the session is invalid
+	// or timed out, e.g. it expired on the server side. The new session is switched to the space of the expired one.
+	// Even if it is not set, a session signs in again when graphd does not know it after its transport is reopened
+	// or moved to another connection, e.g. graphd restarted, since the sessions only live in the memory of graphd.
+	AutoReconnectSession bool
+	// Called by ConnectionPool.GetSessionFromProvider to get the credentials to sign in
+	CredentialProvider CredentialProvider
+	// The max times to call CredentialProvider again if graphd rejects the credentials it returns
+	MaxAuthRetries int
+	// Sign in with ValidateUsername and ValidatePassword on every host when the pool is created,
+	// and switch to SpaceName if it is set. The hosts failing it are marked as unhealthy,
+	// NewConnectionPool fails only if no host passes it.
+	ValidateOnCreate bool
+	ValidateUsername string
+	ValidatePassword string
+	// The hosts used only when every host given to NewConnectionPool is unhealthy, e.g. in another datacenter
+	// The pool fails back once a primary host recovers, idle connections to the fallback hosts are closed then
+	FallbackAddresses []HostAddress
+	// Keep the hosts listed more than once, e.g. to give a host more connections, their weights are added up then.
+	// By default the addresses of the same host and port as an earlier one are removed with a warning,
+	// after resolving the hosts, so two host names resolving to the same IP count as duplicates too.
+	AllowDuplicateHosts bool
+	// Use the hosts as they are given instead of resolving them to IPs, the dialer resolves them then
+	DisableResolution bool
+	// Expand a host resolving to multiple IPs into one address per IP, only the first IP is used otherwise
+	ResolveAllIPs bool
+	// The interval to resolve the hosts again and follow the DNS changes, 0 value means the hosts are resolved once
+	// Idle connections to the addresses which disappeared are closed
+	ResolveInterval time.Duration
+	// The number of rows fetched at a time by Session.ExecuteIter, 0 value means the default value of 1000
+	IterPageSize int
+	// The number of parameter sets Session.ExecuteParameterizedBatch sends in one request, 0 value means
+	// the default value of 100. 1 value sends every set alone, so a failure is reported for that set only.
+	ParameterizedBatchSize int
+	// The interval to ping idle connections and probe unhealthy hosts in background
+	// Connections failing the ping are closed and their hosts are skipped until they recover
+	// 0 value means the health check is disabled
+	HealthCheckInterval time.Duration
+	// The number of consecutive failures to open a connection to a host after which its circuit opens:
+	// the host is skipped by the pool for CircuitBreakerCooldown, then a single connection is tried again
+	// to test if it recovered. 0 value means the circuit breaker is disabled. See ConnectionPool.HostStats.
+	CircuitBreakerThreshold int
+	// How long an open circuit skips its host, 0 value means the default value of 30 seconds
+	CircuitBreakerCooldown time.Duration
+	// Ping an idle connection before handing it out, a connection failing the ping is closed and the next idle
+	// one or a new one is handed out instead. It costs a round trip on every checkout, and finds the connections
+	// which died silently, e.g. closed by a firewall, before a query fails on them.
+	// If it is false, only the connections already known as broken are skipped.
+	TestOnBorrow bool
+	// Kill the query on graphd with KILL QUERY when the context of ExecuteWithContext is done before the query returns,
+	// so graphd stops working on it instead of only the client giving up. It is best-effort: the plan ID of the query
+	// is looked up with SHOW QUERIES on another connection of the pool, which needs a graphd supporting both statements,
+	// and nothing is killed if the query is not found, e.g. it has finished already or no connection could be taken.
+	KillQueryOnCancel bool
+	// The size of the buffer of the channel returned by ConnectionPool.Events, the events are dropped when it is full
+	// 0 value means no event is sent
+	EventBufferSize int
+	// The max time Close waits for the running queries to finish before closing the transports, the sessions
+	// are signed out once their queries finish. 0 value means the running queries are not waited for,
+	// Close only signs out the sessions running no query before closing the transports
+	CloseTimeOut time.Duration
+	// A warning with the stack of the goroutine which got the session is logged if a session is not released
+	// within it, to find the sessions never released. 0 value means the check is disabled.
+	// The sessions kept idle by a SessionPool are not checked.
+	SessionLeakThreshold time.Duration
+	// The strategy to choose a host for a new connection, nil value means round-robin weighted by HostAddress.Weight
+	LoadBalancer LoadBalancer
+	// The function to open network connections to graphd, nil value means TCP is used
+	Dialer Dialer
+	// Creates and opens the connections of the pool, nil value means NewDefaultConnectionFactory of the config
+	// is used. A connection reopening its own broken transport always opens it the default way.
+	ConnectionFactory ConnectionFactory
+	// The local IP the TCP connections are opened from, empty value means the OS chooses it
+	// It is ignored if Dialer is set
+	LocalAddr string
+	// The sizes of the OS receive and send buffers of the TCP connections in bytes, 0 value means the OS default
+	// Larger buffers raise the throughput of large results on links with a high round trip time
+	SocketRecvBuf int
+	SocketSendBuf int
+	// The period of the TCP keepalive probes, so dead peers are found out without a query
+	// 0 value means keepalive is disabled, it is ignored if Dialer is set
+	TCPKeepAlive time.Duration
+	// The TLS config used to connect to graphd, nil value means TLS is disabled
+	// Use GetDefaultSSLConfig to build it from certificate files
+	SslConfig *tls.Config
+	// Called for every new connection to get its TLS config, it takes precedence over SslConfig, so renewed
+	// certificates are used without recreating the pool. The connections already opened keep their
+	// certificates until they are closed, MaxConnLifetime could be set to recycle them.
+	// GetReloadingSSLConfig is enough if only the client certificate is renewed.
+	SslConfigProvider func() (*tls.Config, error)
+	// The observer of the metrics of the pool and its sessions, nil value means the metrics are ignored
+	MetricsObserver MetricsObserver
+	// Called synchronously when a connection is taken from the pool and when it is given back,
+	// including the ones taken by sessions. They run on the path of every checkout and checkin,
+	// so they must be fast and must not block.
+	OnAcquire func(info ConnectionInfo)
+	OnRelease func(info ConnectionInfo)
+	// Remove a single semicolon at the end of the statements given to Session.Execute, ExecuteJson and the
+	// like before sending them, with the whitespaces around it, e.g. for the statements pasted from a console
+	// which some versions of graphd reject. A semicolon inside a string literal, a quoted label or a comment
+	// is kept. ExecuteRaw always sends the statement as it is.
+	TrimTrailingSemicolon bool
+	// The metadata sent in a comment in front of every statement, e.g. {"app": "billing", "version": "1.4.2"},
+	// so the queries of an application could be found in the query logs of graphd without a tracing backend.
+	// It is merged with the metadata of the call set by WithStatementMetadata, e.g. a request ID, whose values
+	// win on the same key. nil value means no comment unless the call has metadata.
+	// WithoutStatementComment leaves the comment out for the statements it could break.
+	StatementComment map[string]string
+	// Render the metadata into the comment put in front of the statement, followed by a space.
+	// nil value means /* key=value key=value */ with the keys sorted. The result must be a single whole comment.
+	StatementCommentFormat func(metadata map[string]string) string
+	// The statements executed in order on every session right after it signs in and switches to SpaceName.
+	// They are executed again when the session signs in again, see AutoReconnectSession, and by Session.Reset.
+	// Getting a session fails if any of them fails.
+	OnConnect []string
+	// How ValueWrapper.AsString, ResultSet.Scan, ResultSet.AsMaps and the JSON encoding of the results handle
+	// the string values which are not valid UTF-8, the zero value UTF8Lenient returns the bytes as they are
+	UTF8Policy UTF8Policy
+	// The logger of the pool and its sessions, it takes precedence over the one passed to NewConnectionPool
+	// If both are nil, nothing is logged
+	Logger Logger
+}
+
+// PoolOption overrides fields of a PoolConfig, see PoolConfig.With
+type PoolOption func(conf *PoolConfig)
+
+// WithSpace sets SpaceName and VIDType
+func WithSpace(space string, vidType VIDType) PoolOption {
+	return func(conf *PoolConfig) {
+		conf.SpaceName = space
+		conf.VIDType = vidType
+	}
+}
+
+// WithTimeOut sets TimeOut, ConnTimeOut and ExecTimeOut are left as is
+func WithTimeOut(timeout time.Duration) PoolOption {
+	return func(conf *PoolConfig) {
+		conf.TimeOut = timeout
+	}
+}
+
+// WithExecTimeOut sets ExecTimeOut
+func WithExecTimeOut(timeout time.Duration) PoolOption {
+	return func(conf *PoolConfig) {
+		conf.ExecTimeOut = timeout
+	}
+}
+
+// WithPoolSize sets MinConnPoolSize and MaxConnPoolSize
+func WithPoolSize(min, max int) PoolOption {
+	return func(conf *PoolConfig) {
+		conf.MinConnPoolSize = min
+		conf.MaxConnPoolSize = max
+	}
+}
+
+// With returns a copy of the config with the options applied, conf is not changed.
+// The slices and the TLS config are copied, and a (Weighted)RoundRobinLoadBalancer is replaced by a new one,
+// so the pools of the two configs share no state. Other interface values, e.g. the logger or a custom
+// LoadBalancer, are shared.
+func (conf PoolConfig) With(opts ...PoolOption) PoolConfig {
+	if conf.FallbackAddresses != nil {
+		conf.FallbackAddresses = append([]HostAddress(nil), conf.FallbackAddresses...)
+	}
+	if conf.RetryPolicy.RetriableCodes != nil {
+		conf.RetryPolicy.RetriableCodes = append([]graph.ErrorCode(nil), conf.RetryPolicy.RetriableCodes...)
+	}
+	if conf.TransportHeaders != nil {
+		headers := make(map[string]string, len(conf.TransportHeaders))
+		for key, value := range conf.TransportHeaders {
+			headers[key] = value
+		}
+		conf.TransportHeaders = headers
+	}
+	if conf.OnConnect != nil {
+		conf.OnConnect = append([]string(nil), conf.OnConnect...)
+	}
+	if conf.SslConfig != nil {
+		conf.SslConfig = conf.SslConfig.Clone()
+	}
+	if _, ok := conf.LoadBalancer.(*RoundRobinLoadBalancer); ok {
+		conf.LoadBalancer = NewRoundRobinLoadBalancer()
+	}
+	if _, ok := conf.LoadBalancer.(*WeightedRoundRobinLoadBalancer); ok {
+		conf.LoadBalancer = NewWeightedRoundRobinLoadBalancer()
+	}
+	for _, opt := range opts {
+		opt(&conf)
+	}
+	return conf
 }
 
 // Validate config
-func (conf PoolConfig) validateConf(log Logger) {
+func (conf *PoolConfig) validateConf(log Logger) {
 	if conf.TimeOut < 0 {
 		conf.TimeOut = 0 * time.Millisecond
 		log.Warn("Illegal Timeout value, the default value of 0 second has been applied")
 	}
+	if conf.ConnTimeOut < 0 {
+		conf.ConnTimeOut = 0 * time.Millisecond
+		log.Warn("Illegal ConnTimeOut value, the value of TimeOut has been applied")
+	}
+	if conf.ExecTimeOut < 0 {
+		conf.ExecTimeOut = 0 * time.Millisecond
+		log.Warn("Illegal ExecTimeOut value, the value of TimeOut has been applied")
+	}
 	if conf.IdleTime < 0 {
 		conf.IdleTime = 0 * time.Millisecond
 		log.Warn("Invalid IdleTime value, the default value of 0 second has been applied")
 	}
+	if conf.MaxConnLifetime < 0 {
+		conf.MaxConnLifetime = 0 * time.Millisecond
+		log.Warn("Invalid MaxConnLifetime value, the default value of 0 second has been applied")
+	}
 	if conf.MaxConnPoolSize < 1 {
 		conf.MaxConnPoolSize = 10
 		log.Warn("Invalid MaxConnPoolSize value, the default value of 10 has been applied")
@@ -41,6 +338,199 @@ func (conf PoolConfig) validateConf(log Logger) {
 		conf.MinConnPoolSize = 0
 		log.Warn("Invalid MinConnPoolSize value, the default value of 0 has been applied")
 	}
+	if conf.MaxAuthRetries < 0 {
+		conf.MaxAuthRetries = 0
+		log.Warn("Invalid MaxAuthRetries value, the default value of 0 has been applied")
+	}
+	if conf.LocalAddr != "" && net.ParseIP(conf.LocalAddr) == nil {
+		conf.LocalAddr = ""
+		log.Warn("Invalid LocalAddr value, the OS chooses the local address")
+	}
+	if conf.SocketRecvBuf < 0 {
+		conf.SocketRecvBuf = 0
+		log.Warn("Invalid SocketRecvBuf value, the OS default has been applied")
+	}
+	if conf.SocketSendBuf < 0 {
+		conf.SocketSendBuf = 0
+		log.Warn("Invalid SocketSendBuf value, the OS default has been applied")
+	}
+	if conf.TCPKeepAlive < 0 {
+		conf.TCPKeepAlive = 0
+		log.Warn("Invalid TCPKeepAlive value, keepalive has been disabled")
+	}
+	if conf.MaxConcurrentQueries < 0 {
+		conf.MaxConcurrentQueries = 0
+		log.Warn("Invalid MaxConcurrentQueries value, the number of concurrent queries has been unlimited")
+	}
+	if conf.QueriesPerSecond < 0 {
+		conf.QueriesPerSecond = 0
+		log.Warn("Invalid QueriesPerSecond value, the rate of queries has been unlimited")
+	}
+	if conf.QueryBurst < 0 {
+		conf.QueryBurst = 0
+		log.Warn("Invalid QueryBurst value, the default value of 1 has been applied")
+	}
+	if conf.ResultCacheSize < 0 {
+		conf.ResultCacheSize = 0
+		log.Warn("Invalid ResultCacheSize value, the result cache has been disabled")
+	}
+	if conf.ResultCacheTTL < 0 {
+		conf.ResultCacheTTL = 0
+		log.Warn("Invalid ResultCacheTTL value, the cached results will not expire")
+	}
+	if conf.ValidSpaceCacheTTL < 0 {
+		conf.ValidSpaceCacheTTL = 0
+		log.Warn("Invalid ValidSpaceCacheTTL value, the spaces will always be validated")
+	}
+	if conf.ParameterizedBatchSize < 0 {
+		conf.ParameterizedBatchSize = 0
+		log.Warn("Invalid ParameterizedBatchSize value, the default value of 100 has been applied")
+	}
+	if conf.IterPageSize < 0 {
+		conf.IterPageSize = defaultIterPageSize
+		log.Warn("Invalid IterPageSize value, the default value of 1000 has been applied")
+	}
+	if conf.ResolveInterval < 0 {
+		conf.ResolveInterval = 0
+		log.Warn("Invalid ResolveInterval value, the default value of 0 second has been applied")
+	}
+	if conf.HealthCheckInterval < 0 {
+		conf.HealthCheckInterval = 0
+		log.Warn("Invalid HealthCheckInterval value, the default value of 0 second has been applied")
+	}
+	if conf.CircuitBreakerThreshold < 0 {
+		conf.CircuitBreakerThreshold = 0
+		log.Warn("Invalid CircuitBreakerThreshold value, the circuit breaker has been disabled")
+	}
+	if conf.CircuitBreakerCooldown < 0 {
+		conf.CircuitBreakerCooldown = 0
+		log.Warn("Invalid CircuitBreakerCooldown value, the default value of 30 seconds has been applied")
+	}
+	if conf.BufferSize < 0 {
+		conf.BufferSize = defaultBufferSize
+		log.Warn("Invalid BufferSize value, the default value of 128KB has been applied")
+	}
+	if conf.BufferSize == 0 {
+		conf.BufferSize = defaultBufferSize
+	}
+	if conf.InitParallelism < 0 {
+		conf.InitParallelism = defaultInitParallelism
+		log.Warn("Invalid InitParallelism value, the default value of 8 has been applied")
+	}
+	if conf.InitParallelism == 0 {
+		conf.InitParallelism = defaultInitParallelism
+	}
+	if conf.UTF8Policy != UTF8Lenient && conf.UTF8Policy != UTF8Strict && conf.UTF8Policy != UTF8Replace {
+		conf.UTF8Policy = UTF8Lenient
+		log.Warn("Invalid UTF8Policy value, the lenient policy has been applied")
+	}
+	if conf.Protocol != ProtocolBinary && conf.Protocol != ProtocolCompact {
+		conf.Protocol = ProtocolBinary
+		log.Warn("Invalid Protocol value, the binary protocol has been applied")
+	}
+	if conf.Transport != TransportBuffered && conf.Transport != TransportFramed && conf.Transport != TransportHeader {
+		conf.Transport = TransportBuffered
+		log.Warn("Invalid Transport value, the buffered transport has been applied")
+	}
+	if len(conf.TransportHeaders) > 0 && conf.Transport != TransportHeader {
+		log.Warn("TransportHeaders are ignored by the " + conf.Transport.String() + " transport, set Transport to TransportHeader to send them")
+	}
+	if conf.MaxFrameSize < 0 {
+		conf.MaxFrameSize = 0
+		log.Warn("Invalid MaxFrameSize value, the default size of 2GB has been applied")
+	}
+	if conf.MaxResponseBytes < 0 {
+		conf.MaxResponseBytes = 0
+		log.Warn("Invalid MaxResponseBytes value, the default value of 0 has been applied")
+	}
+	if conf.StatementInErrorMaxLen < 0 {
+		conf.StatementInErrorMaxLen = 0
+		log.Warn("Invalid StatementInErrorMaxLen value, the default value of 256 has been applied")
+	}
+	if conf.EventBufferSize < 0 {
+		conf.EventBufferSize = 0
+		log.Warn("Invalid EventBufferSize value, the default value of 0 has been applied")
+	}
+	if conf.MaxRetries < 0 {
+		conf.MaxRetries = 0
+		log.Warn("Invalid MaxRetries value, the default value of 0 has been applied")
+	}
+	conf.RetryPolicy.validate(log)
+	if conf.CloseTimeOut < 0 {
+		conf.CloseTimeOut = 0 * time.Millisecond
+		log.Warn("Invalid CloseTimeOut value, the default value of 0 second has been applied")
+	}
+	if conf.SessionLeakThreshold < 0 {
+		conf.SessionLeakThreshold = 0 * time.Millisecond
+		log.Warn("Invalid SessionLeakThreshold value, the leak check has been disabled")
+	}
+	if conf.MinConnPoolSize > conf.MaxConnPoolSize {
+		conf.MinConnPoolSize = conf.MaxConnPoolSize
+		log.Warn("MinConnPoolSize is larger than MaxConnPoolSize, MinConnPoolSize has been set to MaxConnPoolSize")
+	}
+}
+
+// Return the timeout to establish a connection
+func (conf PoolConfig) getConnTimeout() time.Duration {
+	if conf.ConnTimeOut > 0 {
+		return conf.ConnTimeOut
+	}
+	return conf.TimeOut
+}
+
+// Return the TLS config of a new connection, nil if TLS is disabled
+func (conf PoolConfig) getSslConfig() (*tls.Config, error) {
+	if conf.SslConfigProvider != nil {
+		return conf.SslConfigProvider()
+	}
+	return conf.SslConfig, nil
+}
+
+// Return the socket timeout of RPCs
+func (conf PoolConfig) getExecTimeout() time.Duration {
+	if conf.ExecTimeOut > 0 {
+		return conf.ExecTimeOut
+	}
+	return conf.TimeOut
+}
+
+// Return the socket timeout of the pings of the health check and TestOnBorrow
+func (conf PoolConfig) getPingTimeout() time.Duration {
+	if timeout := conf.getExecTimeout(); timeout > 0 {
+		return timeout
+	}
+	return defaultPingTimeout
+}
+
+// Return the max size of a frame of the framed transport
+func (conf PoolConfig) getMaxFrameSize() int {
+	if conf.MaxFrameSize > 0 {
+		return conf.MaxFrameSize
+	}
+	return defaultMaxFrameSize
+}
+
+// Return how long an open circuit skips its host
+func (conf PoolConfig) getCircuitBreakerCooldown() time.Duration {
+	if conf.CircuitBreakerCooldown > 0 {
+		return conf.CircuitBreakerCooldown
+	}
+	return defaultCircuitBreakerCooldown
+}
+
+// Return the number of parameter sets sent in one request
+func (conf PoolConfig) getParameterizedBatchSize() int {
+	if conf.ParameterizedBatchSize > 0 {
+		return conf.ParameterizedBatchSize
+	}
+	return defaultParameterizedBatchSize
+}
+
+func (conf PoolConfig) getStatementInErrorMaxLen() int {
+	if conf.StatementInErrorMaxLen > 0 {
+		return conf.StatementInErrorMaxLen
+	}
+	return defaultStatementInErrorMaxLen
 }
 
 // Return the default config
@@ -50,5 +540,12 @@ func GetDefaultConf() PoolConfig {
 		IdleTime:        0 * time.Millisecond,
 		MaxConnPoolSize: 10,
 		MinConnPoolSize: 0,
+		BufferSize:      defaultBufferSize,
+		MaxRetries:      1,
+		CloseTimeOut:    10 * time.Second,
+		TCPKeepAlive:    15 * time.Second,
+		AcquireTimeout:  10 * time.Second,
+		// The spaces are rarely dropped, and the sessions fail with ErrSpaceNotFound if one is
+		ValidSpaceCacheTTL: time.Minute,
 	}
 }
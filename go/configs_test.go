@@ -0,0 +1,55 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConf(t *testing.T) {
+	conf := PoolConfig{
+		TimeOut:         -1,
+		IdleTime:        -1,
+		MaxConnPoolSize: 0,
+		MinConnPoolSize: 20,
+	}
+	conf.validateConf(nebulaLog)
+
+	assert.Equal(t, GetDefaultConf().TimeOut, conf.TimeOut)
+	assert.Equal(t, GetDefaultConf().IdleTime, conf.IdleTime)
+	assert.Equal(t, 10, conf.MaxConnPoolSize)
+	assert.Equal(t, 10, conf.MinConnPoolSize)
+}
+
+func TestPoolConfig_With(t *testing.T) {
+	conf := GetDefaultConf()
+	conf.FallbackAddresses = []HostAddress{{Host: "10.0.0.1", Port: 9669}}
+	conf.SslConfig = &tls.Config{ServerName: "graphd"}
+	conf.LoadBalancer = NewRoundRobinLoadBalancer()
+
+	derived := conf.With(WithSpace("test", VIDTypeInt64), WithExecTimeOut(time.Minute),
+		func(c *PoolConfig) { c.MaxRetries = 3 })
+	assert.Equal(t, "test", derived.SpaceName)
+	assert.Equal(t, VIDTypeInt64, derived.VIDType)
+	assert.Equal(t, time.Minute, derived.ExecTimeOut)
+	assert.Equal(t, 3, derived.MaxRetries)
+	assert.Equal(t, conf.MaxConnPoolSize, derived.MaxConnPoolSize)
+	assert.Equal(t, conf.FallbackAddresses, derived.FallbackAddresses)
+	assert.Equal(t, "graphd", derived.SslConfig.ServerName)
+
+	// Nothing is shared with the original
+	assert.Equal(t, "", conf.SpaceName)
+	derived.FallbackAddresses[0].Port = 1
+	assert.Equal(t, 9669, conf.FallbackAddresses[0].Port)
+	derived.SslConfig.ServerName = "other"
+	assert.Equal(t, "graphd", conf.SslConfig.ServerName)
+	assert.NotSame(t, conf.LoadBalancer, derived.LoadBalancer)
+}
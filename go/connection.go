@@ -7,90 +7,756 @@
 package nebula
 
 import (
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/facebook/fbthrift/thrift/lib/go/thrift"
 	"github.com/vesoft-inc/nebula-clients/go/nebula/graph"
 )
 
+// A connection wraps one thrift client, which could not run overlapping RPCs.
+// The RPCs are serialized by mu, still a connection must be checked out of the pool exclusively,
+// since the session state of graphd is bound to the session using it.
 type connection struct {
 	severAddress HostAddress
-	graph        *graph.GraphServiceClient
+	// Held during every RPC and while the transport is replaced
+	mu sync.Mutex
+	// The config used to open the transport, kept to reopen it
+	conf PoolConfig
+	// The socket under the buffered transport, used to set timeout and interrupt a blocked RPC
+	sock  socket
+	graph *graph.GraphServiceClient
+	// The time the transport is opened and the time the connection is put back to the pool
+	createdAt time.Time
+	lastUsed  time.Time
+	// Set once an RPC fails with a fatal error, the pool closes the connection instead of reusing it
+	broken bool
+	// Set by the pool to close the connection once it is released instead of interrupting the query on it,
+	// e.g. it outlived MaxConnLifetime while in use. It is guarded by the lock of the pool.
+	retired bool
+	// Set by the pool when the connection is taken from the idle queue and has to pass the ping of TestOnBorrow
+	// before it is handed out. It is set with the lock of the pool held, and cleared by the caller taking it.
+	untested bool
+	stats    *connStats
+	// Set if MaxResponseBytes is, tells if the last response was too large
+	limiter *limitedTransport
+}
+
+// ConnectionInfo describes a connection to PoolConfig.OnAcquire and PoolConfig.OnRelease
+type ConnectionInfo struct {
+	// The host the connection is open to
+	Host HostAddress
+	// The time the transport is opened
+	CreatedAt time.Time
+}
+
+func (cn *connection) info() ConnectionInfo {
+	return ConnectionInfo{Host: cn.severAddress, CreatedAt: cn.createdAt}
+}
+
+// Both thrift.Socket and thrift.SSLSocket implement it
+type socket interface {
+	thrift.Transport
+	SetTimeout(timeout time.Duration) error
+	// Could be called from another goroutine to unblock a read or write
+	Interrupt() error
 }
 
 func newConnection(severAddress HostAddress) *connection {
 	return &connection{
 		severAddress: severAddress,
 		graph:        nil,
+		stats:        &connStats{},
+	}
+}
+
+// Dialer opens a network connection to graphd at address, which is in the form of host:port.
+// It could be used to connect through a proxy or a tunnel, ctx is done once the connect timeout is reached.
+type Dialer func(ctx context.Context, address string) (net.Conn, error)
+
+// Build the net.Dialer used when no Dialer is given, LocalAddr is ignored if it is not a valid IP
+func newNetDialer(conf PoolConfig) *net.Dialer {
+	// A negative value disables keepalive, 0 value means the default period of net.Dialer
+	dialer := &net.Dialer{KeepAlive: -1}
+	if conf.TCPKeepAlive > 0 {
+		dialer.KeepAlive = conf.TCPKeepAlive
+	}
+	if ip := net.ParseIP(conf.LocalAddr); ip != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return dialer
+}
+
+// Open a transport to the given host with conf.Dialer, or TCP if it is nil.
+// If conf.SslConfig is not nil, the transport is wrapped in TLS and the handshake is done before returning.
+func (cn *connection) open(hostAddress HostAddress, conf PoolConfig) error {
+	// IPv6 literals need to be put in brackets
+	newAdd := net.JoinHostPort(hostAddress.Host, strconv.Itoa(hostAddress.Port))
+	ctx := context.Background()
+	if timeout := conf.getConnTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var conn net.Conn
+	var err error
+	if conf.Dialer != nil {
+		conn, err = conf.Dialer(ctx, newAdd)
+	} else {
+		conn, err = newNetDialer(conf).DialContext(ctx, "tcp", newAdd)
+	}
+	if err != nil {
+		return wrapOpenError("Failed to open transport", err)
+	}
+	if err := setSocketBuffers(conn, conf); err != nil {
+		conn.Close()
+		return wrapOpenError("Failed to set the socket buffers", err)
+	}
+	return cn.openConn(conn, hostAddress.Host, conf)
+}
+
+// Set the OS buffers of a TCP connection, connections of other types are left as they are
+func setSocketBuffers(conn net.Conn, conf PoolConfig) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if conf.SocketRecvBuf > 0 {
+		if err := tcpConn.SetReadBuffer(conf.SocketRecvBuf); err != nil {
+			return err
+		}
 	}
+	if conf.SocketSendBuf > 0 {
+		if err := tcpConn.SetWriteBuffer(conf.SocketSendBuf); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (cn *connection) open(hostAddress HostAddress, timeout time.Duration) error {
-	ip := hostAddress.Host
-	port := hostAddress.Port
-	newAdd := fmt.Sprintf("%s:%d", ip, port)
-	timeoutOption := thrift.SocketTimeout(timeout)
-	addressOption := thrift.SocketAddr(newAdd)
-	sock, err := thrift.NewSocket(timeoutOption, addressOption)
+// Open a transport over an established network connection, serverName is used to verify the TLS certificate.
+// The connection is closed if the transport could not be opened.
+func (cn *connection) openConn(conn net.Conn, serverName string, conf PoolConfig) error {
+	cn.conf = conf
+	conn = &countingConn{Conn: conn, stats: cn.stats}
+	timeout := conf.getConnTimeout()
+	sslConfig, err := conf.getSslConfig()
 	if err != nil {
-		return fmt.Errorf("Failed to create a net.Conn-backed Transport,: %s", err.Error())
+		conn.Close()
+		return fmt.Errorf("Failed to get the TLS config, error: %s", err.Error())
 	}
 
-	transport := thrift.NewBufferedTransport(sock, 128<<10)
-	pf := thrift.NewBinaryProtocolFactoryDefault()
-	cn.graph = graph.NewGraphServiceClientFactory(transport, pf)
+	var sock socket
+	if sslConfig != nil {
+		if sslConfig.ServerName == "" {
+			sslConfig = sslConfig.Clone()
+			sslConfig.ServerName = serverName
+		}
+		tlsConn := tls.Client(conn, sslConfig)
+		if timeout > 0 {
+			tlsConn.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return wrapOpenError("Failed to open TLS transport, the TLS handshake may have failed", err)
+		}
+		tlsConn.SetDeadline(time.Time{})
+		sock = thrift.NewSSLSocketFromConnTimeout(tlsConn, sslConfig, timeout)
+	} else {
+		plainSock, err := thrift.NewSocket(thrift.SocketConn(conn), thrift.SocketTimeout(timeout))
+		if err != nil {
+			conn.Close()
+			return wrapOpenError("Failed to create a net.Conn-backed Transport", err)
+		}
+		sock = plainSock
+	}
 
-	if err = cn.graph.Transport.Open(); err != nil {
-		return fmt.Errorf("Failed to open transport, error: %s", err.Error())
+	cn.sock = sock
+	bufferSize := conf.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	transport := conf.Transport.wrap(sock, bufferSize, conf.getMaxFrameSize())
+	// The header transport compresses the payloads by itself
+	if conf.UseCompression && conf.Transport != TransportHeader {
+		zlibTransport, err := thrift.NewZlibTransport(transport, zlib.BestSpeed)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("Failed to create a zlib transport, error: %s", err.Error())
+		}
+		transport = zlibTransport
+	}
+	cn.limiter = nil
+	if conf.MaxResponseBytes > 0 {
+		cn.limiter = newLimitedTransport(transport, conf.MaxResponseBytes)
+		transport = cn.limiter
 	}
+	protocolFactory := conf.Protocol.factory()
+	if conf.Transport == TransportHeader {
+		if transport, err = newHeaderTransport(transport, conf.Protocol, conf.TransportHeaders, conf.UseCompression); err != nil {
+			conn.Close()
+			return fmt.Errorf("Failed to create a header transport, error: %s", err.Error())
+		}
+		protocolFactory = thrift.NewHeaderProtocolFactory()
+	}
+	cn.graph = graph.NewGraphServiceClientFactory(transport, protocolFactory)
+
+	// The socket is created over an open connection, so the transport needs not to be opened
 	if cn.graph.Transport.IsOpen() == false {
-		return fmt.Errorf("Transport is off")
+		return fmt.Errorf("Transport is off: %w", ErrTransportClosed)
+	}
+	// graphd drops the connection if it could not decode the request, find it out before the connection is used
+	if conf.Protocol != ProtocolBinary || conf.Transport != TransportBuffered {
+		if _, err := cn.graph.Execute(pingSessionID, []byte("YIELD 1")); err != nil {
+			cn.graph.Close()
+			return wrapOpenError(fmt.Sprintf("Failed to open transport, the server may not speak the %s protocol over the %s transport",
+				conf.Protocol, conf.Transport), err)
+		}
 	}
+	// The connect timeout is only for establishing the transport
+	cn.sock.SetTimeout(conf.getExecTimeout())
+	cn.broken = false
+	cn.createdAt = time.Now()
+	cn.lastUsed = cn.createdAt
 	return nil
 }
 
-// Authenticate
+// Check if the connection has been idle longer than IdleTime or open longer than MaxConnLifetime
+func (cn *connection) isExpired(now time.Time) bool {
+	if cn.conf.IdleTime > 0 && now.Sub(cn.lastUsed) > cn.conf.IdleTime {
+		return true
+	}
+	return cn.isOutlived(now)
+}
+
+// Check if the connection has been open longer than MaxConnLifetime
+func (cn *connection) isOutlived(now time.Time) bool {
+	return cn.conf.MaxConnLifetime > 0 && now.Sub(cn.createdAt) > cn.conf.MaxConnLifetime
+}
+
+// Close the current transport and open a new one to the same host with the same options
+func (cn *connection) reopen() error {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.graph.Close()
+	return cn.open(cn.severAddress, cn.conf)
+}
+
+// Authenticate, the returned error matches ErrAuthFailed if graphd rejects the user,
+// ErrSessionLimitReached if graphd has too many sessions, or ErrVersionMismatch if the client is not compatible
 func (cn *connection) authenticate(username, password string) (*graph.AuthResponse, error) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
 	resp, err := cn.graph.Authenticate([]byte(username), []byte(password))
 	if err != nil {
-		err = fmt.Errorf("Authentication fails, %s", err.Error())
-		if e := cn.graph.Close(); e != nil {
-			err = fmt.Errorf("Fail to close transport, error: %s", e.Error())
+		if cn.conf.UseCompression && isCompressionMismatch(err) {
+			err = cn.wrapRPCError("Authentication fails, the server may not support compression", err, TimeoutPhaseAuth)
+		} else if isTransportClosed(err) {
+			// The first RPC on the connection, graphd drops it if it could not decode the request
+			err = cn.wrapRPCError("Authentication fails, the server closed the connection, it may expect another transport or protocol", err, TimeoutPhaseAuth)
+		} else {
+			err = cn.wrapRPCError("Authentication fails", err, TimeoutPhaseAuth)
 		}
+		cn.markBroken(err)
+		cn.graph.Close()
 		return nil, err
 	}
-	return resp, err
+	if err := versionMismatchError(resp); err != nil {
+		return resp, err
+	}
+	if resp.GetErrorCode() != graph.ErrorCode_SUCCEEDED {
+		msg := string(resp.GetErrorMsg())
+		if msg == "" {
+			msg = resp.GetErrorCode().String()
+		}
+		kind := ErrAuthFailed
+		if isSessionLimitError(resp) {
+			kind = ErrSessionLimitReached
+		}
+		return resp, &kindError{
+			kind: kind,
+			msg:  fmt.Sprintf("Authentication fails, error: %s", msg),
+		}
+	}
+	// graphd never gives out the zero session ID, executing with it would fail with E_SESSION_INVALID
+	if resp.GetSessionID() == 0 {
+		return resp, &kindError{
+			kind: ErrAuthFailed,
+			msg:  "Authentication fails, the response carries no session ID",
+		}
+	}
+	return resp, nil
+}
+
+// Authenticate as authenticate does, but give up when ctx is done, the returned error wraps ctx.Err() then.
+// The socket timeout is the exec timeout cut to the deadline of ctx, the transport is closed if it is aborted.
+func (cn *connection) authenticateWithContext(ctx context.Context, username, password string) (*graph.AuthResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("Authentication is aborted: %w", err)
+	}
+	cn.setTimeout(capTimeout(ctx, cn.conf.getExecTimeout()))
+	defer cn.resetTimeout()
+	// The context could never be cancelled, no need to watch it
+	if ctx.Done() == nil {
+		return cn.authenticate(username, password)
+	}
+
+	type result struct {
+		resp *graph.AuthResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := cn.authenticate(username, password)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case res := <-done:
+		// The socket timeout cut to the deadline may fire right before ctx is done
+		if deadline, ok := ctx.Deadline(); ok && res.err != nil && !time.Now().Before(deadline) {
+			<-ctx.Done()
+		}
+		if res.err != nil && ctx.Err() != nil {
+			return nil, fmt.Errorf("Authentication is aborted: %w", ctx.Err())
+		}
+		return res.resp, res.err
+	case <-ctx.Done():
+		// Unblock the RPC and wait for it to return before closing the transport
+		cn.sock.Interrupt()
+		<-done
+		cn.close()
+		cn.setBroken()
+		return nil, fmt.Errorf("Authentication is aborted: %w", ctx.Err())
+	}
 }
 
 func (cn *connection) execute(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
-	return cn.graph.Execute(sessionID, []byte(stmt))
+	return cn.executeWithTimeout(sessionID, stmt, cn.conf.getExecTimeout())
+}
+
+// Execute a query with another socket timeout than the exec timeout, which is restored afterwards
+func (cn *connection) executeWithTimeout(sessionID int64, stmt string, timeout time.Duration) (*graph.ExecutionResponse, error) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.sock.SetTimeout(timeout)
+	defer cn.sock.SetTimeout(cn.conf.getExecTimeout())
+	resp, err := cn.graph.Execute(sessionID, []byte(stmt))
+	if err != nil {
+		cn.markBroken(err)
+		if cn.limiter != nil && cn.limiter.exceeded {
+			// The rest of the response is still on the way
+			cn.broken = true
+			err = &kindError{
+				kind: ErrResponseTooLarge,
+				msg:  fmt.Sprintf("Failed to execute, the response exceeds MaxResponseBytes of %d bytes", cn.conf.MaxResponseBytes),
+				err:  err,
+			}
+		} else {
+			err = cn.wrapRPCError("Failed to execute", err, TimeoutPhaseExecute)
+		}
+		cn.stats.observeQuery(err)
+		return nil, err
+	}
+	cn.stats.observeQuery(CheckResponse(resp))
+	return resp, nil
+}
+
+// Execute a query which is aborted when ctx is done, with the socket timeout set by withExecTimeout if any,
+// cut to the deadline of ctx. The transport is closed if the query is aborted, the returned error wraps ctx.Err().
+func (cn *connection) executeWithContext(ctx context.Context, sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+	timeout := capTimeout(ctx, execTimeoutFromContext(ctx, cn.conf.getExecTimeout()))
+	// The context could never be cancelled, no need to watch it
+	if ctx.Done() == nil {
+		return cn.executeWithTimeout(sessionID, stmt, timeout)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to execute: %w", err)
+	}
+
+	type result struct {
+		resp *graph.ExecutionResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := cn.executeWithTimeout(sessionID, stmt, timeout)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case res := <-done:
+		// The socket timeout cut to the deadline may fire right before ctx is done
+		if deadline, ok := ctx.Deadline(); ok && res.err != nil && !time.Now().Before(deadline) {
+			<-ctx.Done()
+		}
+		if res.err != nil && ctx.Err() != nil {
+			return nil, fmt.Errorf("Execution is aborted: %w", ctx.Err())
+		}
+		return res.resp, res.err
+	case <-ctx.Done():
+		// Unblock the RPC and wait for it to return before closing the transport
+		cn.sock.Interrupt()
+		<-done
+		cn.close()
+		cn.setBroken()
+		return nil, fmt.Errorf("Execution is aborted: %w", ctx.Err())
+	}
+}
+
+// Wrap the error of an RPC as wrapRPCError does, a frame rejected by the framed transport is reported with MaxFrameSize.
+// It matches ErrResponseTooLarge then.
+func (cn *connection) wrapRPCError(msg string, err error, phase TimeoutPhase) error {
+	if cn.conf.Transport == TransportFramed && isFrameTooLarge(err) {
+		return &kindError{
+			kind: ErrResponseTooLarge,
+			msg: fmt.Sprintf("%s, the response frame exceeds MaxFrameSize of %d bytes, error: %s",
+				msg, cn.conf.getMaxFrameSize(), err.Error()),
+			err: err,
+		}
+	}
+	return wrapRPCError(msg, err, phase)
+}
+
+// Check if the error means the transport is broken and could not be used any more
+func isTransportClosed(err error) bool {
+	if errors.Is(err, ErrTransportClosed) {
+		return true
+	}
+	if err, ok := err.(thrift.TransportException); ok {
+		switch err.TypeID() {
+		case thrift.END_OF_FILE, thrift.NOT_OPEN:
+			return true
+		}
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "broken pipe") ||
+		strings.Contains(msg, "connection reset by peer") ||
+		strings.Contains(msg, "use of closed network connection")
+}
+
+// Check if the error means graphd did not answer in time
+func isTimeout(err error) bool {
+	if errors.Is(err, ErrTimeout) {
+		return true
+	}
+	if e, ok := err.(thrift.TransportException); ok && e.TypeID() == thrift.TIMED_OUT {
+		return true
+	}
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return true
+	}
+	// The dial error is turned into a plain message by thrift.Socket
+	return strings.Contains(err.Error(), "i/o timeout")
+}
+
+// Check if an RPC error leaves the transport in a state the connection could not be reused in:
+//   - the transport is closed or reset, see isTransportClosed
+//   - graphd did not answer in time, its late response would be read as the answer of the next RPC
+//   - the response could not be decoded or decompressed, the rest of it is left in the stream
+//
+// An application exception, e.g. an RPC graphd does not support, is answered in a well-formed response
+// and leaves the connection usable, so does a query failing on the server side with an error code.
+func isFatalError(err error) bool {
+	if isTransportClosed(err) || isTimeout(err) || isCompressionMismatch(err) {
+		return true
+	}
+	// A transport exception also satisfies the interface, any one left here is fatal as well
+	var protocolErr thrift.ProtocolException
+	return errors.As(err, &protocolErr)
 }
 
-// unsupported
-// func (client *GraphClient) ExecuteJson((sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
-// 	return cn.graph.ExecuteJson(sessionID, []byte(stmt))
-// }
+// Mark the connection as broken if the RPC error is fatal, must be called with mu held
+func (cn *connection) markBroken(err error) {
+	if isFatalError(err) {
+		cn.broken = true
+	}
+}
+
+// Mark the connection as broken
+func (cn *connection) setBroken() {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.broken = true
+}
+
+// Check if the transport is open and no RPC has failed with a fatal error on it
+func (cn *connection) isOpen() bool {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return !cn.broken && cn.graph != nil && cn.graph.Transport.IsOpen()
+}
+
+// Check if an RPC has failed with a fatal error since the transport is opened
+func (cn *connection) isBroken() bool {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return cn.broken
+}
+
+// Check if the error means the response could not be decompressed,
+// which happens when the server does not speak zlib
+func isCompressionMismatch(err error) bool {
+	if e, ok := err.(thrift.TransportException); ok && e.Err() != nil {
+		err = e.Err()
+	}
+	return err == zlib.ErrHeader || err == zlib.ErrChecksum || err == zlib.ErrDictionary
+}
+
+// Execute a query and return the result in JSON format
+func (cn *connection) executeJson(sessionID int64, stmt string) ([]byte, error) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	jsonResp, err := cn.graph.ExecuteJson(sessionID, []byte(stmt))
+	if e, ok := err.(thrift.ApplicationException); ok && e.TypeID() == thrift.UNKNOWN_METHOD {
+		err = fmt.Errorf("Failed to execute a query in JSON format: %w", ErrUnsupportedByServer)
+		cn.stats.observeQuery(err)
+		return nil, err
+	}
+	if err != nil {
+		cn.markBroken(err)
+		err = cn.wrapRPCError("Failed to execute a query in JSON format", err, TimeoutPhaseExecute)
+		cn.stats.observeQuery(err)
+		return nil, err
+	}
+	cn.stats.observeQuery(nil)
+	return jsonResp, nil
+}
 
-// Check connection to host address
-func (cn *connection) ping() bool {
-	_, err := cn.execute(1, "YIELD 1")
+// Execute a query and copy the result in JSON format to w. With the binary protocol the result is copied
+// from the transport as it is read, otherwise it is decoded as a whole first. The header protocol hides
+// the transport the payload is read from, so it is decoded first with the header transport as well.
+func (cn *connection) executeJsonTo(sessionID int64, stmt string, w io.Writer) error {
+	if cn.conf.Protocol != ProtocolBinary || cn.conf.Transport == TransportHeader {
+		jsonResp, err := cn.executeJson(sessionID, stmt)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(jsonResp); err != nil {
+			return fmt.Errorf("Failed to write the result in JSON format, error: %s", err.Error())
+		}
+		return nil
+	}
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	writer := &recordingWriter{w: w}
+	err := cn.streamJson(sessionID, stmt, writer)
+	if e, ok := err.(thrift.ApplicationException); ok && e.TypeID() == thrift.UNKNOWN_METHOD {
+		err = fmt.Errorf("Failed to execute a query in JSON format: %w", ErrUnsupportedByServer)
+		cn.stats.observeQuery(err)
+		return err
+	}
+	if writer.err != nil {
+		// The rest of the response is left on the transport
+		cn.broken = true
+		err = fmt.Errorf("Failed to write the result in JSON format, error: %s", writer.err.Error())
+		cn.stats.observeQuery(err)
+		return err
+	}
 	if err != nil {
-		return false
+		cn.markBroken(err)
+		err = cn.wrapRPCError("Failed to execute a query in JSON format", err, TimeoutPhaseExecute)
+		cn.stats.observeQuery(err)
+		return err
 	}
-	return true
+	cn.stats.observeQuery(nil)
+	return nil
+}
+
+// Send executeJson and read the reply as GraphServiceClient does, except that the binary result field
+// is copied to w instead of being read into memory, must be called with mu held
+func (cn *connection) streamJson(sessionID int64, stmt string, w io.Writer) error {
+	client := cn.graph
+	if client.OutputProtocol == nil {
+		client.OutputProtocol = client.ProtocolFactory.GetProtocol(client.Transport)
+	}
+	if client.InputProtocol == nil {
+		client.InputProtocol = client.ProtocolFactory.GetProtocol(client.Transport)
+	}
+	oprot, iprot := client.OutputProtocol, client.InputProtocol
+	client.SeqId++
+	if err := oprot.WriteMessageBegin("executeJson", thrift.CALL, client.SeqId); err != nil {
+		return err
+	}
+	args := graph.GraphServiceExecuteJsonArgs{SessionId: sessionID, Stmt: []byte(stmt)}
+	if err := args.Write(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteMessageEnd(); err != nil {
+		return err
+	}
+	if err := oprot.Flush(); err != nil {
+		return err
+	}
+
+	method, typeID, seqID, err := iprot.ReadMessageBegin()
+	if err != nil {
+		return err
+	}
+	if method != "executeJson" {
+		return thrift.NewApplicationException(thrift.WRONG_METHOD_NAME, "executeJson failed: wrong method name")
+	}
+	if client.SeqId != seqID {
+		return thrift.NewApplicationException(thrift.BAD_SEQUENCE_ID, "executeJson failed: out of sequence response")
+	}
+	if typeID == thrift.EXCEPTION {
+		appErr, err := thrift.NewApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "Unknown Exception").Read(iprot)
+		if err != nil {
+			return err
+		}
+		if err := iprot.ReadMessageEnd(); err != nil {
+			return err
+		}
+		return appErr
+	}
+	if typeID != thrift.REPLY {
+		return thrift.NewApplicationException(thrift.INVALID_MESSAGE_TYPE_EXCEPTION, "executeJson failed: invalid message type")
+	}
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		_, fieldType, fieldID, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if fieldType == thrift.STOP {
+			break
+		}
+		if fieldID == 0 && fieldType == thrift.STRING {
+			// A binary is its length in i32 followed by the bytes with the binary protocol
+			size, err := iprot.ReadI32()
+			if err != nil {
+				return err
+			}
+			if size < 0 {
+				return thrift.NewProtocolExceptionWithType(thrift.NEGATIVE_SIZE, nil)
+			}
+			if _, err := io.CopyN(w, iprot.Transport(), int64(size)); err != nil {
+				return err
+			}
+		} else if err := iprot.Skip(fieldType); err != nil {
+			return err
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return err
+	}
+	return iprot.ReadMessageEnd()
+}
+
+// A writer keeping the first error of the wrapped one, to tell it from the errors of reading the transport
+type recordingWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (r *recordingWriter) Write(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.w.Write(b)
+	r.err = err
+	return n, err
+}
+
+// A session ID graphd never gives out, graphd answers E_SESSION_INVALID to it without executing the statement
+const pingSessionID = 0
+
+// Check connection to host address with an RPC, graphd must answer in timeout.
+// 0 value of timeout means the exec timeout is used.
+func (cn *connection) ping(timeout time.Duration) error {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	if timeout <= 0 {
+		timeout = cn.conf.getExecTimeout()
+	}
+	cn.sock.SetTimeout(timeout)
+	defer cn.sock.SetTimeout(cn.conf.getExecTimeout())
+	// Only whether graphd answers matters, not the error code of the response
+	if _, err := cn.graph.Execute(pingSessionID, []byte("YIELD 1")); err != nil {
+		cn.markBroken(err)
+		return cn.wrapRPCError("Failed to ping", err, TimeoutPhaseExecute)
+	}
+	return nil
 }
 
 // Sign out and release seesin ID
+// Nothing is sent for the zero session ID, which is never given out by graphd.
 func (cn *connection) signOut(sessionID int64) error {
+	if sessionID == 0 {
+		return nil
+	}
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
 	// Release session ID to graphd
 	if err := cn.graph.Signout(sessionID); err != nil {
+		cn.markBroken(err)
 		return err
 	}
 	return nil
 }
 
+// Unblock the running RPC and make the following ones fail, could be called from another goroutine.
+// It does not take mu, which is held by the running RPC.
+func (cn *connection) interrupt() {
+	cn.sock.Interrupt()
+}
+
+// Call fn with the thrift client, the connection is marked as broken if fn fails with a fatal error
+func (cn *connection) withRawClient(fn func(client *graph.GraphServiceClient) error) error {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	err := fn(cn.graph)
+	if err != nil {
+		cn.markBroken(err)
+	}
+	return err
+}
+
+// Restore the socket timeout to the exec timeout, whatever the previous user of the connection set
+func (cn *connection) resetTimeout() {
+	cn.setTimeout(cn.conf.getExecTimeout())
+}
+
+func (cn *connection) setTimeout(timeout time.Duration) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.sock.SetTimeout(timeout)
+}
+
+// Return the timeout cut to the time left before the deadline of ctx, 0 value of timeout means no timeout
+func capTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+	// A timeout of 0 would mean no timeout, the RPC on a done ctx fails at once instead
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		remaining = time.Nanosecond
+	}
+	if timeout <= 0 || remaining < timeout {
+		return remaining
+	}
+	return timeout
+}
+
 // Close transport
 func (cn *connection) close() {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
 	cn.graph.Close()
 }
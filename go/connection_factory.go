@@ -0,0 +1,53 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import "time"
+
+// ConnectionFactory creates the connections of a pool, see PoolConfig.ConnectionFactory.
+// A pool creates a connection to a host with NewConnection, then opens its transport with Open,
+// e.g. a test could wrap the default factory to fail or delay the opening of some hosts.
+// Both methods must be safe for concurrent use.
+type ConnectionFactory interface {
+	// NewConnection returns a connection to the host, its transport is not opened yet
+	NewConnection(addr HostAddress) (*connection, error)
+	// Open opens the transport of the connection, giving up after the connect timeout
+	Open(cn *connection, timeout time.Duration) error
+}
+
+// The factory of the thrift transports configured by the PoolConfig
+type defaultConnectionFactory struct {
+	conf PoolConfig
+}
+
+// NewDefaultConnectionFactory returns the factory a pool uses when PoolConfig.ConnectionFactory is nil,
+// the transports are opened with the Dialer, the TLS config and the other options of conf
+func NewDefaultConnectionFactory(conf PoolConfig) ConnectionFactory {
+	return &defaultConnectionFactory{conf: conf}
+}
+
+func (factory *defaultConnectionFactory) NewConnection(addr HostAddress) (*connection, error) {
+	return newConnection(addr), nil
+}
+
+func (factory *defaultConnectionFactory) Open(cn *connection, timeout time.Duration) error {
+	conf := factory.conf
+	conf.ConnTimeOut = timeout
+	return cn.open(cn.severAddress, conf)
+}
+
+// Create a connection to the host with the factory of the pool and open it
+func (pool *ConnectionPool) openConn(host HostAddress, timeout time.Duration) (*connection, error) {
+	conn, err := pool.factory.NewConnection(host)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.factory.Open(conn, timeout); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
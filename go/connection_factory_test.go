@@ -0,0 +1,96 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+// Wraps the default factory, failing the hosts in down and recording the timeouts
+type fakeConnectionFactory struct {
+	ConnectionFactory
+	mu       sync.Mutex
+	down     map[HostAddress]bool
+	created  int
+	timeouts []time.Duration
+}
+
+func (factory *fakeConnectionFactory) NewConnection(addr HostAddress) (*connection, error) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	factory.created++
+	return factory.ConnectionFactory.NewConnection(addr)
+}
+
+func (factory *fakeConnectionFactory) Open(cn *connection, timeout time.Duration) error {
+	factory.mu.Lock()
+	factory.timeouts = append(factory.timeouts, timeout)
+	down := factory.down[cn.severAddress]
+	factory.mu.Unlock()
+	if down {
+		return errors.New("host is down")
+	}
+	return factory.ConnectionFactory.Open(cn, timeout)
+}
+
+func TestPool_ConnectionFactory(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	down := HostAddress{Host: "127.0.0.1", Port: 1}
+	conf := GetDefaultConf()
+	conf.ConnTimeOut = time.Second
+	conf.MinConnPoolSize = 1
+	factory := &fakeConnectionFactory{ConnectionFactory: NewDefaultConnectionFactory(conf), down: map[HostAddress]bool{down: true}}
+	conf.ConnectionFactory = factory
+	pool, err := NewConnectionPool([]HostAddress{down, host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	// One connection per host is checked, then the initial one is opened to the reachable host
+	assert.Equal(t, 3, factory.created)
+	assert.Equal(t, []time.Duration{time.Second, time.Second, time.Second}, factory.timeouts)
+	stats := pool.HostStats()
+	assert.False(t, stats[0].Healthy)
+	assert.True(t, stats[1].Healthy)
+
+	errs := pool.Ping(100 * time.Millisecond)
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[down].Error(), "host is down")
+	}
+	assert.Equal(t, 100*time.Millisecond, factory.timeouts[len(factory.timeouts)-1])
+
+	session, err := pool.GetSession("root", "nebula")
+	if assert.NoError(t, err) {
+		assert.Equal(t, host, session.GetHostAddress())
+		session.Release()
+	}
+}
+
+func TestPool_DefaultConnectionFactoryConfig(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf := GetDefaultConf()
+	conf.BufferSize = -1
+	conf.TCPKeepAlive = -time.Second
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	// The transports are opened with the validated config
+	factoryConf := pool.factory.(*defaultConnectionFactory).conf
+	assert.Equal(t, defaultBufferSize, factoryConf.BufferSize)
+	assert.Equal(t, pool.conf.TCPKeepAlive, factoryConf.TCPKeepAlive)
+}
@@ -8,20 +8,86 @@ package nebula
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"runtime/debug"
+	"strings"
 	"sync"
-
-	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"time"
 )
 
 type ConnectionPool struct {
 	idleConnectionQueue   list.List
 	activeConnectionQueue list.List
-	addresses             []HostAddress
-	conf                  PoolConfig
-	hostIndex             int
-	log                   Logger
-	rwLock                sync.RWMutex
+	// The addresses given by the user, which are resolved to addresses
+	configAddresses []HostAddress
+	// The primary addresses followed by the fallback ones
+	addresses    []HostAddress
+	hosts        map[HostAddress]*hostStatus
+	conf         PoolConfig
+	loadBalancer LoadBalancer
+	factory      ConnectionFactory
+	log          Logger
+	metrics      MetricsObserver
+	rwLock       sync.RWMutex
+	// Sessions which are not released yet, they are signed out when the pool is closed
+	// The timers of the leak check, nil if it is disabled
+	sessions map[*Session]*time.Timer
+	// Channels of the goroutines waiting in GetConnectionWithContext, the longest waiting one is at the front
+	waiters list.List
+	// Number of idle connections taken out of the queue to be pinged, they still count against MaxConnPoolSize
+	checking int
+	// The credentials cached by GetSessionFromProvider
+	credentials *credentials
+	// Semaphore of the running queries, nil if MaxConcurrentQueries is not set
+	querySlots chan struct{}
+	// The limiter of the queries per second, nil if QueriesPerSecond is not set
+	rateLimiter *rateLimiter
+	// The results of Session.ExecuteCached, nil if ResultCacheSize is not set
+	resultCache *resultCache
+	// The spaces known to be valid, nil if ValidSpaceCacheTTL is not set
+	validSpaces *validSpaceCache
+	// The lifecycle events, nil if EventBufferSize is not set
+	// eventsMu guards the sends against the close of the channel
+	events       chan PoolEvent
+	eventsMu     sync.Mutex
+	eventsClosed bool
+	// Set by Drain, closed once no connection is in use, nil if the pool is not draining
+	drained chan struct{}
+	// Closed when the pool is closed to stop the background goroutines
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// Status of a host tracked by the pool
+type hostStatus struct {
+	healthy bool
+	// Number of connections opened to the host
+	workload int
+	// No connection is handed out to the host until then, it is set when the host reports a leader change
+	skipUntil time.Time
+	tier      HostTier
+	// Number of failures to open a connection since the last success, and the end of the cooldown
+	// of the open circuit, zero value if the circuit is closed, see PoolConfig.CircuitBreakerThreshold
+	failures  int
+	openUntil time.Time
+	// The error which made the host unhealthy, nil if it is healthy
+	lastErr error
+}
+
+// How long a host reporting a leader change is skipped
+const leaderChangeCooldown = 5 * time.Second
+
+// Check if the host is skipped because it reported a leader change recently
+func (status *hostStatus) isSkipped(now time.Time) bool {
+	return now.Before(status.skipUntil)
+}
+
+// Check if the circuit of the host is open, the host is not given any connection then
+func (status *hostStatus) isCircuitOpen(now time.Time) bool {
+	return now.Before(status.openUntil)
 }
 
 func NewConnectionPool(addresses []HostAddress, conf PoolConfig, log Logger) (*ConnectionPool, error) {
@@ -33,20 +99,71 @@ func NewConnectionPool(addresses []HostAddress, conf PoolConfig, log Logger) (*C
 	return newPool, nil
 }
 
+// Clone creates a new pool to the same addresses, with the config of pool overridden by the options,
+// e.g. pool.Clone(WithSpace("test", VIDTypeString)). The two pools share no connection or session.
+func (pool *ConnectionPool) Clone(opts ...PoolOption) (*ConnectionPool, error) {
+	addresses := append([]HostAddress(nil), pool.configAddresses...)
+	// The sizes could be changed by Resize
+	pool.rwLock.RLock()
+	conf := pool.conf
+	pool.rwLock.RUnlock()
+	return NewConnectionPool(addresses, conf.With(opts...), pool.log)
+}
+
 func (pool *ConnectionPool) initPool(addresses []HostAddress, conf PoolConfig, log Logger) error {
+	pool.log = log
+	if conf.Logger != nil {
+		pool.log = conf.Logger
+	}
+	if pool.log == nil {
+		pool.log = NoopLogger{}
+	}
 	// Process domain to IP
-	convAddress, err := DomainToIP(addresses)
+	convAddress, tiers, err := resolveTiers(addresses, conf.FallbackAddresses, conf, pool.log)
 	if err != nil {
 		return fmt.Errorf("Failed to find IP, error: %s ", err.Error())
 	}
 
+	pool.configAddresses = addresses
 	pool.addresses = convAddress
 	pool.conf = conf
-	pool.hostIndex = 0
-	pool.log = log
+	pool.metrics = conf.MetricsObserver
+	if pool.metrics == nil {
+		pool.metrics = NoopMetricsObserver{}
+	}
+	pool.hosts = make(map[HostAddress]*hostStatus)
+	for _, address := range pool.addresses {
+		pool.hosts[address] = &hostStatus{healthy: true, tier: tiers[address]}
+	}
+	pool.closeCh = make(chan struct{})
+	pool.sessions = make(map[*Session]*time.Timer)
+	pool.loadBalancer = conf.LoadBalancer
+	if pool.loadBalancer == nil {
+		pool.loadBalancer = NewWeightedRoundRobinLoadBalancer()
+	}
 
 	// Check config
 	pool.conf.validateConf(pool.log)
+	// The default factory opens the transports with the validated config
+	pool.factory = pool.conf.ConnectionFactory
+	if pool.factory == nil {
+		pool.factory = NewDefaultConnectionFactory(pool.conf)
+	}
+	if pool.conf.MaxConcurrentQueries > 0 {
+		pool.querySlots = make(chan struct{}, pool.conf.MaxConcurrentQueries)
+	}
+	if pool.conf.QueriesPerSecond > 0 {
+		pool.rateLimiter = newRateLimiter(pool.conf.QueriesPerSecond, pool.conf.QueryBurst)
+	}
+	if pool.conf.ResultCacheSize > 0 {
+		pool.resultCache = newResultCache(pool.conf.ResultCacheSize, pool.conf.ResultCacheTTL)
+	}
+	if pool.conf.ValidSpaceCacheTTL > 0 {
+		pool.validSpaces = newValidSpaceCache(pool.conf.ValidSpaceCacheTTL)
+	}
+	if pool.conf.EventBufferSize > 0 {
+		pool.events = make(chan PoolEvent, pool.conf.EventBufferSize)
+	}
 	// Check input
 	if len(addresses) == 0 {
 		return fmt.Errorf("Failed to initialize connection pool: illegal address input")
@@ -55,36 +172,262 @@ func (pool *ConnectionPool) initPool(addresses []HostAddress, conf PoolConfig, l
 		return fmt.Errorf("Failed to initialize connection pool: no configuration")
 	}
 
-	for i := 0; i < pool.conf.MinConnPoolSize; i++ {
-		// Simple round-robin
-		newConn := newConnection(pool.addresses[i%len(addresses)])
+	// Make sure at least one of the given hosts is reachable
+	if !pool.checkAddresses() {
+		return fmt.Errorf("Failed to initialize connection pool: no reachable host in %v", pool.addresses)
+	}
+
+	if err := pool.openInitialConns(); err != nil {
+		return err
+	}
+	if pool.conf.HealthCheckInterval > 0 {
+		go pool.healthCheck(pool.conf.HealthCheckInterval)
+	}
+	if pool.conf.ResolveInterval > 0 && !pool.conf.DisableResolution {
+		go pool.refreshAddressesPeriodically(pool.conf.ResolveInterval)
+	}
+	pool.log.Info("connection pool is initialized successfully")
+	return nil
+}
+
+// Open the MinConnPoolSize connections of a new pool, InitParallelism of them at the same time.
+// If any of them fails, the opened ones are closed and the error tells how many failed.
+func (pool *ConnectionPool) openInitialConns() error {
+	total := pool.conf.MinConnPoolSize
+	conns := make([]*connection, total)
+	errs := make([]error, total)
+	// Pick the hosts by the load balancer first, so it sees the connections to come
+	for i := range conns {
+		host, err := pool.getHost()
+		if err != nil {
+			for _, conn := range conns[:i] {
+				pool.hosts[conn.severAddress].workload--
+			}
+			return err
+		}
+		if conns[i], err = pool.factory.NewConnection(host); err != nil {
+			for _, conn := range conns[:i] {
+				pool.hosts[conn.severAddress].workload--
+			}
+			return err
+		}
+		pool.hosts[conns[i].severAddress].workload++
+	}
+	slots := make(chan struct{}, pool.conf.InitParallelism)
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, conn *connection) {
+			defer wg.Done()
+			errs[i] = pool.factory.Open(conn, pool.conf.getConnTimeout())
+			<-slots
+		}(i, conn)
+	}
+	wg.Wait()
+
+	var msgs []string
+	seen := make(map[string]bool)
+	for _, err := range errs {
+		if err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		for _, conn := range conns {
+			pool.sendEvent(EventConnOpened, conn.severAddress, nil)
+			pool.idleConnectionQueue.PushBack(conn)
+		}
+		return nil
+	}
+	failed := 0
+	for i, conn := range conns {
+		if errs[i] == nil {
+			pool.closeConn(conn)
+			continue
+		}
+		failed++
+		pool.hosts[conn.severAddress].workload--
+	}
+	return fmt.Errorf("Failed to open connection, %d of %d connections opened, %d failed, error: %s",
+		total-failed, total, failed, strings.Join(msgs, "; "))
+}
 
-		// Open connection to host
-		err := newConn.open(newConn.severAddress, pool.conf.TimeOut)
+// Open a connection to every configured host once and mark the unreachable ones as unhealthy.
+// The hosts failing the validation are marked as unhealthy too if ValidateOnCreate is set.
+// Return false if none of the hosts is reachable.
+func (pool *ConnectionPool) checkAddresses() bool {
+	reachable := false
+	for _, address := range pool.addresses {
+		newConn, err := pool.openConn(address, pool.conf.getConnTimeout())
+		pool.observeOpen(address, err)
 		if err != nil {
-			// If initialization failed, clean idle queue
-			idleLen := pool.idleConnectionQueue.Len()
-			for i := 0; i < idleLen; i++ {
-				pool.idleConnectionQueue.Front().Value.(*connection).close()
-				pool.idleConnectionQueue.Remove(pool.idleConnectionQueue.Front())
+			pool.log.Warn(fmt.Sprintf("Host %s:%d is unreachable, %s", address.Host, address.Port, err.Error()))
+			pool.setHealthy(address, false, err)
+			continue
+		}
+		if pool.conf.ValidateOnCreate {
+			if err := pool.validateConn(newConn); err != nil {
+				pool.log.Warn(fmt.Sprintf("Host %s:%d failed the validation, %s", address.Host, address.Port, err.Error()))
+				pool.setHealthy(address, false, err)
+				newConn.close()
+				continue
 			}
-			return fmt.Errorf("Failed to open connection, error: %s ", err.Error())
 		}
-		// Mark connection as in use
-		pool.idleConnectionQueue.PushBack(newConn)
+		newConn.close()
+		reachable = true
 	}
-	pool.log.Info("connection pool is initialized successfully")
-	return nil
+	return reachable
+}
+
+// Sign in a throwaway session on the connection and switch it to SpaceName
+func (pool *ConnectionPool) validateConn(conn *connection) error {
+	resp, err := conn.authenticate(pool.conf.ValidateUsername, pool.conf.ValidatePassword)
+	if err != nil {
+		return err
+	}
+	sessionID := resp.GetSessionID()
+	defer conn.signOut(sessionID)
+	if pool.conf.SpaceName == "" {
+		return nil
+	}
+	space := pool.conf.SpaceName
+	useResp, err := conn.execute(sessionID, "USE "+EscapeLabel(space))
+	return useSpaceError(space, useResp, err)
 }
 
 func (pool *ConnectionPool) GetSession(username, password string) (*Session, error) {
+	return pool.GetSessionWithContext(context.Background(), username, password)
+}
+
+// GetPinnedSession creates a session which runs every statement on the same connection, to the same host,
+// with the same session ID until it is released, for a sequence of statements relying on the state of the session.
+// A broken transport is reopened to the same host, but the session is never moved to another connection,
+// nor signed in again if graphd loses it, the error is returned instead, ignoring AutoReconnectSession
+// and RetryOnLeaderChange. Like any session it holds its connection, so it counts against MaxConnPoolSize
+// for its whole lifetime, release it as soon as the sequence is done.
+func (pool *ConnectionPool) GetPinnedSession(username, password string) (*Session, error) {
+	session, err := pool.GetSession(username, password)
+	if err != nil {
+		return nil, err
+	}
+	session.pinned = true
+	return session, nil
+}
+
+// GetSessionFromHost creates a session on a connection to the given host if it could, e.g. the graphd
+// colocated with the application. The host is matched by its IP and port with the addresses of the pool,
+// a host name is resolved first. The session falls back to a host chosen as GetSession does, without an error,
+// if the host is not one of the pool, is unhealthy, skipped after a leader change, has its circuit open,
+// or is a fallback host while the primary ones are used, see PoolConfig.FallbackAddresses,
+// and if opening a connection to it fails or the pool is full and has no idle connection to it.
+// GetHostAddress tells which host the session got. A session moved to another connection later,
+// e.g. after its transport broke, goes to any host as well.
+func (pool *ConnectionPool) GetSessionFromHost(addr HostAddress, username, password string) (*Session, error) {
+	ctx := context.Background()
+	if host, ok := pool.findAddress(addr); ok {
+		ctx = context.WithValue(ctx, preferredHostKey{}, host)
+	} else {
+		pool.log.Warn(fmt.Sprintf("Host %s:%d is not an address of the pool, another host is used", addr.Host, addr.Port))
+	}
+	return pool.GetSessionWithContext(ctx, username, password)
+}
+
+// Key of the host GetSessionWithContext prefers in the context
+type preferredHostKey struct{}
+
+// Return the address of the pool with the IP and the port of addr, resolving it if it is a host name
+func (pool *ConnectionPool) findAddress(addr HostAddress) (HostAddress, bool) {
+	pool.rwLock.RLock()
+	addresses := pool.addresses
+	pool.rwLock.RUnlock()
+	candidates := []HostAddress{addr}
+	if net.ParseIP(addr.Host) == nil {
+		resolved, err := resolveAddresses(candidates, true)
+		if err != nil {
+			return HostAddress{}, false
+		}
+		candidates = resolved
+	}
+	for _, candidate := range candidates {
+		for _, address := range addresses {
+			if address.Host == candidate.Host && address.Port == candidate.Port {
+				return address, true
+			}
+		}
+	}
+	return HostAddress{}, false
+}
+
+// Take an idle connection to the host or open a new one to it, nil if the host could not be used
+func (pool *ConnectionPool) takeConnTo(host HostAddress) *connection {
+	start := time.Now()
+	for {
+		pool.rwLock.Lock()
+		conn := pool.takeConnToLocked(host)
+		pool.observeConnCount()
+		pool.rwLock.Unlock()
+		if conn == nil || pool.testOnBorrow(conn) {
+			pool.metrics.ObservePoolGet(time.Since(start))
+			if conn != nil {
+				pool.onAcquire(conn)
+			}
+			return conn
+		}
+	}
+}
+
+func (pool *ConnectionPool) takeConnToLocked(host HostAddress) *connection {
+	now := time.Now()
+	status, ok := pool.hosts[host]
+	if pool.isClosed() || pool.drained != nil || !ok || !status.healthy || status.isSkipped(now) ||
+		status.isCircuitOpen(now) || status.tier != pool.activeTier() {
+		return nil
+	}
+	pool.evictExpiredConns()
+	for ele := pool.idleConnectionQueue.Front(); ele != nil; {
+		next := ele.Next()
+		conn := ele.Value.(*connection)
+		if conn.severAddress != host {
+			ele = next
+			continue
+		}
+		pool.idleConnectionQueue.Remove(ele)
+		if conn.isBroken() {
+			pool.evictConn(conn, nil)
+		} else {
+			conn.untested = pool.conf.TestOnBorrow
+			pool.activeConnectionQueue.PushBack(conn)
+			return conn
+		}
+		ele = next
+	}
+	if pool.getTotalConnCount() >= pool.conf.MaxConnPoolSize {
+		return nil
+	}
+	conn, err := pool.newConnTo(host)
+	if err != nil {
+		return nil
+	}
+	return conn
+}
+
+// GetSessionWithContext is like GetSession, but gives up when ctx is done, the returned error wraps ctx.Err() then.
+// The deadline of ctx caps AcquireTimeout and the socket timeout of signing in, so a ctx given to this and
+// then to Session.ExecuteWithContext bounds the whole time to get a session and execute a query.
+func (pool *ConnectionPool) GetSessionWithContext(ctx context.Context, username, password string) (*Session, error) {
 	// Get valid and usable connection
 	var conn *connection = nil
 	var err error = nil
+	if host, ok := ctx.Value(preferredHostKey{}).(HostAddress); ok {
+		conn = pool.takeConnTo(host)
+	}
 	const retryTimes = 3
-	for i := 0; i < retryTimes; i++ {
-		conn, err = pool.getIdleConn()
-		if err == nil {
+	for i := 0; i < retryTimes && conn == nil; i++ {
+		conn, err = pool.acquireConnWithContext(ctx)
+		// Waiting again for a released connection is up to the caller
+		if err == nil || errors.Is(err, ErrPoolExhausted) || ctx.Err() != nil {
 			break
 		}
 	}
@@ -92,50 +435,103 @@ func (pool *ConnectionPool) GetSession(username, password string) (*Session, err
 		return nil, err
 	}
 	// Authenticate
-	resp, err := conn.authenticate(username, password)
-	if err != nil || resp.GetErrorCode() != graph.ErrorCode_SUCCEEDED {
-		// if authentication failed, put connection back
-		pool.rwLock.Lock()
-		defer pool.rwLock.Unlock()
-		removeFromList(&pool.activeConnectionQueue, conn)
-		pool.idleConnectionQueue.PushBack(conn)
+	resp, err := conn.authenticateWithContext(ctx, username, password)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("Failed to get session: %w", ctx.Err())
+	}
+	if err != nil {
+		// if authentication failed, put connection back, a waiting caller gets it
+		pool.release(conn)
 		return nil, err
 	}
 
 	sessID := resp.GetSessionID()
 	// Create new session
 	newSession := Session{
-		sessionID:  sessID,
-		connection: conn,
-		connPool:   pool,
-		log:        pool.log,
+		sessionID:      sessID,
+		connection:     conn,
+		connPool:       pool,
+		log:            pool.log,
+		vidType:        pool.conf.VIDType,
+		username:       username,
+		password:       []byte(password),
+		defaultSpace:   pool.conf.SpaceName,
+		defaultVIDType: pool.conf.VIDType,
+		authResp:       resp,
 	}
+	pool.watchLeak(&newSession)
 
+	// Do not leave the session in the default space if the space could not be used
+	if pool.conf.SpaceName != "" {
+		if err := newSession.useSpace(ctx, pool.conf.SpaceName); err != nil {
+			newSession.Release()
+			return nil, err
+		}
+	}
+	if err := newSession.runOnConnect(ctx); err != nil {
+		newSession.Release()
+		return nil, err
+	}
 	return &newSession, nil
 }
 
 func (pool *ConnectionPool) getIdleConn() (*connection, error) {
-	pool.rwLock.Lock()
-	defer pool.rwLock.Unlock()
+	start := time.Now()
+	for {
+		pool.rwLock.Lock()
+		conn, err := pool.takeConn()
+		pool.observeConnCount()
+		pool.rwLock.Unlock()
+		if err != nil || pool.testOnBorrow(conn) {
+			pool.metrics.ObservePoolGet(time.Since(start))
+			if err == nil {
+				pool.onAcquire(conn)
+			}
+			return conn, err
+		}
+	}
+}
 
+// Take an idle connection or open a new one, must be called with the lock held
+func (pool *ConnectionPool) takeConn() (*connection, error) {
+	if pool.isClosed() {
+		return nil, fmt.Errorf("Failed to get connection: %w", ErrPoolClosed)
+	}
+	if pool.drained != nil {
+		return nil, fmt.Errorf("Failed to get connection: %w", ErrPoolDraining)
+	}
+
+	pool.evictExpiredConns()
+	pool.closeOffTierConns()
 	// Take an idle valid connection if possible
 	if pool.idleConnectionQueue.Len() > 0 {
 		var newConn *connection = nil
 		var newEle *list.Element = nil
-		for ele := pool.idleConnectionQueue.Front(); ele != nil; ele = ele.Next() {
-			// Check if connection is valid
-			if res := ele.Value.(*connection).ping(); res == true {
-				newConn = ele.Value.(*connection)
-				newEle = ele
+		now := time.Now()
+		for ele := pool.idleConnectionQueue.Front(); ele != nil; {
+			next := ele.Next()
+			conn := ele.Value.(*connection)
+			if status, ok := pool.hosts[conn.severAddress]; ok && status.isSkipped(now) {
+				ele = next
+				continue
+			}
+			// Check if connection is valid, a dead one is replaced instead of being handed out
+			if conn.isBroken() {
+				pool.idleConnectionQueue.Remove(ele)
+				pool.evictConn(conn, nil)
+			} else {
+				newConn, newEle = conn, ele
 				break
 			}
+			ele = next
 		}
 		if newConn == nil {
 			newConn, err := pool.createConnection()
 			return newConn, err
 		}
-		// Remove new connection from idle and add to active if found
+		// Remove new connection from idle and add to active if found, it is pinged by the caller if TestOnBorrow is set
 		pool.idleConnectionQueue.Remove(newEle)
+		newConn.untested = pool.conf.TestOnBorrow
 		pool.activeConnectionQueue.PushBack(newConn)
 		return newConn, nil
 	}
@@ -143,34 +539,397 @@ func (pool *ConnectionPool) getIdleConn() (*connection, error) {
 	// Create a new connection if there is no idle connection and total connection < pool max size
 	newConn, err := pool.createConnection()
 	return newConn, err
-	// TODO: If no idle avaliable, wait for timeout and reconnect
+}
+
+// GetConnection takes an idle connection from the pool, or opens a new one if the
+// pool has not reached MaxConnPoolSize yet.
+// The connection must be given back with Release once the caller is done with it.
+func (pool *ConnectionPool) GetConnection() (*connection, error) {
+	return pool.acquireConn()
+}
+
+// Take a connection, waiting up to AcquireTimeout for one to be released if the pool is full
+func (pool *ConnectionPool) acquireConn() (*connection, error) {
+	return pool.acquireConnWithContext(context.Background())
+}
+
+// Take a connection, waiting up to AcquireTimeout for one to be released if the pool is full,
+// or until ctx is done if it comes first
+func (pool *ConnectionPool) acquireConnWithContext(parent context.Context) (*connection, error) {
+	timeout := pool.conf.AcquireTimeout
+	if timeout < 0 {
+		if err := parent.Err(); err != nil {
+			return nil, fmt.Errorf("Failed to get connection: %w", err)
+		}
+		return pool.getIdleConn()
+	}
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn, err := pool.GetConnectionWithContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		return nil, &kindError{
+			kind: ErrPoolExhausted,
+			msg:  fmt.Sprintf("Failed to get connection: no connection is released in %v and %s", timeout, ErrPoolFull.Error()),
+			err:  ErrPoolFull,
+		}
+	}
+	return conn, err
+}
+
+// GetConnectionWithContext is like GetConnection, but if the pool has reached MaxConnPoolSize,
+// it waits for a connection to be released until ctx is done, then the returned error wraps ctx.Err().
+// The waiting callers are served in the order they started waiting.
+func (pool *ConnectionPool) GetConnectionWithContext(ctx context.Context) (*connection, error) {
+	start := time.Now()
+	defer func() { pool.metrics.ObservePoolGet(time.Since(start)) }()
+	for {
+		pool.rwLock.Lock()
+		conn, err := pool.takeConn()
+		if errors.Is(err, ErrPoolFull) {
+			break
+		}
+		pool.observeConnCount()
+		pool.rwLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		if pool.testOnBorrow(conn) {
+			pool.onAcquire(conn)
+			return conn, nil
+		}
+	}
+	// The lock is still held
+	waiter := make(chan *connection, 1)
+	ele := pool.waiters.PushBack(waiter)
+	pool.rwLock.Unlock()
+
+	select {
+	case conn, ok := <-waiter:
+		if !ok {
+			// The waiters are let go when the pool is drained or closed
+			if pool.isClosed() {
+				return nil, fmt.Errorf("Failed to get connection: %w", ErrPoolClosed)
+			}
+			return nil, fmt.Errorf("Failed to get connection: %w", ErrPoolDraining)
+		}
+		pool.onAcquire(conn)
+		return conn, nil
+	case <-ctx.Done():
+		pool.rwLock.Lock()
+		// A connection may have been handed over before the lock is taken
+		select {
+		case conn, ok := <-waiter:
+			pool.rwLock.Unlock()
+			if ok {
+				pool.putBack(conn)
+			}
+		default:
+			pool.waiters.Remove(ele)
+			pool.rwLock.Unlock()
+		}
+		return nil, fmt.Errorf("Failed to get connection: %w", ctx.Err())
+	}
+}
+
+// Release gives a connection obtained from GetConnection back to the pool
+func (pool *ConnectionPool) Release(conn *connection) {
+	pool.release(conn)
+}
+
+// Wait for the turn of a query if QueriesPerSecond is set, then for a slot to run it if MaxConcurrentQueries is set.
+// releaseQuerySlot must be called once the query finishes if no error is returned.
+func (pool *ConnectionPool) acquireQuerySlot(ctx context.Context) error {
+	if pool.rateLimiter != nil {
+		if err := pool.rateLimiter.wait(ctx, pool.closeCh); err != nil {
+			return err
+		}
+	}
+	if pool.querySlots == nil {
+		return nil
+	}
+	select {
+	case pool.querySlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("Failed to execute, no query slot is freed in time: %w", ctx.Err())
+	case <-pool.closeCh:
+		return fmt.Errorf("Failed to execute: %w", ErrPoolClosed)
+	}
+}
+
+func (pool *ConnectionPool) releaseQuerySlot() {
+	if pool.querySlots != nil {
+		<-pool.querySlots
+	}
+}
+
+// Run PoolConfig.OnAcquire for a connection taken from the pool
+func (pool *ConnectionPool) onAcquire(conn *connection) {
+	if pool.conf.OnAcquire != nil {
+		pool.conf.OnAcquire(conn.info())
+	}
+}
+
+// Run PoolConfig.OnRelease for a connection given back to the pool
+func (pool *ConnectionPool) onRelease(conn *connection) {
+	if pool.conf.OnRelease != nil {
+		pool.conf.OnRelease(conn.info())
+	}
 }
 
 // Release connection to pool
 func (pool *ConnectionPool) release(conn *connection) {
+	pool.onRelease(conn)
+	pool.putBack(conn)
+}
+
+// Put a connection back to the idle queue, or hand it over to a waiter, or close it if it could not be reused
+func (pool *ConnectionPool) putBack(conn *connection) {
 	pool.rwLock.Lock()
 	defer pool.rwLock.Unlock()
 	// Remove connection from active queue and add into idle queue
 	removeFromList(&pool.activeConnectionQueue, conn)
+	defer pool.observeConnCount()
+	defer pool.notifyDrained()
+	conn.lastUsed = time.Now()
+	// The connection is not reused if the pool is closed, its host has been removed by a DNS refresh,
+	// an RPC has failed with a fatal error on it, it has been retired, or its host is not in the active tier
+	if pool.isClosed() {
+		pool.closeConn(conn)
+		return
+	}
+	if pool.hosts[conn.severAddress] == nil || conn.isBroken() || conn.retired || pool.isOffTier(conn, pool.activeTier()) {
+		pool.evictConn(conn, nil)
+		return
+	}
+	// The next user gets the timeout of the config
+	conn.resetTimeout()
+	// Hand the connection over to the longest waiting caller of GetConnectionWithContext
+	if front := pool.waiters.Front(); front != nil {
+		pool.activeConnectionQueue.PushBack(conn)
+		pool.waiters.Remove(front).(chan *connection) <- conn
+		return
+	}
 	pool.idleConnectionQueue.PushBack(conn)
 }
 
-// Close all connection
+// Close the idle connections which have expired, and retire the ones in use which have outlived MaxConnLifetime
+// so they are closed once released, must be called with the lock held
+func (pool *ConnectionPool) evictExpiredConns() {
+	now := time.Now()
+	for ele := pool.idleConnectionQueue.Front(); ele != nil; {
+		next := ele.Next()
+		if conn := ele.Value.(*connection); conn.isExpired(now) {
+			pool.idleConnectionQueue.Remove(ele)
+			pool.evictConn(conn, nil)
+		}
+		ele = next
+	}
+	for ele := pool.activeConnectionQueue.Front(); ele != nil; ele = ele.Next() {
+		if conn := ele.Value.(*connection); !conn.retired && conn.isOutlived(now) {
+			conn.retired = true
+		}
+	}
+}
+
+// Report the number of connections, must be called with the lock held
+func (pool *ConnectionPool) observeConnCount() {
+	pool.metrics.ObserveConnCount(pool.activeConnectionQueue.Len(), pool.idleConnectionQueue.Len())
+}
+
+// Stop tracking a released session
+func (pool *ConnectionPool) removeSession(session *Session) {
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	if timer := pool.sessions[session]; timer != nil {
+		timer.Stop()
+	}
+	delete(pool.sessions, session)
+}
+
+// Track a session handed out to a user, a warning with the current stack is logged
+// if it is not released in SessionLeakThreshold
+func (pool *ConnectionPool) watchLeak(session *Session) {
+	var timer *time.Timer
+	if threshold := pool.conf.SessionLeakThreshold; threshold > 0 {
+		sessionID := session.sessionID
+		stack := debug.Stack()
+		timer = time.AfterFunc(threshold, func() {
+			pool.log.Warn(fmt.Sprintf("Session %d has not been released in %v, it was got by:\n%s",
+				sessionID, threshold, stack))
+		})
+	}
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	if old := pool.sessions[session]; old != nil {
+		old.Stop()
+	}
+	pool.sessions[session] = timer
+}
+
+// Stop the leak check of a session kept idle by a SessionPool
+func (pool *ConnectionPool) unwatchLeak(session *Session) {
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	if timer, ok := pool.sessions[session]; ok && timer != nil {
+		timer.Stop()
+		pool.sessions[session] = nil
+	}
+}
+
+// SignoutAll releases every session got from the pool which is not released yet, e.g. for a clean shutdown.
+// A session running a query is released once the query finishes. The sessions kept by a SessionPool
+// are released too, the SessionPool drops them and signs in new ones as they are needed.
+// It returns the number of sessions released.
+func (pool *ConnectionPool) SignoutAll() int {
+	sessions := pool.liveSessions()
+	for _, session := range sessions {
+		session.Release()
+	}
+	if len(sessions) > 0 {
+		pool.log.Info(fmt.Sprintf("%d sessions have been signed out", len(sessions)))
+	}
+	return len(sessions)
+}
+
+// Return the sessions which are not released
+func (pool *ConnectionPool) liveSessions() []*Session {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	sessions := make([]*Session, 0, len(pool.sessions))
+	for session := range pool.sessions {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// Close stops the health check, signs out the sessions which are not released and closes all connections.
+// It waits up to CloseTimeOut for the running queries to finish, the transports are closed anyway after that.
+// Calling Close more than once is safe, GetSession and GetConnection return ErrPoolClosed once it is called.
 func (pool *ConnectionPool) Close() {
+	closing := false
+	// Stop the health check and reject new connections
+	pool.closeOnce.Do(func() {
+		close(pool.closeCh)
+		closing = true
+	})
+	if !closing {
+		return
+	}
+
+	pool.releaseSessions(pool.liveSessions())
+
 	pool.rwLock.Lock()
 	defer pool.rwLock.Unlock()
 	idleLen := pool.idleConnectionQueue.Len()
 	activeLen := pool.activeConnectionQueue.Len()
 
 	for i := 0; i < idleLen; i++ {
-		pool.idleConnectionQueue.Front().Value.(*connection).close()
+		pool.closeConn(pool.idleConnectionQueue.Front().Value.(*connection))
 		pool.idleConnectionQueue.Remove(pool.idleConnectionQueue.Front())
 	}
+	// The connections in use may still be running a query, unblock them instead of closing them
+	// under their users, they are closed when they are released
 	for i := 0; i < activeLen; i++ {
-		pool.activeConnectionQueue.Front().Value.(*connection).close()
+		pool.activeConnectionQueue.Front().Value.(*connection).interrupt()
 		pool.activeConnectionQueue.Remove(pool.activeConnectionQueue.Front())
 	}
+	for pool.waiters.Len() > 0 {
+		close(pool.waiters.Remove(pool.waiters.Front()).(chan *connection))
+	}
+	pool.closeEvents()
+}
+
+// Drain stops handing out connections and waits for the ones in use to be released, e.g. to shut down
+// without failing the running queries. GetSession and GetConnection return ErrPoolDraining once it is called,
+// including the callers waiting for a connection to be released, so does a session which has to reopen its
+// connection. The sessions and the connections in use keep working and are released normally.
+//
+// Drain returns nil once no connection is in use, Close should be called then. If ctx is done first,
+// the pool is closed at once as Close does and the returned error wraps ctx.Err().
+// The sessions kept by a SessionPool are never released by themselves, close the SessionPool before.
+func (pool *ConnectionPool) Drain(ctx context.Context) error {
+	pool.rwLock.Lock()
+	if pool.isClosed() {
+		pool.rwLock.Unlock()
+		return fmt.Errorf("Failed to drain: %w", ErrPoolClosed)
+	}
+	if pool.drained == nil {
+		pool.drained = make(chan struct{})
+		for pool.waiters.Len() > 0 {
+			close(pool.waiters.Remove(pool.waiters.Front()).(chan *connection))
+		}
+		pool.notifyDrained()
+	}
+	drained := pool.drained
+	pool.rwLock.Unlock()
 
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		pool.rwLock.RLock()
+		inUse := pool.getActiveConnCount()
+		pool.rwLock.RUnlock()
+		pool.log.Warn(fmt.Sprintf("Timed out draining the pool, closing it with %d connections in use", inUse))
+		pool.Close()
+		return fmt.Errorf("Failed to drain, the pool is closed: %w", ctx.Err())
+	}
+}
+
+// Signal Drain if the pool is draining and no connection is in use, must be called with the lock held
+func (pool *ConnectionPool) notifyDrained() {
+	if pool.drained == nil || pool.activeConnectionQueue.Len() > 0 {
+		return
+	}
+	select {
+	case <-pool.drained:
+	default:
+		close(pool.drained)
+	}
+}
+
+// Release the sessions, every session is released once its running query finishes.
+// Give up waiting after CloseTimeOut, only the sessions running no query are waited for if it is 0.
+func (pool *ConnectionPool) releaseSessions(sessions []*Session) {
+	if len(sessions) == 0 {
+		return
+	}
+	if pool.conf.CloseTimeOut <= 0 {
+		var busy []*Session
+		for _, session := range sessions {
+			if session.isBusy() {
+				busy = append(busy, session)
+			} else {
+				session.Release()
+			}
+		}
+		// The queries fail once Close interrupts their transports, the sessions are released then
+		go func() {
+			for _, session := range busy {
+				session.Release()
+			}
+		}()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, session := range sessions {
+			session.Release()
+		}
+		close(done)
+	}()
+	timer := time.NewTimer(pool.conf.CloseTimeOut)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		pool.log.Warn(fmt.Sprintf("Timed out waiting for %d sessions to be released, closing their connections", len(sessions)))
+	}
 }
 
 func (pool *ConnectionPool) getActiveConnCount() int {
@@ -181,32 +940,90 @@ func (pool *ConnectionPool) getIdleConnCount() int {
 	return pool.idleConnectionQueue.Len()
 }
 
-// Get a valid host (round robin)
-func (pool *ConnectionPool) getHost() HostAddress {
-	if pool.hostIndex == len(pool.addresses) {
-		pool.hostIndex = 0
+// Get a healthy host chosen by the load balancer
+// If all hosts are unhealthy, choose among all of them so the pool could recover.
+// The hosts whose circuit is open are never chosen, ErrCircuitOpen is returned if it is open for all of them.
+func (pool *ConnectionPool) getHost() (HostAddress, error) {
+	var candidates []HostAddress
+	var workload []int
+	now := time.Now()
+	tier := pool.activeTier()
+	for _, address := range pool.addresses {
+		status := pool.hosts[address]
+		if status.healthy && !status.isSkipped(now) && !status.isCircuitOpen(now) && status.tier == tier {
+			candidates = append(candidates, address)
+			workload = append(workload, status.workload)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, address := range pool.addresses {
+			if status := pool.hosts[address]; !status.isCircuitOpen(now) {
+				candidates = append(candidates, address)
+				workload = append(workload, status.workload)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return HostAddress{}, fmt.Errorf("Failed to get connection: %w", ErrCircuitOpen)
+	}
+	return candidates[pool.loadBalancer.Select(candidates, workload)], nil
+}
+
+// Skip the host for leaderChangeCooldown, so the retry of the statement goes to another host
+func (pool *ConnectionPool) skipHost(host HostAddress) {
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	if status, ok := pool.hosts[host]; ok {
+		status.skipUntil = time.Now().Add(leaderChangeCooldown)
 	}
-	host := pool.addresses[pool.hostIndex]
-	pool.hostIndex++
-	return host
 }
 
 // Select a new host to create a new connection
 func (pool *ConnectionPool) newConnToHost() (*connection, error) {
-	// Get a valid host (round robin)
-	host := pool.getHost()
-	newConn := newConnection(host)
-	// Open connection to host
-	err := newConn.open(newConn.severAddress, pool.conf.TimeOut)
+	// Get a valid host chosen by the load balancer
+	host, err := pool.getHost()
 	if err != nil {
 		return nil, err
 	}
+	return pool.newConnTo(host)
+}
+
+// Open a new connection to the host and add it to the active queue, must be called with the lock held
+func (pool *ConnectionPool) newConnTo(host HostAddress) (*connection, error) {
+	newConn, err := pool.openConn(host, pool.conf.getConnTimeout())
+	pool.observeOpen(host, err)
+	if err != nil {
+		pool.log.Warn(fmt.Sprintf("Failed to open connection to host %s:%d, %s", host.Host, host.Port, err.Error()))
+		pool.setHealthy(host, false, err)
+		return nil, err
+	}
+	pool.setHealthy(host, true, nil)
+	pool.hosts[host].workload++
+	pool.sendEvent(EventConnOpened, host, nil)
 	// Add connection to active queue
 	pool.activeConnectionQueue.PushBack(newConn)
-	// TODO: update workload
 	return newConn, nil
 }
 
+// Close a connection and update the workload of its host
+func (pool *ConnectionPool) closeConn(conn *connection) {
+	conn.close()
+	pool.sendEvent(EventConnClosed, conn.severAddress, nil)
+	if status, ok := pool.hosts[conn.severAddress]; ok && status.workload > 0 {
+		status.workload--
+	}
+}
+
+// Return the number of connections opened to the given host
+func (pool *ConnectionPool) getServerWorkload(host HostAddress) int {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	if status, ok := pool.hosts[host]; ok {
+		return status.workload
+	}
+	return 0
+}
+
 // Remove a connection from list
 func removeFromList(l *list.List, conn *connection) {
 	for ele := l.Front(); ele != nil; ele = ele.Next() {
@@ -216,18 +1033,62 @@ func removeFromList(l *list.List, conn *connection) {
 	}
 }
 
+// Ping the connection taken from the idle queue if TestOnBorrow is set, true if it is fine or needs no ping.
+// It is called without the lock, so a connection which does not answer holds up no other caller,
+// a connection failing the ping is evicted.
+func (pool *ConnectionPool) testOnBorrow(conn *connection) bool {
+	if !conn.untested {
+		return true
+	}
+	conn.untested = false
+	err := conn.ping(conn.conf.getPingTimeout())
+	if err == nil {
+		return true
+	}
+	pool.log.Warn(fmt.Sprintf("Failed to validate connection to host: %s, port: %d on borrow, %s",
+		conn.severAddress.Host, conn.severAddress.Port, err.Error()))
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	removeFromList(&pool.activeConnectionQueue, conn)
+	pool.evictConn(conn, err)
+	pool.observeConnCount()
+	pool.notifyDrained()
+	return false
+}
+
 // Compare total connection number with pool max size and return a connection if capable
 func (pool *ConnectionPool) createConnection() (*connection, error) {
-	totalConn := pool.idleConnectionQueue.Len() + pool.activeConnectionQueue.Len()
+	totalConn := pool.getTotalConnCount()
 	// If no idle avaliable and the number of total connection reaches the max pool size, return error/wait for timeout
 	if totalConn >= pool.conf.MaxConnPoolSize {
-		return nil, fmt.Errorf("Failed to get connection: No valid connection in the idle queue and connection number has reached the pool capacity")
+		pool.sendEvent(EventPoolExhausted, HostAddress{}, ErrPoolFull)
+		return nil, fmt.Errorf("Failed to get connection: %w", ErrPoolFull)
 	}
 
 	newConn, err := pool.newConnToHost()
 	if err != nil {
 		return nil, err
 	}
-	// TODO: update workload
 	return newConn, nil
 }
+
+// Ping opens a connection to every configured host and checks it answers in timeout.
+// The errors of the unreachable hosts are returned, so the map is empty if all hosts are reachable.
+func (pool *ConnectionPool) Ping(timeout time.Duration) map[HostAddress]error {
+	pool.rwLock.RLock()
+	addresses := pool.addresses
+	pool.rwLock.RUnlock()
+	errs := make(map[HostAddress]error)
+	for _, address := range addresses {
+		conn, err := pool.openConn(address, timeout)
+		if err != nil {
+			errs[address] = err
+			continue
+		}
+		if err := conn.ping(timeout); err != nil {
+			errs[address] = err
+		}
+		conn.close()
+	}
+	return errs
+}
@@ -0,0 +1,1170 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestPool_Close(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+
+	conf := GetDefaultConf()
+	conf.TimeOut = 100 * time.Millisecond
+	conf.MinConnPoolSize = 1
+	conf.CloseTimeOut = time.Second
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{sessionID: 1, connection: conn, connPool: pool, log: nebulaLog}
+	pool.watchLeak(session)
+
+	// Close waits for the running query, which fails after ExecTimeOut, then signs the session out
+	go session.Execute("YIELD 1")
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	pool.Close()
+	assert.True(t, time.Since(start) < conf.CloseTimeOut)
+	assert.Nil(t, session.connection)
+	assert.Equal(t, 0, pool.getIdleConnCount())
+	assert.Equal(t, 0, pool.getActiveConnCount())
+
+	// Double close is safe
+	pool.Close()
+	_, err = pool.GetConnection()
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+}
+
+func TestPool_Drain(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		time.Sleep(200 * time.Millisecond)
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 2
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A caller waiting for a connection is let go by Drain
+	waited := make(chan error, 1)
+	go func() {
+		_, err := pool.GetConnection()
+		waited <- err
+	}()
+
+	// The running query finishes and the session is released normally
+	executed := make(chan error, 1)
+	go func() {
+		_, err := session.Execute("YIELD 1")
+		session.Release()
+		executed <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	drained := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		drained <- pool.Drain(ctx)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, errors.Is(<-waited, ErrPoolDraining))
+	_, err = pool.GetSession("root", "nebula")
+	assert.True(t, errors.Is(err, ErrPoolDraining))
+
+	assert.NoError(t, <-executed)
+	select {
+	case <-drained:
+		t.Fatal("Drain returns with a connection in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	pool.Release(conn)
+	assert.NoError(t, <-drained)
+	assert.Equal(t, 0, pool.getActiveConnCount())
+	pool.Close()
+	assert.True(t, errors.Is(pool.Drain(context.Background()), ErrPoolClosed))
+}
+
+func TestPool_DrainTimeout(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The session is never released, the pool is closed once ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = pool.Drain(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Nil(t, session.connection)
+	_, err = pool.GetSession("root", "nebula")
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+}
+
+func TestPool_CloseTimeout(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+
+	conf := GetDefaultConf()
+	conf.ExecTimeOut = 10 * time.Second
+	conf.CloseTimeOut = 100 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{sessionID: 1, connection: conn, connPool: pool, log: nebulaLog}
+	pool.watchLeak(session)
+
+	// The query never finishes, its transport is closed once CloseTimeOut is reached
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.Execute("YIELD 1")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	pool.Close()
+	assert.True(t, time.Since(start) < time.Second)
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("The query is not interrupted by Close")
+	}
+}
+
+func TestPool_CloseWithoutTimeout(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	listener, silentHost := startSilentServer(t)
+	defer listener.Close()
+
+	pool, err := NewConnectionPool([]HostAddress{host}, PoolConfig{}, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.GetSession("root", "nebula"); err != nil {
+		t.Fatal(err)
+	}
+	silentPool, err := NewConnectionPool([]HostAddress{silentHost}, PoolConfig{}, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := silentPool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy := &Session{sessionID: 1, connection: conn, connPool: silentPool, log: nebulaLog}
+	silentPool.watchLeak(busy)
+
+	// The idle sessions are signed out even if CloseTimeOut is 0
+	pool.Close()
+	assert.Eventually(t, func() bool { return service.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+
+	// The running query is not waited for, it fails once its transport is interrupted
+	done := make(chan error, 1)
+	go func() {
+		_, err := busy.Execute("YIELD 1")
+		done <- err
+	}()
+	assert.Eventually(t, busy.isBusy, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	silentPool.Close()
+	assert.True(t, time.Since(start) < time.Second)
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("The query is not interrupted by Close")
+	}
+	assert.Eventually(t, func() bool { return busy.isReleased() }, time.Second, time.Millisecond)
+}
+
+func TestPool_SpaceName(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	conf := GetDefaultConf()
+	conf.SpaceName = "nba"
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "nba", resp.GetSpaceName())
+	session.Release()
+
+	// The session is not handed out if the space does not exist
+	pool.conf.SpaceName = "not_exist"
+	_, err = pool.GetSession("root", "nebula")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Failed to use space not_exist")
+	}
+	assert.Eventually(t, func() bool { return service.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestPool_Ping(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	listener, silentHost := startSilentServer(t)
+	defer listener.Close()
+
+	pool, err := NewConnectionPool([]HostAddress{host, silentHost}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	start := time.Now()
+	errs := pool.Ping(100 * time.Millisecond)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[silentHost], ErrTimeout))
+}
+
+func TestPool_ConnExpiration(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+
+	for _, conf := range []PoolConfig{
+		{IdleTime: 50 * time.Millisecond, MaxConnPoolSize: 1, MinConnPoolSize: 1},
+		{MaxConnLifetime: 50 * time.Millisecond, MaxConnPoolSize: 1, MinConnPoolSize: 1},
+	} {
+		pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := pool.GetConnection()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Release(conn)
+		// The connection is reused before it expires
+		reused, err := pool.GetConnection()
+		assert.NoError(t, err)
+		assert.Equal(t, conn, reused)
+		pool.Release(reused)
+
+		time.Sleep(100 * time.Millisecond)
+		fresh, err := pool.GetConnection()
+		assert.NoError(t, err)
+		assert.True(t, conn != fresh)
+		assert.Equal(t, 1, pool.getServerWorkload(host))
+		pool.Release(fresh)
+		pool.Close()
+	}
+}
+
+func TestPool_RetireInUse(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf := PoolConfig{MaxConnLifetime: 50 * time.Millisecond, MaxConnPoolSize: 2}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection outlives MaxConnLifetime while in use, it is retired but not interrupted
+	time.Sleep(100 * time.Millisecond)
+	other, err := pool.GetConnection()
+	assert.NoError(t, err)
+	assert.True(t, conn.retired)
+	assert.False(t, other.retired)
+	assert.NoError(t, conn.ping(0))
+
+	// It is closed once released
+	pool.Release(conn)
+	assert.Equal(t, 0, pool.getIdleConnCount())
+	assert.Equal(t, 1, pool.getActiveConnCount())
+	assert.Equal(t, 1, pool.getServerWorkload(host))
+	pool.Release(other)
+	assert.Equal(t, 1, pool.getIdleConnCount())
+}
+
+func TestPool_Hooks(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) func(info ConnectionInfo) {
+		return func(info ConnectionInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, host, info.Host)
+			assert.False(t, info.CreatedAt.IsZero())
+			events = append(events, event)
+		}
+	}
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	conf.OnAcquire = record("acquire")
+	conf.OnRelease = record("release")
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Release(conn)
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Release()
+	_, err = pool.GetSession("root", "wrong")
+	assert.Error(t, err)
+
+	// A connection handed over to a waiter
+	conn, err = pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pool.Release(conn)
+	}()
+	waited, err := pool.GetConnectionWithContext(context.Background())
+	if assert.NoError(t, err) {
+		pool.Release(waited)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"acquire", "release", "acquire", "release", "acquire", "release",
+		"acquire", "release", "acquire", "release",
+	}, events)
+}
+
+func TestPool_ValidateOnCreate(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	badService := testutil.NewFakeGraphService()
+	badService.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR, ErrorMsg: []byte("SpaceNotFound")}, nil
+	}
+	stopBad, badHost := startFakeServer(t, badService)
+	defer stopBad()
+
+	log := &recordLogger{}
+	conf := GetDefaultConf()
+	conf.Logger = log
+	conf.SpaceName = "nba"
+	conf.ValidateOnCreate = true
+	conf.ValidateUsername = "root"
+	conf.ValidatePassword = "nebula"
+	// The host without the space is marked as unhealthy
+	pool, err := NewConnectionPool([]HostAddress{host, badHost}, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, pool.hosts[host].healthy)
+	assert.False(t, pool.hosts[badHost].healthy)
+	if assert.Len(t, log.warnings, 1) {
+		assert.Contains(t, log.warnings[0], "failed the validation")
+	}
+	pool.Close()
+	// The throwaway sessions are signed out
+	assert.Eventually(t, func() bool {
+		return service.SessionCount() == 0 && badService.SessionCount() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Wrong credentials fail all hosts
+	conf.ValidatePassword = "wrong"
+	_, err = NewConnectionPool([]HostAddress{host, badHost}, conf, nil)
+	assert.Error(t, err)
+}
+
+func TestPool_ResolveAddresses(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	records := map[string][]string{"graphd": {"127.0.0.1", "127.0.0.2"}}
+	var mu sync.Mutex
+	lookupHost = func(host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ips, ok := records[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	defer func() { lookupHost = net.LookupHost }()
+
+	conf := GetDefaultConf()
+	conf.ResolveAllIPs = true
+	conf.ResolveInterval = 20 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{{Host: "graphd", Port: host.Port}}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, []HostAddress{host, {Host: "127.0.0.2", Port: host.Port}}, pool.addresses)
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Release(conn)
+
+	// The second IP disappears from DNS, the idle connection to it is closed
+	mu.Lock()
+	records["graphd"] = []string{"127.0.0.2"}
+	mu.Unlock()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return len(pool.addresses) == 1 && pool.idleConnectionQueue.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Only the first IP is used by default, and nothing is resolved if resolution is disabled
+	addresses, err := resolveAddresses([]HostAddress{{Host: "graphd", Port: 1}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []HostAddress{{Host: "127.0.0.2", Port: 1}}, addresses)
+	conf = GetDefaultConf()
+	conf.DisableResolution = true
+	localhost := HostAddress{Host: "localhost", Port: host.Port}
+	literalPool, err := NewConnectionPool([]HostAddress{localhost}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer literalPool.Close()
+	assert.Equal(t, []HostAddress{localhost}, literalPool.addresses)
+}
+
+func TestPool_DuplicateHosts(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	lookupHost = func(name string) ([]string, error) {
+		if name == "graphd" {
+			return []string{host.Host}, nil
+		}
+		return net.LookupHost(name)
+	}
+	defer func() { lookupHost = net.LookupHost }()
+	log := &recordLogger{}
+	conf := GetDefaultConf()
+	conf.Logger = log
+	// graphd resolves to the same IP
+	pool, err := NewConnectionPool([]HostAddress{host, {Host: "graphd", Port: host.Port}, host}, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []HostAddress{host}, pool.addresses)
+	duplicates := 0
+	for _, warning := range log.warnings {
+		if strings.Contains(warning, "is listed more than once") {
+			duplicates++
+		}
+	}
+	assert.Equal(t, 2, duplicates)
+	pool.Close()
+
+	log = &recordLogger{}
+	conf.Logger = log
+	conf.AllowDuplicateHosts = true
+	pool, err = NewConnectionPool([]HostAddress{host, host}, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, []HostAddress{{Host: host.Host, Port: host.Port, Weight: 2}}, pool.addresses)
+	assert.Empty(t, log.warnings)
+}
+
+func TestPool_GetConnectionWithContext(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	conf.AcquireTimeout = 50 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn, err := pool.GetConnectionWithContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// GetConnection waits for AcquireTimeout
+	start := time.Now()
+	_, err = pool.GetConnection()
+	assert.True(t, errors.Is(err, ErrPoolExhausted))
+	assert.True(t, errors.Is(err, ErrPoolFull))
+	assert.True(t, time.Since(start) >= conf.AcquireTimeout)
+	_, err = pool.GetSession("root", "nebula")
+	assert.True(t, errors.Is(err, ErrPoolExhausted))
+	// Or fails at once
+	pool.conf.AcquireTimeout = -1
+	start = time.Now()
+	_, err = pool.GetConnection()
+	assert.True(t, errors.Is(err, ErrPoolFull))
+	assert.False(t, errors.Is(err, ErrPoolExhausted))
+	assert.True(t, time.Since(start) < conf.AcquireTimeout)
+	pool.conf.AcquireTimeout = 0
+
+	// The waiter gives up at its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetConnectionWithContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 0, pool.waiters.Len())
+
+	// The waiters are served in the order they started waiting
+	const waiterCount = 5
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := 0; i < waiterCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := pool.GetConnectionWithContext(context.Background())
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			pool.Release(conn)
+		}(i)
+		// Start the next waiter only after this one is queued
+		assert.Eventually(t, func() bool {
+			pool.rwLock.RLock()
+			defer pool.rwLock.RUnlock()
+			return pool.waiters.Len() == i+1
+		}, time.Second, time.Millisecond)
+	}
+	pool.Release(conn)
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+
+	// Closing the pool wakes up the waiters
+	conn, err = pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.GetConnectionWithContext(context.Background())
+		done <- err
+	}()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return pool.waiters.Len() == 1
+	}, time.Second, time.Millisecond)
+	pool.Close()
+	assert.True(t, errors.Is(<-done, ErrPoolClosed))
+}
+
+func TestPool_Stats(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MinConnPoolSize = 2
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	stats := pool.Stats()
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, host, stats[0].Host)
+		assert.Equal(t, int64(0), stats[0].Queries)
+		assert.False(t, stats[0].Active)
+		assert.True(t, stats[0].LastUsed.IsZero())
+	}
+
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	start := time.Now()
+	_, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	service.QueueErrorCodes(graph.ErrorCode_E_SYNTAX_ERROR)
+	_, err = session.Execute("YIELD")
+	assert.NoError(t, err)
+
+	stats = pool.Stats()
+	if assert.Len(t, stats, 2) {
+		active := stats[0]
+		assert.True(t, active.Active)
+		assert.Equal(t, host, active.Host)
+		assert.Equal(t, int64(2), active.Queries)
+		var execErr *ExecutionError
+		if assert.True(t, errors.As(active.LastError, &execErr)) {
+			assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, execErr.ErrorCode)
+		}
+		assert.False(t, active.LastUsed.Before(start))
+		assert.True(t, active.BytesRead > 0)
+		assert.True(t, active.BytesWritten > 0)
+		assert.False(t, stats[1].Active)
+	}
+}
+
+func TestPool_ReleaseResetsTimeout(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		time.Sleep(50 * time.Millisecond)
+		sessionID := int64(100)
+		return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &sessionID}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	conf.ExecTimeOut = time.Second
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connection is handed over to the waiting caller without a ping, which would set the timeout too
+	waiting := make(chan *connection)
+	go func() {
+		next, err := pool.GetConnectionWithContext(context.Background())
+		assert.NoError(t, err)
+		waiting <- next
+	}()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return pool.waiters.Len() == 1
+	}, time.Second, time.Millisecond)
+	// A timeout left behind by the previous user
+	conn.sock.SetTimeout(10 * time.Millisecond)
+	pool.release(conn)
+	next := <-waiting
+	assert.Same(t, conn, next)
+	_, err = next.authenticate("root", "nebula")
+	assert.NoError(t, err)
+	pool.release(next)
+}
+
+func TestPool_InitParallelism(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	var mu sync.Mutex
+	dialing, maxDialing, dials := 0, 0, 0
+	conf := GetDefaultConf()
+	conf.MinConnPoolSize = 10
+	conf.InitParallelism = 5
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		mu.Lock()
+		dials++
+		// The first dial is the reachability check, the eighth and tenth of the pool fail
+		n := dials
+		dialing++
+		if dialing > maxDialing {
+			maxDialing = dialing
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			dialing--
+			mu.Unlock()
+		}()
+		time.Sleep(50 * time.Millisecond)
+		if n == 9 || n == 11 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+
+	_, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "8 of 10 connections opened, 2 failed")
+		assert.Contains(t, err.Error(), "connection refused")
+	}
+
+	mu.Lock()
+	dials = 100
+	mu.Unlock()
+	start := time.Now()
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	// The check and two rounds of five dials
+	assert.True(t, time.Since(start) < 400*time.Millisecond)
+	assert.Equal(t, 10, pool.getIdleConnCount())
+	assert.Equal(t, 10, pool.getServerWorkload(host))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 5, maxDialing)
+}
+
+func TestPool_SessionLeak(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	log := &recordLogger{}
+	conf := GetDefaultConf()
+	conf.SessionLeakThreshold = 50 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	warnings := func() []string {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		return append([]string(nil), log.warnings...)
+	}
+
+	released, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	released.Release()
+	_, err = pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The idle sessions of a session pool are not reported
+	sessionPool, err := NewSessionPool(pool, SessionPoolConfig{Username: "root", Password: "nebula"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sessionPool.Execute("YIELD 1")
+	assert.NoError(t, err)
+
+	time.Sleep(150 * time.Millisecond)
+	if assert.Len(t, warnings(), 1) {
+		assert.Contains(t, warnings()[0], "has not been released in 50ms")
+		assert.Contains(t, warnings()[0], "TestPool_SessionLeak")
+	}
+
+	// The leaked session and the one of the session pool
+	assert.Equal(t, 2, pool.SignoutAll())
+	assert.Eventually(t, func() bool { return service.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, pool.SignoutAll())
+	assert.Equal(t, 0, pool.getActiveConnCount())
+
+	// The session pool signs in a new session in place of the released one
+	_, err = sessionPool.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sessionPool.getIdleSessionCount())
+	assert.Equal(t, 1, service.SessionCount())
+}
+
+func TestPool_Clone(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.Spaces = []string{"nba", "test"}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.SpaceName = "nba"
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	clone, err := pool.Clone(WithSpace("test", VIDTypeString), WithPoolSize(0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := clone.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", resp.GetSpaceName())
+	assert.Equal(t, "nba", pool.conf.SpaceName)
+	assert.Equal(t, 0, pool.getActiveConnCount())
+	session.Release()
+
+	// Closing the clone leaves the original usable
+	clone.Close()
+	session, err = pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Release()
+}
+
+func TestPool_ContextDeadline(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		time.Sleep(400 * time.Millisecond)
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	conf.ExecTimeOut = time.Second
+	conf.AcquireTimeout = time.Second
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The deadline comes before AcquireTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = pool.GetSessionWithContext(ctx, "root", "nebula")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(err, ErrPoolExhausted))
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+
+	// Waiting for the connection and executing share one budget of 200ms,
+	// 100ms for the connection leaves too little for the query of 400ms
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		pool.release(conn)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	session, err := pool.GetSessionWithContext(ctx, "root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	_, err = session.ExecuteWithContext(ctx, "YIELD 1")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 200*time.Millisecond)
+	assert.True(t, elapsed < 350*time.Millisecond, elapsed)
+
+	// A query in the budget succeeds
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = session.ExecuteWithContext(ctx, "YIELD 1")
+	assert.NoError(t, err)
+}
+
+func TestCapTimeout(t *testing.T) {
+	assert.Equal(t, time.Second, capTimeout(context.Background(), time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	assert.Equal(t, time.Second, capTimeout(ctx, time.Second))
+	assert.True(t, capTimeout(ctx, 0) > 50*time.Second)
+	assert.True(t, capTimeout(ctx, time.Hour) <= time.Minute)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, time.Second, capTimeout(ctx, time.Second))
+	ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	assert.Equal(t, time.Nanosecond, capTimeout(ctx, time.Second))
+}
+
+func TestPool_TestOnBorrow(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	var mu sync.Mutex
+	var socks []net.Conn
+	conf := GetDefaultConf()
+	conf.TestOnBorrow = true
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err == nil {
+			mu.Lock()
+			socks = append(socks, conn)
+			mu.Unlock()
+		}
+		return conn, err
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(conn)
+	// The idle connection dies silently
+	mu.Lock()
+	for _, sock := range socks {
+		sock.Close()
+	}
+	mu.Unlock()
+
+	next, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotSame(t, conn, next)
+	assert.False(t, conn.isOpen())
+	_, err = next.authenticate("root", "nebula")
+	assert.NoError(t, err)
+	pool.release(next)
+	assert.Equal(t, 1, pool.getIdleConnCount())
+}
+
+func TestPool_TestOnBorrowWithoutLock(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+	conf := GetDefaultConf()
+	conf.TimeOut = 500 * time.Millisecond
+	conf.MinConnPoolSize = 1
+	conf.TestOnBorrow = true
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	done := make(chan *connection, 1)
+	go func() {
+		conn, err := pool.GetConnection()
+		assert.NoError(t, err)
+		done <- conn
+	}()
+	// The pool could be used while the idle connection is pinged
+	start := time.Now()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return pool.idleConnectionQueue.Len() == 0
+	}, time.Second, time.Millisecond)
+	assert.True(t, time.Since(start) < conf.TimeOut/2)
+
+	// The server never answers, so a new connection is handed out in place of the idle one
+	conn := <-done
+	if assert.NotNil(t, conn) {
+		assert.False(t, conn.untested)
+		assert.Equal(t, 1, pool.getServerWorkload(host))
+		pool.release(conn)
+	}
+}
+
+func TestPool_CancelAuthentication(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	var slow atomic.Value
+	slow.Store(true)
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		if slow.Load().(bool) {
+			time.Sleep(300 * time.Millisecond)
+		}
+		id := int64(100)
+		return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &id}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ExecTimeOut = time.Second
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// A cancellation without a deadline aborts the hung sign in too
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = pool.GetSessionWithContext(ctx, "root", "nebula")
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Less(t, int64(time.Since(start)), int64(250*time.Millisecond))
+
+	// The aborted connection is not handed out again
+	slow.Store(false)
+	session, err := pool.GetSession("root", "nebula")
+	if assert.NoError(t, err) {
+		_, err = session.Execute("YIELD 1")
+		assert.NoError(t, err)
+		session.Release()
+	}
+}
+
+func TestPool_GetSessionFromHost(t *testing.T) {
+	stop1, host1 := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop1()
+	stop2, host2 := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop2()
+	conf := GetDefaultConf()
+	conf.MinConnPoolSize = 0
+	conf.MaxConnPoolSize = 2
+	pool, err := NewConnectionPool([]HostAddress{host1, host2}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Every session goes to the host, the idle connections to it are reused
+	for i := 0; i < 2; i++ {
+		var sessions []*Session
+		for j := 0; j < 2; j++ {
+			session, err := pool.GetSessionFromHost(host2, "root", "nebula")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, host2, session.GetHostAddress())
+			sessions = append(sessions, session)
+		}
+		for _, session := range sessions {
+			session.Release()
+		}
+	}
+	assert.Equal(t, 2, pool.getServerWorkload(host2))
+
+	// A host name is resolved
+	lookupHost = func(host string) ([]string, error) {
+		if host == "graphd2" {
+			return []string{host2.Host}, nil
+		}
+		return net.LookupHost(host)
+	}
+	defer func() { lookupHost = net.LookupHost }()
+	session, err := pool.GetSessionFromHost(HostAddress{Host: "graphd2", Port: host2.Port}, "root", "nebula")
+	if assert.NoError(t, err) {
+		assert.Equal(t, host2, session.GetHostAddress())
+		session.Release()
+	}
+
+	// The pool is full without an idle connection to the host
+	session, err = pool.GetSessionFromHost(host1, "root", "nebula")
+	if assert.NoError(t, err) {
+		assert.Equal(t, host2, session.GetHostAddress())
+		session.Release()
+	}
+
+	// An unhealthy or unknown host falls back to another one
+	conf.MaxConnPoolSize = 10
+	conf.ConnectionFactory = &fakeConnectionFactory{ConnectionFactory: NewDefaultConnectionFactory(conf), down: map[HostAddress]bool{host2: true}}
+	pool2, err := NewConnectionPool([]HostAddress{host1, host2}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool2.Close()
+	for _, addr := range []HostAddress{host2, {Host: "127.0.0.2", Port: host2.Port}, {Host: "unknown", Port: 1}} {
+		session, err := pool2.GetSessionFromHost(addr, "root", "nebula")
+		if assert.NoError(t, err) {
+			assert.Equal(t, host1, session.GetHostAddress())
+			session.Release()
+		}
+	}
+}
+
+func TestPool_AuthFailureServesWaiter(t *testing.T) {
+	// The sign-in of the wrong user is slow, so the other caller waits for the only connection meanwhile
+	service := testutil.NewFakeGraphService()
+	nextID := int64(1)
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		if username == "wrong" {
+			time.Sleep(100 * time.Millisecond)
+			return &graph.AuthResponse{ErrorCode: graph.ErrorCode_E_BAD_USERNAME_PASSWORD, ErrorMsg: []byte("Bad username/password")}
+		}
+		sessionID := nextID
+		nextID++
+		return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &sessionID}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	conf.AcquireTimeout = time.Second
+	var released int32
+	conf.OnRelease = func(info ConnectionInfo) {
+		atomic.AddInt32(&released, 1)
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	failed := make(chan error, 1)
+	go func() {
+		_, err := pool.GetSession("wrong", "nebula")
+		failed <- err
+	}()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return pool.getActiveConnCount() == 1
+	}, time.Second, time.Millisecond)
+	session, err := pool.GetSession("root", "nebula")
+	if assert.NoError(t, err) {
+		session.Release()
+	}
+	assert.True(t, errors.Is(<-failed, ErrAuthFailed))
+	assert.True(t, atomic.LoadInt32(&released) >= 1)
+}
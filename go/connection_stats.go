@@ -0,0 +1,115 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats is a snapshot of the statistics of a pooled connection, kept since the connection is
+// created and across the transport being reopened
+type ConnectionStats struct {
+	Host HostAddress
+	// The tier of Host, ConnectionPool.ActiveTier tells which tier new connections are opened to
+	Tier HostTier
+	// Whether the connection is used by a session, otherwise it is idle in the pool
+	Active bool
+	// Number of queries executed, including the failed ones
+	Queries int64
+	// The error of the last failed query, either an RPC error or an *ExecutionError, nil if none has failed
+	LastError error
+	// The time the last query finished, zero value if there has been no query
+	LastUsed time.Time
+	// Bytes read from and written to the network, including the TLS overhead if TLS is used
+	BytesRead    int64
+	BytesWritten int64
+}
+
+// Counters of a connection, updated with atomic operations so reading them does not wait for a running RPC.
+// The int64 fields are kept first to be 64-bit aligned on 32-bit platforms.
+type connStats struct {
+	queries      int64
+	bytesRead    int64
+	bytesWritten int64
+	// Unix time in nanoseconds
+	lastUsed int64
+	// Holds a storedError, atomic.Value requires storing the same type every time
+	lastError atomic.Value
+}
+
+type storedError struct {
+	err error
+}
+
+// Count a query and remember its error if it failed
+func (s *connStats) observeQuery(err error) {
+	atomic.AddInt64(&s.queries, 1)
+	atomic.StoreInt64(&s.lastUsed, time.Now().UnixNano())
+	if err != nil {
+		s.lastError.Store(storedError{err})
+	}
+}
+
+// Return a snapshot of the counters
+func (s *connStats) snapshot() ConnectionStats {
+	stats := ConnectionStats{
+		Queries:      atomic.LoadInt64(&s.queries),
+		BytesRead:    atomic.LoadInt64(&s.bytesRead),
+		BytesWritten: atomic.LoadInt64(&s.bytesWritten),
+	}
+	if lastUsed := atomic.LoadInt64(&s.lastUsed); lastUsed != 0 {
+		stats.LastUsed = time.Unix(0, lastUsed)
+	}
+	if stored, ok := s.lastError.Load().(storedError); ok {
+		stats.LastError = stored.err
+	}
+	return stats
+}
+
+// A network connection counting the bytes going through it
+type countingConn struct {
+	net.Conn
+	stats *connStats
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.stats.bytesRead, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.stats.bytesWritten, int64(n))
+	return n, err
+}
+
+// Stats returns the statistics of every connection in the pool, the active ones first.
+// It only takes the read lock of the pool and does not wait for the running queries.
+func (pool *ConnectionPool) Stats() []ConnectionStats {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	stats := make([]ConnectionStats, 0, pool.activeConnectionQueue.Len()+pool.idleConnectionQueue.Len())
+	for ele := pool.activeConnectionQueue.Front(); ele != nil; ele = ele.Next() {
+		stats = append(stats, pool.getStats(ele.Value.(*connection), true))
+	}
+	for ele := pool.idleConnectionQueue.Front(); ele != nil; ele = ele.Next() {
+		stats = append(stats, pool.getStats(ele.Value.(*connection), false))
+	}
+	return stats
+}
+
+// Must be called with the lock held
+func (pool *ConnectionPool) getStats(conn *connection, active bool) ConnectionStats {
+	stats := conn.stats.snapshot()
+	stats.Host = conn.severAddress
+	stats.Tier = pool.tierOf(conn)
+	stats.Active = active
+	return stats
+}
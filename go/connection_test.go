@@ -0,0 +1,566 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestIsCompressionMismatch(t *testing.T) {
+	// An uncompressed response read through a zlib transport
+	buf := thrift.NewMemoryBuffer()
+	buf.WriteString("uncompressed response")
+	transport, err := thrift.NewZlibTransport(buf, zlib.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = transport.Read(make([]byte, 16))
+	assert.True(t, isCompressionMismatch(err))
+	assert.False(t, isCompressionMismatch(thrift.NewTransportException(thrift.END_OF_FILE, "EOF")))
+}
+
+// Build a response with columns player and age and the given number of rows.
+// String values are not used since the generated union writer does not count them as set.
+func genLargeResp(rowSize int) *graph.ExecutionResponse {
+	rows := make([]*nebula.Row, 0, rowSize)
+	for i := 0; i < rowSize; i++ {
+		vertex := &nebula.Vertex{
+			Vid:  nebula.VertexID(fmt.Sprintf("player%d", i)),
+			Tags: []*nebula.Tag{{Name: []byte("player")}},
+		}
+		rows = append(rows, &nebula.Row{Values: []*nebula.Value{
+			{VVal: vertex}, intValue(int64(i % 100)),
+		}})
+	}
+	return &graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("player"), []byte("age")},
+			Rows:        rows,
+		},
+	}
+}
+
+// Count the bytes of a serialized response written through the transport
+func writeResp(b *testing.B, resp *graph.ExecutionResponse, useCompression bool, protocol Protocol) int {
+	buf := thrift.NewMemoryBuffer()
+	var transport thrift.Transport = buf
+	if useCompression {
+		zlibTransport, err := thrift.NewZlibTransport(buf, zlib.BestSpeed)
+		if err != nil {
+			b.Fatal(err)
+		}
+		transport = zlibTransport
+	}
+	if err := resp.Write(protocol.factory().GetProtocol(transport)); err != nil {
+		b.Fatal(err)
+	}
+	if err := transport.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Len()
+}
+
+func BenchmarkTransport(b *testing.B) {
+	resp := genLargeResp(10000)
+	for _, protocol := range []Protocol{ProtocolBinary, ProtocolCompact} {
+		for _, useCompression := range []bool{false, true} {
+			b.Run(fmt.Sprintf("Protocol=%s/UseCompression=%t", protocol, useCompression), func(b *testing.B) {
+				var n int
+				for i := 0; i < b.N; i++ {
+					n = writeResp(b, resp, useCompression, protocol)
+				}
+				b.ReportMetric(float64(n), "bytes/resp")
+			})
+		}
+	}
+}
+
+// Return a dialer serving the handler over an in-memory pipe for every connection
+func pipeDialer(handler graph.GraphService, protocol Protocol, transport TransportType, dialed *int) Dialer {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		*dialed++
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			sock, err := thrift.NewSocket(thrift.SocketConn(server))
+			if err != nil {
+				return
+			}
+			var trans thrift.Transport = sock
+			if transport == TransportFramed {
+				trans = thrift.NewFramedTransport(sock)
+			}
+			prot := protocol.factory().GetProtocol(trans)
+			if transport == TransportHeader {
+				prot = thrift.NewHeaderProtocol(sock)
+			}
+			processor := graph.NewGraphServiceProcessor(handler)
+			for {
+				if keepOpen, err := thrift.Process(processor, prot, prot); err != nil || !keepOpen {
+					return
+				}
+			}
+		}()
+		return client, nil
+	}
+}
+
+func TestConnection_Dialer(t *testing.T) {
+	dialed := 0
+	conf := GetDefaultConf()
+	conf.Dialer = pipeDialer(testutil.NewFakeGraphService(), ProtocolBinary, TransportBuffered, &dialed)
+	// Nothing is listening on the address, all connections go through the pipe
+	pool, err := NewConnectionPool([]HostAddress{{Host: "127.0.0.1", Port: 1}}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.Equal(t, 2, dialed)
+}
+
+func TestConnection_IPv6(t *testing.T) {
+	var dialedAddress string
+	conf := GetDefaultConf()
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		dialedAddress = address
+		return nil, fmt.Errorf("refused")
+	}
+	host := HostAddress{Host: "::1", Port: 3699}
+	assert.Error(t, newConnection(host).open(host, conf))
+	assert.Equal(t, "[::1]:3699", dialedAddress)
+
+	// Connect to a real IPv6 listener if the loopback interface supports it
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not supported")
+	}
+	defer listener.Close()
+	host = HostAddress{Host: "::1", Port: listener.Addr().(*net.TCPAddr).Port}
+	conn := newConnection(host)
+	assert.NoError(t, conn.open(host, GetDefaultConf()))
+	conn.close()
+}
+
+func TestConnection_NetDialer(t *testing.T) {
+	conf := GetDefaultConf()
+	assert.Nil(t, newNetDialer(conf).LocalAddr)
+	assert.Equal(t, 15*time.Second, newNetDialer(conf).KeepAlive)
+	conf.TCPKeepAlive = 0
+	assert.True(t, newNetDialer(conf).KeepAlive < 0)
+	conf.LocalAddr = "127.0.0.2"
+	assert.Equal(t, &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}, newNetDialer(conf).LocalAddr)
+
+	// The connection comes from the local address
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	remote := make(chan net.Addr, 1)
+	go func() {
+		if accepted, err := listener.Accept(); err == nil {
+			remote <- accepted.RemoteAddr()
+			accepted.Close()
+		}
+	}()
+	host := HostAddress{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+	conn := newConnection(host)
+	if err := conn.open(host, conf); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	assert.Equal(t, "127.0.0.2", (<-remote).(*net.TCPAddr).IP.String())
+}
+
+func TestSetSocketBuffers(t *testing.T) {
+	conf := GetDefaultConf()
+	conf.SocketRecvBuf = 1 << 20
+	conf.SocketSendBuf = 1 << 20
+	// Other connections than TCP are left as they are
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	assert.NoError(t, setSocketBuffers(client, conf))
+
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conn := newConnection(host)
+	if assert.NoError(t, conn.open(host, conf)) {
+		_, err := conn.authenticate("root", "nebula")
+		assert.NoError(t, err)
+		conn.close()
+	}
+}
+
+// Loopback has almost no round trip time, the difference of the buffer sizes shows up if a delay is
+// added to it, e.g. by "tc qdisc add dev lo root netem delay 50ms" on Linux.
+func BenchmarkSocketBuffers(b *testing.B) {
+	resp := genLargeResp(10000)
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		return resp, nil
+	}
+	server, err := testutil.StartServer(service)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer server.Stop()
+	host := HostAddress{Host: server.Host, Port: server.Port}
+
+	for _, size := range []int{0, 64 << 10, 4 << 20} {
+		b.Run(fmt.Sprintf("SocketRecvBuf=%d", size), func(b *testing.B) {
+			conf := GetDefaultConf()
+			conf.SocketRecvBuf = size
+			conn := newConnection(host)
+			if err := conn.open(host, conf); err != nil {
+				b.Fatal(err)
+			}
+			defer conn.close()
+			authResp, err := conn.authenticate("root", "nebula")
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := conn.execute(authResp.GetSessionID(), "GO FROM 1 OVER follow"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestConnection_Protocol(t *testing.T) {
+	conf := GetDefaultConf()
+	conf.Protocol = ProtocolCompact
+	dialed := 0
+	conf.Dialer = pipeDialer(testutil.NewFakeGraphService(), ProtocolCompact, TransportBuffered, &dialed)
+	conn := newConnection(HostAddress{Host: "127.0.0.1", Port: 1})
+	if err := conn.open(conn.severAddress, conf); err != nil {
+		t.Fatal(err)
+	}
+	_, err := conn.authenticate("root", "nebula")
+	assert.NoError(t, err)
+	conn.close()
+
+	// The fake server speaks the binary protocol
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf.Dialer = nil
+	err = newConnection(host).open(host, conf)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the server may not speak the compact protocol")
+	}
+}
+
+func TestConnection_Transport(t *testing.T) {
+	conf := GetDefaultConf()
+	conf.Transport = TransportFramed
+	// The server may wait for the rest of a message it could not decode
+	conf.TimeOut = 500 * time.Millisecond
+	dialed := 0
+	conf.Dialer = pipeDialer(testutil.NewFakeGraphService(), ProtocolBinary, TransportFramed, &dialed)
+	conn := newConnection(HostAddress{Host: "127.0.0.1", Port: 1})
+	if err := conn.open(conn.severAddress, conf); err != nil {
+		t.Fatal(err)
+	}
+	_, err := conn.authenticate("root", "nebula")
+	assert.NoError(t, err)
+	conn.close()
+
+	// The fake server uses the buffered transport
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf.Dialer = nil
+	err = newConnection(host).open(host, conf)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the server may not speak the binary protocol over the framed transport")
+	}
+
+	// And the other way around
+	conf = GetDefaultConf()
+	conf.TimeOut = 500 * time.Millisecond
+	conf.Dialer = pipeDialer(testutil.NewFakeGraphService(), ProtocolBinary, TransportFramed, &dialed)
+	conn = newConnection(HostAddress{Host: "127.0.0.1", Port: 1})
+	if err := conn.open(conn.severAddress, conf); err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.authenticate("root", "nebula")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "it may expect another transport or protocol")
+	}
+}
+
+func TestConnection_HeaderTransport(t *testing.T) {
+	// A server reading the headers every connection sends
+	headers := make(chan map[string]string, 10)
+	dialer := func(ctx context.Context, address string) (net.Conn, error) {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			sock, err := thrift.NewSocket(thrift.SocketConn(server))
+			if err != nil {
+				return
+			}
+			trans := thrift.NewHeaderTransport(sock)
+			prot := thrift.NewHeaderProtocol(trans)
+			processor := graph.NewGraphServiceProcessor(testutil.NewFakeGraphService())
+			for {
+				keepOpen, err := thrift.Process(processor, prot, prot)
+				if err != nil || !keepOpen {
+					return
+				}
+				headers <- trans.ReadHeaders()
+			}
+		}()
+		return client, nil
+	}
+
+	for _, protocol := range []Protocol{ProtocolBinary, ProtocolCompact} {
+		for _, useCompression := range []bool{false, true} {
+			conf := GetDefaultConf()
+			conf.TimeOut = 500 * time.Millisecond
+			conf.Transport = TransportHeader
+			conf.TransportHeaders = map[string]string{"route": "graphd-1"}
+			conf.Protocol = protocol
+			conf.UseCompression = useCompression
+			conf.Dialer = dialer
+			conn := newConnection(HostAddress{Host: "127.0.0.1", Port: 1})
+			if err := conn.open(conn.severAddress, conf); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := conn.authenticate("root", "nebula")
+			if assert.NoError(t, err, protocol) {
+				assert.Equal(t, graph.ErrorCode_SUCCEEDED, resp.GetErrorCode())
+			}
+			var buf bytes.Buffer
+			assert.NoError(t, conn.executeJsonTo(resp.GetSessionID(), "YIELD 1", &buf))
+			conn.close()
+			// The verification on open, authenticate and executeJson
+			for i := 0; i < 3; i++ {
+				assert.Equal(t, "graphd-1", (<-headers)["route"])
+			}
+		}
+	}
+
+	// The fake server uses the buffered transport
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf := GetDefaultConf()
+	conf.TimeOut = 500 * time.Millisecond
+	conf.Transport = TransportHeader
+	err := newConnection(host).open(host, conf)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the server may not speak the binary protocol over the header transport")
+	}
+}
+
+func TestConnection_ConcurrentExecute(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conn := newConnection(host)
+	if err := conn.open(host, GetDefaultConf()); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	authResp, err := conn.authenticate("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := authResp.GetSessionID()
+	if _, err := conn.execute(sessionID, "USE nba"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overlapping RPCs would mix up the requests and responses on the transport
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				resp, err := conn.execute(sessionID, "YIELD 1")
+				if assert.NoError(t, err) {
+					assert.Equal(t, "nba", string(resp.GetSpaceName()))
+				}
+				assert.NoError(t, conn.ping(0))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIsFatalError(t *testing.T) {
+	for _, tc := range []struct {
+		err   error
+		fatal bool
+	}{
+		{thrift.NewTransportException(thrift.END_OF_FILE, "EOF"), true},
+		{thrift.NewTransportException(thrift.TIMED_OUT, "i/o timeout"), true},
+		{thrift.NewProtocolExceptionWithType(thrift.INVALID_DATA, fmt.Errorf("invalid data")), true},
+		{wrapRPCError("Failed to execute", thrift.NewTransportException(thrift.NOT_OPEN, "not open"), TimeoutPhaseExecute), true},
+		{thrift.NewApplicationException(thrift.UNKNOWN_METHOD, "unknown method"), false},
+		{&ExecutionError{ErrorCode: graph.ErrorCode_E_SYNTAX_ERROR}, false},
+	} {
+		assert.Equal(t, tc.fatal, isFatalError(tc.err), tc.err.Error())
+	}
+}
+
+func TestConnection_Broken(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ExecTimeOut = 200 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed query leaves the connection usable
+	service.QueueErrorCodes(graph.ErrorCode_E_SYNTAX_ERROR)
+	_, err = session.Execute("YIELD")
+	assert.NoError(t, err)
+	assert.False(t, session.connection.isBroken())
+
+	// The late response of a timed out query must not be read by the next one
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		// The fake service is locked while sleeping, the next query waits for the rest of the sleep
+		if stmt == "slow" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SpaceName: []byte(stmt)}, nil
+	}
+	_, err = session.Execute("slow")
+	assert.True(t, isTimeout(err))
+	assert.True(t, session.connection.isBroken())
+	resp, err := session.Execute("fast")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "fast", resp.GetSpaceName())
+	}
+
+	// A broken connection is closed instead of going back to the pool
+	_, err = session.Execute("slow")
+	assert.True(t, isTimeout(err))
+	session.Release()
+	assert.Equal(t, 0, pool.getIdleConnCount())
+	assert.Equal(t, 0, pool.getActiveConnCount())
+}
+
+func TestConnection_MaxResponseBytes(t *testing.T) {
+	newService := func() *testutil.FakeGraphService {
+		service := testutil.NewFakeGraphService()
+		service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+			size := 10
+			if stmt == "large" {
+				size = 4096
+			}
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SpaceName: make([]byte, size)}, nil
+		}
+		return service
+	}
+	stop, host := startFakeServer(t, newService())
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxResponseBytes = 1024
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	_, err = session.Execute("small")
+	assert.NoError(t, err)
+	_, err = session.Execute("large")
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), err)
+	assert.True(t, session.connection.isBroken())
+	// The transport is reopened for the next query
+	resp, err := session.Execute("small")
+	if assert.NoError(t, err) {
+		assert.Len(t, resp.GetSpaceName(), 10)
+	}
+
+	// A frame is rejected by its header
+	conf.Transport = TransportFramed
+	dialed := 0
+	conf.Dialer = pipeDialer(newService(), ProtocolBinary, TransportFramed, &dialed)
+	conn := newConnection(HostAddress{Host: "127.0.0.1", Port: 1})
+	if err := conn.open(conn.severAddress, conf); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	auth, err := conn.authenticate("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.execute(auth.GetSessionID(), "large")
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), err)
+	assert.Less(t, conn.limiter.read, int64(1024))
+}
+
+func TestConnection_MaxFrameSize(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SpaceName: make([]byte, 4096)}, nil
+	}
+	conf := GetDefaultConf()
+	conf.Transport = TransportFramed
+	conf.MaxFrameSize = 1024
+	dialed := 0
+	conf.Dialer = pipeDialer(service, ProtocolBinary, TransportFramed, &dialed)
+	conn := newConnection(HostAddress{Host: "127.0.0.1", Port: 1})
+	if err := conn.open(conn.severAddress, conf); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	auth, err := conn.authenticate("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.execute(auth.GetSessionID(), "large")
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), err)
+	assert.Contains(t, err.Error(), "the response frame exceeds MaxFrameSize of 1024 bytes")
+	assert.True(t, conn.isBroken())
+
+	// The default is large enough
+	assert.Equal(t, math.MaxInt32, GetDefaultConf().getMaxFrameSize())
+}
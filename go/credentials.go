@@ -0,0 +1,70 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CredentialProvider returns the user to sign in, e.g. short-lived credentials from a secrets manager.
+// It must be safe for concurrent use.
+type CredentialProvider func() (username, password string, err error)
+
+// The last credentials which signed in successfully
+type credentials struct {
+	username string
+	password string
+}
+
+// GetSessionFromProvider creates a session with the credentials of PoolConfig.CredentialProvider.
+// The last credentials which succeeded are reused. If graphd rejects them, the provider is called again
+// for fresh ones, up to PoolConfig.MaxAuthRetries more times if they are rejected too.
+func (pool *ConnectionPool) GetSessionFromProvider() (*Session, error) {
+	provider := pool.conf.CredentialProvider
+	if provider == nil {
+		return nil, fmt.Errorf("Failed to get session: no CredentialProvider in the config")
+	}
+	if cred := pool.getCachedCredentials(); cred != nil {
+		session, err := pool.GetSession(cred.username, cred.password)
+		if err == nil || !errors.Is(err, ErrAuthFailed) {
+			return session, err
+		}
+		pool.log.Warn("The cached credentials are rejected, fetching new ones")
+		pool.setCachedCredentials(nil)
+	}
+
+	var err error
+	for i := 0; i <= pool.conf.MaxAuthRetries; i++ {
+		username, password, providerErr := provider()
+		if providerErr != nil {
+			return nil, fmt.Errorf("Failed to get credentials: %w", providerErr)
+		}
+		var session *Session
+		session, err = pool.GetSession(username, password)
+		if err == nil {
+			pool.setCachedCredentials(&credentials{username: username, password: password})
+			return session, nil
+		}
+		if !errors.Is(err, ErrAuthFailed) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+func (pool *ConnectionPool) getCachedCredentials() *credentials {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	return pool.credentials
+}
+
+func (pool *ConnectionPool) setCachedCredentials(cred *credentials) {
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	pool.credentials = cred
+}
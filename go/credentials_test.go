@@ -0,0 +1,66 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestPool_GetSessionFromProvider(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+
+	// The provider rotates the password, it returns the right one at the second call
+	var mu sync.Mutex
+	passwords := []string{"expired", "nebula", "expired", "expired"}
+	calls := 0
+	conf := GetDefaultConf()
+	conf.MaxAuthRetries = 1
+	conf.CredentialProvider = func() (string, string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls >= len(passwords) {
+			return "", "", errors.New("vault is sealed")
+		}
+		password := passwords[calls]
+		calls++
+		return "root", password, nil
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	session, err := pool.GetSessionFromProvider()
+	if assert.NoError(t, err) {
+		session.Release()
+	}
+	assert.Equal(t, 2, calls)
+	// The good credentials are cached
+	session, err = pool.GetSessionFromProvider()
+	if assert.NoError(t, err) {
+		session.Release()
+	}
+	assert.Equal(t, 2, calls)
+
+	// The cached credentials are rejected, so are the fresh ones out of the retries
+	pool.setCachedCredentials(&credentials{username: "root", password: "revoked"})
+	_, err = pool.GetSessionFromProvider()
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.Nil(t, pool.getCachedCredentials())
+	_, err = pool.GetSessionFromProvider()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "vault is sealed")
+	}
+}
@@ -0,0 +1,122 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvConfig is what ConfigFromEnv reads from the environment
+type EnvConfig struct {
+	Hosts    []HostAddress
+	Username string
+	Password string
+	// GetDefaultConf with the values of the optional variables
+	PoolConfig PoolConfig
+}
+
+// Look up an environment variable, replaced in tests
+var lookupEnv = os.LookupEnv
+
+// ConfigFromEnv reads the config from the environment variables:
+//
+//	NEBULA_HOSTS                 required, comma separated addresses parsed by ParseHostAddresses
+//	NEBULA_USER                  required
+//	NEBULA_PASSWORD              required, it could be set to an empty value
+//	NEBULA_SPACE                 PoolConfig.SpaceName
+//	NEBULA_TIMEOUT               PoolConfig.TimeOut, a duration parsed by time.ParseDuration, e.g. "3s"
+//	NEBULA_CONN_TIMEOUT          PoolConfig.ConnTimeOut
+//	NEBULA_EXEC_TIMEOUT          PoolConfig.ExecTimeOut
+//	NEBULA_IDLE_TIME             PoolConfig.IdleTime
+//	NEBULA_MAX_CONN_POOL_SIZE    PoolConfig.MaxConnPoolSize
+//	NEBULA_MIN_CONN_POOL_SIZE    PoolConfig.MinConnPoolSize
+//
+// The fields of unset variables keep the values of GetDefaultConf.
+// The error lists every missing required variable and every invalid value.
+func ConfigFromEnv() (*EnvConfig, error) {
+	envConf := &EnvConfig{PoolConfig: GetDefaultConf()}
+	conf := &envConf.PoolConfig
+	var missing, invalid []string
+
+	if hosts, ok := lookupEnv("NEBULA_HOSTS"); !ok || strings.TrimSpace(hosts) == "" {
+		missing = append(missing, "NEBULA_HOSTS")
+	} else if addresses, err := ParseHostAddresses(hosts); err != nil {
+		invalid = append(invalid, fmt.Sprintf("NEBULA_HOSTS: %s", err.Error()))
+	} else {
+		envConf.Hosts = addresses
+	}
+	if user, ok := lookupEnv("NEBULA_USER"); !ok || user == "" {
+		missing = append(missing, "NEBULA_USER")
+	} else {
+		envConf.Username = user
+	}
+	if password, ok := lookupEnv("NEBULA_PASSWORD"); !ok {
+		missing = append(missing, "NEBULA_PASSWORD")
+	} else {
+		envConf.Password = password
+	}
+	if space, ok := lookupEnv("NEBULA_SPACE"); ok {
+		conf.SpaceName = space
+	}
+
+	durations := []struct {
+		name  string
+		field *time.Duration
+	}{
+		{"NEBULA_TIMEOUT", &conf.TimeOut},
+		{"NEBULA_CONN_TIMEOUT", &conf.ConnTimeOut},
+		{"NEBULA_EXEC_TIMEOUT", &conf.ExecTimeOut},
+		{"NEBULA_IDLE_TIME", &conf.IdleTime},
+	}
+	for _, d := range durations {
+		value, ok := lookupEnv(d.name)
+		if !ok || value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			invalid = append(invalid, fmt.Sprintf("%s: %q is not a non-negative duration", d.name, value))
+			continue
+		}
+		*d.field = duration
+	}
+	sizes := []struct {
+		name  string
+		field *int
+	}{
+		{"NEBULA_MAX_CONN_POOL_SIZE", &conf.MaxConnPoolSize},
+		{"NEBULA_MIN_CONN_POOL_SIZE", &conf.MinConnPoolSize},
+	}
+	for _, s := range sizes {
+		value, ok := lookupEnv(s.name)
+		if !ok || value == "" {
+			continue
+		}
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			invalid = append(invalid, fmt.Sprintf("%s: %q is not a non-negative integer", s.name, value))
+			continue
+		}
+		*s.field = size
+	}
+
+	if len(missing) == 0 && len(invalid) == 0 {
+		return envConf, nil
+	}
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing environment variables %s", strings.Join(missing, ", ")))
+	}
+	if len(invalid) > 0 {
+		problems = append(problems, fmt.Sprintf("invalid environment variables %s", strings.Join(invalid, "; ")))
+	}
+	return nil, fmt.Errorf("Failed to read the config from the environment: %s", strings.Join(problems, ", "))
+}
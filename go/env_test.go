@@ -0,0 +1,68 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setEnv(env map[string]string) {
+	lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	defer func() { lookupEnv = os.LookupEnv }()
+
+	setEnv(map[string]string{
+		"NEBULA_HOSTS":              "127.0.0.1:9669, [::1]:9670",
+		"NEBULA_USER":               "root",
+		"NEBULA_PASSWORD":           "",
+		"NEBULA_SPACE":              "nba",
+		"NEBULA_TIMEOUT":            "3s",
+		"NEBULA_EXEC_TIMEOUT":       "500ms",
+		"NEBULA_MAX_CONN_POOL_SIZE": "20",
+	})
+	conf, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []HostAddress{{Host: "127.0.0.1", Port: 9669}, {Host: "::1", Port: 9670}}, conf.Hosts)
+	assert.Equal(t, "root", conf.Username)
+	assert.Equal(t, "", conf.Password)
+	assert.Equal(t, "nba", conf.PoolConfig.SpaceName)
+	assert.Equal(t, 3*time.Second, conf.PoolConfig.TimeOut)
+	assert.Equal(t, 500*time.Millisecond, conf.PoolConfig.ExecTimeOut)
+	assert.Equal(t, 20, conf.PoolConfig.MaxConnPoolSize)
+	// The unset ones keep the defaults
+	assert.Equal(t, GetDefaultConf().MinConnPoolSize, conf.PoolConfig.MinConnPoolSize)
+	assert.Equal(t, GetDefaultConf().ConnTimeOut, conf.PoolConfig.ConnTimeOut)
+
+	setEnv(map[string]string{
+		"NEBULA_USER":               "root",
+		"NEBULA_TIMEOUT":            "3",
+		"NEBULA_MIN_CONN_POOL_SIZE": "-1",
+	})
+	_, err = ConfigFromEnv()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing environment variables NEBULA_HOSTS, NEBULA_PASSWORD")
+		assert.Contains(t, err.Error(), `NEBULA_TIMEOUT: "3" is not a non-negative duration`)
+		assert.Contains(t, err.Error(), `NEBULA_MIN_CONN_POOL_SIZE: "-1" is not a non-negative integer`)
+	}
+
+	setEnv(map[string]string{"NEBULA_HOSTS": "localhost", "NEBULA_USER": "root", "NEBULA_PASSWORD": "nebula"})
+	_, err = ConfigFromEnv()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "NEBULA_HOSTS: Failed to parse address")
+	}
+}
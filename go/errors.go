@@ -0,0 +1,482 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// ErrSessionInvalid is returned when graphd does not recognize the session any more,
+// e.g. after graphd restarted. The caller should release the session and create a new one.
+var ErrSessionInvalid = errors.New("Session is invalid, please sign in again")
+
+// ErrUnsupportedByServer is returned when graphd does not implement the called RPC
+var ErrUnsupportedByServer = errors.New("Unsupported by server")
+
+// ErrUnsupportedType is returned when a value of a type this version of the protocol has no member for is asked for,
+// e.g. by ValueWrapper.AsGeography
+var ErrUnsupportedType = errors.New("Unsupported type")
+
+// ErrPoolClosed is returned when a connection or a session is requested from a closed pool
+var ErrPoolClosed = errors.New("Connection pool has been closed")
+
+// ErrPoolDraining is returned when a connection or a session is requested from a pool being drained, see ConnectionPool.Drain
+var ErrPoolDraining = errors.New("Connection pool is draining")
+
+// ErrCircuitOpen is returned when the circuit of every host is open, see PoolConfig.CircuitBreakerThreshold
+var ErrCircuitOpen = errors.New("Circuit is open for all hosts")
+
+// ErrPoolFull is returned when the pool has no idle connection and could not open more for MaxConnPoolSize
+var ErrPoolFull = errors.New("No valid connection in the idle queue and connection number has reached the pool capacity")
+
+// ErrEmptyStatement is returned without sending anything when the statement is empty or only has whitespaces
+var ErrEmptyStatement = errors.New("Statement is empty")
+
+// ErrStatementRead is returned by Session.ExecuteReader when the statement could not be read, nothing is sent
+// to graphd then. The error wraps the one of the reader too.
+var ErrStatementRead = errors.New("Failed to read the statement")
+
+// ErrSpaceNotFound is returned when a session could not be switched to a space because it does not exist
+var ErrSpaceNotFound = errors.New("Space not found")
+
+// ErrNoPermission is returned when a session could not be switched to a space the user has no access to
+var ErrNoPermission = errors.New("No permission")
+
+// ErrPermissionDenied is returned when graphd rejects a statement since the user lacks the privilege for it,
+// see PermissionError. It is the same error as ErrNoPermission.
+var ErrPermissionDenied = ErrNoPermission
+
+// ErrPoolExhausted is returned when no connection is released in PoolConfig.AcquireTimeout while the pool is full.
+// It matches ErrPoolFull too.
+var ErrPoolExhausted = errors.New("Connection pool is exhausted")
+
+// ErrAuthFailed is returned when graphd rejects the username or password
+var ErrAuthFailed = errors.New("Authentication failed")
+
+// ErrSessionLimitReached is returned when graphd rejects signing in since it has too many sessions,
+// it does not match ErrAuthFailed. See SessionPoolConfig.SessionLimitRetryPolicy to wait for one to be freed.
+var ErrSessionLimitReached = errors.New("Session limit reached")
+
+// ErrTransportClosed is returned when the transport to graphd could not be opened or is broken.
+// The statement could be retried on another connection.
+var ErrTransportClosed = errors.New("Transport is closed")
+
+// ErrTimeout is returned when graphd does not answer in time, the error is a *TimeoutError telling the phase
+var ErrTimeout = errors.New("Timed out")
+
+// ErrNoSpaceSelected is returned by Session.Execute when graphd rejects a query since the session is in no space.
+// Set PoolConfig.SpaceName or execute USE first.
+var ErrNoSpaceSelected = errors.New("No space is selected")
+
+// ErrResponseTooLarge is returned when a response is larger than PoolConfig.MaxResponseBytes,
+// or a frame of the framed transport is larger than PoolConfig.MaxFrameSize.
+// The rest of the response is not read, the connection is closed instead of being reused.
+var ErrResponseTooLarge = errors.New("Response is too large")
+
+// ErrVersionMismatch is returned when graphd rejects signing in since the client is not compatible with its version,
+// see VersionMismatchError. It does not match ErrAuthFailed.
+var ErrVersionMismatch = errors.New("Version mismatch")
+
+// An error keeping its own message and cause, it matches one of the errors above with errors.Is
+type kindError struct {
+	kind error
+	msg  string
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// TimeoutPhase is what timed out, see TimeoutError
+type TimeoutPhase int
+
+const (
+	// Opening the transport, the TLS handshake included, bound by PoolConfig.ConnTimeOut
+	TimeoutPhaseConnect TimeoutPhase = iota
+	// Signing in, bound by PoolConfig.ExecTimeOut
+	TimeoutPhaseAuth
+	// Executing a query or a ping, bound by PoolConfig.ExecTimeOut or the timeout given to the query
+	TimeoutPhaseExecute
+)
+
+func (phase TimeoutPhase) String() string {
+	switch phase {
+	case TimeoutPhaseConnect:
+		return "connect"
+	case TimeoutPhaseAuth:
+		return "auth"
+	case TimeoutPhaseExecute:
+		return "execute"
+	default:
+		return fmt.Sprintf("TimeoutPhase(%d)", int(phase))
+	}
+}
+
+// TimeoutError is returned when graphd does not answer in time, it matches ErrTimeout with errors.Is.
+// The phase tells which timeout to tune, e.g. a slow network to graphd times out opening the transport,
+// while a slow query times out executing.
+type TimeoutError struct {
+	Phase TimeoutPhase
+	msg   string
+	// The error of the transport
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return e.msg
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap an error returned by an RPC of the phase, it is a *TimeoutError or matches ErrTransportClosed
+// depending on the cause
+func wrapRPCError(msg string, err error, phase TimeoutPhase) error {
+	msg = fmt.Sprintf("%s, error: %s", msg, err.Error())
+	if isTimeout(err) {
+		return &TimeoutError{Phase: phase, msg: msg, Err: err}
+	}
+	var kind error
+	if isTransportClosed(err) {
+		kind = ErrTransportClosed
+	}
+	return &kindError{kind: kind, msg: msg, err: err}
+}
+
+// The default max bytes of the statement added to an error, see PoolConfig.StatementInErrorMaxLen
+const defaultStatementInErrorMaxLen = 256
+
+// Add the statement, truncated to maxLen bytes, to the error of executing it, the error still matches what err matches
+func withStatement(err error, stmt string, maxLen int) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{msg: fmt.Sprintf("%s, statement: %s", err.Error(), truncateStatement(stmt, maxLen)), err: err}
+}
+
+// Quote the statement, cut to maxLen bytes at a rune boundary with its full length if it is longer
+func truncateStatement(stmt string, maxLen int) string {
+	if len(stmt) <= maxLen {
+		return strconv.Quote(stmt)
+	}
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(stmt[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%q... (%d bytes)", stmt[:cut], len(stmt))
+}
+
+// Build the error of switching a session to the space with USE, nil if it succeeded.
+// It matches ErrSpaceNotFound or ErrNoPermission if graphd reports either of them.
+func useSpaceError(space string, resp *graph.ExecutionResponse, err error) error {
+	if err != nil {
+		return &kindError{msg: fmt.Sprintf("Failed to use space %s, error: %s", space, err.Error()), err: err}
+	}
+	if resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED {
+		return nil
+	}
+	var kind error
+	switch resp.GetErrorCode() {
+	case graph.ErrorCode_E_BAD_PERMISSION:
+		kind = ErrNoPermission
+	case graph.ErrorCode_E_EXECUTION_ERROR, graph.ErrorCode_E_SEMANTIC_ERROR:
+		// graphd reports SpaceNotFound or "Space not found" depending on the version
+		msg := strings.ToLower(strings.Replace(string(resp.GetErrorMsg()), " ", "", -1))
+		if strings.Contains(msg, "notfound") {
+			kind = ErrSpaceNotFound
+		}
+	}
+	return &kindError{
+		kind: kind,
+		msg:  fmt.Sprintf("Failed to use space %s, error: %s", space, resp.GetErrorMsg()),
+		err:  CheckResponse(resp),
+	}
+}
+
+// Check if graphd rejected the sign-in since it has too many sessions,
+// it reports "Too many sessions" or "Too many connections" depending on the version
+func isSessionLimitError(resp *graph.AuthResponse) bool {
+	msg := strings.ToLower(string(resp.GetErrorMsg()))
+	return strings.Contains(msg, "too many sessions") || strings.Contains(msg, "too many connections")
+}
+
+// ClientVersion is the version of graphd this client is built for
+const ClientVersion = "2.0.0"
+
+// VersionMismatchError is returned when graphd rejects signing in since the client is not compatible with it,
+// e.g. a client of another major version, or one connected to another cluster than intended.
+// It matches ErrVersionMismatch with errors.Is. Align the version of the client with the one of graphd.
+type VersionMismatchError struct {
+	ClientVersion string
+	// The version graphd reports in its error message, empty if it reports none
+	ServerVersion string
+	ErrorMsg      string
+}
+
+func (e *VersionMismatchError) Error() string {
+	server := "the server"
+	if e.ServerVersion != "" {
+		server = "the server version " + e.ServerVersion
+	}
+	return fmt.Sprintf("Authentication fails, the client version %s is not compatible with %s, "+
+		"use a client matching the version of graphd, error: %s", e.ClientVersion, server, e.ErrorMsg)
+}
+
+func (e *VersionMismatchError) Is(target error) bool {
+	return target == ErrVersionMismatch
+}
+
+var (
+	// graphd reports e.g. "Version mismatch", "Incompatible version" or "The version of the client is not in the whitelist"
+	versionMismatchPattern = regexp.MustCompile(
+		`(?i)version.*(mismatch|incompatible|not compatible|not supported|unsupported|white ?list)|(mismatch|incompatible|unsupported).*version`)
+	// e.g. "the server version is 2.5.0" or "server version: v3.0.0"
+	serverVersionPattern = regexp.MustCompile(`(?i)server(?:'s)? version(?: is)?[\s:=(]*v?(\d[\w.+-]*)`)
+)
+
+// Return a *VersionMismatchError if graphd rejected the sign-in since the client is not compatible with it,
+// nil otherwise
+func versionMismatchError(resp *graph.AuthResponse) error {
+	msg := string(resp.GetErrorMsg())
+	if resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED || !versionMismatchPattern.MatchString(msg) {
+		return nil
+	}
+	mismatch := &VersionMismatchError{ClientVersion: ClientVersion, ErrorMsg: msg}
+	if match := serverVersionPattern.FindStringSubmatch(msg); match != nil {
+		mismatch.ServerVersion = strings.TrimRight(match[1], ".")
+	}
+	return mismatch
+}
+
+// Build the error of a query rejected since the session is in no space, nil if it is not the case
+func noSpaceSelectedError(resp *graph.ExecutionResponse) error {
+	switch resp.GetErrorCode() {
+	case graph.ErrorCode_E_SEMANTIC_ERROR, graph.ErrorCode_E_EXECUTION_ERROR:
+	default:
+		return nil
+	}
+	// graphd reports "Space was not chosen." or "No space selected" depending on the version
+	msg := strings.ToLower(strings.Replace(string(resp.GetErrorMsg()), " ", "", -1))
+	if !strings.Contains(msg, "spacewasnotchosen") && !strings.Contains(msg, "nospaceselected") {
+		return nil
+	}
+	return &kindError{
+		kind: ErrNoSpaceSelected,
+		msg: fmt.Sprintf("Failed to execute, no space is selected, set PoolConfig.SpaceName or execute USE <space> first, error: %s",
+			resp.GetErrorMsg()),
+		err: CheckResponse(resp),
+	}
+}
+
+// PermissionError is returned by Session.Execute when graphd rejects the statement since the user lacks
+// the privilege for it, e.g. to answer 403 to the users of a multi-tenant application.
+// It matches ErrPermissionDenied with errors.Is, and the *ExecutionError of the response with errors.As.
+type PermissionError struct {
+	// The operation the statement attempted, its first keywords such as "DROP SPACE" or "INSERT VERTEX",
+	// empty if they could not be found. With several statements, it is the operation of the first one.
+	Operation string
+	Err       *ExecutionError
+}
+
+func (e *PermissionError) Error() string {
+	if e.Operation == "" {
+		return fmt.Sprintf("Permission denied, error: %s", e.Err.ErrorMsg)
+	}
+	return fmt.Sprintf("Permission denied to %s, error: %s", e.Operation, e.Err.ErrorMsg)
+}
+
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrPermissionDenied
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// The keywords followed by the kind of object they apply to, which is part of the operation
+var objectKeywords = map[string]bool{
+	"CREATE": true, "DROP": true, "ALTER": true, "INSERT": true, "UPDATE": true, "UPSERT": true,
+	"DELETE": true, "SHOW": true, "DESCRIBE": true, "DESC": true, "GRANT": true, "REVOKE": true,
+	"CHANGE": true, "REBUILD": true, "KILL": true, "SUBMIT": true,
+}
+
+// Return a *PermissionError if graphd rejected the statement since the user lacks the privilege, nil otherwise
+func permissionError(resp *graph.ExecutionResponse, stmt string) error {
+	if resp.GetErrorCode() != graph.ErrorCode_E_BAD_PERMISSION {
+		return nil
+	}
+	return &PermissionError{Operation: statementOperation(stmt), Err: CheckResponse(resp).(*ExecutionError)}
+}
+
+// Return the first keywords of the first statement, e.g. "DROP SPACE", empty if there is none
+func statementOperation(stmt string) string {
+	parts := splitStatement(stmt)
+	if len(parts) == 0 {
+		return ""
+	}
+	keyword, rest := firstKeyword(parts[0])
+	if objectKeywords[keyword] {
+		if object, _ := firstKeyword(rest); object != "" {
+			return keyword + " " + object
+		}
+	}
+	return keyword
+}
+
+// Wrap an error returned when opening a transport, it is a *TimeoutError of the connect phase or matches ErrTransportClosed
+func wrapOpenError(msg string, err error) error {
+	msg = fmt.Sprintf("%s, error: %s", msg, err.Error())
+	if isTimeout(err) {
+		return &TimeoutError{Phase: TimeoutPhaseConnect, msg: msg, Err: err}
+	}
+	return &kindError{kind: ErrTransportClosed, msg: msg, err: err}
+}
+
+// ExecutionError is the error of a query which failed on the server side
+type ExecutionError struct {
+	ErrorCode graph.ErrorCode
+	ErrorMsg  string
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("Failed to execute, error code: %s, error: %s", e.ErrorCode, e.ErrorMsg)
+}
+
+// BatchError is returned by Session.ExecuteParameterizedBatch when some of the parameter sets failed
+type BatchError struct {
+	// The error of every parameter set, in the order of the sets, nil for the ones which succeeded
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	failed := 0
+	for _, err := range e.Errors {
+		if err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("Failed to execute %d of %d parameter sets, the first error: %s", failed, len(e.Errors), e.Unwrap())
+}
+
+// Unwrap returns the error of the first parameter set which failed
+func (e *BatchError) Unwrap() error {
+	for _, err := range e.Errors {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckResponse returns an *ExecutionError if the query failed on the server side, nil otherwise.
+// Use errors.As to get the error code.
+func CheckResponse(resp *graph.ExecutionResponse) error {
+	if resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED {
+		return nil
+	}
+	return &ExecutionError{ErrorCode: resp.GetErrorCode(), ErrorMsg: string(resp.GetErrorMsg())}
+}
+
+// SyntaxPosition is where graphd found a syntax error in the statement, see ExecutionError.SyntaxPosition
+type SyntaxPosition struct {
+	// The 1-based line and column, 0 if graphd does not report them
+	Line   int
+	Column int
+	// The text near which the error is found, empty if graphd does not report it
+	Near string
+}
+
+var (
+	// graphd reports the location of the parser, e.g. "syntax error at 1.8-12" or "at 2.3-3.1"
+	parserLocationPattern = regexp.MustCompile(`\bat (\d+)\.(\d+)(?:-(?:\d+\.)?\d+)?`)
+	// The other forms, e.g. "at line 1, column 8" or "line 1:8"
+	lineColumnPattern = regexp.MustCompile(`(?i)\bline (\d+)(?:, column |:)(\d+)`)
+	nearPattern       = regexp.MustCompile("near `(.*)'")
+)
+
+// SyntaxPosition parses the message of a syntax error for the position graphd reports, e.g.
+// "SyntaxError: syntax error near `YIELD'" or "syntax error at 1.8-12". The second value is false
+// if the query did not fail with a syntax error, e.g. a semantic or a runtime error.
+// A syntax error may come without any position, its SyntaxPosition is zero then.
+func (e *ExecutionError) SyntaxPosition() (SyntaxPosition, bool) {
+	if e.ErrorCode != graph.ErrorCode_E_SYNTAX_ERROR {
+		return SyntaxPosition{}, false
+	}
+	var pos SyntaxPosition
+	match := parserLocationPattern.FindStringSubmatch(e.ErrorMsg)
+	if match == nil {
+		match = lineColumnPattern.FindStringSubmatch(e.ErrorMsg)
+	}
+	if match != nil {
+		pos.Line, _ = strconv.Atoi(match[1])
+		pos.Column, _ = strconv.Atoi(match[2])
+	}
+	if match := nearPattern.FindStringSubmatch(e.ErrorMsg); match != nil {
+		pos.Near = match[1]
+	}
+	return pos, true
+}
+
+// Format returns the line of stmt the error is on, followed by a line marking the position with a caret,
+// e.g. to show the error in a console. If graphd reports no column, the first occurrence of Near is marked.
+// It returns an empty string if the position could not be found in stmt.
+func (pos SyntaxPosition) Format(stmt string) string {
+	lines := strings.Split(stmt, "\n")
+	line, column := pos.Line, pos.Column
+	if line == 0 || column == 0 {
+		line, column = 0, 0
+		if pos.Near == "" {
+			return ""
+		}
+		for i, text := range lines {
+			if index := strings.Index(text, pos.Near); index >= 0 {
+				line, column = i+1, utf8.RuneCountInString(text[:index])+1
+				break
+			}
+		}
+	}
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	text := lines[line-1]
+	width := utf8.RuneCountInString(text)
+	if column > width+1 {
+		return ""
+	}
+	// Keep the tabs so the caret lines up with the text
+	var marker strings.Builder
+	for _, r := range []rune(text)[:column-1] {
+		if r == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteRune(' ')
+		}
+	}
+	marker.WriteRune('^')
+	return text + "\n" + marker.String()
+}
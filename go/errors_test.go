@@ -0,0 +1,385 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestErrors(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	_, err = pool.GetSession("root", "wrong")
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.False(t, errors.Is(err, ErrTransportClosed))
+	assert.Contains(t, err.Error(), "Bad username/password")
+
+	// Nothing is listening on the address
+	conn := newConnection(closedAddress(t))
+	err = conn.open(conn.severAddress, GetDefaultConf())
+	assert.True(t, errors.Is(err, ErrTransportClosed))
+	assert.False(t, errors.Is(err, ErrAuthFailed))
+
+	// The server never answers
+	listener, silentHost := startSilentServer(t)
+	defer listener.Close()
+	conf := GetDefaultConf()
+	conf.TimeOut = 100 * time.Millisecond
+	conn = newConnection(silentHost)
+	if err := conn.open(silentHost, conf); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	_, err = conn.execute(1, "YIELD 1")
+	assert.True(t, errors.Is(err, ErrTimeout))
+	var transportErr thrift.TransportException
+	assert.True(t, errors.As(err, &transportErr))
+}
+
+func TestTimeoutError(t *testing.T) {
+	listener, silentHost := startSilentServer(t)
+	defer listener.Close()
+	conf := GetDefaultConf()
+	conf.TimeOut = 100 * time.Millisecond
+	var timeoutErr *TimeoutError
+
+	// The dial does not finish in ConnTimeOut
+	dialConf := conf
+	dialConf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	conn := newConnection(silentHost)
+	err := conn.open(silentHost, dialConf)
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.False(t, errors.Is(err, ErrTransportClosed))
+	if assert.True(t, errors.As(err, &timeoutErr)) {
+		assert.Equal(t, TimeoutPhaseConnect, timeoutErr.Phase)
+	}
+
+	// The server never answers the sign-in or the query
+	conn = newConnection(silentHost)
+	if err := conn.open(silentHost, conf); err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.authenticate("root", "nebula")
+	if assert.True(t, errors.As(err, &timeoutErr)) {
+		assert.Equal(t, TimeoutPhaseAuth, timeoutErr.Phase)
+		assert.Contains(t, err.Error(), "Authentication fails")
+	}
+	if err := conn.reopen(); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+	_, err = conn.execute(1, "YIELD 1")
+	if assert.True(t, errors.As(err, &timeoutErr)) {
+		assert.Equal(t, TimeoutPhaseExecute, timeoutErr.Phase)
+		var transportErr thrift.TransportException
+		assert.True(t, errors.As(err, &transportErr))
+	}
+
+	assert.Equal(t, "connect", TimeoutPhaseConnect.String())
+	assert.Equal(t, "auth", TimeoutPhaseAuth.String())
+	assert.Equal(t, "execute", TimeoutPhaseExecute.String())
+	assert.Equal(t, "TimeoutPhase(3)", TimeoutPhase(3).String())
+}
+
+func TestCheckResponse(t *testing.T) {
+	assert.NoError(t, CheckResponse(genResp()))
+
+	err := CheckResponse(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_E_SYNTAX_ERROR,
+		ErrorMsg:  []byte("syntax error near `YIEL'"),
+	})
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, execErr.ErrorCode)
+		assert.Equal(t, "syntax error near `YIEL'", execErr.ErrorMsg)
+	}
+	assert.Equal(t, "Failed to execute, error code: E_SYNTAX_ERROR, error: syntax error near `YIEL'", err.Error())
+}
+
+func TestErrNoSpaceSelected(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		if stmt == "GO FROM 1 OVER follow" {
+			return &graph.ExecutionResponse{
+				ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR,
+				ErrorMsg:  []byte("SemanticError: Space was not chosen."),
+			}, nil
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR, ErrorMsg: []byte("SemanticError: bad")}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	resp, err := session.Execute("GO FROM 1 OVER follow")
+	assert.True(t, errors.Is(err, ErrNoSpaceSelected))
+	assert.Contains(t, err.Error(), "execute USE <space> first")
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SEMANTIC_ERROR, execErr.ErrorCode)
+	}
+	assert.Equal(t, graph.ErrorCode_E_SEMANTIC_ERROR, resp.GetErrorCode())
+
+	// Other errors are still reported by the result set
+	resp, err = session.Execute("YIELD $-.a")
+	assert.NoError(t, err)
+	assert.False(t, resp.IsSucceeded())
+}
+
+func TestPermissionError(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		if strings.Contains(strings.ToUpper(stmt), "DROP") {
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_BAD_PERMISSION, ErrorMsg: []byte("No permission to drop")}, nil
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	resp, err := session.ExecuteWithContext(WithTraceID(context.Background(), "t1"), "drop space if exists test")
+	var permErr *PermissionError
+	if assert.True(t, errors.As(err, &permErr)) {
+		assert.Equal(t, "DROP SPACE", permErr.Operation)
+		assert.Equal(t, "Permission denied to DROP SPACE, error: No permission to drop", err.Error())
+	}
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	assert.True(t, errors.Is(err, ErrNoPermission))
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_BAD_PERMISSION, execErr.ErrorCode)
+	}
+	assert.Equal(t, graph.ErrorCode_E_BAD_PERMISSION, resp.GetErrorCode())
+
+	// A batch going on after errors keeps the failure in the result
+	results, err := session.ExecuteBatch([]string{"DROP TAG player", "YIELD 1"}, true)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.False(t, results[0].IsSucceeded())
+	}
+	results, err = session.ExecuteBatch([]string{"DROP TAG player", "YIELD 1"}, false)
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	assert.Len(t, results, 1)
+
+	assert.Equal(t, "INSERT VERTEX", statementOperation("/* c */ $a = INSERT VERTEX player(name) VALUES 1:(\"a\")"))
+	assert.Equal(t, "GO", statementOperation("GO FROM 1 OVER e | YIELD 1"))
+	assert.Equal(t, "", statementOperation("  "))
+	assert.Equal(t, "Permission denied, error: denied", (&PermissionError{Err: &ExecutionError{ErrorMsg: "denied"}}).Error())
+}
+
+func TestIncludeStatementInError(t *testing.T) {
+	assert.Equal(t, `"YIELD 1"`, truncateStatement("YIELD 1", 10))
+	assert.Equal(t, `"YIELD"... (7 bytes)`, truncateStatement("YIELD 1", 5))
+	// The statement is not cut inside a rune
+	assert.Equal(t, `"YIELD \""... (11 bytes)`, truncateStatement(`YIELD "中"`, 8))
+
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		switch {
+		case stmt == "GO FROM 1 OVER follow":
+			return &graph.ExecutionResponse{
+				ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR,
+				ErrorMsg:  []byte("SemanticError: Space was not chosen."),
+			}, nil
+		case strings.HasPrefix(stmt, "FETCH"):
+			time.Sleep(300 * time.Millisecond)
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ExecTimeOut = 200 * time.Millisecond
+	conf.IncludeStatementInError = true
+	conf.StatementInErrorMaxLen = 12
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	_, err = session.Execute("GO FROM 1 OVER follow")
+	assert.True(t, errors.Is(err, ErrNoSpaceSelected))
+	assert.Contains(t, err.Error(), `statement: "GO FROM 1 OV"... (21 bytes)`)
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SEMANTIC_ERROR, execErr.ErrorCode)
+	}
+
+	_, err = session.Execute("FETCH PROP ON player 1")
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.Contains(t, err.Error(), `statement: "FETCH PROP O"... (22 bytes)`)
+
+	// The statements are left out by default
+	pool.conf.IncludeStatementInError = false
+	_, err = session.Execute("GO FROM 1 OVER follow")
+	assert.True(t, errors.Is(err, ErrNoSpaceSelected))
+	assert.NotContains(t, err.Error(), "statement")
+}
+
+func TestAuthResponseErrors(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		switch username {
+		case "locked":
+			// An error code without a message
+			return &graph.AuthResponse{ErrorCode: graph.ErrorCode_E_BAD_USERNAME_PASSWORD}
+		case "nosession":
+			// Succeeded without a session ID
+			return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}
+		default:
+			id := int64(100)
+			return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &id}
+		}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	_, err = pool.GetSession("locked", "nebula")
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.Contains(t, err.Error(), "E_BAD_USERNAME_PASSWORD")
+	_, err = pool.GetSession("nosession", "nebula")
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.Contains(t, err.Error(), "no session ID")
+	// The failures give the connection back
+	session, err := pool.GetSession("root", "nebula")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(100), session.sessionID)
+		session.Release()
+	}
+}
+
+func TestVersionMismatchError(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		switch username {
+		case "old":
+			return &graph.AuthResponse{
+				ErrorCode: graph.ErrorCode_E_BAD_PERMISSION,
+				ErrorMsg:  []byte("Client version mismatch, the server version is 3.0.0."),
+			}
+		case "other":
+			return &graph.AuthResponse{
+				ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR,
+				ErrorMsg:  []byte("Incompatible protocol version"),
+			}
+		default:
+			return &graph.AuthResponse{ErrorCode: graph.ErrorCode_E_BAD_USERNAME_PASSWORD, ErrorMsg: []byte("Bad username/password")}
+		}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	_, err = pool.GetSession("old", "nebula")
+	assert.True(t, errors.Is(err, ErrVersionMismatch))
+	assert.False(t, errors.Is(err, ErrAuthFailed))
+	var mismatch *VersionMismatchError
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, ClientVersion, mismatch.ClientVersion)
+		assert.Equal(t, "3.0.0", mismatch.ServerVersion)
+		assert.Contains(t, err.Error(), "the client version 2.0.0 is not compatible with the server version 3.0.0")
+	}
+	// The server version is not reported
+	_, err = pool.GetSession("other", "nebula")
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Empty(t, mismatch.ServerVersion)
+		assert.Contains(t, err.Error(), "not compatible with the server, use a client matching the version of graphd")
+	}
+	_, err = pool.GetSession("root", "wrong")
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.False(t, errors.Is(err, ErrVersionMismatch))
+}
+
+func TestExecutionError_SyntaxPosition(t *testing.T) {
+	err := &ExecutionError{ErrorCode: graph.ErrorCode_E_SYNTAX_ERROR, ErrorMsg: "SyntaxError: syntax error near `YILD'"}
+	pos, ok := err.SyntaxPosition()
+	assert.True(t, ok)
+	assert.Equal(t, SyntaxPosition{Near: "YILD"}, pos)
+	assert.Equal(t, "GO FROM 1 OVER e YILD e.x\n                 ^", pos.Format("GO FROM 1 OVER e YILD e.x"))
+	// The near text not found in the statement
+	assert.Equal(t, "", pos.Format("YIELD 1"))
+
+	err.ErrorMsg = "SyntaxError: syntax error at 2.3-6 near `YILD'"
+	pos, ok = err.SyntaxPosition()
+	assert.True(t, ok)
+	assert.Equal(t, SyntaxPosition{Line: 2, Column: 3, Near: "YILD"}, pos)
+	assert.Equal(t, "\tYILD 1\n\t ^", pos.Format("USE nba;\n\tYILD 1"))
+
+	err.ErrorMsg = "syntax error at line 1, column 40"
+	pos, _ = err.SyntaxPosition()
+	assert.Equal(t, SyntaxPosition{Line: 1, Column: 40}, pos)
+	assert.Equal(t, "", pos.Format("YIELD 1"))
+
+	// A syntax error without a position
+	err.ErrorMsg = "SyntaxError: unexpected end of input"
+	pos, ok = err.SyntaxPosition()
+	assert.True(t, ok)
+	assert.Equal(t, SyntaxPosition{}, pos)
+	assert.Equal(t, "", pos.Format("YIELD"))
+
+	// Not a syntax error
+	err = &ExecutionError{ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR, ErrorMsg: "SemanticError: `e' not found near `e.x'"}
+	_, ok = err.SyntaxPosition()
+	assert.False(t, ok)
+}
@@ -0,0 +1,71 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The reserved keywords of nGQL, a label equal to one of them must be quoted.
+// Quoting a label which is not a keyword is harmless, so the list could be larger than the parser's.
+var reservedKeywords = map[string]bool{
+	"ADD": true, "ALL": true, "ALTER": true, "AND": true, "AS": true, "ASC": true, "BALANCE": true,
+	"BIDIRECT": true, "BIGINT": true, "BOOL": true, "BY": true, "CASE": true, "CHANGE": true,
+	"CONFIGS": true, "CONTAINS": true, "CREATE": true, "DATE": true, "DATETIME": true, "DELETE": true,
+	"DESC": true, "DESCRIBE": true, "DISTINCT": true, "DOUBLE": true, "DOWNLOAD": true, "DROP": true,
+	"EDGE": true, "EDGES": true, "ELSE": true, "END": true, "EXISTS": true, "EXPLAIN": true, "FALSE": true,
+	"FETCH": true, "FIND": true, "FLOAT": true, "FROM": true, "GET": true, "GO": true, "GRANT": true,
+	"GROUP": true, "IF": true, "IN": true, "INDEX": true, "INGEST": true, "INSERT": true, "INT": true,
+	"INTERSECT": true, "IS": true, "LIMIT": true, "LOOKUP": true, "MATCH": true, "MINUS": true,
+	"NO": true, "NOT": true, "NULL": true, "OF": true, "OFFSET": true, "ON": true, "OR": true,
+	"ORDER": true, "OVER": true, "OVERWRITE": true, "PATH": true, "PROFILE": true, "PROP": true,
+	"REBUILD": true, "RECOVER": true, "REMOVE": true, "RETURN": true, "REVERSELY": true, "REVOKE": true,
+	"SET": true, "SHORTEST": true, "SHOW": true, "SPACE": true, "SPACES": true, "STEP": true,
+	"STEPS": true, "STRING": true, "SUBMIT": true, "TAG": true, "TAGS": true, "THEN": true,
+	"TIME": true, "TIMESTAMP": true, "TO": true, "TRUE": true, "UNION": true, "UNWIND": true,
+	"UPDATE": true, "UPSERT": true, "UPTO": true, "USE": true, "USER": true, "USERS": true,
+	"UUID": true, "VALUES": true, "VERTEX": true, "VERTICES": true, "WHEN": true, "WHERE": true,
+	"WITH": true, "XOR": true, "YIELD": true,
+}
+
+// EscapeString returns s as a double-quoted nGQL string literal, including the quotes.
+// Backslashes, double quotes, newlines, carriage returns and tabs are escaped with a backslash.
+// Other control characters and bytes which are not valid UTF-8, e.g. of a binary VID, are written
+// as octal escapes like \377, so they reach graphd unchanged.
+func EscapeString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || (r < 0x20 && !strings.ContainsRune("\n\r\t", r)) {
+			fmt.Fprintf(&b, `\%03o`, s[i])
+		} else {
+			stringReplacer.WriteString(&b, s[i:i+size])
+		}
+		i += size
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+var stringReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// EscapeLabel returns s as a nGQL label, e.g. the name of a space, tag, edge type or property.
+// s is returned as is if it is a plain identifier which is not a keyword, otherwise it is quoted with
+// backticks and the backticks and backslashes in it are escaped with a backslash.
+func EscapeLabel(s string) string {
+	plain := len(s) > 0 && !reservedKeywords[strings.ToUpper(s)]
+	for i := 0; plain && i < len(s); i++ {
+		plain = isIdentifierChar(s[i], i == 0)
+	}
+	if plain {
+		return s
+	}
+	return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(s) + "`"
+}
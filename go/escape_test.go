@@ -0,0 +1,41 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeString(t *testing.T) {
+	assert.Equal(t, `"Tim"`, EscapeString("Tim"))
+	assert.Equal(t, `""`, EscapeString(""))
+	assert.Equal(t, `"say \"hi\""`, EscapeString(`say "hi"`))
+	assert.Equal(t, `"C:\\dir\\"`, EscapeString(`C:\dir\`))
+	assert.Equal(t, `"a\nb\r\tc"`, EscapeString("a\nb\r\tc"))
+	// Injection attempt stays inside the literal
+	assert.Equal(t, `"\"; DROP SPACE nba; \""`, EscapeString(`"; DROP SPACE nba; "`))
+	assert.Equal(t, "\"`'\"", EscapeString("`'"))
+	// Valid UTF-8 is kept, other bytes are written in octal
+	assert.Equal(t, "\"中文\uFFFD\"", EscapeString("中文\uFFFD"))
+	assert.Equal(t, `"\000\377a\\\376"`, EscapeString("\x00\xffa\\\xfe"))
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, "player", EscapeLabel("player"))
+	assert.Equal(t, "_p1", EscapeLabel("_p1"))
+	assert.Equal(t, "`1p`", EscapeLabel("1p"))
+	assert.Equal(t, "``", EscapeLabel(""))
+	assert.Equal(t, "`my tag`", EscapeLabel("my tag"))
+	assert.Equal(t, "`a\\`b`", EscapeLabel("a`b"))
+	assert.Equal(t, "`a\\\\b`", EscapeLabel(`a\b`))
+	assert.Equal(t, "`中文`", EscapeLabel("中文"))
+	// Keywords are quoted regardless of the case
+	assert.Equal(t, "`match`", EscapeLabel("match"))
+	assert.Equal(t, "`Tag`", EscapeLabel("Tag"))
+}
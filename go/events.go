@@ -0,0 +1,123 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"time"
+)
+
+// PoolEventType is the kind of a PoolEvent
+type PoolEventType int
+
+const (
+	// A connection is opened to the host
+	EventConnOpened PoolEventType = iota
+	// A connection to the host is closed, for whatever reason
+	EventConnClosed
+	// A connection to the host is closed by the pool since it could not be reused, e.g. it expired after IdleTime
+	// or MaxConnLifetime, or failed a health check or TestOnBorrow. It is followed by EventConnClosed.
+	EventConnEvicted
+	// The host failed to be connected or a health check, it is skipped until it recovers
+	EventHostUnhealthy
+	// The host is reachable again after being unhealthy
+	EventHostHealthy
+	// A caller found every connection in use and the pool at MaxConnPoolSize, it waits or fails with ErrPoolFull
+	EventPoolExhausted
+)
+
+func (t PoolEventType) String() string {
+	switch t {
+	case EventConnOpened:
+		return "ConnOpened"
+	case EventConnClosed:
+		return "ConnClosed"
+	case EventConnEvicted:
+		return "ConnEvicted"
+	case EventHostUnhealthy:
+		return "HostUnhealthy"
+	case EventHostHealthy:
+		return "HostHealthy"
+	case EventPoolExhausted:
+		return "PoolExhausted"
+	default:
+		return fmt.Sprintf("PoolEventType(%d)", int(t))
+	}
+}
+
+// PoolEvent is a lifecycle event of a pool, sent to the channel returned by ConnectionPool.Events
+type PoolEvent struct {
+	Type PoolEventType
+	// The host of the connection or the host whose health changed, empty for EventPoolExhausted
+	Host HostAddress
+	Time time.Time
+	// The cause of the event if any, e.g. the error of the failed health check
+	Err error
+}
+
+// Events returns the channel the lifecycle events of the pool are sent to, nil if EventBufferSize is not set.
+// The events are sent without blocking the pool: they are dropped if the buffer is full, so the consumer
+// should read it promptly. The channel is closed once the pool is closed.
+func (pool *ConnectionPool) Events() <-chan PoolEvent {
+	return pool.events
+}
+
+// Send an event without blocking, dropping it if the buffer is full or the pool is closed
+func (pool *ConnectionPool) sendEvent(eventType PoolEventType, host HostAddress, err error) {
+	if pool.events == nil {
+		return
+	}
+	pool.eventsMu.Lock()
+	defer pool.eventsMu.Unlock()
+	if pool.eventsClosed {
+		return
+	}
+	select {
+	case pool.events <- PoolEvent{Type: eventType, Host: host, Time: time.Now(), Err: err}:
+	default:
+	}
+}
+
+// Close the channel of the events, no event is sent after it
+func (pool *ConnectionPool) closeEvents() {
+	if pool.events == nil {
+		return
+	}
+	pool.eventsMu.Lock()
+	defer pool.eventsMu.Unlock()
+	if !pool.eventsClosed {
+		pool.eventsClosed = true
+		close(pool.events)
+	}
+}
+
+// Set the health of the host, an event is sent if it changes, must be called with the lock held
+func (pool *ConnectionPool) setHealthy(host HostAddress, healthy bool, err error) {
+	status, ok := pool.hosts[host]
+	if !ok {
+		return
+	}
+	if !healthy && err != nil {
+		status.lastErr = err
+	}
+	if status.healthy == healthy {
+		return
+	}
+	status.healthy = healthy
+	if healthy {
+		status.lastErr = nil
+		pool.sendEvent(EventHostHealthy, host, nil)
+	} else {
+		pool.sendEvent(EventHostUnhealthy, host, err)
+	}
+}
+
+// Close a connection which could not be reused, must be called with the lock held
+func (pool *ConnectionPool) evictConn(conn *connection, err error) {
+	pool.sendEvent(EventConnEvicted, conn.severAddress, err)
+	pool.closeConn(conn)
+}
@@ -0,0 +1,91 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestPool_Events(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	unreachable := closedAddress(t)
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 1
+	conf.AcquireTimeout = -1
+	conf.IdleTime = 50 * time.Millisecond
+	conf.EventBufferSize = 100
+	pool, err := NewConnectionPool([]HostAddress{host, unreachable}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.GetConnection()
+	assert.True(t, errors.Is(err, ErrPoolFull))
+	pool.release(conn)
+	time.Sleep(100 * time.Millisecond)
+	// The expired connection is evicted before a new one is opened
+	conn, err = pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(conn)
+	pool.Close()
+
+	var events []PoolEventType
+	for event := range pool.Events() {
+		if event.Type != EventPoolExhausted {
+			assert.Contains(t, []HostAddress{host, unreachable}, event.Host)
+		}
+		events = append(events, event.Type)
+	}
+	assert.Equal(t, []PoolEventType{
+		EventHostUnhealthy,
+		EventConnOpened,
+		EventPoolExhausted,
+		EventConnEvicted,
+		EventConnClosed,
+		EventConnOpened,
+		EventConnClosed,
+	}, events)
+	assert.Equal(t, "ConnEvicted", EventConnEvicted.String())
+
+	// The events are dropped instead of blocking the pool if the buffer is full
+	conf.EventBufferSize = 1
+	pool, err = NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		conn, err := pool.GetConnection()
+		if assert.NoError(t, err) {
+			conn.setBroken()
+			pool.release(conn)
+		}
+	}
+	pool.Close()
+	assert.Len(t, pool.Events(), 1)
+
+	// No channel is given if the events are disabled
+	conf.EventBufferSize = 0
+	pool, err = NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Nil(t, pool.Events())
+}
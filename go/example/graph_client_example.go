@@ -8,12 +8,10 @@ package main
 
 import (
 	"fmt"
-	"strconv"
 	"sync"
 	"time"
 
 	nebula "github.com/vesoft-inc/nebula-clients/go"
-	"github.com/vesoft-inc/nebula-clients/go/nebula/graph"
 )
 
 const (
@@ -53,8 +51,8 @@ func main() {
 		// Release session and return connection back to connection pool
 		defer session.Release()
 		// Method used to check execution response
-		checkResp := func(prefix string, err *graph.ExecutionResponse) {
-			if nebula.IsError(err) {
+		checkResp := func(prefix string, err *nebula.ResultSet) {
+			if !err.IsSucceeded() {
 				fmt.Printf("%s, ErrorCode: %v, ErrorMsg: %s", prefix, err.GetErrorCode(), err.GetErrorMsg())
 			}
 		}
@@ -125,46 +123,25 @@ func main() {
 	log.Info("Example finished")
 }
 
-func printResult(resp *graph.ExecutionResponse) {
-	data := resp.GetData()
-	colNames := data.GetColumnNames()
+func printResult(resp *nebula.ResultSet) {
+	colNames := resp.GetColNames()
 	for _, col := range colNames {
 		fmt.Printf("%15s |", col)
 	}
 	fmt.Println()
-	rows := data.GetRows()
-	for _, row := range rows {
-		values := row.GetValues()
-		for _, value := range values {
-			if value.IsSetNVal() {
-				fmt.Printf("%15s |", "__NULL__")
-			} else if value.IsSetBVal() {
-				fmt.Printf("%15t |", strconv.FormatBool(value.GetBVal()))
-			} else if value.IsSetIVal() {
-				fmt.Printf("%15d |", value.GetIVal())
-			} else if value.IsSetFVal() {
-				fmt.Printf("%15.1f |", value.GetFVal())
-			} else if value.IsSetSVal() {
-				fmt.Printf("%15s |", value.GetSVal())
-			} else if value.IsSetDVal() {
-				fmt.Printf("%15s |", value.GetDVal())
-			} else if value.IsSetTVal() {
-				fmt.Printf("%15s |", value.GetTVal())
-			} else if value.IsSetDtVal() {
-				fmt.Printf("%15s |", value.GetDtVal())
-			} else if value.IsSetVVal() {
-				fmt.Printf("%15s |", value.GetVVal())
-			} else if value.IsSetEVal() {
-				fmt.Printf("%15s |", value.GetEVal())
-			} else if value.IsSetPVal() {
-				fmt.Printf("%15s |", value.GetPVal())
-			} else if value.IsSetLVal() {
-				fmt.Printf("%15s |", value.GetLVal())
-			} else if value.IsSetMVal() {
-				fmt.Printf("%15s |", value.GetMVal())
-			} else if value.IsSetUVal() {
-				fmt.Printf("%15s |", value.GetUVal())
+	for i := 0; i < resp.GetRowSize(); i++ {
+		record, err := resp.GetRowValuesByIndex(i)
+		if err != nil {
+			fmt.Printf(err.Error())
+			return
+		}
+		for j := 0; j < len(colNames); j++ {
+			value, err := record.GetValueByIndex(j)
+			if err != nil {
+				fmt.Printf(err.Error())
+				return
 			}
+			fmt.Printf("%15s |", value.String())
 		}
 		fmt.Println()
 	}
@@ -0,0 +1,157 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// Executor executes a statement and returns its result, it is implemented by Session.
+// The wrappers RetryExecutor, LoggingExecutor and CachingExecutor each add a behavior to another Executor,
+// so they could be composed, e.g.
+//
+//	executor := NewLoggingExecutor(NewRetryExecutor(session, policy, log), log)
+type Executor interface {
+	Execute(stmt string) (*ResultSet, error)
+}
+
+var _ Executor = (*Session)(nil)
+
+// RetryExecutor executes the statement again by the RetryPolicy when graphd returns a retriable error code.
+// The transport failures are not retried by it, they are left to the session, see Session.Execute.
+type RetryExecutor struct {
+	next   Executor
+	policy RetryPolicy
+	log    Logger
+}
+
+// NewRetryExecutor returns a RetryExecutor wrapping next, the invalid values of the policy are replaced
+// by the defaults with a warning
+func NewRetryExecutor(next Executor, policy RetryPolicy, log Logger) *RetryExecutor {
+	if log == nil {
+		log = NoopLogger{}
+	}
+	policy.validate(log)
+	return &RetryExecutor{next: next, policy: policy, log: log}
+}
+
+// Execute executes the statement with the wrapped executor until it succeeds, fails with an error code
+// which is not retriable, or runs out of attempts
+func (executor *RetryExecutor) Execute(stmt string) (*ResultSet, error) {
+	var resultSet *ResultSet
+	_, err := executor.policy.execute(context.Background(), executor.log, func() (*graph.ExecutionResponse, error) {
+		res, err := executor.next.Execute(stmt)
+		if err != nil {
+			return nil, err
+		}
+		resultSet = res
+		return res.resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resultSet, nil
+}
+
+// LoggingExecutor logs every statement executed by the wrapped executor with its latency,
+// a failure is logged as an error and a successful one as an info
+type LoggingExecutor struct {
+	next Executor
+	log  Logger
+}
+
+// NewLoggingExecutor returns a LoggingExecutor wrapping next
+func NewLoggingExecutor(next Executor, log Logger) *LoggingExecutor {
+	if log == nil {
+		log = NoopLogger{}
+	}
+	return &LoggingExecutor{next: next, log: log}
+}
+
+// Execute executes the statement with the wrapped executor and logs it
+func (executor *LoggingExecutor) Execute(stmt string) (*ResultSet, error) {
+	start := time.Now()
+	resultSet, err := executor.next.Execute(stmt)
+	elapsed := time.Since(start)
+	switch {
+	case err != nil:
+		executor.log.Error(fmt.Sprintf("Failed to execute %q in %s, %s", stmt, elapsed, err.Error()))
+	case !resultSet.IsSucceeded():
+		executor.log.Error(fmt.Sprintf("Failed to execute %q in %s, error code: %s, error message: %s",
+			stmt, elapsed, resultSet.GetErrorCode(), resultSet.GetErrorMsg()))
+	default:
+		executor.log.Info(fmt.Sprintf("Executed %q in %s, latency on server: %s", stmt, elapsed, resultSet.GetLatency()))
+	}
+	return resultSet, err
+}
+
+// CachingExecutor serves the results of the read-only statements from a LRU cache, see IsReadOnlyStatement.
+// The results are cached by the space the statements are executed in, which is followed from the results
+// returned by the wrapped executor, so the wrapped executor should not be used by others at the same time.
+// Only successful results are cached, and every statement which is not read-only drops the cached results
+// of its space. As Session.ExecuteCached, it is not consistency-safe against the writes made by others.
+type CachingExecutor struct {
+	next  Executor
+	cache *resultCache
+	mu    sync.Mutex
+	// The space, the vid type and the UTF-8 policy of the last result
+	space      string
+	vidType    VIDType
+	utf8Policy UTF8Policy
+}
+
+// NewCachingExecutor returns a CachingExecutor wrapping next, which caches at most size results,
+// for ttl each, 0 ttl means the results never expire
+func NewCachingExecutor(next Executor, size int, ttl time.Duration) *CachingExecutor {
+	if size <= 0 {
+		size = 1
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	return &CachingExecutor{next: next, cache: newResultCache(size, ttl)}
+}
+
+// Execute returns the cached result of the statement, or executes it with the wrapped executor
+func (executor *CachingExecutor) Execute(stmt string) (*ResultSet, error) {
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	key := resultCacheKey{space: executor.space, stmt: stmt}
+	cacheable := IsReadOnlyStatement(stmt)
+	if cacheable {
+		if resp := executor.cache.get(key); resp != nil {
+			resultSet := newResultSet(resp)
+			resultSet.vidType = executor.vidType
+			resultSet.utf8Policy = executor.utf8Policy
+			return resultSet, nil
+		}
+	}
+	resultSet, err := executor.next.Execute(stmt)
+	if !cacheable {
+		// The write may have been applied even if it failed
+		executor.cache.invalidateSpace(key.space)
+	}
+	if err != nil || !resultSet.IsSucceeded() {
+		return resultSet, err
+	}
+	if space := resultSet.GetSpaceName(); space != "" {
+		executor.space = space
+	}
+	executor.vidType = resultSet.vidType
+	executor.utf8Policy = resultSet.utf8Policy
+	if !cacheable {
+		executor.cache.invalidateSpace(executor.space)
+	} else if executor.space == key.space {
+		executor.cache.put(key, resultSet.resp)
+	}
+	return resultSet, nil
+}
@@ -0,0 +1,109 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// An executor returning the scripted error codes in order and recording the statements
+type scriptedExecutor struct {
+	codes []graph.ErrorCode
+	space string
+	stmts []string
+}
+
+func (e *scriptedExecutor) Execute(stmt string) (*ResultSet, error) {
+	e.stmts = append(e.stmts, stmt)
+	if strings.HasPrefix(stmt, "fail") {
+		return nil, errors.New("broken")
+	}
+	code := graph.ErrorCode_SUCCEEDED
+	if len(e.codes) > 0 {
+		code, e.codes = e.codes[0], e.codes[1:]
+	}
+	if strings.HasPrefix(stmt, "USE ") {
+		e.space = strings.TrimPrefix(stmt, "USE ")
+	}
+	return newResultSet(&graph.ExecutionResponse{ErrorCode: code, SpaceName: []byte(e.space)}), nil
+}
+
+func TestRetryExecutor(t *testing.T) {
+	next := &scriptedExecutor{codes: []graph.ErrorCode{graph.ErrorCode_E_RPC_FAILURE, graph.ErrorCode_E_RPC_FAILURE}}
+	log := &recordLogger{}
+	executor := NewRetryExecutor(next, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}, log)
+	resp, err := executor.Execute("SHOW SPACES")
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+	}
+	assert.Len(t, next.stmts, 3)
+	assert.Len(t, log.warnings, 2)
+
+	// A code which is not retriable is returned at once, and so is an error
+	next = &scriptedExecutor{codes: []graph.ErrorCode{graph.ErrorCode_E_SYNTAX_ERROR}}
+	executor = NewRetryExecutor(next, RetryPolicy{MaxAttempts: 3}, nil)
+	resp, err = executor.Execute("SHOW SPACES")
+	if assert.NoError(t, err) {
+		assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, resp.GetErrorCode())
+	}
+	_, err = executor.Execute("fail")
+	assert.Error(t, err)
+	assert.Len(t, next.stmts, 2)
+}
+
+func TestCachingExecutor(t *testing.T) {
+	next := &scriptedExecutor{}
+	executor := NewCachingExecutor(next, 10, time.Minute)
+	for _, stmt := range []string{"USE nba", "GO FROM 1 OVER follow", "GO FROM 1 OVER follow"} {
+		_, err := executor.Execute(stmt)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"USE nba", "GO FROM 1 OVER follow"}, next.stmts)
+
+	// The results are cached by space
+	_, err := executor.Execute("USE test")
+	assert.NoError(t, err)
+	_, err = executor.Execute("GO FROM 1 OVER follow")
+	assert.NoError(t, err)
+	assert.Len(t, next.stmts, 4)
+
+	// A write drops the results of its space, a failed result is not cached
+	_, err = executor.Execute("INSERT VERTEX player() VALUES 1:()")
+	assert.NoError(t, err)
+	next.codes = []graph.ErrorCode{graph.ErrorCode_E_EXECUTION_ERROR}
+	resp, err := executor.Execute("GO FROM 1 OVER follow")
+	if assert.NoError(t, err) {
+		assert.False(t, resp.IsSucceeded())
+	}
+	resp, err = executor.Execute("GO FROM 1 OVER follow")
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+	}
+	assert.Len(t, next.stmts, 7)
+}
+
+func TestExecutor_Compose(t *testing.T) {
+	next := &scriptedExecutor{codes: []graph.ErrorCode{graph.ErrorCode_E_RPC_FAILURE}}
+	var executor Executor = NewLoggingExecutor(
+		NewCachingExecutor(NewRetryExecutor(next, RetryPolicy{MaxAttempts: 2}, nil), 10, 0), nil)
+	for i := 0; i < 3; i++ {
+		resp, err := executor.Execute("SHOW SPACES")
+		if assert.NoError(t, err) {
+			assert.True(t, resp.IsSucceeded())
+		}
+	}
+	assert.Len(t, next.stmts, 2)
+	_, err := executor.Execute("fail")
+	assert.Error(t, err)
+}
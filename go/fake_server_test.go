@@ -0,0 +1,23 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+// Serve the handler on a local port until the returned function is called
+func startFakeServer(t *testing.T, handler graph.GraphService) (func(), HostAddress) {
+	server, err := testutil.StartServer(handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server.Stop, HostAddress{Host: server.Host, Port: server.Port}
+}
@@ -0,0 +1,184 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// Node is a vertex returned by a query
+type Node struct {
+	vertex          *nebula.Vertex
+	tags            []string
+	tagNameIndexMap map[string]int
+	vidType         VIDType
+	utf8Policy      UTF8Policy
+}
+
+// Relationship is an edge returned by a query
+type Relationship struct {
+	edge       *nebula.Edge
+	vidType    VIDType
+	utf8Policy UTF8Policy
+}
+
+// PathWrapper is a path returned by a query, it consists of nodes and the relationships between them
+type PathWrapper struct {
+	path             *nebula.Path
+	nodeList         []*Node
+	relationshipList []*Relationship
+}
+
+func genNode(vertex *nebula.Vertex, vidType VIDType, utf8Policy UTF8Policy) (*Node, error) {
+	if vertex == nil {
+		return nil, fmt.Errorf("Failed to generate Node: invalid vertex")
+	}
+	var tags []string
+	nameIndex := make(map[string]int)
+	for i, tag := range vertex.GetTags() {
+		name := string(tag.GetName())
+		tags = append(tags, name)
+		nameIndex[name] = i
+	}
+	return &Node{
+		vertex:          vertex,
+		tags:            tags,
+		tagNameIndexMap: nameIndex,
+		vidType:         vidType,
+		utf8Policy:      utf8Policy,
+	}, nil
+}
+
+func genRelationship(edge *nebula.Edge, vidType VIDType, utf8Policy UTF8Policy) (*Relationship, error) {
+	if edge == nil {
+		return nil, fmt.Errorf("Failed to generate Relationship: invalid edge")
+	}
+	return &Relationship{edge: edge, vidType: vidType, utf8Policy: utf8Policy}, nil
+}
+
+func genPathWrapper(path *nebula.Path, vidType VIDType, utf8Policy UTF8Policy) (*PathWrapper, error) {
+	if path == nil {
+		return nil, fmt.Errorf("Failed to generate PathWrapper: invalid path")
+	}
+	src, err := genNode(path.GetSrc(), vidType, utf8Policy)
+	if err != nil {
+		return nil, err
+	}
+	nodeList := []*Node{src}
+	var relationshipList []*Relationship
+	srcVid := path.GetSrc().GetVid()
+	for _, step := range path.GetSteps() {
+		dst, err := genNode(step.GetDst(), vidType, utf8Policy)
+		if err != nil {
+			return nil, err
+		}
+		dstVid := step.GetDst().GetVid()
+		edge := &nebula.Edge{
+			Src:     srcVid,
+			Dst:     dstVid,
+			Type:    step.GetType(),
+			Name:    step.GetName(),
+			Ranking: step.GetRanking(),
+			Props:   step.GetProps(),
+		}
+		// A negative edge type means the step goes against the direction of the edge
+		if step.GetType() < 0 {
+			edge.Src, edge.Dst = dstVid, srcVid
+		}
+		nodeList = append(nodeList, dst)
+		relationshipList = append(relationshipList, &Relationship{edge: edge, vidType: vidType, utf8Policy: utf8Policy})
+		srcVid = dstVid
+	}
+	return &PathWrapper{
+		path:             path,
+		nodeList:         nodeList,
+		relationshipList: relationshipList,
+	}, nil
+}
+
+// Return the vid of the node, it is an int if the VID type of the space is VIDTypeInt64, a string otherwise
+func (node Node) GetID() ValueWrapper {
+	return ValueWrapper{value: node.vidType.toValue(node.vertex.GetVid()), vidType: node.vidType, utf8Policy: node.utf8Policy}
+}
+
+// Return the names of all tags of the node
+func (node Node) Tags() []string {
+	return node.tags
+}
+
+// Return true if the node has the given tag
+func (node Node) HasTag(tagName string) bool {
+	_, ok := node.tagNameIndexMap[tagName]
+	return ok
+}
+
+// Return the properties of the given tag
+func (node Node) Properties(tagName string) (map[string]*ValueWrapper, error) {
+	index, ok := node.tagNameIndexMap[tagName]
+	if !ok {
+		return nil, fmt.Errorf("Failed to get properties: tag %s does not exist in the node", tagName)
+	}
+	return wrapProps(node.vertex.GetTags()[index].GetProps(), node.vidType, node.utf8Policy), nil
+}
+
+// Return the vid of the source node
+func (relationship Relationship) SrcID() ValueWrapper {
+	return ValueWrapper{value: relationship.vidType.toValue(relationship.edge.GetSrc()), vidType: relationship.vidType, utf8Policy: relationship.utf8Policy}
+}
+
+// Return the vid of the destination node
+func (relationship Relationship) DstID() ValueWrapper {
+	return ValueWrapper{value: relationship.vidType.toValue(relationship.edge.GetDst()), vidType: relationship.vidType, utf8Policy: relationship.utf8Policy}
+}
+
+// Return the name of the edge type
+func (relationship Relationship) EdgeName() string {
+	return string(relationship.edge.GetName())
+}
+
+// Return the ranking of the edge
+func (relationship Relationship) Ranking() int64 {
+	return int64(relationship.edge.GetRanking())
+}
+
+// Return the properties of the edge
+func (relationship Relationship) Properties() map[string]*ValueWrapper {
+	return wrapProps(relationship.edge.GetProps(), relationship.vidType, relationship.utf8Policy)
+}
+
+// Return all nodes of the path in order, from the start node to the end node
+func (path PathWrapper) GetNodes() []*Node {
+	return path.nodeList
+}
+
+// Return all relationships of the path in order
+func (path PathWrapper) GetRelationships() []*Relationship {
+	return path.relationshipList
+}
+
+// Return the number of relationships in the path
+func (path PathWrapper) GetLength() int {
+	return len(path.relationshipList)
+}
+
+func (path PathWrapper) GetStartNode() *Node {
+	return path.nodeList[0]
+}
+
+func (path PathWrapper) GetEndNode() *Node {
+	return path.nodeList[len(path.nodeList)-1]
+}
+
+func wrapProps(props map[string]*nebula.Value, vidType VIDType, utf8Policy UTF8Policy) map[string]*ValueWrapper {
+	result := make(map[string]*ValueWrapper, len(props))
+	for name, value := range props {
+		result[name] = &ValueWrapper{value: value, vidType: vidType, utf8Policy: utf8Policy}
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+func genVertex(vid string, tagNames ...string) *nebula.Vertex {
+	vertex := &nebula.Vertex{Vid: nebula.VertexID(vid)}
+	for _, name := range tagNames {
+		vertex.Tags = append(vertex.Tags, &nebula.Tag{
+			Name:  []byte(name),
+			Props: map[string]*nebula.Value{"name": strValue(vid)},
+		})
+	}
+	return vertex
+}
+
+func TestNode(t *testing.T) {
+	node, err := newValueWrapper(&nebula.Value{VVal: genVertex("Bob", "person", "student")}).AsNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bob", node.GetID().String())
+	assert.Equal(t, []string{"person", "student"}, node.Tags())
+	assert.True(t, node.HasTag("student"))
+
+	props, err := node.Properties("person")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", props["name"].String())
+	_, err = node.Properties("teacher")
+	assert.Error(t, err)
+
+	_, err = newValueWrapper(intValue(1)).AsNode()
+	assert.EqualError(t, err, "Failed to convert value int to Node")
+}
+
+func TestRelationship(t *testing.T) {
+	edge := &nebula.Edge{
+		Src:     nebula.VertexID("Bob"),
+		Dst:     nebula.VertexID("Tom"),
+		Type:    1,
+		Name:    []byte("like"),
+		Ranking: 2,
+		Props:   map[string]*nebula.Value{"likeness": intValue(90)},
+	}
+	relationship, err := newValueWrapper(&nebula.Value{EVal: edge}).AsRelationship()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bob", relationship.SrcID().String())
+	assert.Equal(t, "Tom", relationship.DstID().String())
+	assert.Equal(t, "like", relationship.EdgeName())
+	assert.Equal(t, int64(2), relationship.Ranking())
+	assert.Equal(t, "90", relationship.Properties()["likeness"].String())
+}
+
+func TestPathWrapper(t *testing.T) {
+	// Bob -like-> Tom <-like- Lily
+	path := &nebula.Path{
+		Src: genVertex("Bob", "person"),
+		Steps: []*nebula.Step{
+			{Dst: genVertex("Tom", "person"), Type: 1, Name: []byte("like")},
+			{Dst: genVertex("Lily", "person"), Type: -1, Name: []byte("like")},
+		},
+	}
+	pathWrapper, err := newValueWrapper(&nebula.Value{PVal: path}).AsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, pathWrapper.GetLength())
+	assert.Equal(t, "Bob", pathWrapper.GetStartNode().GetID().String())
+	assert.Equal(t, "Lily", pathWrapper.GetEndNode().GetID().String())
+	assert.Equal(t, 3, len(pathWrapper.GetNodes()))
+
+	relationships := pathWrapper.GetRelationships()
+	assert.Equal(t, "Bob", relationships[0].SrcID().String())
+	assert.Equal(t, "Tom", relationships[0].DstID().String())
+	// The second step goes against the edge direction
+	assert.Equal(t, "Lily", relationships[1].SrcID().String())
+	assert.Equal(t, "Tom", relationships[1].DstID().String())
+}
+
+func TestNode_VIDType(t *testing.T) {
+	vid := make([]byte, 8)
+	binary.LittleEndian.PutUint64(vid, 100)
+	vertex := &nebula.Vertex{Vid: vid}
+	node, err := ValueWrapper{value: &nebula.Value{VVal: vertex}, vidType: VIDTypeInt64}.AsNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := node.GetID().AsInt()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), id)
+
+	// String IDs are kept as they are
+	node, err = ValueWrapper{value: &nebula.Value{VVal: genVertex("Bob")}}.AsNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bob", node.GetID().String())
+}
@@ -0,0 +1,201 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"time"
+)
+
+// Check the idle connections and unhealthy hosts periodically until the pool is closed
+func (pool *ConnectionPool) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pool.closeCh:
+			return
+		case <-ticker.C:
+			pool.checkIdleConns()
+			pool.probeUnhealthyHosts()
+		}
+	}
+}
+
+// HealthyHosts returns the hosts the last connection to succeeded, in the order of the addresses,
+// e.g. for a readiness probe. It only reads the state kept by the pool, nothing is sent to graphd.
+func (pool *ConnectionPool) HealthyHosts() []HostAddress {
+	return pool.hostsByHealth(true)
+}
+
+// UnhealthyHosts returns the hosts the last connection to failed, in the order of the addresses.
+// They are tried again by the health check, see PoolConfig.HealthCheckInterval, and the error which
+// made each of them unhealthy is given by HostStats.
+func (pool *ConnectionPool) UnhealthyHosts() []HostAddress {
+	return pool.hostsByHealth(false)
+}
+
+func (pool *ConnectionPool) hostsByHealth(healthy bool) []HostAddress {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	var hosts []HostAddress
+	for _, address := range pool.addresses {
+		if pool.hosts[address].healthy == healthy {
+			hosts = append(hosts, address)
+		}
+	}
+	return hosts
+}
+
+func (pool *ConnectionPool) isClosed() bool {
+	select {
+	case <-pool.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Ping all idle connections, close the dead ones and mark their hosts as unhealthy
+func (pool *ConnectionPool) checkIdleConns() {
+	// Take idle connections out of the queue so they are not handed out while being pinged,
+	// they are still counted so no connection is opened in their place meanwhile
+	pool.rwLock.Lock()
+	pool.evictExpiredConns()
+	var conns []*connection
+	for pool.idleConnectionQueue.Len() > 0 {
+		conns = append(conns, pool.idleConnectionQueue.Remove(pool.idleConnectionQueue.Front()).(*connection))
+	}
+	pool.checking += len(conns)
+	timeout := pool.conf.getPingTimeout()
+	pool.rwLock.Unlock()
+
+	var alive, dead []*connection
+	var deadErrs []error
+	for _, conn := range conns {
+		if err := conn.ping(timeout); err != nil {
+			dead = append(dead, conn)
+			deadErrs = append(deadErrs, err)
+		} else {
+			alive = append(alive, conn)
+		}
+	}
+
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	defer pool.observeConnCount()
+	pool.checking -= len(conns)
+	closed := pool.isClosed()
+	for _, conn := range alive {
+		// The pool may have been shrunk by Resize meanwhile
+		if closed || pool.getTotalConnCount() >= pool.conf.MaxConnPoolSize {
+			pool.closeConn(conn)
+			continue
+		}
+		// A caller may have started waiting for a connection while they were counted
+		if front := pool.waiters.Front(); front != nil {
+			pool.activeConnectionQueue.PushBack(conn)
+			pool.waiters.Remove(front).(chan *connection) <- conn
+			continue
+		}
+		pool.idleConnectionQueue.PushBack(conn)
+	}
+	for i, conn := range dead {
+		pool.log.Warn(fmt.Sprintf("Health check failed, evict connection to host: %s, port: %d, %s",
+			conn.severAddress.Host, conn.severAddress.Port, deadErrs[i].Error()))
+		pool.evictConn(conn, deadErrs[i])
+		// The host may have been removed by a DNS refresh
+		pool.setHealthy(conn.severAddress, false, deadErrs[i])
+	}
+}
+
+// Try to open a transport to every unhealthy host, mark a host as healthy again if it succeeds
+func (pool *ConnectionPool) probeUnhealthyHosts() {
+	pool.rwLock.RLock()
+	var unhealthy []HostAddress
+	now := time.Now()
+	for _, address := range pool.addresses {
+		// A host whose circuit is open is left alone until the cooldown ends
+		if status := pool.hosts[address]; !status.healthy && !status.isCircuitOpen(now) {
+			unhealthy = append(unhealthy, address)
+		}
+	}
+	pool.rwLock.RUnlock()
+
+	for _, address := range unhealthy {
+		conn, err := pool.openConn(address, pool.conf.getConnTimeout())
+		if err != nil {
+			pool.rwLock.Lock()
+			pool.observeOpen(address, err)
+			pool.setHealthy(address, false, err)
+			pool.rwLock.Unlock()
+			continue
+		}
+		conn.close()
+		pool.rwLock.Lock()
+		pool.observeOpen(address, nil)
+		pool.setHealthy(address, true, nil)
+		pool.closeOffTierConns()
+		pool.rwLock.Unlock()
+		pool.log.Info(fmt.Sprintf("Host %s:%d is healthy again", address.Host, address.Port))
+	}
+}
+
+// Resolve the configured hosts periodically until the pool is closed
+func (pool *ConnectionPool) refreshAddressesPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pool.closeCh:
+			return
+		case <-ticker.C:
+			pool.refreshAddresses()
+		}
+	}
+}
+
+// Resolve the configured hosts again, start tracking the new addresses and
+// close the idle connections to the addresses which disappeared.
+// The addresses are kept if the hosts could not be resolved.
+func (pool *ConnectionPool) refreshAddresses() {
+	// The duplicates are only warned when the pool is created
+	addresses, tiers, err := resolveTiers(pool.configAddresses, pool.conf.FallbackAddresses, pool.conf, NoopLogger{})
+	if err != nil || len(addresses) == 0 {
+		pool.log.Warn(fmt.Sprintf("Failed to resolve hosts %v, the addresses are kept, error: %v", pool.configAddresses, err))
+		return
+	}
+
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	hosts := make(map[HostAddress]*hostStatus, len(addresses))
+	for _, address := range addresses {
+		status, ok := pool.hosts[address]
+		if !ok {
+			status = &hostStatus{healthy: true}
+			pool.log.Info(fmt.Sprintf("Host %s:%d is added by DNS", address.Host, address.Port))
+		}
+		status.tier = tiers[address]
+		hosts[address] = status
+	}
+	for ele := pool.idleConnectionQueue.Front(); ele != nil; {
+		next := ele.Next()
+		if conn := ele.Value.(*connection); hosts[conn.severAddress] == nil {
+			pool.evictConn(conn, nil)
+			pool.idleConnectionQueue.Remove(ele)
+		}
+		ele = next
+	}
+	for address := range pool.hosts {
+		if hosts[address] == nil {
+			pool.log.Info(fmt.Sprintf("Host %s:%d is removed by DNS", address.Host, address.Port))
+		}
+	}
+	pool.addresses = addresses
+	pool.hosts = hosts
+	pool.observeConnCount()
+}
@@ -0,0 +1,128 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+// Start a TCP server which accepts connections but never answers
+func startSilentServer(t *testing.T) (net.Listener, HostAddress) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return listener, HostAddress{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+}
+
+func TestHealthCheck(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+
+	conf := GetDefaultConf()
+	conf.TimeOut = 100 * time.Millisecond
+	conf.MinConnPoolSize = 2
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, 2, pool.getIdleConnCount())
+
+	// The server never answers, so the ping fails and the connections are evicted
+	pool.checkIdleConns()
+	assert.Equal(t, 0, pool.getIdleConnCount())
+	assert.Equal(t, 0, pool.getServerWorkload(host))
+	assert.False(t, pool.hosts[host].healthy)
+
+	// The host could still be connected, so it rejoins the rotation
+	pool.probeUnhealthyHosts()
+	assert.True(t, pool.hosts[host].healthy)
+}
+
+func TestHealthCheck_CountsCheckedConns(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+
+	conf := GetDefaultConf()
+	assert.Equal(t, defaultPingTimeout, conf.getPingTimeout())
+	conf.TimeOut = 300 * time.Millisecond
+	conf.MinConnPoolSize = 1
+	conf.MaxConnPoolSize = 1
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	done := make(chan struct{})
+	go func() {
+		pool.checkIdleConns()
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return pool.checking == 1
+	}, time.Second, time.Millisecond)
+
+	// The connection being pinged still fills the pool
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetConnectionWithContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, pool.getServerWorkload(host))
+	<-done
+	assert.Equal(t, 0, pool.getServerWorkload(host))
+}
+
+func TestPool_HealthyHosts(t *testing.T) {
+	stop1, host1 := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop1()
+	stop2, host2 := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop2()
+	conf := GetDefaultConf()
+	factory := &fakeConnectionFactory{ConnectionFactory: NewDefaultConnectionFactory(conf), down: map[HostAddress]bool{host2: true}}
+	conf.ConnectionFactory = factory
+	pool, err := NewConnectionPool([]HostAddress{host1, host2}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, []HostAddress{host1}, pool.HealthyHosts())
+	assert.Equal(t, []HostAddress{host2}, pool.UnhealthyHosts())
+	stats := pool.HostStats()
+	assert.NoError(t, stats[0].LastError)
+	if assert.Error(t, stats[1].LastError) {
+		assert.Contains(t, stats[1].LastError.Error(), "host is down")
+	}
+
+	// The reason is cleared once the host recovers
+	factory.mu.Lock()
+	factory.down = nil
+	factory.mu.Unlock()
+	pool.probeUnhealthyHosts()
+	assert.Equal(t, []HostAddress{host1, host2}, pool.HealthyHosts())
+	assert.Empty(t, pool.UnhealthyHosts())
+	assert.NoError(t, pool.HostStats()[1].LastError)
+}
@@ -10,11 +10,104 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type HostAddress struct {
 	Host string
 	Port int
+	// The share of the connections opened to the host relative to the other hosts,
+	// used by WeightedRoundRobinLoadBalancer. 0 value means the weight of 1.
+	Weight int
+}
+
+// Return the weight of the host, the unset and invalid weights are 1
+func (address HostAddress) weight() int {
+	if address.Weight <= 0 {
+		return 1
+	}
+	return address.Weight
+}
+
+// ParseHostAddress parses an address in the form of "host:port", an IPv6 host must be put in brackets,
+// e.g. "[::1]:9669". The port must be in the range [1, 65535].
+func ParseHostAddress(s string) (HostAddress, error) {
+	host, portStr, err := net.SplitHostPort(strings.TrimSpace(s))
+	if err != nil {
+		return HostAddress{}, fmt.Errorf("Failed to parse address %q: %s", s, err.Error())
+	}
+	if host == "" {
+		return HostAddress{}, fmt.Errorf("Failed to parse address %q: missing host", s)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return HostAddress{}, fmt.Errorf("Failed to parse address %q: invalid port %q", s, portStr)
+	}
+	return HostAddress{Host: host, Port: port}, nil
+}
+
+// ParseHostAddresses parses comma separated addresses, e.g. "192.168.8.1:9669, 192.168.8.2:9669",
+// every one of which is parsed by ParseHostAddress. Empty entries are skipped.
+func ParseHostAddresses(csv string) ([]HostAddress, error) {
+	var addresses []HostAddress
+	for _, s := range strings.Split(csv, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		address, err := ParseHostAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("Failed to parse addresses %q: no address is given", csv)
+	}
+	return addresses, nil
+}
+
+// Look up the IPs of a host, replaced in tests
+var lookupHost = net.LookupHost
+
+// Resolve the hosts to IPs, every IP of a host is kept if all is true, the first one otherwise.
+// The duplicated addresses are removed.
+func resolveAddresses(addresses []HostAddress, all bool) ([]HostAddress, error) {
+	var resolved []HostAddress
+	for _, host := range addresses {
+		ips, err := lookupHost(host.Host)
+		if err != nil {
+			return nil, err
+		}
+		if !all {
+			ips = ips[:1]
+		}
+		for _, ip := range ips {
+			resolved = append(resolved, HostAddress{Host: ip, Port: host.Port, Weight: host.Weight})
+		}
+	}
+	return resolved, nil
+}
+
+// Remove the addresses of the same host and port as an earlier one, which are returned as the duplicates.
+// If merge is set, nothing is removed but the weights of the duplicates are added to the first one instead,
+// so a host listed twice gets twice the connections from the weighted round-robin.
+func dedupeAddresses(addresses []HostAddress, merge bool) (deduped []HostAddress, duplicates []HostAddress) {
+	index := make(map[HostAddress]int, len(addresses))
+	for _, address := range addresses {
+		key := HostAddress{Host: address.Host, Port: address.Port}
+		i, ok := index[key]
+		switch {
+		case !ok:
+			index[key] = len(deduped)
+			deduped = append(deduped, address)
+		case merge:
+			deduped[i].Weight = deduped[i].weight() + address.weight()
+		default:
+			duplicates = append(duplicates, address)
+		}
+	}
+	return deduped, duplicates
 }
 
 func DomainToIP(addresses []HostAddress) ([]HostAddress, error) {
@@ -26,7 +119,7 @@ func DomainToIP(addresses []HostAddress) ([]HostAddress, error) {
 			fmt.Fprintf(os.Stderr, "Could not get IPs: %v\n", err)
 			return nil, err
 		}
-		convHost := HostAddress{Host: ips[0].String(), Port: host.Port}
+		convHost := HostAddress{Host: ips[0].String(), Port: host.Port, Weight: host.Weight}
 		newHostsList = append(newHostsList, convHost)
 	}
 	return newHostsList, nil
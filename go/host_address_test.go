@@ -0,0 +1,67 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHostAddress(t *testing.T) {
+	for s, expected := range map[string]HostAddress{
+		"127.0.0.1:9669":  {Host: "127.0.0.1", Port: 9669},
+		" graphd:3699 ":   {Host: "graphd", Port: 3699},
+		"[::1]:9669":      {Host: "::1", Port: 9669},
+		"[fe80::1%lo]:1":  {Host: "fe80::1%lo", Port: 1},
+		"localhost:65535": {Host: "localhost", Port: 65535},
+	} {
+		address, err := ParseHostAddress(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, address, s)
+	}
+
+	for _, s := range []string{"", "graphd", "::1:9669", ":9669", "graphd:", "graphd:0", "graphd:65536", "graphd:port"} {
+		_, err := ParseHostAddress(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseHostAddresses(t *testing.T) {
+	addresses, err := ParseHostAddresses("127.0.0.1:9669, [::1]:9669,")
+	assert.NoError(t, err)
+	assert.Equal(t, []HostAddress{{Host: "127.0.0.1", Port: 9669}, {Host: "::1", Port: 9669}}, addresses)
+
+	_, err = ParseHostAddresses("127.0.0.1:9669,graphd")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `"graphd"`)
+	}
+	_, err = ParseHostAddresses(" , ")
+	assert.Error(t, err)
+}
+
+func TestDedupeAddresses(t *testing.T) {
+	addresses := []HostAddress{
+		{Host: "127.0.0.1", Port: 9669},
+		{Host: "127.0.0.2", Port: 9669, Weight: 2},
+		{Host: "127.0.0.1", Port: 9669, Weight: 3},
+		{Host: "127.0.0.1", Port: 9670},
+		{Host: "127.0.0.2", Port: 9669},
+	}
+	deduped, duplicates := dedupeAddresses(addresses, false)
+	assert.Equal(t, []HostAddress{addresses[0], addresses[1], addresses[3]}, deduped)
+	assert.Equal(t, []HostAddress{addresses[2], addresses[4]}, duplicates)
+
+	// The weights are added up, an unset weight counts as 1
+	deduped, duplicates = dedupeAddresses(addresses, true)
+	assert.Equal(t, []HostAddress{
+		{Host: "127.0.0.1", Port: 9669, Weight: 4},
+		{Host: "127.0.0.2", Port: 9669, Weight: 3},
+		{Host: "127.0.0.1", Port: 9670},
+	}, deduped)
+	assert.Empty(t, duplicates)
+}
@@ -0,0 +1,118 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import "fmt"
+
+// HostTier tells whether a host is one of the addresses given to NewConnectionPool or PoolConfig.FallbackAddresses
+type HostTier int
+
+const (
+	// TierPrimary is the tier of the addresses given to NewConnectionPool
+	TierPrimary HostTier = iota
+	// TierFallback is the tier of PoolConfig.FallbackAddresses, used only if no primary host is healthy
+	TierFallback
+)
+
+func (tier HostTier) String() string {
+	if tier == TierFallback {
+		return "fallback"
+	}
+	return "primary"
+}
+
+// Resolve the primary and the fallback hosts unless DisableResolution is set.
+// The duplicates in a tier are removed with a warning, or merged if AllowDuplicateHosts is set, see dedupeAddresses.
+// The fallback addresses are put after the primary ones, an address in both tiers is a primary one.
+func resolveTiers(primary, fallback []HostAddress, conf PoolConfig, log Logger) ([]HostAddress, map[HostAddress]HostTier, error) {
+	if !conf.DisableResolution {
+		var err error
+		if primary, err = resolveAddresses(primary, conf.ResolveAllIPs); err != nil {
+			return nil, nil, err
+		}
+		if fallback, err = resolveAddresses(fallback, conf.ResolveAllIPs); err != nil {
+			return nil, nil, err
+		}
+	}
+	var duplicates, fallbackDuplicates []HostAddress
+	primary, duplicates = dedupeAddresses(primary, conf.AllowDuplicateHosts)
+	fallback, fallbackDuplicates = dedupeAddresses(fallback, conf.AllowDuplicateHosts)
+	for _, address := range append(duplicates, fallbackDuplicates...) {
+		log.Warn(fmt.Sprintf("Host %s:%d is listed more than once, the duplicate has been removed, "+
+			"set AllowDuplicateHosts to keep it", address.Host, address.Port))
+	}
+
+	tiers := make(map[HostAddress]HostTier, len(primary)+len(fallback))
+	addresses := make([]HostAddress, 0, len(primary)+len(fallback))
+	inPrimary := make(map[HostAddress]bool, len(primary))
+	for _, address := range primary {
+		inPrimary[HostAddress{Host: address.Host, Port: address.Port}] = true
+		tiers[address] = TierPrimary
+		addresses = append(addresses, address)
+	}
+	for _, address := range fallback {
+		if !inPrimary[HostAddress{Host: address.Host, Port: address.Port}] {
+			tiers[address] = TierFallback
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, tiers, nil
+}
+
+// ActiveTier returns the tier new connections are opened to, TierFallback only if fallback addresses
+// are configured and all primary hosts are unhealthy
+func (pool *ConnectionPool) ActiveTier() HostTier {
+	pool.rwLock.RLock()
+	defer pool.rwLock.RUnlock()
+	return pool.activeTier()
+}
+
+// Return the tier new connections are opened to, must be called with the lock held
+func (pool *ConnectionPool) activeTier() HostTier {
+	hasFallback := false
+	for _, address := range pool.addresses {
+		status := pool.hosts[address]
+		if status.tier == TierPrimary && status.healthy {
+			return TierPrimary
+		}
+		if status.tier == TierFallback && status.healthy {
+			hasFallback = true
+		}
+	}
+	if hasFallback {
+		return TierFallback
+	}
+	return TierPrimary
+}
+
+// Check if the connection is opened to a host of another tier than the active one, it is not reused then
+// so the pool fails back to the primary hosts once one of them recovers. Must be called with the lock held.
+func (pool *ConnectionPool) isOffTier(conn *connection, activeTier HostTier) bool {
+	status, ok := pool.hosts[conn.severAddress]
+	return ok && status.tier != activeTier
+}
+
+// Return the tier of the host the connection is opened to, must be called with the lock held
+func (pool *ConnectionPool) tierOf(conn *connection) HostTier {
+	if status, ok := pool.hosts[conn.severAddress]; ok {
+		return status.tier
+	}
+	return TierPrimary
+}
+
+// Close the idle connections to the hosts not in the active tier, must be called with the lock held
+func (pool *ConnectionPool) closeOffTierConns() {
+	tier := pool.activeTier()
+	for ele := pool.idleConnectionQueue.Front(); ele != nil; {
+		next := ele.Next()
+		if conn := ele.Value.(*connection); pool.isOffTier(conn, tier) {
+			pool.idleConnectionQueue.Remove(ele)
+			pool.evictConn(conn, nil)
+		}
+		ele = next
+	}
+}
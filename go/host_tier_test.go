@@ -0,0 +1,110 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+// A dialer which could take a host down, closing the connections to it and refusing new ones
+type outageDialer struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	conns map[string][]net.Conn
+}
+
+func (d *outageDialer) dial(ctx context.Context, address string) (net.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down[address] {
+		return nil, fmt.Errorf("connection refused")
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err == nil {
+		d.conns[address] = append(d.conns[address], conn)
+	}
+	return conn, err
+}
+
+func (d *outageDialer) setDown(host HostAddress, down bool) {
+	address := net.JoinHostPort(host.Host, strconv.Itoa(host.Port))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down[address] = down
+	if down {
+		for _, conn := range d.conns[address] {
+			conn.Close()
+		}
+		d.conns[address] = nil
+	}
+}
+
+func TestPool_FallbackAddresses(t *testing.T) {
+	stopPrimary, primary := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stopPrimary()
+	stopFallback, fallback := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stopFallback()
+	dialer := &outageDialer{down: make(map[string]bool), conns: make(map[string][]net.Conn)}
+
+	conf := GetDefaultConf()
+	conf.FallbackAddresses = []HostAddress{fallback}
+	conf.HealthCheckInterval = 20 * time.Millisecond
+	conf.Dialer = dialer.dial
+	pool, err := NewConnectionPool([]HostAddress{primary}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, []HostAddress{primary, fallback}, pool.addresses)
+
+	// The fallback host is not used while the primary one is healthy
+	for i := 0; i < 3; i++ {
+		conn, err := pool.GetConnection()
+		if assert.NoError(t, err) {
+			assert.Equal(t, primary, conn.severAddress)
+			defer pool.Release(conn)
+		}
+	}
+	assert.Equal(t, TierPrimary, pool.ActiveTier())
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Release(conn)
+
+	// The primary host goes down, the health check finds it out from the idle connection
+	dialer.setDown(primary, true)
+	assert.Eventually(t, func() bool { return pool.ActiveTier() == TierFallback }, time.Second, 10*time.Millisecond)
+	conn, err = pool.GetConnection()
+	if assert.NoError(t, err) {
+		assert.Equal(t, fallback, conn.severAddress)
+		stats := pool.Stats()
+		assert.Equal(t, TierFallback, stats[len(stats)-1].Tier)
+		pool.Release(conn)
+	}
+	assert.Equal(t, 1, pool.getIdleConnCount())
+
+	// The primary host recovers, the idle connection to the fallback host is closed
+	dialer.setDown(primary, false)
+	assert.Eventually(t, func() bool { return pool.ActiveTier() == TierPrimary }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return pool.getServerWorkload(fallback) == 0 }, time.Second, 10*time.Millisecond)
+	conn, err = pool.GetConnection()
+	if assert.NoError(t, err) {
+		assert.Equal(t, primary, conn.severAddress)
+		pool.Release(conn)
+	}
+}
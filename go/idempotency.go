@@ -0,0 +1,149 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// The keywords starting a statement or a part of a pipe which only reads
+var readOnlyKeywords = map[string]bool{
+	"GO": true, "FETCH": true, "LOOKUP": true, "MATCH": true, "FIND": true, "GET": true,
+	"YIELD": true, "SHOW": true, "DESCRIBE": true, "DESC": true, "USE": true, "RETURN": true,
+	"UNWIND": true, "WITH": true, "ORDER": true, "LIMIT": true, "GROUP": true,
+}
+
+// IsReadOnlyStatement tells if the statement only reads, so it is safe to execute it again when it is not
+// known whether graphd has executed it, e.g. the transport broke after the statement was sent.
+// It is the default of PoolConfig.IdempotencyClassifier.
+//
+// The statement is split into parts by the semicolons and the pipes outside literals and comments,
+// and it is read-only if every part starts with a reading keyword, such as GO, FETCH, LOOKUP, MATCH,
+// FIND, GET, YIELD, SHOW, DESCRIBE, USE, RETURN, or ORDER BY, LIMIT and GROUP BY after a pipe.
+// An assignment like $a = GO ... is classified by its right side. A statement under EXPLAIN is read-only,
+// PROFILE executes the statement so it is classified by the statement after it.
+// Anything else, including an empty statement, is classified as a write.
+func IsReadOnlyStatement(stmt string) bool {
+	parts := splitStatement(stmt)
+	if len(parts) == 0 {
+		return false
+	}
+	switch keyword, rest := firstKeyword(parts[0]); keyword {
+	case "EXPLAIN":
+		return true
+	case "PROFILE":
+		// PROFILE FORMAT="row" executes the statement after it
+		if k, r := firstKeyword(rest); k == "FORMAT" {
+			if end := strings.IndexAny(r, " \t\r\n"); end >= 0 {
+				rest = r[end:]
+			}
+		}
+		parts[0] = rest
+	}
+	for _, part := range parts {
+		if keyword, _ := firstKeyword(part); !readOnlyKeywords[keyword] {
+			return false
+		}
+	}
+	return true
+}
+
+// Split the statement by the semicolons and pipes outside literals, quoted labels and comments,
+// the comments are removed and the empty parts are skipped
+func splitStatement(stmt string) []string {
+	var parts []string
+	var b strings.Builder
+	flush := func() {
+		if part := strings.TrimSpace(b.String()); part != "" {
+			parts = append(parts, part)
+		}
+		b.Reset()
+	}
+	var quote byte
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		if quote != 0 {
+			b.WriteByte(c)
+			if c == '\\' && i+1 < len(stmt) {
+				i++
+				b.WriteByte(stmt[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+			b.WriteByte(c)
+		case strings.HasPrefix(stmt[i:], "/*"):
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				i = len(stmt)
+			} else {
+				i += end + 3
+			}
+			b.WriteByte(' ')
+		case c == '#' || strings.HasPrefix(stmt[i:], "//") || strings.HasPrefix(stmt[i:], "--"):
+			end := strings.IndexByte(stmt[i:], '\n')
+			if end < 0 {
+				i = len(stmt)
+			} else {
+				i += end
+			}
+			b.WriteByte(' ')
+		case strings.HasPrefix(stmt[i:], "||"):
+			// The logical OR, not a pipe
+			b.WriteString("||")
+			i++
+		case c == ';' || c == '|':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return parts
+}
+
+// Return the first word of the part in upper case and the text after it,
+// opening parentheses and an assignment to a variable are skipped
+func firstKeyword(part string) (string, string) {
+	part = strings.TrimLeftFunc(part, func(r rune) bool { return unicode.IsSpace(r) || r == '(' })
+	if strings.HasPrefix(part, "$") {
+		if eq := strings.IndexByte(part, '='); eq > 0 {
+			return firstKeyword(part[eq+1:])
+		}
+	}
+	end := strings.IndexFunc(part, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(part)
+	}
+	return strings.ToUpper(part[:end]), part[end:]
+}
+
+type idempotentKey struct{}
+
+// Return a context marking the statement executed with it as idempotent or not, which overrides
+// PoolConfig.IdempotencyClassifier
+func withIdempotent(ctx context.Context, idempotent bool) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, idempotent)
+}
+
+// Tell if the statement could be executed again after an ambiguous failure, the mark set by withIdempotent
+// takes precedence over the classifier of the config
+func (session *Session) isIdempotent(ctx context.Context, stmt string) bool {
+	if idempotent, ok := ctx.Value(idempotentKey{}).(bool); ok {
+		return idempotent
+	}
+	if classify := session.connPool.conf.IdempotencyClassifier; classify != nil {
+		return classify(stmt)
+	}
+	return IsReadOnlyStatement(stmt)
+}
@@ -0,0 +1,120 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	for _, stmt := range []string{
+		"GO FROM 1 OVER follow",
+		"  fetch prop on player 1",
+		"USE nba; MATCH (v) RETURN v LIMIT 1",
+		"GO FROM 1 OVER follow YIELD follow._dst AS id | ORDER BY $-.id | LIMIT 10",
+		"GO FROM 1 OVER follow WHERE $$.player.age > 30 || $$.player.age < 20",
+		"$a = GO FROM 1 OVER follow; GO FROM $a.id OVER serve",
+		"(GO FROM 1 OVER follow) UNION (GO FROM 2 OVER follow)",
+		"EXPLAIN INSERT VERTEX player(name) VALUES 1:(\"Tim\")",
+		"PROFILE FORMAT=\"row\" GO FROM 1 OVER follow",
+		"/* traceID=abc */ SHOW SPACES",
+		"YIELD \"a; DELETE VERTEX 1\"",
+		"# comment\nYIELD 1",
+	} {
+		assert.True(t, IsReadOnlyStatement(stmt), stmt)
+	}
+	for _, stmt := range []string{
+		"",
+		"INSERT VERTEX player(name) VALUES 1:(\"Tim\")",
+		"USE nba; DELETE VERTEX 1",
+		"GO FROM 1 OVER follow YIELD follow._dst AS id | DELETE VERTEX $-.id",
+		"PROFILE UPDATE VERTEX 1 SET player.age = 1",
+		"$a = INSERT VERTEX player(name) VALUES 1:(\"Tim\")",
+		"CREATE SPACE test",
+		"/* GO */ DROP SPACE nba",
+	} {
+		assert.False(t, IsReadOnlyStatement(stmt), stmt)
+	}
+}
+
+// A connection whose next read fails after the response arrives, if the flag is set
+type breakingConn struct {
+	net.Conn
+	breakNext *bool
+	mu        *sync.Mutex
+}
+
+func (c *breakingConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	broken := *c.breakNext
+	*c.breakNext = false
+	c.mu.Unlock()
+	if broken {
+		// Drop the response so the statement has been executed but the caller could not tell
+		c.Conn.Read(b)
+		c.Conn.Close()
+	}
+	return c.Conn.Read(b)
+}
+
+func TestSession_Idempotent(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	var mu sync.Mutex
+	breakNext := false
+	conf := GetDefaultConf()
+	conf.MaxRetries = 1
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return &breakingConn{Conn: conn, breakNext: &breakNext, mu: &mu}, nil
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	execute := func(fn func(string) (*ResultSet, error), stmt string) error {
+		mu.Lock()
+		breakNext = true
+		mu.Unlock()
+		service.ResetStatements()
+		_, err := fn(stmt)
+		return err
+	}
+
+	// A read is retried
+	assert.NoError(t, execute(session.Execute, "YIELD 1"))
+	assert.Equal(t, []string{"YIELD 1", "YIELD 1"}, service.Statements())
+	// A write is not
+	insert := "INSERT VERTEX player(name) VALUES 1:(\"Tim\")"
+	assert.Error(t, execute(session.Execute, insert))
+	assert.Equal(t, []string{insert}, service.Statements())
+	// Unless it is marked as idempotent
+	assert.NoError(t, execute(session.ExecuteIdempotent, insert))
+	assert.Equal(t, []string{insert, insert}, service.Statements())
+
+	// The classifier could be replaced
+	pool.conf.IdempotencyClassifier = func(stmt string) bool { return true }
+	assert.NoError(t, execute(session.Execute, insert))
+	assert.Len(t, service.Statements(), 2)
+}
@@ -0,0 +1,47 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+// Interceptor wraps the execution of a statement by a session, see Session.RegisterInterceptor.
+// It calls next to go on with the statement, rewritten or not, and returns its result or another one.
+// It may also return without calling next, e.g. to serve a result from a cache or to reject the statement,
+// it must return a result set or an error then.
+type Interceptor func(stmt string, next func(string) (*ResultSet, error)) (*ResultSet, error)
+
+// RegisterInterceptor adds an interceptor to the session. The interceptors run in registration order:
+// the first registered one is the outermost and gets the statement first, its next calls the second one,
+// and the next of the last one executes the statement. An error returned by next, or by the session, goes back
+// through the interceptors in reverse order, each of them could return it as is, wrap it or replace it,
+// the caller gets what the first one returns.
+// The interceptors wrap Execute, ExecuteWithContext and the methods built on them, e.g. ExecuteBatch,
+// while ExecuteRaw, ExecuteJson and ExecuteJsonTo bypass them, as do the statements the client executes itself,
+// e.g. the OnConnect ones and the USE of Reset. The statement they get is the one given by the caller,
+// it is trimmed and checked for emptiness after the last interceptor. They run without the lock of the session
+// held, so they could execute other statements on the session, which go through all interceptors again.
+func (session *Session) RegisterInterceptor(interceptor Interceptor) {
+	if interceptor == nil {
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.interceptors = append(session.interceptors, interceptor)
+}
+
+// Run the statement through the interceptors of the session, execute does the real execution
+func (session *Session) intercept(stmt string, execute func(string) (*ResultSet, error)) (*ResultSet, error) {
+	session.mu.Lock()
+	interceptors := session.interceptors
+	session.mu.Unlock()
+	next := execute
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := interceptors[i], next
+		next = func(stmt string) (*ResultSet, error) {
+			return interceptor(stmt, inner)
+		}
+	}
+	return next(stmt)
+}
@@ -0,0 +1,85 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestSession_RegisterInterceptor(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.OnConnect = []string{"YIELD 0"}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	service.ResetStatements()
+
+	// Registration order, the first one is the outermost
+	var calls []string
+	session.RegisterInterceptor(func(stmt string, next func(string) (*ResultSet, error)) (*ResultSet, error) {
+		calls = append(calls, "first "+stmt)
+		resultSet, err := next(stmt + " AS a")
+		if err != nil {
+			return nil, fmt.Errorf("first: %w", err)
+		}
+		return resultSet, nil
+	})
+	session.RegisterInterceptor(func(stmt string, next func(string) (*ResultSet, error)) (*ResultSet, error) {
+		calls = append(calls, "second "+stmt)
+		if strings.HasPrefix(stmt, "DROP") {
+			return nil, errors.New("rejected")
+		}
+		return next(stmt)
+	})
+	session.RegisterInterceptor(nil)
+
+	resultSet, err := session.Execute("YIELD 1")
+	if assert.NoError(t, err) {
+		assert.True(t, resultSet.IsSucceeded())
+	}
+	assert.Equal(t, []string{"first YIELD 1", "second YIELD 1 AS a"}, calls)
+	assert.Equal(t, []string{"YIELD 1 AS a"}, service.Statements())
+
+	// A short circuit never reaches graphd, its error is propagated outwards
+	_, err = session.Execute("DROP SPACE test")
+	if assert.Error(t, err) {
+		assert.Equal(t, "first: rejected", err.Error())
+	}
+	assert.Len(t, service.Statements(), 1)
+
+	// The checks of the session apply to the rewritten statement
+	calls = nil
+	_, err = session.ExecuteBatch([]string{"YIELD 2"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first YIELD 2", "second YIELD 2 AS a"}, calls)
+
+	// The statements of the client itself and ExecuteRaw bypass the interceptors
+	calls = nil
+	service.ResetStatements()
+	assert.NoError(t, session.Reset())
+	_, err = session.ExecuteRaw("YIELD 3")
+	assert.NoError(t, err)
+	assert.Empty(t, calls)
+	assert.Equal(t, []string{"YIELD 0", "YIELD 3"}, service.Statements())
+}
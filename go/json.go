@@ -0,0 +1,162 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// MarshalJSON encodes the result as {"columns": [...], "rows": [[...], ...]}, the values of a row are
+// in the order of the columns and encoded as ValueWrapper.MarshalJSON does.
+func (res ResultSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dataSetJSON(res.columnNames, res.getRows(), res.vidType, res.utf8Policy))
+}
+
+// MarshalJSON encodes the record as an object from the column names to the values
+func (record Record) MarshalJSON() ([]byte, error) {
+	values := make(map[string]ValueWrapper, len(record.columnNames))
+	for i, name := range record.columnNames {
+		if i < len(record._record) {
+			values[name] = ValueWrapper{value: record._record[i], vidType: record.vidType, utf8Policy: record.utf8Policy}
+		}
+	}
+	return json.Marshal(values)
+}
+
+// MarshalJSON encodes the value as:
+//   - null for empty and null values
+//   - a boolean, an integer without precision loss, a number or a string for the primitive values.
+//     NaN and infinite floats, which JSON could not represent, are encoded as the strings "NaN", "+Inf" and "-Inf".
+//     A string which is not valid UTF-8 fails with UTF8Strict, its invalid bytes are replaced with U+FFFD otherwise
+//   - "2006-01-02", "15:04:05.000000" and "2006-01-02T15:04:05.000000" strings for date, time and datetime
+//   - an object for vertex, edge and path, see Node, Relationship and PathWrapper
+//   - an array for list and set, an object for map, {"columns": [...], "rows": [...]} for dataset
+func (valWrap ValueWrapper) MarshalJSON() ([]byte, error) {
+	value := valWrap.value
+	switch {
+	case value == nil || value.IsSetNVal():
+		return []byte("null"), nil
+	case value.IsSetBVal():
+		return json.Marshal(value.GetBVal())
+	case value.IsSetIVal():
+		return json.Marshal(value.GetIVal())
+	case value.IsSetFVal():
+		f := value.GetFVal()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return json.Marshal(f)
+	case value.IsSetSVal():
+		s, err := valWrap.AsString()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(s)
+	case value.IsSetDVal():
+		t, _ := valWrap.AsDate()
+		return json.Marshal(t.Format("2006-01-02"))
+	case value.IsSetTVal():
+		t, _ := valWrap.AsTime()
+		return json.Marshal(t.Format("15:04:05.000000"))
+	case value.IsSetDtVal():
+		t, _ := valWrap.AsDateTime()
+		return json.Marshal(t.Format("2006-01-02T15:04:05.000000"))
+	case value.IsSetVVal():
+		node, err := valWrap.AsNode()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(node)
+	case value.IsSetEVal():
+		relationship, err := valWrap.AsRelationship()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(relationship)
+	case value.IsSetPVal():
+		path, err := valWrap.AsPath()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(path)
+	case value.IsSetLVal():
+		return json.Marshal(wrapValues(value.GetLVal().GetValues(), valWrap.vidType, valWrap.utf8Policy))
+	case value.IsSetUVal():
+		return json.Marshal(wrapValues(value.GetUVal().GetValues(), valWrap.vidType, valWrap.utf8Policy))
+	case value.IsSetMVal():
+		return json.Marshal(wrapProps(value.GetMVal().GetKvs(), valWrap.vidType, valWrap.utf8Policy))
+	case value.IsSetGVal():
+		dataSet := value.GetGVal()
+		var names []string
+		for _, name := range dataSet.GetColumnNames() {
+			names = append(names, string(name))
+		}
+		return json.Marshal(dataSetJSON(names, dataSet.GetRows(), valWrap.vidType, valWrap.utf8Policy))
+	default:
+		return []byte("null"), nil
+	}
+}
+
+// MarshalJSON encodes the node as {"vid": ..., "tags": {"<tag>": {"<prop>": ...}}}
+func (node Node) MarshalJSON() ([]byte, error) {
+	tags := make(map[string]map[string]*ValueWrapper, len(node.vertex.GetTags()))
+	for _, tag := range node.vertex.GetTags() {
+		tags[string(tag.GetName())] = wrapProps(tag.GetProps(), node.vidType, node.utf8Policy)
+	}
+	return json.Marshal(struct {
+		Vid  ValueWrapper                        `json:"vid"`
+		Tags map[string]map[string]*ValueWrapper `json:"tags"`
+	}{node.GetID(), tags})
+}
+
+// MarshalJSON encodes the relationship as {"src": ..., "dst": ..., "name": ..., "ranking": ..., "props": {...}}
+func (relationship Relationship) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Src     ValueWrapper             `json:"src"`
+		Dst     ValueWrapper             `json:"dst"`
+		Name    string                   `json:"name"`
+		Ranking int64                    `json:"ranking"`
+		Props   map[string]*ValueWrapper `json:"props"`
+	}{relationship.SrcID(), relationship.DstID(), relationship.EdgeName(), relationship.Ranking(), relationship.Properties()})
+}
+
+// MarshalJSON encodes the path as {"nodes": [...], "relationships": [...]}
+func (path PathWrapper) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Nodes         []*Node         `json:"nodes"`
+		Relationships []*Relationship `json:"relationships"`
+	}{path.GetNodes(), path.GetRelationships()})
+}
+
+// The JSON form of a result, the values of a row are in the order of the columns
+type dataSetJSONForm struct {
+	Columns []string         `json:"columns"`
+	Rows    [][]ValueWrapper `json:"rows"`
+}
+
+func dataSetJSON(columnNames []string, rows []*nebula.Row, vidType VIDType, utf8Policy UTF8Policy) dataSetJSONForm {
+	form := dataSetJSONForm{Columns: columnNames, Rows: make([][]ValueWrapper, 0, len(rows))}
+	if form.Columns == nil {
+		form.Columns = []string{}
+	}
+	for _, row := range rows {
+		form.Rows = append(form.Rows, wrapValues(row.GetValues(), vidType, utf8Policy))
+	}
+	return form
+}
+
+func wrapValues(values []*nebula.Value, vidType VIDType, utf8Policy UTF8Policy) []ValueWrapper {
+	wrapped := make([]ValueWrapper, 0, len(values))
+	for _, value := range values {
+		wrapped = append(wrapped, ValueWrapper{value: value, vidType: vidType, utf8Policy: utf8Policy})
+	}
+	return wrapped
+}
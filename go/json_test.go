@@ -0,0 +1,67 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+func TestResultSet_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(newResultSet(genResp()))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"columns": ["name", "age"], "rows": [["Bob", 10], ["Tom", 11]]}`, string(data))
+
+	record, err := newResultSet(genResp()).GetRowValuesByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = json.Marshal(record)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Bob", "age": 10}`, string(data))
+}
+
+func TestValueWrapper_MarshalJSON(t *testing.T) {
+	null := nebula.NullType___NULL__
+	nan := math.NaN()
+	edge := &nebula.Edge{
+		Src:     nebula.VertexID("Bob"),
+		Dst:     nebula.VertexID("Tom"),
+		Name:    []byte("like"),
+		Ranking: 2,
+		Props:   map[string]*nebula.Value{"likeness": intValue(90)},
+	}
+	cases := []struct {
+		value *nebula.Value
+		json  string
+	}{
+		{nil, `null`},
+		{&nebula.Value{NVal: &null}, `null`},
+		// Larger than the max integer a float64 could represent exactly
+		{intValue(math.MaxInt64), `9223372036854775807`},
+		{&nebula.Value{FVal: &nan}, `"NaN"`},
+		{&nebula.Value{DtVal: &nebula.DateTime{Year: 2020, Month: 10, Day: 1, Hour: 8, Sec: 1, Microsec: 5}},
+			`"2020-10-01T08:00:01.000005"`},
+		{&nebula.Value{VVal: genVertex("Bob", "person")},
+			`{"vid": "Bob", "tags": {"person": {"name": "Bob"}}}`},
+		{&nebula.Value{EVal: edge},
+			`{"src": "Bob", "dst": "Tom", "name": "like", "ranking": 2, "props": {"likeness": 90}}`},
+		{&nebula.Value{LVal: &nebula.List{Values: []*nebula.Value{intValue(1), {NVal: &null}}}}, `[1, null]`},
+		{&nebula.Value{MVal: &nebula.Map{Kvs: map[string]*nebula.Value{"a": strValue("b")}}}, `{"a": "b"}`},
+	}
+	for _, c := range cases {
+		data, err := json.Marshal(ValueWrapper{value: c.value})
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, c.json, string(data))
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// LoadBalancer decides which host a new connection of the pool is opened to.
+// Implementations must be safe for concurrent use.
+type LoadBalancer interface {
+	// Select the index of a host in hosts.
+	// workload[i] is the number of connections currently opened to hosts[i].
+	// Only healthy hosts are passed in, and hosts is never empty.
+	Select(hosts []HostAddress, workload []int) int
+}
+
+// RoundRobinLoadBalancer picks hosts one after another, the weights of the hosts are ignored
+type RoundRobinLoadBalancer struct {
+	index uint64
+}
+
+// NewRoundRobinLoadBalancer returns a round-robin LoadBalancer
+func NewRoundRobinLoadBalancer() *RoundRobinLoadBalancer {
+	return &RoundRobinLoadBalancer{}
+}
+
+func (lb *RoundRobinLoadBalancer) Select(hosts []HostAddress, workload []int) int {
+	next := atomic.AddUint64(&lb.index, 1) - 1
+	return int(next % uint64(len(hosts)))
+}
+
+// WeightedRoundRobinLoadBalancer picks hosts one after another in proportion to HostAddress.Weight,
+// e.g. a host of weight 3 is picked 3 times as often as a host of weight 1, and the picks of a host
+// are spread out instead of coming in a row. It is the default LoadBalancer of the pool, which is
+// round-robin if no weight is set.
+type WeightedRoundRobinLoadBalancer struct {
+	mu sync.Mutex
+	// The current weights of the smooth weighted round-robin, kept across the changes of the healthy hosts
+	current map[HostAddress]int
+}
+
+// NewWeightedRoundRobinLoadBalancer returns a weighted round-robin LoadBalancer
+func NewWeightedRoundRobinLoadBalancer() *WeightedRoundRobinLoadBalancer {
+	return &WeightedRoundRobinLoadBalancer{current: make(map[HostAddress]int)}
+}
+
+func (lb *WeightedRoundRobinLoadBalancer) Select(hosts []HostAddress, workload []int) int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	selected, total := 0, 0
+	for i, host := range hosts {
+		lb.current[host] += host.weight()
+		total += host.weight()
+		if lb.current[host] > lb.current[hosts[selected]] {
+			selected = i
+		}
+	}
+	lb.current[hosts[selected]] -= total
+	// Forget the hosts which are no longer passed in, so the map does not grow with the DNS changes
+	if len(lb.current) > len(hosts) {
+		passed := make(map[HostAddress]bool, len(hosts))
+		for _, host := range hosts {
+			passed[host] = true
+		}
+		for host := range lb.current {
+			if !passed[host] {
+				delete(lb.current, host)
+			}
+		}
+	}
+	return selected
+}
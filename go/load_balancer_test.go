@@ -0,0 +1,104 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestRoundRobinLoadBalancer(t *testing.T) {
+	lb := NewRoundRobinLoadBalancer()
+	workload := make([]int, len(poolAddress))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	counts := make(map[int]int)
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			index := lb.Select(poolAddress, workload)
+			mu.Lock()
+			counts[index]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for i := range poolAddress {
+		assert.Equal(t, 100, counts[i])
+	}
+}
+
+func TestWeightedRoundRobinLoadBalancer(t *testing.T) {
+	lb := NewWeightedRoundRobinLoadBalancer()
+	hosts := []HostAddress{
+		{Host: "127.0.0.1", Port: 3699, Weight: 3},
+		{Host: "127.0.0.1", Port: 3700},
+		{Host: "127.0.0.1", Port: 3701, Weight: 2},
+	}
+	workload := make([]int, len(hosts))
+
+	var picks []int
+	counts := make(map[int]int)
+	for i := 0; i < 600; i++ {
+		index := lb.Select(hosts, workload)
+		picks = append(picks, index)
+		counts[index]++
+	}
+	assert.Equal(t, 300, counts[0])
+	assert.Equal(t, 100, counts[1])
+	assert.Equal(t, 200, counts[2])
+	// The picks of the heaviest host are spread out
+	assert.Equal(t, []int{0, 2, 0, 1, 2, 0}, picks[:6])
+
+	// Without weights it is round-robin
+	lb = NewWeightedRoundRobinLoadBalancer()
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, i%len(poolAddress), lb.Select(poolAddress, workload))
+	}
+	// A host being left out is forgotten
+	assert.Equal(t, 0, lb.Select(poolAddress[:1], workload[:1]))
+	assert.Len(t, lb.current, 1)
+}
+
+func TestPool_WeightedHosts(t *testing.T) {
+	stopHeavy, heavy := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stopHeavy()
+	stopLight, light := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stopLight()
+	heavy.Weight = 3
+
+	conf := GetDefaultConf()
+	conf.MaxConnPoolSize = 40
+	pool, err := NewConnectionPool([]HostAddress{heavy, light}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	counts := make(map[HostAddress]int)
+	var sessions []*Session
+	for i := 0; i < 40; i++ {
+		session, err := pool.GetSession("root", "nebula")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+		counts[session.GetHostAddress()]++
+	}
+	for _, session := range sessions {
+		session.Release()
+	}
+	assert.InDelta(t, 30, counts[heavy], 2)
+	assert.InDelta(t, 10, counts[light], 2)
+}
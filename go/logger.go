@@ -17,6 +17,7 @@ type Logger interface {
 	Fatal(msg string)
 }
 
+// DefaultLogger writes messages with the standard log package
 type DefaultLogger struct{}
 
 func (l DefaultLogger) Info(msg string) {
@@ -34,3 +35,14 @@ func (l DefaultLogger) Error(msg string) {
 func (l DefaultLogger) Fatal(msg string) {
 	log.Fatalf("[FATAL] %s", msg)
 }
+
+// NoopLogger discards all messages, it is used when no logger is given
+type NoopLogger struct{}
+
+func (l NoopLogger) Info(msg string) {}
+
+func (l NoopLogger) Warn(msg string) {}
+
+func (l NoopLogger) Error(msg string) {}
+
+func (l NoopLogger) Fatal(msg string) {}
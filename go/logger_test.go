@@ -0,0 +1,70 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A logger keeping the warnings in memory
+type recordLogger struct {
+	NoopLogger
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordLogger) Warn(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, msg)
+}
+
+// Return a local address nothing is listening on
+func closedAddress(t *testing.T) HostAddress {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return HostAddress{Host: "127.0.0.1", Port: port}
+}
+
+func TestLogger(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+	unreachable := closedAddress(t)
+
+	log := &recordLogger{}
+	conf := GetDefaultConf()
+	conf.TimeOut = 100 * time.Millisecond
+	conf.Logger = log
+	// The logger in the config takes precedence
+	pool, err := NewConnectionPool([]HostAddress{host, unreachable}, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, log, pool.log)
+	if assert.Len(t, log.warnings, 1) {
+		assert.Contains(t, log.warnings[0], "is unreachable")
+	}
+
+	// Nothing is logged without a logger
+	conf.Logger = nil
+	noopPool, err := NewConnectionPool([]HostAddress{host}, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer noopPool.Close()
+	assert.Equal(t, NoopLogger{}, noopPool.log)
+}
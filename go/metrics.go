@@ -0,0 +1,33 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"time"
+)
+
+// MetricsObserver receives the metrics of the pool, e.g. to export them to Prometheus.
+// The methods are called synchronously, some of them with the lock of the pool held,
+// so they must return quickly and must not call the pool.
+type MetricsObserver interface {
+	// Called after a query is executed, including the retries.
+	// err is an *ExecutionError if the query failed on the server side.
+	ObserveExecute(duration time.Duration, err error)
+	// Called after a connection is taken from the pool, waited includes the time to open a new one
+	ObservePoolGet(waited time.Duration)
+	// Called with the number of connections in use and idle whenever they change
+	ObserveConnCount(active, idle int)
+}
+
+// NoopMetricsObserver ignores all metrics, it is used when no observer is given
+type NoopMetricsObserver struct{}
+
+func (o NoopMetricsObserver) ObserveExecute(duration time.Duration, err error) {}
+
+func (o NoopMetricsObserver) ObservePoolGet(waited time.Duration) {}
+
+func (o NoopMetricsObserver) ObserveConnCount(active, idle int) {}
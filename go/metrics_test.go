@@ -0,0 +1,82 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+// An observer keeping the metrics in memory
+type recordObserver struct {
+	mu          sync.Mutex
+	executeErrs []error
+	gets        int
+	active      int
+	idle        int
+}
+
+func (o *recordObserver) ObserveExecute(duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.executeErrs = append(o.executeErrs, err)
+}
+
+func (o *recordObserver) ObservePoolGet(waited time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gets++
+}
+
+func (o *recordObserver) ObserveConnCount(active, idle int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.active, o.idle = active, idle
+}
+
+func TestMetricsObserver(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	observer := &recordObserver{}
+	conf := GetDefaultConf()
+	conf.MetricsObserver = observer
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, observer.gets)
+	assert.Equal(t, 1, observer.active)
+
+	_, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	service.QueueErrorCodes(graph.ErrorCode_E_SYNTAX_ERROR)
+	_, err = session.Execute("YIEL 1")
+	assert.NoError(t, err)
+	if assert.Len(t, observer.executeErrs, 2) {
+		assert.NoError(t, observer.executeErrs[0])
+		var execErr *ExecutionError
+		assert.True(t, errors.As(observer.executeErrs[1], &execErr))
+	}
+
+	session.Release()
+	assert.Equal(t, 0, observer.active)
+	assert.Equal(t, 1, observer.idle)
+}
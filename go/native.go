@@ -0,0 +1,182 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// AsMaps converts every row into a map from the column names to the values converted to native Go values,
+// e.g. to pass the result to a template or json.Marshal. The values are converted as:
+//   - nil for empty and null values
+//   - bool, int64, float64 and string for the primitive values, integers are kept as int64 so none loses precision
+//   - time.Time for date, time and datetime, as ValueWrapper.AsDate, AsTime and AsDateTime return them
+//   - map[string]interface{} with the keys "vid" and "tags" for a vertex, tags map to their properties,
+//     "src", "dst", "name", "ranking" and "props" for an edge, "nodes" and "relationships" for a path
+//   - []interface{} for list and set, map[string]interface{} for map, []map[string]interface{} for dataset
+//
+// The error tells the row and the column of the first value which could not be converted.
+func (res ResultSet) AsMaps() ([]map[string]interface{}, error) {
+	return dataSetMaps(res.columnNames, res.getRows(), res.vidType, res.utf8Policy)
+}
+
+func dataSetMaps(columnNames []string, rows []*nebula.Row, vidType VIDType, utf8Policy UTF8Policy) ([]map[string]interface{}, error) {
+	maps := make([]map[string]interface{}, 0, len(rows))
+	for i, row := range rows {
+		values := row.GetValues()
+		m := make(map[string]interface{}, len(columnNames))
+		for j, name := range columnNames {
+			if j >= len(values) {
+				m[name] = nil
+				continue
+			}
+			value, err := nativeValue(ValueWrapper{value: values[j], vidType: vidType, utf8Policy: utf8Policy})
+			if err != nil {
+				return nil, fmt.Errorf("Failed to convert the value of row %d, column %s: %s", i, name, err.Error())
+			}
+			m[name] = value
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+// Convert the value to a native Go value as AsMaps does
+func nativeValue(valWrap ValueWrapper) (interface{}, error) {
+	value := valWrap.value
+	switch {
+	case value == nil || value.IsSetNVal():
+		return nil, nil
+	case value.IsSetBVal():
+		return value.GetBVal(), nil
+	case value.IsSetIVal():
+		return value.GetIVal(), nil
+	case value.IsSetFVal():
+		return value.GetFVal(), nil
+	case value.IsSetSVal():
+		return valWrap.AsString()
+	case value.IsSetDVal():
+		return valWrap.AsDate()
+	case value.IsSetTVal():
+		return valWrap.AsTime()
+	case value.IsSetDtVal():
+		return valWrap.AsDateTime()
+	case value.IsSetVVal():
+		node, err := valWrap.AsNode()
+		if err != nil {
+			return nil, err
+		}
+		return nodeMap(node)
+	case value.IsSetEVal():
+		relationship, err := valWrap.AsRelationship()
+		if err != nil {
+			return nil, err
+		}
+		return relationshipMap(relationship)
+	case value.IsSetPVal():
+		path, err := valWrap.AsPath()
+		if err != nil {
+			return nil, err
+		}
+		nodes := make([]interface{}, 0, len(path.GetNodes()))
+		for _, node := range path.GetNodes() {
+			m, err := nodeMap(node)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, m)
+		}
+		relationships := make([]interface{}, 0, len(path.GetRelationships()))
+		for _, relationship := range path.GetRelationships() {
+			m, err := relationshipMap(relationship)
+			if err != nil {
+				return nil, err
+			}
+			relationships = append(relationships, m)
+		}
+		return map[string]interface{}{"nodes": nodes, "relationships": relationships}, nil
+	case value.IsSetLVal():
+		return nativeValues(value.GetLVal().GetValues(), valWrap.vidType, valWrap.utf8Policy)
+	case value.IsSetUVal():
+		return nativeValues(value.GetUVal().GetValues(), valWrap.vidType, valWrap.utf8Policy)
+	case value.IsSetMVal():
+		return nativeProps(wrapProps(value.GetMVal().GetKvs(), valWrap.vidType, valWrap.utf8Policy))
+	case value.IsSetGVal():
+		dataSet := value.GetGVal()
+		var names []string
+		for _, name := range dataSet.GetColumnNames() {
+			names = append(names, string(name))
+		}
+		return dataSetMaps(names, dataSet.GetRows(), valWrap.vidType, valWrap.utf8Policy)
+	default:
+		return nil, nil
+	}
+}
+
+func nodeMap(node *Node) (map[string]interface{}, error) {
+	vid, err := nativeValue(node.GetID())
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string]interface{}, len(node.vertex.GetTags()))
+	for _, tag := range node.vertex.GetTags() {
+		props, err := nativeProps(wrapProps(tag.GetProps(), node.vidType, node.utf8Policy))
+		if err != nil {
+			return nil, err
+		}
+		tags[string(tag.GetName())] = props
+	}
+	return map[string]interface{}{"vid": vid, "tags": tags}, nil
+}
+
+func relationshipMap(relationship *Relationship) (map[string]interface{}, error) {
+	src, err := nativeValue(relationship.SrcID())
+	if err != nil {
+		return nil, err
+	}
+	dst, err := nativeValue(relationship.DstID())
+	if err != nil {
+		return nil, err
+	}
+	props, err := nativeProps(relationship.Properties())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"src":     src,
+		"dst":     dst,
+		"name":    relationship.EdgeName(),
+		"ranking": relationship.Ranking(),
+		"props":   props,
+	}, nil
+}
+
+func nativeValues(values []*nebula.Value, vidType VIDType, utf8Policy UTF8Policy) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		v, err := nativeValue(ValueWrapper{value: value, vidType: vidType, utf8Policy: utf8Policy})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func nativeProps(props map[string]*ValueWrapper) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(props))
+	for name, prop := range props {
+		v, err := nativeValue(*prop)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = v
+	}
+	return result, nil
+}
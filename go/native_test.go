@@ -0,0 +1,90 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+func TestResultSet_AsMaps(t *testing.T) {
+	maps, err := newResultSet(genResp()).AsMaps()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []map[string]interface{}{
+			{"name": "Bob", "age": int64(10)},
+			{"name": "Tom", "age": int64(11)},
+		}, maps)
+	}
+
+	null := nebula.NullType___NULL__
+	edge := &nebula.Edge{
+		Src:     nebula.VertexID("Bob"),
+		Dst:     nebula.VertexID("Tom"),
+		Name:    []byte("like"),
+		Ranking: 2,
+		Props:   map[string]*nebula.Value{"likeness": intValue(90)},
+	}
+	resultSet := newResultSet(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("v"), []byte("e"), []byte("null"), []byte("list"), []byte("date"), []byte("short")},
+			Rows: []*nebula.Row{{Values: []*nebula.Value{
+				{VVal: genVertex("Bob", "person")},
+				{EVal: edge},
+				{NVal: &null},
+				{LVal: &nebula.List{Values: []*nebula.Value{intValue(1), {MVal: &nebula.Map{
+					Kvs: map[string]*nebula.Value{"a": strValue("b")},
+				}}}}},
+				{DVal: &nebula.Date{Year: 2020, Month: 10, Day: 1}},
+			}}},
+		},
+	})
+	maps, err = resultSet.AsMaps()
+	if !assert.NoError(t, err) || !assert.Len(t, maps, 1) {
+		return
+	}
+	row := maps[0]
+	assert.Equal(t, map[string]interface{}{
+		"vid":  "Bob",
+		"tags": map[string]interface{}{"person": map[string]interface{}{"name": "Bob"}},
+	}, row["v"])
+	assert.Equal(t, map[string]interface{}{
+		"src": "Bob", "dst": "Tom", "name": "like", "ranking": int64(2),
+		"props": map[string]interface{}{"likeness": int64(90)},
+	}, row["e"])
+	assert.Nil(t, row["null"])
+	assert.Equal(t, []interface{}{int64(1), map[string]interface{}{"a": "b"}}, row["list"])
+	assert.Equal(t, time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC), row["date"])
+	// A value missing from the row is nil
+	value, ok := row["short"]
+	assert.True(t, ok)
+	assert.Nil(t, value)
+	_, err = json.Marshal(maps)
+	assert.NoError(t, err)
+
+	// A malformed value fails with its position
+	badPath := &nebula.Value{PVal: &nebula.Path{
+		Src:   &nebula.Vertex{Vid: nebula.VertexID("Bob")},
+		Steps: []*nebula.Step{{Name: []byte("follow")}},
+	}}
+	resultSet = newResultSet(&graph.ExecutionResponse{
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("path")},
+			Rows:        []*nebula.Row{{Values: []*nebula.Value{intValue(1)}}, {Values: []*nebula.Value{badPath}}},
+		},
+	})
+	_, err = resultSet.AsMaps()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "row 1, column path")
+	}
+}
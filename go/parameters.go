@@ -0,0 +1,274 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// The default number of parameter sets ExecuteParameterizedBatch sends in one request
+const defaultParameterizedBatchSize = 100
+
+// Convert the Go values of params into nebula values.
+//
+// The supported Go types and the nebula types they are converted to:
+//
+//	nil                                   NULL
+//	bool                                  bool
+//	int, int8, int16, int32, int64        int
+//	uint, uint8, uint16, uint32, uint64   int (uint and uint64 must not overflow int64)
+//	float32, float64                      float
+//	string, []byte                        string
+//	slice or array of supported values    list
+//	map with string keys                  map
+//	*nebula.Value                         used as is, it must not be nil
+//
+// Any other type, e.g. a channel or a struct, is rejected.
+func parametersToValues(params map[string]interface{}) (map[string]*nebula.Value, error) {
+	values := make(map[string]*nebula.Value, len(params))
+	for name, param := range params {
+		value, err := toValue(param)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to convert parameter %s: %s", name, err.Error())
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func toValue(param interface{}) (*nebula.Value, error) {
+	value := nebula.NewValue()
+	switch v := param.(type) {
+	case nil:
+		null := nebula.NullType___NULL__
+		value.NVal = &null
+		return value, nil
+	case *nebula.Value:
+		if v == nil {
+			return nil, fmt.Errorf("nil *nebula.Value, use nil for NULL")
+		}
+		return v, nil
+	case []byte:
+		value.SVal = v
+		return value, nil
+	}
+
+	rv := reflect.ValueOf(param)
+	switch rv.Kind() {
+	case reflect.Bool:
+		b := rv.Bool()
+		value.BVal = &b
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		value.IVal = &i
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int64", u)
+		}
+		i := int64(u)
+		value.IVal = &i
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		value.FVal = &f
+	case reflect.String:
+		value.SVal = []byte(rv.String())
+	case reflect.Slice, reflect.Array:
+		list := nebula.NewList()
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := toValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, elem)
+		}
+		value.LVal = list
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s, only string keys are supported", rv.Type().Key())
+		}
+		kvs := make(map[string]*nebula.Value, rv.Len())
+		for _, key := range rv.MapKeys() {
+			elem, err := toValue(rv.MapIndex(key).Interface())
+			if err != nil {
+				return nil, err
+			}
+			kvs[key.String()] = elem
+		}
+		value.MVal = &nebula.Map{Kvs: kvs}
+	default:
+		return nil, fmt.Errorf("unsupported type %T", param)
+	}
+	return value, nil
+}
+
+// Render a nebula value as a nGQL literal. A negative number is put in parentheses, so that it could not
+// start a -- comment after a minus, e.g. 10-$x.
+func valueToLiteral(value *nebula.Value) (string, error) {
+	switch {
+	case value == nil:
+		return "", fmt.Errorf("nil *nebula.Value could not be written as a literal")
+	case value.IsSetNVal():
+		return "NULL", nil
+	case value.IsSetBVal():
+		return strconv.FormatBool(value.GetBVal()), nil
+	case value.IsSetIVal():
+		literal := strconv.FormatInt(value.GetIVal(), 10)
+		if value.GetIVal() < 0 {
+			literal = "(" + literal + ")"
+		}
+		return literal, nil
+	case value.IsSetFVal():
+		f := value.GetFVal()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return "", fmt.Errorf("%v could not be written as a literal", f)
+		}
+		literal := strconv.FormatFloat(f, 'g', -1, 64)
+		// Keep the literal a float, e.g. 1.0 should not become the int 1
+		if !strings.ContainsAny(literal, ".e") {
+			literal += ".0"
+		}
+		if math.Signbit(f) {
+			literal = "(" + literal + ")"
+		}
+		return literal, nil
+	case value.IsSetSVal():
+		return EscapeString(string(value.GetSVal())), nil
+	case value.IsSetLVal():
+		var elems []string
+		for _, elem := range value.GetLVal().GetValues() {
+			literal, err := valueToLiteral(elem)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, literal)
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case value.IsSetMVal():
+		kvs := value.GetMVal().GetKvs()
+		keys := make([]string, 0, len(kvs))
+		for key := range kvs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var elems []string
+		for _, key := range keys {
+			literal, err := valueToLiteral(kvs[key])
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, EscapeLabel(key)+": "+literal)
+		}
+		return "{" + strings.Join(elems, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("value %s could not be written as a literal", value.String())
+	}
+}
+
+// Replace every $name in stmt, where name is a key of params, by the literal of the parameter.
+// Placeholders inside string literals, quoted labels and comments, and names not found in params,
+// like the $-, $^ and $$ references or user defined variables, are left untouched.
+func renderParameters(stmt string, params map[string]*nebula.Value) (string, error) {
+	return parseTemplate(stmt).render(params)
+}
+
+// A statement split into the text and the $name placeholders outside literals, quoted labels and comments
+type template []templatePart
+
+// Text if name is empty, a placeholder otherwise
+type templatePart struct {
+	text string
+	name string
+}
+
+func parseTemplate(stmt string) template {
+	var parts template
+	var quote byte
+	// Start of the text not added to parts yet
+	start := 0
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		if quote != 0 {
+			if c == '\\' && i+1 < len(stmt) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case strings.HasPrefix(stmt[i:], "/*"):
+			// The comment is kept as text, an apostrophe in it does not start a literal
+			if end := strings.Index(stmt[i+2:], "*/"); end < 0 {
+				i = len(stmt)
+			} else {
+				i += end + 3
+			}
+		case c == '#' || strings.HasPrefix(stmt[i:], "//") || strings.HasPrefix(stmt[i:], "--"):
+			if end := strings.IndexByte(stmt[i:], '\n'); end < 0 {
+				i = len(stmt)
+			} else {
+				i += end
+			}
+		case c == '$':
+			end := i + 1
+			for end < len(stmt) && isIdentifierChar(stmt[end], end == i+1) {
+				end++
+			}
+			if end > i+1 {
+				if start < i {
+					parts = append(parts, templatePart{text: stmt[start:i]})
+				}
+				parts = append(parts, templatePart{name: stmt[i+1 : end]})
+				start = end
+				i = end - 1
+			}
+		}
+	}
+	if start < len(stmt) {
+		parts = append(parts, templatePart{text: stmt[start:]})
+	}
+	return parts
+}
+
+// Replace the placeholders found in params by the literals of the parameters
+func (t template) render(params map[string]*nebula.Value) (string, error) {
+	var builder strings.Builder
+	for _, part := range t {
+		if part.name == "" {
+			builder.WriteString(part.text)
+			continue
+		}
+		value, ok := params[part.name]
+		if !ok {
+			builder.WriteString("$" + part.name)
+			continue
+		}
+		literal, err := valueToLiteral(value)
+		if err != nil {
+			return "", fmt.Errorf("Failed to render parameter %s: %s", part.name, err.Error())
+		}
+		builder.WriteString(literal)
+	}
+	return builder.String(), nil
+}
+
+func isIdentifierChar(c byte, first bool) bool {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
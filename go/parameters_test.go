@@ -0,0 +1,145 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestParameters_Render(t *testing.T) {
+	params := map[string]interface{}{
+		"name":  "Tom \"the cat\"\n",
+		"age":   10,
+		"score": 1.0,
+		"alive": true,
+		"none":  nil,
+		"tags":  []interface{}{"a", int8(1)},
+		"props": map[string]interface{}{"k": 2, "my key": "v"},
+	}
+	values, err := parametersToValues(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, err := renderParameters("YIELD $name, $age, $score, $alive, $none, $tags, $props", values)
+	assert.NoError(t, err)
+	assert.Equal(t, `YIELD "Tom \"the cat\"\n", 10, 1.0, true, NULL, ["a", 1], {k: 2, `+"`my key`"+`: "v"}`, stmt)
+
+	// References, unknown names and quoted text are not replaced
+	stmt, err = renderParameters(`GO FROM $var.id OVER like YIELD $$.person.name, $^.person.age, "$name", $age`, values)
+	assert.NoError(t, err)
+	assert.Equal(t, `GO FROM $var.id OVER like YIELD $$.person.name, $^.person.age, "$name", 10`, stmt)
+
+	// Negative numbers could not start a -- comment after a minus
+	values, err = parametersToValues(map[string]interface{}{"x": -5, "f": -1.5, "z": math.Copysign(0, -1), "l": []int{-1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err = renderParameters("YIELD 10-$x, 3-$f, $z, $l", values)
+	assert.NoError(t, err)
+	assert.Equal(t, "YIELD 10-(-5), 3-(-1.5), (-0.0), [(-1)]", stmt)
+
+	// An apostrophe in a comment does not start a literal, the placeholders in comments are left as they are
+	for stmt, expected := range map[string]string{
+		"YIELD $x /* it's $x */, $x": "YIELD (-5) /* it's $x */, (-5)",
+		"YIELD $x -- it's $x\n, $x":  "YIELD (-5) -- it's $x\n, (-5)",
+		"YIELD $x // it's $x\n, $x":  "YIELD (-5) // it's $x\n, (-5)",
+		"YIELD $x # it's $x\n, $x":   "YIELD (-5) # it's $x\n, (-5)",
+		"YIELD '$x' /* $x */ \"$x\"": "YIELD '$x' /* $x */ \"$x\"",
+	} {
+		actual, err := renderParameters(stmt, values)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestParameters_UnsupportedType(t *testing.T) {
+	_, err := parametersToValues(map[string]interface{}{"ch": make(chan int)})
+	assert.EqualError(t, err, "Failed to convert parameter ch: unsupported type chan int")
+
+	_, err = parametersToValues(map[string]interface{}{"big": uint64(1 << 63)})
+	assert.Error(t, err)
+
+	// A typed nil value is rejected instead of being dereferenced
+	_, err = parametersToValues(map[string]interface{}{"v": (*nebula.Value)(nil)})
+	assert.EqualError(t, err, "Failed to convert parameter v: nil *nebula.Value, use nil for NULL")
+	_, err = renderParameters("YIELD $v", map[string]*nebula.Value{"v": nil})
+	assert.Error(t, err)
+	_, err = renderParameters("YIELD $v", map[string]*nebula.Value{"v": {LVal: &nebula.List{Values: []*nebula.Value{nil}}}})
+	assert.Error(t, err)
+}
+
+func TestSession_ExecuteParameterizedBatch(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		if strings.Contains(stmt, "bad") {
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR, ErrorMsg: []byte("bad vertex")}, nil
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ParameterizedBatchSize = 2
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	stmt := `INSERT VERTEX player(name) VALUES $vid:($name);`
+	assert.NoError(t, session.ExecuteParameterizedBatch(stmt, []map[string]interface{}{
+		{"vid": "a", "name": "Tim"},
+		{"vid": "b", "name": "Tony"},
+		{"vid": "c", "name": "Manu"},
+	}))
+	assert.Equal(t, []string{
+		`INSERT VERTEX player(name) VALUES "a":("Tim"); INSERT VERTEX player(name) VALUES "b":("Tony")`,
+		`INSERT VERTEX player(name) VALUES "c":("Manu")`,
+	}, service.Statements())
+	assert.NoError(t, session.ExecuteParameterizedBatch(stmt, nil))
+
+	// A set which could not be bound is skipped, a failed request fails all its sets
+	service.ResetStatements()
+	err = session.ExecuteParameterizedBatch(stmt, []map[string]interface{}{
+		{"vid": "a", "name": "Tim"},
+		{"vid": "b", "name": make(chan int)},
+		{"vid": "c", "name": "Manu"},
+		{"vid": "d", "name": "bad"},
+		{"vid": "e", "name": "Tony"},
+	})
+	var batchErr *BatchError
+	if assert.True(t, errors.As(err, &batchErr)) && assert.Len(t, batchErr.Errors, 5) {
+		assert.NoError(t, batchErr.Errors[0])
+		assert.Contains(t, batchErr.Errors[1].Error(), "unsupported type")
+		assert.NoError(t, batchErr.Errors[2])
+		var execErr *ExecutionError
+		if assert.True(t, errors.As(batchErr.Errors[3], &execErr)) {
+			assert.Equal(t, graph.ErrorCode_E_EXECUTION_ERROR, execErr.ErrorCode)
+		}
+		assert.Equal(t, batchErr.Errors[3], batchErr.Errors[4])
+		assert.Equal(t, "Failed to execute 3 of 5 parameter sets, the first error: "+batchErr.Errors[1].Error(), err.Error())
+	}
+	assert.Equal(t, []string{
+		`INSERT VERTEX player(name) VALUES "a":("Tim"); INSERT VERTEX player(name) VALUES "c":("Manu")`,
+		`INSERT VERTEX player(name) VALUES "d":("bad"); INSERT VERTEX player(name) VALUES "e":("Tony")`,
+	}, service.Statements())
+}
@@ -0,0 +1,172 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// FormatPlanTree formats the plan as an indented tree, the root node comes first and the nodes it
+// depends on are nested under it. Profiling stats are shown if the plan comes from PROFILE.
+// An empty string is returned for a nil plan.
+func FormatPlanTree(plan *graph.PlanDescription) string {
+	if plan == nil || len(plan.GetPlanNodeDescs()) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	p := newPlan(plan)
+	p.writeTree(&b, p.nodes[0], "", "", make(map[int64]bool))
+	return b.String()
+}
+
+// FormatPlanDOT formats the plan as a Graphviz DOT graph, an edge points from a node to the node
+// depending on it. The output could be rendered by e.g. "dot -Tpng".
+// An empty string is returned for a nil plan.
+func FormatPlanDOT(plan *graph.PlanDescription) string {
+	if plan == nil || len(plan.GetPlanNodeDescs()) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	p := newPlan(plan)
+	b.WriteString("digraph exec_plan {\n\trankdir=BT;\n")
+	for _, node := range p.nodes {
+		// Every line is a field of the record
+		lines := planNodeLines(node)
+		for i, line := range lines {
+			lines[i] = escapeDOT(line)
+		}
+		fmt.Fprintf(&b, "\t\"%s\"[label=\"{%s}\", shape=Mrecord];\n", planNodeName(node), strings.Join(lines, "|"))
+	}
+	for _, node := range p.nodes {
+		for _, id := range node.GetDependencies() {
+			if dep := p.node(id); dep != nil {
+				fmt.Fprintf(&b, "\t\"%s\"->\"%s\";\n", planNodeName(dep), planNodeName(node))
+			}
+		}
+		for _, branch := range p.branches[node.GetId()] {
+			label := "else"
+			if branch.GetBranchInfo().GetIsDoBranch() {
+				label = "do"
+			}
+			fmt.Fprintf(&b, "\t\"%s\"->\"%s\"[label=\"%s\", style=dashed];\n",
+				planNodeName(branch), planNodeName(node), label)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// A plan with its nodes looked up by ID
+type plan struct {
+	nodes []*graph.PlanNodeDescription
+	index map[int64]int64
+	// The first nodes of the branches of every Select and Loop node, keyed by the ID of the condition node
+	branches map[int64][]*graph.PlanNodeDescription
+}
+
+func newPlan(desc *graph.PlanDescription) *plan {
+	p := &plan{
+		nodes:    desc.GetPlanNodeDescs(),
+		index:    desc.GetNodeIndexMap(),
+		branches: make(map[int64][]*graph.PlanNodeDescription),
+	}
+	for _, node := range p.nodes {
+		if info := node.GetBranchInfo(); info != nil {
+			p.branches[info.GetConditionNodeID()] = append(p.branches[info.GetConditionNodeID()], node)
+		}
+	}
+	// The do branch comes before the else branch
+	for _, branches := range p.branches {
+		sort.SliceStable(branches, func(i, j int) bool {
+			return branches[i].GetBranchInfo().GetIsDoBranch() && !branches[j].GetBranchInfo().GetIsDoBranch()
+		})
+	}
+	return p
+}
+
+// Return the node of the ID, nil if the plan has no such node
+func (p *plan) node(id int64) *graph.PlanNodeDescription {
+	if i, ok := p.index[id]; ok && i >= 0 && i < int64(len(p.nodes)) {
+		return p.nodes[i]
+	}
+	return nil
+}
+
+// Write the node and the subtree under it, a node already written on the path is not expanded again
+func (p *plan) writeTree(b *strings.Builder, node *graph.PlanNodeDescription, prefix, childPrefix string,
+	visited map[int64]bool) {
+	lines := planNodeLines(node)
+	b.WriteString(prefix + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		b.WriteString(childPrefix + "  " + line + "\n")
+	}
+	if visited[node.GetId()] {
+		return
+	}
+	visited[node.GetId()] = true
+	defer delete(visited, node.GetId())
+
+	type child struct {
+		label string
+		node  *graph.PlanNodeDescription
+	}
+	var children []child
+	for _, branch := range p.branches[node.GetId()] {
+		label := "(else) "
+		if branch.GetBranchInfo().GetIsDoBranch() {
+			label = "(do) "
+		}
+		children = append(children, child{label, branch})
+	}
+	for _, id := range node.GetDependencies() {
+		if dep := p.node(id); dep != nil {
+			children = append(children, child{"", dep})
+		}
+	}
+	for i, c := range children {
+		if i == len(children)-1 {
+			p.writeTree(b, c.node, childPrefix+"└─ "+c.label, childPrefix+"   ", visited)
+		} else {
+			p.writeTree(b, c.node, childPrefix+"├─ "+c.label, childPrefix+"│  ", visited)
+		}
+	}
+}
+
+// Return e.g. Project_3, which is unique in the plan
+func planNodeName(node *graph.PlanNodeDescription) string {
+	return fmt.Sprintf("%s_%d", node.GetName(), node.GetId())
+}
+
+// Return the name of the node followed by its description and profiling stats, one per line
+func planNodeLines(node *graph.PlanNodeDescription) []string {
+	lines := []string{fmt.Sprintf("%s[%d] outputVar: %s", node.GetName(), node.GetId(), node.GetOutputVar())}
+	for _, pair := range node.GetDescription() {
+		lines = append(lines, fmt.Sprintf("%s: %s", pair.GetKey(), pair.GetValue()))
+	}
+	for i, profile := range node.GetProfiles() {
+		line := fmt.Sprintf("rows: %d, execTime: %dus, totalTime: %dus",
+			profile.GetRows(), profile.GetExecDurationInUs(), profile.GetTotalDurationInUs())
+		// A node in a loop is profiled once per round
+		if len(node.GetProfiles()) > 1 {
+			line = fmt.Sprintf("round %d: %s", i+1, line)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Escape the characters having a meaning in a field of a quoted record label of DOT
+func escapeDOT(field string) string {
+	return dotReplacer.Replace(field)
+}
+
+var dotReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`,
+	"<", `\<`, ">", `\>`, "\n", `\n`)
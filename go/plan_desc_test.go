@@ -0,0 +1,91 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// Build the plan of PROFILE GO FROM 1 OVER follow
+func genPlanDesc() *graph.PlanDescription {
+	return &graph.PlanDescription{
+		PlanNodeDescs: []*graph.PlanNodeDescription{
+			{
+				Name:         []byte("Project"),
+				Id:           2,
+				OutputVar:    []byte("__Project_2"),
+				Description:  []*graph.Pair{{Key: []byte("columns"), Value: []byte(`["follow._dst"]`)}},
+				Profiles:     []*graph.ProfilingStats{{Rows: 3, ExecDurationInUs: 10, TotalDurationInUs: 20}},
+				Dependencies: []int64{1},
+			},
+			{
+				Name:         []byte("GetNeighbors"),
+				Id:           1,
+				OutputVar:    []byte("__GetNeighbors_1"),
+				Profiles:     []*graph.ProfilingStats{{Rows: 3, ExecDurationInUs: 100, TotalDurationInUs: 200}},
+				Dependencies: []int64{0},
+			},
+			{Name: []byte("Start"), Id: 0, OutputVar: []byte("__Start_0")},
+		},
+		NodeIndexMap: map[int64]int64{2: 0, 1: 1, 0: 2},
+		Format:       []byte("row"),
+	}
+}
+
+func TestResultSet_GetPlanDesc(t *testing.T) {
+	assert.Nil(t, newResultSet(genResp()).GetPlanDesc())
+	assert.Equal(t, "", FormatPlanTree(nil))
+	assert.Equal(t, "", FormatPlanDOT(nil))
+
+	resp := genResp()
+	resp.PlanDesc = genPlanDesc()
+	plan := newResultSet(resp).GetPlanDesc()
+	assert.Equal(t, resp.PlanDesc, plan)
+	assert.Equal(t, `Project[2] outputVar: __Project_2
+  columns: ["follow._dst"]
+  rows: 3, execTime: 10us, totalTime: 20us
+└─ GetNeighbors[1] outputVar: __GetNeighbors_1
+     rows: 3, execTime: 100us, totalTime: 200us
+   └─ Start[0] outputVar: __Start_0
+`, FormatPlanTree(plan))
+	assert.Equal(t, `digraph exec_plan {
+	rankdir=BT;
+	"Project_2"[label="{Project[2] outputVar: __Project_2|columns: [\"follow._dst\"]|rows: 3, execTime: 10us, totalTime: 20us}", shape=Mrecord];
+	"GetNeighbors_1"[label="{GetNeighbors[1] outputVar: __GetNeighbors_1|rows: 3, execTime: 100us, totalTime: 200us}", shape=Mrecord];
+	"Start_0"[label="{Start[0] outputVar: __Start_0}", shape=Mrecord];
+	"GetNeighbors_1"->"Project_2";
+	"Start_0"->"GetNeighbors_1";
+}
+`, FormatPlanDOT(plan))
+}
+
+func TestFormatPlanTree_Branches(t *testing.T) {
+	// A loop whose body depends on the loop itself
+	plan := &graph.PlanDescription{
+		PlanNodeDescs: []*graph.PlanNodeDescription{
+			{Name: []byte("Loop"), Id: 1, Dependencies: []int64{0}},
+			{Name: []byte("Start"), Id: 0},
+			{
+				Name:         []byte("Project"),
+				Id:           2,
+				BranchInfo:   &graph.PlanNodeBranchInfo{IsDoBranch: true, ConditionNodeID: 1},
+				Dependencies: []int64{1},
+			},
+		},
+		NodeIndexMap: map[int64]int64{1: 0, 0: 1, 2: 2},
+	}
+	assert.Equal(t, `Loop[1] outputVar: 
+├─ (do) Project[2] outputVar: 
+│  └─ Loop[1] outputVar: 
+└─ Start[0] outputVar: 
+`, FormatPlanTree(plan))
+	assert.Contains(t, FormatPlanDOT(plan), `"Project_2"->"Loop_1"[label="do", style=dashed];`)
+}
@@ -0,0 +1,65 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+)
+
+// PreparedStatement is a statement template with $name placeholders, executed with different parameters.
+//
+// graphd of this version could not prepare a statement on the server side, so the statement is sent with
+// the parameters rendered as literals, the same as ExecuteWithParameter, and graphd parses it every time.
+// The template is only split by the placeholders once on the client side. The API stays the same if
+// server-side preparing becomes available.
+type PreparedStatement struct {
+	session  *Session
+	stmt     string
+	template template
+}
+
+// Prepare splits the statement by its $name placeholders, the ones inside string literals and quoted
+// labels are not placeholders. The returned statement is executed on this session.
+func (session *Session) Prepare(stmt string) (*PreparedStatement, error) {
+	if isEmptyStatement(stmt) {
+		return nil, fmt.Errorf("Failed to prepare: %w", ErrEmptyStatement)
+	}
+	return &PreparedStatement{session: session, stmt: stmt, template: parseTemplate(stmt)}, nil
+}
+
+// Execute the statement with the parameters, see parametersToValues for the supported types.
+// A placeholder not found in params is sent as it is, like the $-, $^ and $$ references.
+func (ps *PreparedStatement) Execute(params map[string]interface{}) (*ResultSet, error) {
+	values, err := parametersToValues(params)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := ps.template.render(values)
+	if err != nil {
+		return nil, err
+	}
+	return ps.session.Execute(rendered)
+}
+
+// Return the names of the placeholders in the order they appear, a name appearing more than once is
+// returned once
+func (ps *PreparedStatement) GetParamNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, part := range ps.template {
+		if part.name != "" && !seen[part.name] {
+			seen[part.name] = true
+			names = append(names, part.name)
+		}
+	}
+	return names
+}
+
+// Return the statement template
+func (ps *PreparedStatement) String() string {
+	return ps.stmt
+}
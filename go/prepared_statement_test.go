@@ -0,0 +1,55 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestSession_Prepare(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	_, err = session.Prepare("  ")
+	assert.True(t, errors.Is(err, ErrEmptyStatement))
+
+	stmt, err := session.Prepare(`GO FROM $id OVER follow WHERE $$.player.name != "$id" AND $^.player.age > $age YIELD $age`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"id", "age"}, stmt.GetParamNames())
+	for _, params := range []map[string]interface{}{
+		{"id": "player100", "age": 30},
+		{"id": "player101", "age": 40},
+	} {
+		resp, err := stmt.Execute(params)
+		assert.NoError(t, err)
+		assert.True(t, resp.IsSucceeded())
+	}
+	_, err = stmt.Execute(map[string]interface{}{"id": make(chan int)})
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		`GO FROM "player100" OVER follow WHERE $$.player.name != "$id" AND $^.player.age > 30 YIELD 30`,
+		`GO FROM "player101" OVER follow WHERE $$.player.name != "$id" AND $^.player.age > 40 YIELD 40`,
+	}, service.Statements())
+}
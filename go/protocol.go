@@ -0,0 +1,151 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift"
+)
+
+// Protocol is the thrift protocol used to encode the RPCs, it must be the one graphd is configured with
+type Protocol int
+
+const (
+	// The thrift binary protocol, which graphd speaks by default
+	ProtocolBinary Protocol = iota
+	// The thrift compact protocol, whose payloads are smaller
+	ProtocolCompact
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolBinary:
+		return "binary"
+	case ProtocolCompact:
+		return "compact"
+	default:
+		return "unknown"
+	}
+}
+
+func (p Protocol) factory() thrift.ProtocolFactory {
+	if p == ProtocolCompact {
+		return thrift.NewCompactProtocolFactory()
+	}
+	return thrift.NewBinaryProtocolFactoryDefault()
+}
+
+// TransportType is the thrift transport carrying the RPCs, it must be the one graphd is configured with
+type TransportType int
+
+const (
+	// The buffered transport, which graphd uses by default
+	TransportBuffered TransportType = iota
+	// The framed transport, every message is prefixed with its length
+	TransportFramed
+	// The header transport of fbthrift, every message is framed with a header of key/value pairs, which proxies
+	// and tracing systems in front of graphd may read, see PoolConfig.TransportHeaders. graphd of Nebula 2.x serves
+	// its RPCs with the fbthrift server, which accepts it besides the buffered and the framed transports,
+	// while a proxy which does not speak it fails the round trip verifying the connection on open.
+	TransportHeader
+)
+
+// The default max length of a framed message, far beyond the 16MB of thrift so large results are not rejected
+const defaultMaxFrameSize = math.MaxInt32
+
+func (t TransportType) String() string {
+	switch t {
+	case TransportBuffered:
+		return "buffered"
+	case TransportFramed:
+		return "framed"
+	case TransportHeader:
+		return "header"
+	default:
+		return "unknown"
+	}
+}
+
+// Wrap the socket in the transport, with a buffer of bufferSize under the frames of at most maxFrameSize bytes
+func (t TransportType) wrap(sock thrift.Transport, bufferSize int, maxFrameSize int) thrift.Transport {
+	buffered := thrift.NewBufferedTransport(sock, bufferSize)
+	if t == TransportFramed {
+		return thrift.NewFramedTransportMaxLength(buffered, uint32(maxFrameSize))
+	}
+	return buffered
+}
+
+// Wrap the transport in the header transport, which carries the headers with every message and sends the payloads
+// with the protocol, compressed by its zlib transform if compress is true. It must be the outermost transport since
+// the header protocol reads the frames from it.
+func newHeaderTransport(trans thrift.Transport, protocol Protocol, headers map[string]string, compress bool) (thrift.Transport, error) {
+	header := thrift.NewHeaderTransport(trans)
+	protocolID := thrift.ProtocolIDBinary
+	if protocol == ProtocolCompact {
+		protocolID = thrift.ProtocolIDCompact
+	}
+	if err := header.SetProtocolID(protocolID); err != nil {
+		return nil, err
+	}
+	if compress {
+		if err := header.AddTransform(thrift.TransformZlib); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range headers {
+		header.SetPersistentHeader(key, value)
+	}
+	return header, nil
+}
+
+// Check if the error means the framed transport rejected a frame larger than its max length
+func isFrameTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Incorrect frame size")
+}
+
+// A transport failing the reads once a response is larger than max bytes, the count restarts when a request is flushed.
+// The size of a frame is known after its header is read, so a framed response is rejected before its body is read.
+type limitedTransport struct {
+	thrift.Transport
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func newLimitedTransport(trans thrift.Transport, max int64) *limitedTransport {
+	return &limitedTransport{Transport: trans, max: max}
+}
+
+func (t *limitedTransport) Read(b []byte) (int, error) {
+	if t.exceeded {
+		return 0, t.err()
+	}
+	n, err := t.Transport.Read(b)
+	t.read += int64(n)
+	size := t.read
+	if remaining := t.Transport.RemainingBytes(); remaining != thrift.UnknownRemaining {
+		size += int64(remaining)
+	}
+	if size > t.max {
+		t.exceeded = true
+		return n, t.err()
+	}
+	return n, err
+}
+
+func (t *limitedTransport) Flush() error {
+	t.read = 0
+	t.exceeded = false
+	return t.Transport.Flush()
+}
+
+func (t *limitedTransport) err() error {
+	return fmt.Errorf("The response exceeds %d bytes", t.max)
+}
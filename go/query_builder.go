@@ -0,0 +1,411 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The builders below produce nGQL statements for Session.Execute.
+// Names are quoted as labels and values are rendered as literals, see parametersToValues
+// for the supported Go types, so the statements are safe to build from user input.
+
+// InsertVertexBuilder builds an INSERT VERTEX statement for one vertex
+type InsertVertexBuilder struct {
+	tag     string
+	vid     interface{}
+	props   map[string]interface{}
+	vidType VIDType
+	maxLen  int
+}
+
+// InsertVertex starts an INSERT VERTEX statement for the tag
+func InsertVertex(tag string) *InsertVertexBuilder {
+	return &InsertVertexBuilder{tag: tag}
+}
+
+// VID sets the ID of the vertex, a string or an integer
+func (b *InsertVertexBuilder) VID(vid interface{}) *InsertVertexBuilder {
+	b.vid = vid
+	return b
+}
+
+// WithVIDType sets the VID type of the space, VIDTypeString by default
+func (b *InsertVertexBuilder) WithVIDType(vidType VIDType) *InsertVertexBuilder {
+	b.vidType = vidType
+	return b
+}
+
+// WithVIDMaxLen sets the N of the FIXED_STRING(N) VID type of the space, Build checks the VID with ValidateVID then
+func (b *InsertVertexBuilder) WithVIDMaxLen(maxLen int) *InsertVertexBuilder {
+	b.maxLen = maxLen
+	return b
+}
+
+// Props sets the properties of the vertex
+func (b *InsertVertexBuilder) Props(props map[string]interface{}) *InsertVertexBuilder {
+	b.props = props
+	return b
+}
+
+// Build returns the statement, e.g. INSERT VERTEX player(age, name) VALUES "player100":(42, "Tim")
+func (b *InsertVertexBuilder) Build() (string, error) {
+	vid, err := b.vidType.checkedLiteral(b.vid, b.maxLen)
+	if err != nil {
+		return "", err
+	}
+	names, values, err := propsLiterals(b.props)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("INSERT VERTEX %s(%s) VALUES %s:(%s)", EscapeLabel(b.tag), names, vid, values), nil
+}
+
+// InsertEdgeBuilder builds an INSERT EDGE statement for one edge
+type InsertEdgeBuilder struct {
+	edgeType string
+	src      interface{}
+	dst      interface{}
+	rank     int64
+	props    map[string]interface{}
+	vidType  VIDType
+	maxLen   int
+}
+
+// InsertEdge starts an INSERT EDGE statement for the edge type
+func InsertEdge(edgeType string) *InsertEdgeBuilder {
+	return &InsertEdgeBuilder{edgeType: edgeType}
+}
+
+// From sets the ID of the source vertex, a string or an integer
+func (b *InsertEdgeBuilder) From(src interface{}) *InsertEdgeBuilder {
+	b.src = src
+	return b
+}
+
+// To sets the ID of the destination vertex, a string or an integer
+func (b *InsertEdgeBuilder) To(dst interface{}) *InsertEdgeBuilder {
+	b.dst = dst
+	return b
+}
+
+// Rank sets the ranking of the edge, 0 by default
+func (b *InsertEdgeBuilder) Rank(rank int64) *InsertEdgeBuilder {
+	b.rank = rank
+	return b
+}
+
+// WithVIDType sets the VID type of the space, VIDTypeString by default
+func (b *InsertEdgeBuilder) WithVIDType(vidType VIDType) *InsertEdgeBuilder {
+	b.vidType = vidType
+	return b
+}
+
+// WithVIDMaxLen sets the N of the FIXED_STRING(N) VID type of the space, Build checks the VIDs with ValidateVID then
+func (b *InsertEdgeBuilder) WithVIDMaxLen(maxLen int) *InsertEdgeBuilder {
+	b.maxLen = maxLen
+	return b
+}
+
+// Props sets the properties of the edge
+func (b *InsertEdgeBuilder) Props(props map[string]interface{}) *InsertEdgeBuilder {
+	b.props = props
+	return b
+}
+
+// Build returns the statement, e.g. INSERT EDGE follow(degree) VALUES "player100"->"player101"@0:(95)
+func (b *InsertEdgeBuilder) Build() (string, error) {
+	src, err := b.vidType.checkedLiteral(b.src, b.maxLen)
+	if err != nil {
+		return "", err
+	}
+	dst, err := b.vidType.checkedLiteral(b.dst, b.maxLen)
+	if err != nil {
+		return "", err
+	}
+	names, values, err := propsLiterals(b.props)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("INSERT EDGE %s(%s) VALUES %s->%s@%d:(%s)",
+		EscapeLabel(b.edgeType), names, src, dst, b.rank, values), nil
+}
+
+// LookupBuilder builds a LOOKUP statement matching properties by equality
+type LookupBuilder struct {
+	schema string
+	conds  map[string]interface{}
+	yields []string
+}
+
+// Lookup starts a LOOKUP statement on the tag or edge type
+func Lookup(schema string) *LookupBuilder {
+	return &LookupBuilder{schema: schema, conds: make(map[string]interface{})}
+}
+
+// Where adds a condition that the property equals the value, conditions are joined with AND
+func (b *LookupBuilder) Where(prop string, value interface{}) *LookupBuilder {
+	b.conds[prop] = value
+	return b
+}
+
+// Yield sets the properties to return
+func (b *LookupBuilder) Yield(props ...string) *LookupBuilder {
+	b.yields = props
+	return b
+}
+
+// Build returns the statement, e.g. LOOKUP ON player WHERE player.name == "Tim" YIELD player.age
+func (b *LookupBuilder) Build() (string, error) {
+	if len(b.conds) == 0 {
+		return "", fmt.Errorf("Failed to build query: LOOKUP needs at least one condition")
+	}
+	schema := EscapeLabel(b.schema)
+	props := make([]string, 0, len(b.conds))
+	for prop := range b.conds {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	conds := make([]string, 0, len(props))
+	for _, prop := range props {
+		literal, err := toLiteral(b.conds[prop])
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, fmt.Sprintf("%s.%s == %s", schema, EscapeLabel(prop), literal))
+	}
+	stmt := fmt.Sprintf("LOOKUP ON %s WHERE %s", schema, strings.Join(conds, " AND "))
+	if len(b.yields) > 0 {
+		yields := make([]string, 0, len(b.yields))
+		for _, prop := range b.yields {
+			yields = append(yields, schema+"."+EscapeLabel(prop))
+		}
+		stmt += " YIELD " + strings.Join(yields, ", ")
+	}
+	return stmt, nil
+}
+
+// GoBuilder builds a GO statement traversing the edges from the given vertices.
+// The WHERE and YIELD expressions are written as they are, they should not be built from user input:
+// use $name placeholders and Session.ExecuteWithParameter for the values in them.
+type GoBuilder struct {
+	steps     int
+	vids      []interface{}
+	edges     []string
+	direction string
+	where     string
+	yields    []string
+	vidType   VIDType
+	maxLen    int
+}
+
+// Go starts a GO statement of one step
+func Go() *GoBuilder {
+	return &GoBuilder{steps: 1}
+}
+
+// Steps sets the number of steps to traverse, 1 by default
+func (b *GoBuilder) Steps(n int) *GoBuilder {
+	b.steps = n
+	return b
+}
+
+// From sets the IDs of the vertices to start from, strings or integers
+func (b *GoBuilder) From(vids ...interface{}) *GoBuilder {
+	b.vids = vids
+	return b
+}
+
+// Over sets the edge types to traverse
+func (b *GoBuilder) Over(edges ...string) *GoBuilder {
+	b.edges = edges
+	return b
+}
+
+// Reversely traverses the edges against their direction
+func (b *GoBuilder) Reversely() *GoBuilder {
+	b.direction = "REVERSELY"
+	return b
+}
+
+// Bidirect traverses the edges in both directions
+func (b *GoBuilder) Bidirect() *GoBuilder {
+	b.direction = "BIDIRECT"
+	return b
+}
+
+// Where sets the condition the traversed edges must match, e.g. follow.degree > 90
+func (b *GoBuilder) Where(expr string) *GoBuilder {
+	b.where = expr
+	return b
+}
+
+// Yield sets the expressions to return, e.g. follow._dst, $$.player.name AS name
+func (b *GoBuilder) Yield(exprs ...string) *GoBuilder {
+	b.yields = exprs
+	return b
+}
+
+// WithVIDType sets the VID type of the space, VIDTypeString by default
+func (b *GoBuilder) WithVIDType(vidType VIDType) *GoBuilder {
+	b.vidType = vidType
+	return b
+}
+
+// WithVIDMaxLen sets the N of the FIXED_STRING(N) VID type of the space, Build checks the VIDs with ValidateVID then
+func (b *GoBuilder) WithVIDMaxLen(maxLen int) *GoBuilder {
+	b.maxLen = maxLen
+	return b
+}
+
+// Build returns the statement, e.g. GO 2 STEPS FROM "player100", "player101" OVER follow YIELD follow._dst
+func (b *GoBuilder) Build() (string, error) {
+	if b.steps < 1 {
+		return "", fmt.Errorf("Failed to build query: GO needs at least one step, got %d", b.steps)
+	}
+	if len(b.edges) == 0 {
+		return "", fmt.Errorf("Failed to build query: GO needs at least one edge type")
+	}
+	vids, err := vidsLiterals(b.vids, b.vidType, b.maxLen)
+	if err != nil {
+		return "", err
+	}
+	edges := make([]string, 0, len(b.edges))
+	for _, edge := range b.edges {
+		edges = append(edges, EscapeLabel(edge))
+	}
+	stmt := fmt.Sprintf("GO %d STEPS FROM %s OVER %s", b.steps, vids, strings.Join(edges, ", "))
+	if b.direction != "" {
+		stmt += " " + b.direction
+	}
+	if b.where != "" {
+		stmt += " WHERE " + b.where
+	}
+	if len(b.yields) > 0 {
+		stmt += " YIELD " + strings.Join(b.yields, ", ")
+	}
+	return stmt, nil
+}
+
+// FetchBuilder builds a FETCH PROP ON statement reading the properties of the given vertices
+type FetchBuilder struct {
+	tags    []string
+	vids    []interface{}
+	yields  []string
+	vidType VIDType
+	maxLen  int
+}
+
+// Fetch starts a FETCH PROP ON statement
+func Fetch() *FetchBuilder {
+	return &FetchBuilder{}
+}
+
+// Prop sets the tags whose properties are fetched, all tags if none is given
+func (b *FetchBuilder) Prop(tags ...string) *FetchBuilder {
+	b.tags = tags
+	return b
+}
+
+// On sets the IDs of the vertices to fetch, strings or integers
+func (b *FetchBuilder) On(vids ...interface{}) *FetchBuilder {
+	b.vids = vids
+	return b
+}
+
+// Yield sets the properties to return, of the single tag given to Prop
+func (b *FetchBuilder) Yield(props ...string) *FetchBuilder {
+	b.yields = props
+	return b
+}
+
+// WithVIDType sets the VID type of the space, VIDTypeString by default
+func (b *FetchBuilder) WithVIDType(vidType VIDType) *FetchBuilder {
+	b.vidType = vidType
+	return b
+}
+
+// WithVIDMaxLen sets the N of the FIXED_STRING(N) VID type of the space, Build checks the VIDs with ValidateVID then
+func (b *FetchBuilder) WithVIDMaxLen(maxLen int) *FetchBuilder {
+	b.maxLen = maxLen
+	return b
+}
+
+// Build returns the statement, e.g. FETCH PROP ON player "player100" YIELD player.name
+func (b *FetchBuilder) Build() (string, error) {
+	vids, err := vidsLiterals(b.vids, b.vidType, b.maxLen)
+	if err != nil {
+		return "", err
+	}
+	tags := "*"
+	if len(b.tags) > 0 {
+		escaped := make([]string, 0, len(b.tags))
+		for _, tag := range b.tags {
+			escaped = append(escaped, EscapeLabel(tag))
+		}
+		tags = strings.Join(escaped, ", ")
+	}
+	stmt := fmt.Sprintf("FETCH PROP ON %s %s", tags, vids)
+	if len(b.yields) > 0 {
+		if len(b.tags) != 1 {
+			return "", fmt.Errorf("Failed to build query: FETCH yields the properties of a single tag, got %d tags", len(b.tags))
+		}
+		tag := EscapeLabel(b.tags[0])
+		yields := make([]string, 0, len(b.yields))
+		for _, prop := range b.yields {
+			yields = append(yields, tag+"."+EscapeLabel(prop))
+		}
+		stmt += " YIELD " + strings.Join(yields, ", ")
+	}
+	return stmt, nil
+}
+
+// Render the vertex IDs as a comma-separated list, at least one is needed
+func vidsLiterals(vids []interface{}, vidType VIDType, maxLen int) (string, error) {
+	if len(vids) == 0 {
+		return "", fmt.Errorf("Failed to build query: no VID is given")
+	}
+	literals := make([]string, 0, len(vids))
+	for _, vid := range vids {
+		literal, err := vidType.checkedLiteral(vid, maxLen)
+		if err != nil {
+			return "", err
+		}
+		literals = append(literals, literal)
+	}
+	return strings.Join(literals, ", "), nil
+}
+
+// Render a Go value as a nGQL literal
+func toLiteral(v interface{}) (string, error) {
+	value, err := toValue(v)
+	if err != nil {
+		return "", err
+	}
+	return valueToLiteral(value)
+}
+
+// Render the property names and values in the order of the names
+func propsLiterals(props map[string]interface{}) (string, string, error) {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0, len(names))
+	for i, name := range names {
+		literal, err := toLiteral(props[name])
+		if err != nil {
+			return "", "", fmt.Errorf("Failed to build query: property %s, %s", name, err.Error())
+		}
+		values = append(values, literal)
+		names[i] = EscapeLabel(name)
+	}
+	return strings.Join(names, ", "), strings.Join(values, ", "), nil
+}
@@ -0,0 +1,176 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestInsertVertex(t *testing.T) {
+	stmt, err := InsertVertex("player").VID("player100").Props(map[string]interface{}{
+		"name": `Tim "The Big Fundamental" Duncan`,
+		"age":  42,
+	}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT VERTEX player(age, name) VALUES "player100":(42, "Tim \"The Big Fundamental\" Duncan")`, stmt)
+
+	stmt, err = InsertVertex("player").VID([]byte{0xff, 'a', 0x00}).Props(nil).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT VERTEX player() VALUES "\377a\000":()`, stmt)
+
+	stmt, err = InsertVertex("my tag").VID(100).Props(nil).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT VERTEX `my tag`() VALUES \"100\":()", stmt)
+	stmt, err = InsertVertex("my tag").WithVIDType(VIDTypeInt64).VID(100).Props(nil).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT VERTEX `my tag`() VALUES 100:()", stmt)
+	// A negative VID is not put in parentheses like a negative property
+	stmt, err = InsertVertex("t").VID(-1).Props(map[string]interface{}{"a": -2}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT VERTEX t(a) VALUES "-1":((-2))`, stmt)
+	_, err = InsertVertex("player").WithVIDType(VIDTypeInt64).VID("player100").Build()
+	assert.Error(t, err)
+
+	_, err = InsertVertex("player").VID(1.5).Build()
+	assert.Error(t, err)
+	_, err = InsertVertex("player").VID("a").Props(map[string]interface{}{"ch": make(chan int)}).Build()
+	assert.Error(t, err)
+}
+
+func TestInsertEdge(t *testing.T) {
+	stmt, err := InsertEdge("follow").From("player100").To("player101\"").Rank(1).
+		Props(map[string]interface{}{"degree": 95}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT EDGE follow(degree) VALUES "player100"->"player101\""@1:(95)`, stmt)
+
+	stmt, err = InsertEdge("follow").WithVIDType(VIDTypeInt64).From("100").To(101).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT EDGE follow() VALUES 100->101@0:()`, stmt)
+
+	_, err = InsertEdge("follow").From("player100").Build()
+	assert.Error(t, err)
+}
+
+func TestLookup(t *testing.T) {
+	stmt, err := Lookup("player").Where("name", "Tim").Where("age", 42).Yield("name", "age").Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `LOOKUP ON player WHERE player.age == 42 AND player.name == "Tim" YIELD player.name, player.age`, stmt)
+
+	_, err = Lookup("player").Build()
+	assert.Error(t, err)
+}
+
+func TestGo(t *testing.T) {
+	stmt, err := Go().From("player100").Over("follow").Yield("follow._dst").Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `GO 1 STEPS FROM "player100" OVER follow YIELD follow._dst`, stmt)
+
+	stmt, err = Go().Steps(2).From("player100", "player\"101").Over("follow", "my edge").Reversely().
+		Where("follow.degree > 90").Yield("follow._dst AS id", "$$.player.name AS name").Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "GO 2 STEPS FROM \"player100\", \"player\\\"101\" OVER follow, `my edge` REVERSELY "+
+		"WHERE follow.degree > 90 YIELD follow._dst AS id, $$.player.name AS name", stmt)
+
+	stmt, err = Go().WithVIDType(VIDTypeInt64).From(100, "101").Over("follow").Bidirect().Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `GO 1 STEPS FROM 100, 101 OVER follow BIDIRECT`, stmt)
+
+	_, err = Go().Over("follow").Build()
+	assert.Error(t, err)
+	_, err = Go().From("player100").Build()
+	assert.Error(t, err)
+	_, err = Go().Steps(0).From("player100").Over("follow").Build()
+	assert.Error(t, err)
+	_, err = Go().WithVIDType(VIDTypeInt64).From("player100").Over("follow").Build()
+	assert.Error(t, err)
+	_, err = Go().WithVIDMaxLen(8).From("player100").Over("follow").Build()
+	assert.Error(t, err)
+}
+
+func TestFetch(t *testing.T) {
+	stmt, err := Fetch().Prop("player").On("player100", 101).Yield("name", "age").Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `FETCH PROP ON player "player100", "101" YIELD player.name, player.age`, stmt)
+
+	stmt, err = Fetch().WithVIDType(VIDTypeInt64).On(100, uint(101)).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `FETCH PROP ON * 100, 101`, stmt)
+	stmt, err = Fetch().Prop("player", "my tag").On("player100").Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "FETCH PROP ON player, `my tag` \"player100\"", stmt)
+
+	_, err = Fetch().Prop("player").Build()
+	assert.Error(t, err)
+	_, err = Fetch().Prop("player", "team").On("player100").Yield("name").Build()
+	assert.Error(t, err)
+}
+
+func TestSession_ExecuteTraversalBuilders(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	goStmt, err := Go().Steps(2).From("player100").Over("follow").Yield("follow._dst").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchStmt, err := Fetch().Prop("player").On("player100").Yield("name").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{goStmt, fetchStmt} {
+		resp, err := session.Execute(stmt)
+		if assert.NoError(t, err) {
+			assert.True(t, resp.IsSucceeded())
+		}
+	}
+	assert.Equal(t, []string{goStmt, fetchStmt}, service.Statements())
+}
+
+func TestValidateVID(t *testing.T) {
+	assert.NoError(t, ValidateVID("player100", VIDTypeString, 10))
+	assert.NoError(t, ValidateVID("player100", VIDTypeString, 0))
+	assert.EqualError(t, ValidateVID("player100", VIDTypeString, 8),
+		`Invalid VID "player100": 9 bytes exceed the FIXED_STRING(8) of the space`)
+	assert.Error(t, ValidateVID("", VIDTypeString, 8))
+	assert.NoError(t, ValidateVID("-9223372036854775808", VIDTypeInt64, 0))
+	assert.EqualError(t, ValidateVID("9223372036854775808", VIDTypeInt64, 0),
+		"Invalid VID 9223372036854775808: out of the range of int64")
+	assert.EqualError(t, ValidateVID("player100", VIDTypeInt64, 0), `Invalid VID "player100": not an int64`)
+
+	vid := HashVID("user-42")
+	assert.Len(t, vid, 32)
+	assert.Equal(t, vid, HashVID("user-42"))
+	assert.NotEqual(t, vid, HashVID("user-43"))
+	assert.NoError(t, ValidateVID(vid, VIDTypeString, 32))
+
+	// The builders check the VIDs if the max length is set
+	_, err := InsertVertex("player").VID("player100").WithVIDMaxLen(8).Build()
+	assert.EqualError(t, err,
+		`Failed to build query: Invalid VID "player100": 9 bytes exceed the FIXED_STRING(8) of the space`)
+	_, err = InsertVertex("player").VID(123456789).WithVIDMaxLen(8).Build()
+	assert.Error(t, err)
+	_, err = InsertEdge("follow").From("player100").To("player1000").WithVIDMaxLen(9).Build()
+	assert.Error(t, err)
+	stmt, err := InsertEdge("follow").From("player100").To("player101").WithVIDMaxLen(9).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT EDGE follow() VALUES "player100"->"player101"@0:()`, stmt)
+}
@@ -0,0 +1,43 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"time"
+)
+
+// QuickExecute opens a connection to addr, signs in, switches to space if it is not empty, executes stmt,
+// then signs out and closes the connection. timeout applies to connecting and to every RPC, 0 value means no timeout.
+// It is meant for scripts and one-off queries, every call pays for a new connection and session,
+// so use a ConnectionPool for anything executed frequently.
+func QuickExecute(addr HostAddress, user, pass, space, stmt string, timeout time.Duration) (*ResultSet, error) {
+	conf := GetDefaultConf()
+	conf.TimeOut = timeout
+	conn := newConnection(addr)
+	if err := conn.open(addr, conf); err != nil {
+		return nil, err
+	}
+	defer conn.close()
+	authResp, err := conn.authenticate(user, pass)
+	if err != nil {
+		return nil, err
+	}
+	sessionID := authResp.GetSessionID()
+	defer conn.signOut(sessionID)
+
+	if space != "" {
+		resp, err := conn.execute(sessionID, "USE "+EscapeLabel(space))
+		if err := useSpaceError(space, resp, err); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := conn.execute(sessionID, stmt)
+	if err != nil {
+		return nil, err
+	}
+	return newResultSet(resp), nil
+}
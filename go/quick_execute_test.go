@@ -0,0 +1,41 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestQuickExecute(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	resp, err := QuickExecute(host, "root", "nebula", "nba", "YIELD 1", time.Second)
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+		assert.Equal(t, "nba", resp.GetSpaceName())
+	}
+	assert.Equal(t, []string{"USE nba", "YIELD 1"}, service.Statements())
+	// The session is signed out
+	assert.Eventually(t, func() bool { return service.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+
+	_, err = QuickExecute(host, "root", "wrong", "", "YIELD 1", time.Second)
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	_, err = QuickExecute(host, "root", "nebula", "not_exist", "YIELD 1", time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Failed to use space not_exist")
+	}
+	_, err = QuickExecute(closedAddress(t), "root", "nebula", "", "YIELD 1", time.Second)
+	assert.Error(t, err)
+}
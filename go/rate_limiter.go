@@ -0,0 +1,139 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiterStats is a snapshot of the rate limiter of a pool, see PoolConfig.QueriesPerSecond
+type RateLimiterStats struct {
+	QueriesPerSecond float64
+	Burst            int
+	// The queries which could start at once, negative if the waiting queries have reserved the coming ones
+	Tokens float64
+	// Number of queries waiting for their turn
+	Waiting int
+	// Number of queries let through, the ones which had to wait included
+	Admitted int64
+	// Number of queries which had to wait for their turn
+	Delayed int64
+	// Number of queries which gave up, their context was done before their turn came
+	Rejected int64
+}
+
+// A token bucket shared by all sessions of a pool: it holds up to burst tokens, refilled at rate per second,
+// and every query takes one. A query finding no token reserves the next one and waits until it is refilled.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	mu    sync.Mutex
+	// Can be negative, by the tokens reserved by the waiting queries
+	tokens float64
+	// The time tokens was last refilled
+	last     time.Time
+	waiting  int
+	admitted int64
+	delayed  int64
+	rejected int64
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Add the tokens refilled since the last time, must be called with mu held
+func (limiter *rateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(limiter.last); elapsed > 0 {
+		limiter.tokens += elapsed.Seconds() * limiter.rate
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+		limiter.last = now
+	}
+}
+
+// Take a token, waiting for it if the bucket is empty. It fails at once without waiting if ctx would be done
+// before the token is refilled, and when ctx is done or closeCh is closed while waiting.
+func (limiter *rateLimiter) wait(ctx context.Context, closeCh <-chan struct{}) error {
+	limiter.mu.Lock()
+	now := time.Now()
+	limiter.refill(now)
+	if limiter.tokens >= 1 {
+		limiter.tokens--
+		limiter.admitted++
+		limiter.mu.Unlock()
+		return nil
+	}
+	delay := time.Duration((1 - limiter.tokens) / limiter.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+		limiter.rejected++
+		limiter.mu.Unlock()
+		return fmt.Errorf("Failed to execute, the rate limit of %g queries per second is exceeded until the deadline: %w",
+			limiter.rate, context.DeadlineExceeded)
+	}
+	limiter.tokens--
+	limiter.waiting++
+	limiter.delayed++
+	limiter.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		err = fmt.Errorf("Failed to execute, the rate limit of %g queries per second is exceeded: %w", limiter.rate, ctx.Err())
+	case <-closeCh:
+		err = fmt.Errorf("Failed to execute: %w", ErrPoolClosed)
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.waiting--
+	if err != nil {
+		// Give the reserved token back for the queries behind
+		limiter.refill(time.Now())
+		limiter.tokens++
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+		limiter.rejected++
+		return err
+	}
+	limiter.admitted++
+	return nil
+}
+
+func (limiter *rateLimiter) stats() RateLimiterStats {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.refill(time.Now())
+	return RateLimiterStats{
+		QueriesPerSecond: limiter.rate,
+		Burst:            int(limiter.burst),
+		Tokens:           limiter.tokens,
+		Waiting:          limiter.waiting,
+		Admitted:         limiter.admitted,
+		Delayed:          limiter.delayed,
+		Rejected:         limiter.rejected,
+	}
+}
+
+// RateLimiterStats returns the state of the rate limiter of the pool, false if QueriesPerSecond is not set
+func (pool *ConnectionPool) RateLimiterStats() (RateLimiterStats, bool) {
+	if pool.rateLimiter == nil {
+		return RateLimiterStats{}, false
+	}
+	return pool.rateLimiter.stats(), true
+}
@@ -0,0 +1,108 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(20, 2)
+	closeCh := make(chan struct{})
+	start := time.Now()
+	// The burst goes at once, then one query every 50ms
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, limiter.wait(context.Background(), closeCh))
+	}
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 140*time.Millisecond, elapsed)
+	assert.True(t, elapsed < 400*time.Millisecond, elapsed)
+
+	// A deadline before the turn fails at once, and takes no token
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	err := limiter.wait(ctx, closeCh)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < 10*time.Millisecond)
+
+	// A cancelled wait gives its token back
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = limiter.wait(ctx, closeCh)
+	assert.True(t, errors.Is(err, context.Canceled))
+	close(closeCh)
+	err = limiter.wait(context.Background(), closeCh)
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+
+	stats := limiter.stats()
+	assert.Equal(t, 20.0, stats.QueriesPerSecond)
+	assert.Equal(t, 2, stats.Burst)
+	assert.Equal(t, 0, stats.Waiting)
+	assert.Equal(t, int64(5), stats.Admitted)
+	assert.Equal(t, int64(5), stats.Delayed)
+	assert.Equal(t, int64(3), stats.Rejected)
+	assert.True(t, stats.Tokens <= 2)
+}
+
+func TestSession_QueriesPerSecond(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := pool.RateLimiterStats()
+	assert.False(t, ok)
+	pool.Close()
+
+	conf := GetDefaultConf()
+	conf.QueriesPerSecond = 10
+	pool, err = NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	// The limit is shared by the sessions
+	sessions := make([]*Session, 2)
+	for i := range sessions {
+		if sessions[i], err = pool.GetSession("root", "nebula"); err != nil {
+			t.Fatal(err)
+		}
+		defer sessions[i].Release()
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		resp, err := sessions[i%2].Execute("YIELD 1")
+		if assert.NoError(t, err) {
+			assert.True(t, resp.IsSucceeded())
+		}
+	}
+	assert.True(t, time.Since(start) >= 280*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = sessions[0].ExecuteWithContext(ctx, "YIELD 1")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	stats, ok := pool.RateLimiterStats()
+	if assert.True(t, ok) {
+		assert.Equal(t, 1, stats.Burst)
+		assert.Equal(t, int64(4), stats.Admitted)
+		assert.Equal(t, int64(3), stats.Delayed)
+		assert.Equal(t, int64(1), stats.Rejected)
+	}
+}
@@ -0,0 +1,71 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The backoff between the attempts of WaitReady, starting at the initial one and doubled up to the max
+const (
+	waitReadyInitialBackoff = 100 * time.Millisecond
+	waitReadyMaxBackoff     = 2 * time.Second
+)
+
+// WaitReady blocks until the cluster could serve queries, e.g. in a test waiting for Nebula started alongside it.
+// It signs in with ValidateUsername and ValidatePassword, switches to SpaceName if it is set, and executes
+// YIELD 1, again and again with a backoff until all of them succeed. If ctx is done first, the error of the last
+// attempt is returned, unless ctx aborted it, which returns the error of the attempt before.
+// It fails at once if the pool is closed.
+func (pool *ConnectionPool) WaitReady(ctx context.Context) error {
+	policy := RetryPolicy{InitialBackoff: waitReadyInitialBackoff, Jitter: 0.2}
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		err := pool.checkReady(ctx)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrPoolClosed) {
+			return err
+		}
+		// The attempt aborted by ctx tells nothing about the cluster
+		if ctx.Err() == nil || lastErr == nil {
+			lastErr = err
+		}
+		backoff := policy.backoff(attempt)
+		if backoff > waitReadyMaxBackoff {
+			backoff = waitReadyMaxBackoff
+		}
+		if ctx.Err() == nil {
+			pool.log.Info(fmt.Sprintf("The cluster is not ready, retry in %s, error: %s", backoff, err.Error()))
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("Failed to wait for the cluster to be ready, the last error: %w", lastErr)
+		}
+	}
+}
+
+// Sign in a session and execute a trivial query with it
+func (pool *ConnectionPool) checkReady(ctx context.Context) error {
+	session, err := pool.GetSessionWithContext(ctx, pool.conf.ValidateUsername, pool.conf.ValidatePassword)
+	if err != nil {
+		return err
+	}
+	defer session.Release()
+	resultSet, err := session.ExecuteWithContext(ctx, "YIELD 1")
+	if err != nil {
+		return err
+	}
+	return CheckResponse(resultSet.GetResponse())
+}
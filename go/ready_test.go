@@ -0,0 +1,69 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestPool_WaitReady(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	var ready int32
+	var signIns int64
+	// graphd rejects signing in until metad is ready
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		signIns++
+		if atomic.LoadInt32(&ready) == 0 || username != "reader" {
+			return &graph.AuthResponse{ErrorCode: graph.ErrorCode_E_RPC_FAILURE, ErrorMsg: []byte("metad is not ready")}
+		}
+		sessionID := signIns
+		return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &sessionID}
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ValidateUsername = "reader"
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The error of the last attempt is returned when ctx is done first
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	err = pool.WaitReady(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "metad is not ready")
+	}
+
+	// The query fails once after signing in succeeds
+	service.QueueErrorCodes(graph.ErrorCode_E_RPC_FAILURE)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&ready, 1)
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	service.ResetStatements()
+	assert.NoError(t, pool.WaitReady(ctx))
+	assert.Equal(t, []string{"YIELD 1", "YIELD 1"}, service.Statements())
+
+	pool.Close()
+	start := time.Now()
+	err = pool.WaitReady(ctx)
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+}
@@ -0,0 +1,68 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+)
+
+// Resize changes MinConnPoolSize and MaxConnPoolSize of a running pool, e.g. when a service scales with its load.
+//
+// Growing opens no connection at once, they are opened as they are needed up to the new max, except for
+// the callers waiting for a connection to be released, which get a new one right away.
+// Shrinking closes the idle connections until the pool is down to the new min. If the connections in use
+// are more than the new max, the ones over it are retired and closed once released, the sessions holding them
+// keep working until then. No connection is opened until the pool is under the new max.
+//
+// An error is returned without any change if min is negative, max is less than 1 or min is larger than max.
+func (pool *ConnectionPool) Resize(min, max int) error {
+	if min < 0 || max < 1 || min > max {
+		return fmt.Errorf("Failed to resize the pool: invalid size, min %d, max %d", min, max)
+	}
+	pool.rwLock.Lock()
+	defer pool.rwLock.Unlock()
+	if pool.isClosed() {
+		return fmt.Errorf("Failed to resize the pool: %w", ErrPoolClosed)
+	}
+	pool.conf.MinConnPoolSize = min
+	pool.conf.MaxConnPoolSize = max
+	defer pool.observeConnCount()
+
+	for pool.idleConnectionQueue.Len() > 0 && pool.getTotalConnCount() > min {
+		pool.closeConn(pool.idleConnectionQueue.Remove(pool.idleConnectionQueue.Front()).(*connection))
+	}
+	// The retired connections are closed anyway once released
+	excess := pool.idleConnectionQueue.Len() - max
+	for ele := pool.activeConnectionQueue.Front(); ele != nil; ele = ele.Next() {
+		if !ele.Value.(*connection).retired {
+			excess++
+		}
+	}
+	for ele := pool.activeConnectionQueue.Front(); ele != nil && excess > 0; ele = ele.Next() {
+		if conn := ele.Value.(*connection); !conn.retired {
+			conn.retired = true
+			excess--
+		}
+	}
+
+	// The waiters are served by the connections the larger max allows
+	for front := pool.waiters.Front(); front != nil && pool.drained == nil; front = pool.waiters.Front() {
+		conn, err := pool.createConnection()
+		if err != nil {
+			break
+		}
+		pool.waiters.Remove(front).(chan *connection) <- conn
+	}
+	pool.log.Info(fmt.Sprintf("The pool is resized, min: %d, max: %d", min, max))
+	return nil
+}
+
+// Return the number of connections opened by the pool, retired ones and the ones being pinged included,
+// must be called with the lock held
+func (pool *ConnectionPool) getTotalConnCount() int {
+	return pool.idleConnectionQueue.Len() + pool.activeConnectionQueue.Len() + pool.checking
+}
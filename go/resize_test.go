@@ -0,0 +1,87 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestPool_Resize(t *testing.T) {
+	stop, host := startFakeServer(t, testutil.NewFakeGraphService())
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MinConnPoolSize = 2
+	conf.MaxConnPoolSize = 2
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Error(t, pool.Resize(3, 2))
+	assert.Error(t, pool.Resize(-1, 2))
+	assert.Error(t, pool.Resize(0, 0))
+
+	// Growing serves the caller waiting for a full pool, and opens nothing else
+	var held []*connection
+	for i := 0; i < 2; i++ {
+		conn, err := pool.GetConnection()
+		if err != nil {
+			t.Fatal(err)
+		}
+		held = append(held, conn)
+	}
+	done := make(chan *connection, 1)
+	go func() {
+		conn, err := pool.GetConnectionWithContext(context.Background())
+		assert.NoError(t, err)
+		done <- conn
+	}()
+	assert.Eventually(t, func() bool {
+		pool.rwLock.RLock()
+		defer pool.rwLock.RUnlock()
+		return pool.waiters.Len() == 1
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, pool.Resize(2, 4))
+	select {
+	case conn := <-done:
+		held = append(held, conn)
+	case <-time.After(time.Second):
+		t.Fatal("The waiter is not served")
+	}
+	assert.Equal(t, 3, pool.getServerWorkload(host))
+
+	// Shrinking below the connections in use retires the ones over the max, they are closed once released
+	assert.NoError(t, pool.Resize(0, 1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetConnectionWithContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	for _, conn := range held {
+		pool.Release(conn)
+	}
+	assert.Equal(t, 1, pool.getServerWorkload(host))
+	assert.Equal(t, 1, pool.getIdleConnCount())
+	session, err := pool.GetSession("root", "nebula")
+	if assert.NoError(t, err) {
+		session.Release()
+	}
+
+	// The idle connections are closed down to the min
+	assert.NoError(t, pool.Resize(0, 1))
+	assert.Equal(t, 0, pool.getServerWorkload(host))
+	assert.Equal(t, 0, pool.getIdleConnCount())
+
+	pool.Close()
+	assert.True(t, errors.Is(pool.Resize(1, 2), ErrPoolClosed))
+}
@@ -0,0 +1,121 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// The results are cached by user, space and statement, users may be granted different spaces
+type resultCacheKey struct {
+	username string
+	space    string
+	stmt     string
+}
+
+type resultCacheEntry struct {
+	key       resultCacheKey
+	resp      *graph.ExecutionResponse
+	expiresAt time.Time
+}
+
+// A LRU cache of the successful responses of the statements executed by Session.ExecuteCached
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[resultCacheKey]*list.Element
+	// The most recently used entry is at the front
+	lru list.List
+	// Replaced in tests
+	now func() time.Time
+}
+
+func newResultCache(size int, ttl time.Duration) *resultCache {
+	return &resultCache{size: size, ttl: ttl, entries: make(map[resultCacheKey]*list.Element), now: time.Now}
+}
+
+// Return the cached response of the key, nil if there is none or it has expired
+func (c *resultCache) get(key resultCacheKey) *graph.ExecutionResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ele, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := ele.Value.(*resultCacheEntry)
+	if c.ttl > 0 && !c.now().Before(entry.expiresAt) {
+		c.remove(ele)
+		return nil
+	}
+	c.lru.MoveToFront(ele)
+	return entry.resp
+}
+
+// Cache the response of the key, the least recently used entry is evicted if the cache is full
+func (c *resultCache) put(key resultCacheKey, resp *graph.ExecutionResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ele, ok := c.entries[key]; ok {
+		c.remove(ele)
+	}
+	c.entries[key] = c.lru.PushFront(&resultCacheEntry{key: key, resp: resp, expiresAt: c.now().Add(c.ttl)})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Drop every entry of the space
+func (c *resultCache) invalidateSpace(space string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ele := c.lru.Front(); ele != nil; {
+		next := ele.Next()
+		if ele.Value.(*resultCacheEntry).key.space == space {
+			c.remove(ele)
+		}
+		ele = next
+	}
+}
+
+// Must be called with mu held
+func (c *resultCache) remove(ele *list.Element) {
+	delete(c.entries, ele.Value.(*resultCacheEntry).key)
+	c.lru.Remove(ele)
+}
+
+func (c *resultCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+type cacheableKey struct{}
+
+// ExecuteCached executes a read-only statement whose result may be served from the result cache of the pool,
+// see PoolConfig.ResultCacheSize. A cached result is returned without a round trip until it expires after
+// ResultCacheTTL or is evicted. The results are cached by user, space and statement, only successful ones are
+// cached, and a statement which switches the session to another space, e.g. with USE, is never cached. It behaves as Execute
+// if the cache is disabled or the statement is not read-only, see IsReadOnlyStatement.
+// The cache is not consistency-safe: a cached result does not reflect the writes made after it was cached,
+// unless ResultCacheInvalidateOnWrite is set and the writes are executed by the sessions of the same pool.
+// Only use it for data which is rarely written or could be stale.
+func (session *Session) ExecuteCached(stmt string) (*ResultSet, error) {
+	// The statement is kept as it is given, it may be tagged with a trace ID before it is sent
+	return session.ExecuteWithContext(context.WithValue(context.Background(), cacheableKey{}, stmt), stmt)
+}
+
+// Return the statement executed with ctx to look up in the cache, false if it should not be cached
+func cacheableStatement(ctx context.Context) (string, bool) {
+	stmt, ok := ctx.Value(cacheableKey{}).(string)
+	return stmt, ok
+}
@@ -0,0 +1,112 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestResultCache(t *testing.T) {
+	now := time.Now()
+	cache := newResultCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+	key := func(stmt string) resultCacheKey { return resultCacheKey{username: "root", space: "nba", stmt: stmt} }
+	resp := func(stmt string) *graph.ExecutionResponse {
+		return &graph.ExecutionResponse{ErrorMsg: []byte(stmt)}
+	}
+
+	// The least recently used entry is evicted
+	cache.put(key("a"), resp("a"))
+	cache.put(key("b"), resp("b"))
+	assert.Equal(t, "a", string(cache.get(key("a")).GetErrorMsg()))
+	cache.put(key("c"), resp("c"))
+	assert.Equal(t, 2, cache.len())
+	assert.Nil(t, cache.get(key("b")))
+	assert.NotNil(t, cache.get(key("a")))
+	assert.NotNil(t, cache.get(key("c")))
+	// The keys are distinct by user and space
+	assert.Nil(t, cache.get(resultCacheKey{username: "user", space: "nba", stmt: "a"}))
+	assert.Nil(t, cache.get(resultCacheKey{username: "root", space: "test", stmt: "a"}))
+
+	// An entry expires after the TTL
+	now = now.Add(30 * time.Second)
+	cache.put(key("a"), resp("a2"))
+	now = now.Add(40 * time.Second)
+	assert.Nil(t, cache.get(key("c")))
+	assert.Equal(t, "a2", string(cache.get(key("a")).GetErrorMsg()))
+	assert.Equal(t, 1, cache.len())
+
+	cache.put(resultCacheKey{username: "root", space: "test", stmt: "a"}, resp("a"))
+	cache.invalidateSpace("nba")
+	assert.Nil(t, cache.get(key("a")))
+	assert.Equal(t, 1, cache.len())
+}
+
+func TestSession_ExecuteCached(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ResultCacheSize = 10
+	conf.ResultCacheInvalidateOnWrite = true
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	_, err = session.Execute("USE nba")
+	assert.NoError(t, err)
+	service.ResetStatements()
+
+	for i := 0; i < 3; i++ {
+		resp, err := session.ExecuteCached("GO FROM 1 OVER follow")
+		if assert.NoError(t, err) {
+			assert.True(t, resp.IsSucceeded())
+			assert.Equal(t, "nba", resp.GetSpaceName())
+		}
+	}
+	assert.Equal(t, []string{"GO FROM 1 OVER follow"}, service.Statements())
+	// Execute bypasses the cache
+	_, err = session.Execute("GO FROM 1 OVER follow")
+	assert.NoError(t, err)
+	assert.Len(t, service.Statements(), 2)
+
+	// Writes are not cached
+	service.ResetStatements()
+	for i := 0; i < 2; i++ {
+		_, err = session.ExecuteCached("INSERT VERTEX player(name) VALUES 1:(\"Tim\")")
+		assert.NoError(t, err)
+	}
+	assert.Len(t, service.Statements(), 2)
+	// The failures are not cached
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_E_EXECUTION_ERROR)
+	resp, err := session.ExecuteCached("FETCH PROP ON player 1")
+	assert.NoError(t, err)
+	assert.False(t, resp.IsSucceeded())
+	resp, err = session.ExecuteCached("FETCH PROP ON player 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.Len(t, service.Statements(), 2)
+
+	// A write drops the results of the space
+	assert.Equal(t, 1, pool.resultCache.len())
+	_, err = session.Execute("DELETE VERTEX 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pool.resultCache.len())
+}
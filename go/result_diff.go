@@ -0,0 +1,204 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// CompareMode tells how ResultSet.Diff matches the rows of two results
+type CompareMode int
+
+const (
+	// CompareUnordered matches the rows in any order, a row found twice in one result must be found twice in the other
+	CompareUnordered CompareMode = iota
+	// CompareOrdered matches the rows by their index, e.g. for a query with ORDER BY
+	CompareOrdered
+)
+
+// Equal tells if both results have the same column names in the same order and the same rows in any order,
+// see Diff to find out what differs
+func (res ResultSet) Equal(other *ResultSet) bool {
+	return len(res.Diff(other, CompareUnordered)) == 0
+}
+
+// Diff compares the column names and the rows of both results and describes every difference found,
+// e.g. for the failure message of a test. It is empty if they are equal, the rows are not compared if the
+// columns differ. The values are compared by type and content, so the int 1 differs from the float 1.0,
+// a NULL equals a NULL of the same kind, and the values of maps, properties, tags and sets match in any order,
+// while lists and paths keep theirs. The error codes and the other fields of the responses are ignored.
+func (res ResultSet) Diff(other *ResultSet, mode CompareMode) []string {
+	if other == nil {
+		return []string{"the other result is nil"}
+	}
+	if !equalStrings(res.columnNames, other.columnNames) {
+		return []string{fmt.Sprintf("the columns differ: %v != %v", res.columnNames, other.columnNames)}
+	}
+	rows, otherRows := rowKeys(res.getRows()), rowKeys(other.getRows())
+	var diffs []string
+	if mode == CompareOrdered {
+		for i := 0; i < len(rows) || i < len(otherRows); i++ {
+			switch {
+			case i >= len(otherRows):
+				diffs = append(diffs, fmt.Sprintf("row %d %s is not in the other result", i, rows[i]))
+			case i >= len(rows):
+				diffs = append(diffs, fmt.Sprintf("row %d %s of the other result is not in this one", i, otherRows[i]))
+			case rows[i] != otherRows[i]:
+				diffs = append(diffs, fmt.Sprintf("row %d differs: %s != %s", i, rows[i], otherRows[i]))
+			}
+		}
+		return diffs
+	}
+	// The indexes of the rows of the other result not matched yet, by key
+	unmatched := make(map[string][]int)
+	for i, key := range otherRows {
+		unmatched[key] = append(unmatched[key], i)
+	}
+	for i, key := range rows {
+		if indexes := unmatched[key]; len(indexes) > 0 {
+			unmatched[key] = indexes[1:]
+		} else {
+			diffs = append(diffs, fmt.Sprintf("row %d %s is not in the other result", i, key))
+		}
+	}
+	var missing []int
+	for _, indexes := range unmatched {
+		missing = append(missing, indexes...)
+	}
+	sort.Ints(missing)
+	for _, i := range missing {
+		diffs = append(diffs, fmt.Sprintf("row %d %s of the other result is not in this one", i, otherRows[i]))
+	}
+	return diffs
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Render every row as a string equal for equal rows
+func rowKeys(rows []*nebula.Row) []string {
+	keys := make([]string, len(rows))
+	for i, row := range rows {
+		keys[i] = "(" + valueKeys(row.GetValues()) + ")"
+	}
+	return keys
+}
+
+func valueKeys(values []*nebula.Value) string {
+	keys := make([]string, len(values))
+	for i, value := range values {
+		keys[i] = valueKey(value)
+	}
+	return strings.Join(keys, ", ")
+}
+
+// Render the value with its type, the maps and the sets in a fixed order, so equal values give equal strings
+func valueKey(value *nebula.Value) string {
+	switch {
+	case value == nil:
+		return "<missing>"
+	case value.IsSetNVal():
+		if value.GetNVal() == nebula.NullType___NULL__ {
+			return "NULL"
+		}
+		return "NULL(" + value.GetNVal().String() + ")"
+	case value.IsSetBVal():
+		return strconv.FormatBool(value.GetBVal())
+	case value.IsSetIVal():
+		return strconv.FormatInt(value.GetIVal(), 10)
+	case value.IsSetFVal():
+		// Keep a float apart from an int
+		literal := strconv.FormatFloat(value.GetFVal(), 'g', -1, 64)
+		if !strings.ContainsAny(literal, ".eIN") {
+			literal += ".0"
+		}
+		return literal
+	case value.IsSetSVal():
+		return strconv.Quote(string(value.GetSVal()))
+	case value.IsSetDVal():
+		d := value.GetDVal()
+		return fmt.Sprintf("date(%04d-%02d-%02d)", d.GetYear(), d.GetMonth(), d.GetDay())
+	case value.IsSetTVal():
+		t := value.GetTVal()
+		return fmt.Sprintf("time(%02d:%02d:%02d.%06d)", t.GetHour(), t.GetMinute(), t.GetSec(), t.GetMicrosec())
+	case value.IsSetDtVal():
+		dt := value.GetDtVal()
+		return fmt.Sprintf("datetime(%04d-%02d-%02dT%02d:%02d:%02d.%06d)", dt.GetYear(), dt.GetMonth(), dt.GetDay(),
+			dt.GetHour(), dt.GetMinute(), dt.GetSec(), dt.GetMicrosec())
+	case value.IsSetVVal():
+		return vertexKey(value.GetVVal())
+	case value.IsSetEVal():
+		e := value.GetEVal()
+		return fmt.Sprintf("[%q %q->%q @%d %d %s]", e.GetName(), e.GetSrc(), e.GetDst(), e.GetRanking(), e.GetType(),
+			propsKey(e.GetProps()))
+	case value.IsSetPVal():
+		var b strings.Builder
+		b.WriteString("<" + vertexKey(value.GetPVal().GetSrc()))
+		for _, step := range value.GetPVal().GetSteps() {
+			fmt.Fprintf(&b, "-[%q @%d %d %s]->%s", step.GetName(), step.GetRanking(), step.GetType(),
+				propsKey(step.GetProps()), vertexKey(step.GetDst()))
+		}
+		return b.String() + ">"
+	case value.IsSetLVal():
+		return "[" + valueKeys(value.GetLVal().GetValues()) + "]"
+	case value.IsSetMVal():
+		return propsKey(value.GetMVal().GetKvs())
+	case value.IsSetUVal():
+		values := value.GetUVal().GetValues()
+		keys := make([]string, len(values))
+		for i, elem := range values {
+			keys[i] = valueKey(elem)
+		}
+		sort.Strings(keys)
+		return "set{" + strings.Join(keys, ", ") + "}"
+	case value.IsSetGVal():
+		ds := value.GetGVal()
+		names := make([]string, len(ds.GetColumnNames()))
+		for i, name := range ds.GetColumnNames() {
+			names[i] = string(name)
+		}
+		return fmt.Sprintf("dataset(%q %v)", names, rowKeys(ds.GetRows()))
+	default:
+		return value.String()
+	}
+}
+
+func vertexKey(vertex *nebula.Vertex) string {
+	tags := make([]string, len(vertex.GetTags()))
+	for i, tag := range vertex.GetTags() {
+		tags[i] = fmt.Sprintf(":%q%s", tag.GetName(), propsKey(tag.GetProps()))
+	}
+	sort.Strings(tags)
+	return fmt.Sprintf("(%q %s)", vertex.GetVid(), strings.Join(tags, " "))
+}
+
+func propsKey(props map[string]*nebula.Value) string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	elems := make([]string, len(keys))
+	for i, key := range keys {
+		elems[i] = strconv.Quote(key) + ": " + valueKey(props[key])
+	}
+	return "{" + strings.Join(elems, ", ") + "}"
+}
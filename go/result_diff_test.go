@@ -0,0 +1,89 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+func TestResultSet_Diff(t *testing.T) {
+	resultSet := newResultSet(genResp())
+	reversed := genResp()
+	rows := reversed.Data.Rows
+	rows[0], rows[1] = rows[1], rows[0]
+	other := newResultSet(reversed)
+
+	assert.True(t, resultSet.Equal(newResultSet(genResp())))
+	assert.True(t, resultSet.Equal(other))
+	assert.Equal(t, []string{
+		`row 0 differs: ("Bob", 10) != ("Tom", 11)`,
+		`row 1 differs: ("Tom", 11) != ("Bob", 10)`,
+	}, resultSet.Diff(other, CompareOrdered))
+
+	// A duplicated row must be found as many times
+	duplicated := genResp()
+	duplicated.Data.Rows[1] = duplicated.Data.Rows[0]
+	assert.Equal(t, []string{
+		`row 1 ("Tom", 11) is not in the other result`,
+		`row 1 ("Bob", 10) of the other result is not in this one`,
+	}, resultSet.Diff(newResultSet(duplicated), CompareUnordered))
+	shorter := genResp()
+	shorter.Data.Rows = shorter.Data.Rows[:1]
+	assert.Equal(t, []string{`row 1 ("Tom", 11) is not in the other result`}, resultSet.Diff(newResultSet(shorter), CompareOrdered))
+
+	renamed := genResp()
+	renamed.Data.ColumnNames[1] = []byte("years")
+	assert.Equal(t, []string{"the columns differ: [name age] != [name years]"}, resultSet.Diff(newResultSet(renamed), CompareOrdered))
+	assert.False(t, resultSet.Equal(nil))
+}
+
+func TestResultSet_DiffValues(t *testing.T) {
+	// A result with one row of the values
+	gen := func(values ...*nebula.Value) *ResultSet {
+		names := make([][]byte, len(values))
+		for i := range values {
+			names[i] = []byte("v")
+		}
+		return newResultSet(&graph.ExecutionResponse{
+			ErrorCode: graph.ErrorCode_SUCCEEDED,
+			Data:      &nebula.DataSet{ColumnNames: names, Rows: []*nebula.Row{{Values: values}}},
+		})
+	}
+	null := nebula.NullType___NULL__
+	nan := nebula.NullType_NaN
+	f := 10.0
+
+	assert.True(t, gen(&nebula.Value{NVal: &null}).Equal(gen(&nebula.Value{NVal: &null})))
+	assert.Equal(t, []string{"row 0 differs: (NULL) != (NULL(NaN))"},
+		gen(&nebula.Value{NVal: &null}).Diff(gen(&nebula.Value{NVal: &nan}), CompareOrdered))
+	assert.Equal(t, []string{"row 0 differs: (10) != (10.0)"}, gen(intValue(10)).Diff(gen(&nebula.Value{FVal: &f}), CompareOrdered))
+	assert.False(t, gen(intValue(10)).Equal(gen(strValue("10"))))
+
+	// The maps, the tags and the sets match in any order, the lists do not
+	bob := genVertex("Bob", "person", "student")
+	reordered := genVertex("Bob", "student", "person")
+	reordered.Tags[0].Props["age"] = intValue(10)
+	bob.Tags[1].Props["age"] = intValue(10)
+	assert.True(t, gen(&nebula.Value{VVal: bob}).Equal(gen(&nebula.Value{VVal: reordered})))
+	reordered.Tags[0].Props["age"] = intValue(11)
+	assert.False(t, gen(&nebula.Value{VVal: bob}).Equal(gen(&nebula.Value{VVal: reordered})))
+	set := &nebula.Value{UVal: &nebula.Set{Values: []*nebula.Value{intValue(1), strValue("a")}}}
+	reorderedSet := &nebula.Value{UVal: &nebula.Set{Values: []*nebula.Value{strValue("a"), intValue(1)}}}
+	assert.True(t, gen(set).Equal(gen(reorderedSet)))
+	list := &nebula.Value{LVal: &nebula.List{Values: []*nebula.Value{intValue(1), strValue("a")}}}
+	reorderedList := &nebula.Value{LVal: &nebula.List{Values: []*nebula.Value{strValue("a"), intValue(1)}}}
+	assert.False(t, gen(list).Equal(gen(reorderedList)))
+	edge := &nebula.Value{EVal: &nebula.Edge{Src: []byte("a"), Dst: []byte("b"), Name: []byte("follow"), Type: 1,
+		Props: map[string]*nebula.Value{"degree": intValue(90), "since": {DVal: &nebula.Date{Year: 2020, Month: 1, Day: 2}}}}}
+	assert.Equal(t, `["follow" "a"->"b" @0 1 {"degree": 90, "since": date(2020-01-02)}]`, valueKey(edge))
+	assert.True(t, gen(edge, list).Equal(gen(edge, list)))
+}
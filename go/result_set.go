@@ -0,0 +1,274 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// ResultSet is the result of a query returned by Session.Execute.
+// The values are kept as they are decoded from the response and only converted when they are accessed,
+// so a malformed value, e.g. a path with a step missing its destination, only fails the accessor of its column,
+// such as ValueWrapper.AsPath, while the other columns and rows stay usable.
+type ResultSet struct {
+	resp            *graph.ExecutionResponse
+	columnNames     []string
+	colNameIndexMap map[string]int
+	vidType         VIDType
+	// How string values with invalid UTF-8 are read, see PoolConfig.UTF8Policy
+	utf8Policy UTF8Policy
+}
+
+// ColumnType is the name and the data type of a column
+type ColumnType struct {
+	Name string
+	// One of the types returned by ValueWrapper.GetType, or "unknown" if the column has no non-null value
+	Type string
+}
+
+// Record is a row of a ResultSet, its values are converted lazily as those of the ResultSet
+type Record struct {
+	columnNames     []string
+	_record         []*nebula.Value
+	colNameIndexMap map[string]int
+	vidType         VIDType
+	utf8Policy      UTF8Policy
+}
+
+func newResultSet(resp *graph.ExecutionResponse) *ResultSet {
+	var colNames []string
+	colNameIndexMap := make(map[string]int)
+	var names [][]byte
+	if resp.GetData() != nil {
+		names = resp.GetData().GetColumnNames()
+	}
+	for i, name := range names {
+		colNames = append(colNames, string(name))
+		colNameIndexMap[string(name)] = i
+	}
+	return &ResultSet{
+		resp:            resp,
+		columnNames:     colNames,
+		colNameIndexMap: colNameIndexMap,
+	}
+}
+
+// Return true if the query succeeded on the server side, it only depends on the error code.
+// A result is in one of three states:
+//   - failed: IsSucceeded() is false, GetErrorCode and GetErrorMsg tell why
+//   - succeeded without rows: IsSucceeded() and IsEmpty() are true, e.g. an INSERT or a GO finding nothing
+//   - succeeded with rows: IsSucceeded() is true and IsEmpty() is false
+func (res ResultSet) IsSucceeded() bool {
+	return res.resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED
+}
+
+// Return true if the result has no row, a failed query is always empty, so check IsSucceeded first
+func (res ResultSet) IsEmpty() bool {
+	return res.GetRowSize() == 0
+}
+
+// Return the error code returned by the server
+func (res ResultSet) GetErrorCode() graph.ErrorCode {
+	return res.resp.GetErrorCode()
+}
+
+// Return the error message returned by the server
+func (res ResultSet) GetErrorMsg() string {
+	return string(res.resp.GetErrorMsg())
+}
+
+// Return the space the session is in after the query
+func (res ResultSet) GetSpaceName() string {
+	return string(res.resp.GetSpaceName())
+}
+
+// Return the time graphd spent on the query, the network round trip is not included
+func (res ResultSet) GetLatency() time.Duration {
+	return time.Duration(res.resp.GetLatencyInUs()) * time.Microsecond
+}
+
+// Return the warnings graphd attached to the response, e.g. about a deprecated syntax, one per line of
+// its comment. An empty slice is returned if there is none, a failed query could carry warnings too.
+func (res ResultSet) GetWarnings() []string {
+	warnings := []string{}
+	for _, line := range strings.Split(string(res.resp.GetComment()), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			warnings = append(warnings, line)
+		}
+	}
+	return warnings
+}
+
+// Return the name and the data type of every column.
+// The response of graphd carries no column types, so the type of a column is inferred from its first
+// value which is neither null nor empty, and is "unknown" if there is no such value.
+// A column holding values of different types, e.g. properties of different tags, reports the first one.
+func (res ResultSet) GetColTypes() []ColumnType {
+	colTypes := make([]ColumnType, len(res.columnNames))
+	for i, name := range res.columnNames {
+		colTypes[i] = ColumnType{Name: name, Type: "unknown"}
+		for _, row := range res.getRows() {
+			values := row.GetValues()
+			if i >= len(values) {
+				continue
+			}
+			if value := (ValueWrapper{value: values[i]}); !value.IsEmpty() && !value.IsNull() {
+				colTypes[i].Type = value.GetType()
+				break
+			}
+		}
+	}
+	return colTypes
+}
+
+// Return the names of all columns
+func (res ResultSet) GetColNames() []string {
+	return res.columnNames
+}
+
+// Return the number of columns
+func (res ResultSet) GetColSize() int {
+	return len(res.columnNames)
+}
+
+// Return the number of rows
+func (res ResultSet) GetRowSize() int {
+	return len(res.getRows())
+}
+
+// Return the rows of the response, nil if the response has no data
+func (res ResultSet) getRows() []*nebula.Row {
+	if res.resp.GetData() == nil {
+		return nil
+	}
+	return res.resp.GetData().GetRows()
+}
+
+// Return the row at the given index
+func (res ResultSet) GetRowValuesByIndex(index int) (*Record, error) {
+	rows := res.getRows()
+	if index < 0 || index >= len(rows) {
+		return nil, fmt.Errorf("Failed to get row, the index %d is out of range [0, %d)", index, len(rows))
+	}
+	return res.newRecord(rows[index]), nil
+}
+
+func (res ResultSet) newRecord(row *nebula.Row) *Record {
+	return &Record{
+		columnNames:     res.columnNames,
+		_record:         row.GetValues(),
+		colNameIndexMap: res.colNameIndexMap,
+		vidType:         res.vidType,
+		utf8Policy:      res.utf8Policy,
+	}
+}
+
+// ForEach calls fn with every row in order, it stops at the first error returned by fn and returns it
+func (res ResultSet) ForEach(fn func(record *Record) error) error {
+	for _, row := range res.getRows() {
+		if err := fn(res.newRecord(row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Select returns a result of only the named columns in the given order, e.g. to hide internal columns before
+// the result is serialized. The rows share their values with res, nothing is copied but the rows themselves.
+// The response of the returned result is a copy of the one of res with the projected data.
+// An error is returned if any column does not exist.
+func (res ResultSet) Select(cols ...string) (*ResultSet, error) {
+	indexes := make([]int, 0, len(cols))
+	names := make([][]byte, 0, len(cols))
+	for _, col := range cols {
+		index, ok := res.colNameIndexMap[col]
+		if !ok {
+			return nil, fmt.Errorf("Failed to select columns, column %s does not exist", col)
+		}
+		indexes = append(indexes, index)
+		names = append(names, []byte(col))
+	}
+	rows := res.getRows()
+	projectedRows := make([]*nebula.Row, 0, len(rows))
+	for _, row := range rows {
+		values := row.GetValues()
+		projected := make([]*nebula.Value, len(indexes))
+		for i, index := range indexes {
+			if index < len(values) {
+				projected[i] = values[index]
+			}
+		}
+		projectedRows = append(projectedRows, &nebula.Row{Values: projected})
+	}
+	resp := *res.resp
+	resp.Data = &nebula.DataSet{ColumnNames: names, Rows: projectedRows}
+	resultSet := newResultSet(&resp)
+	resultSet.vidType = res.vidType
+	resultSet.utf8Policy = res.utf8Policy
+	return resultSet, nil
+}
+
+// Columns returns the values grouped by column instead of by row, e.g. to feed a columnar format.
+// Every column holds one value per row in the order of the rows, a map has no order so GetColNames
+// gives the order of the columns. The values are wrapped without being converted, as those of a Record.
+// An error is returned if the names of two columns are the same, or a row misses the value of a column.
+func (res ResultSet) Columns() (map[string][]ValueWrapper, error) {
+	rows := res.getRows()
+	columns := make(map[string][]ValueWrapper, len(res.columnNames))
+	for _, name := range res.columnNames {
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("Failed to get columns, column %s is duplicated", name)
+		}
+		columns[name] = make([]ValueWrapper, len(rows))
+	}
+	for i, row := range rows {
+		values := row.GetValues()
+		for j, name := range res.columnNames {
+			if j >= len(values) || values[j] == nil {
+				return nil, fmt.Errorf("Failed to get columns, the value of column %s is missing from row %d", name, i)
+			}
+			columns[name][i] = ValueWrapper{value: values[j], vidType: res.vidType, utf8Policy: res.utf8Policy}
+		}
+	}
+	return columns, nil
+}
+
+// Return the raw response of the query
+func (res ResultSet) GetResponse() *graph.ExecutionResponse {
+	return res.resp
+}
+
+// Return the plan of an EXPLAIN or PROFILE statement, nil if the statement is neither of them.
+// FormatPlanTree and FormatPlanDOT turn it into a readable form.
+func (res ResultSet) GetPlanDesc() *graph.PlanDescription {
+	return res.resp.GetPlanDesc()
+}
+
+// Return the value at the given column index, an error is returned if the row has no value at it
+func (record Record) GetValueByIndex(index int) (*ValueWrapper, error) {
+	if index < 0 || index >= len(record._record) {
+		return nil, fmt.Errorf("Failed to get value, the index %d is out of range [0, %d)", index, len(record._record))
+	}
+	if record._record[index] == nil {
+		return nil, fmt.Errorf("Failed to get value, the value at index %d is missing from the response", index)
+	}
+	return &ValueWrapper{value: record._record[index], vidType: record.vidType, utf8Policy: record.utf8Policy}, nil
+}
+
+// Return the value of the given column
+func (record Record) GetValueByColName(colName string) (*ValueWrapper, error) {
+	index, ok := record.colNameIndexMap[colName]
+	if !ok {
+		return nil, fmt.Errorf("Failed to get value, column %s does not exist", colName)
+	}
+	return record.GetValueByIndex(index)
+}
@@ -0,0 +1,280 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+func intValue(i int64) *nebula.Value {
+	return &nebula.Value{IVal: &i}
+}
+
+func strValue(s string) *nebula.Value {
+	return &nebula.Value{SVal: []byte(s)}
+}
+
+// Build a response with columns name and age
+func genResp() *graph.ExecutionResponse {
+	return &graph.ExecutionResponse{
+		ErrorCode:   graph.ErrorCode_SUCCEEDED,
+		LatencyInUs: 1500,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("name"), []byte("age")},
+			Rows: []*nebula.Row{
+				{Values: []*nebula.Value{strValue("Bob"), intValue(10)}},
+				{Values: []*nebula.Value{strValue("Tom"), intValue(11)}},
+			},
+		},
+	}
+}
+
+func TestResultSet(t *testing.T) {
+	resultSet := newResultSet(genResp())
+	assert.True(t, resultSet.IsSucceeded())
+	assert.Equal(t, []string{"name", "age"}, resultSet.GetColNames())
+	assert.Equal(t, 2, resultSet.GetRowSize())
+	assert.False(t, resultSet.IsEmpty())
+	assert.Equal(t, 1500*time.Microsecond, resultSet.GetLatency())
+
+	record, err := resultSet.GetRowValuesByIndex(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := record.GetValueByColName("name")
+	assert.NoError(t, err)
+	assert.Equal(t, "Tom", name.String())
+	age, err := record.GetValueByIndex(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "11", age.String())
+
+	_, err = record.GetValueByColName("gender")
+	assert.Error(t, err)
+	_, err = resultSet.GetRowValuesByIndex(2)
+	assert.Error(t, err)
+
+	// A response without data
+	resultSet = newResultSet(&graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SYNTAX_ERROR})
+	assert.False(t, resultSet.IsSucceeded())
+	assert.True(t, resultSet.IsEmpty())
+	assert.Equal(t, 0, resultSet.GetRowSize())
+	assert.Equal(t, 0, resultSet.GetColSize())
+}
+
+func TestResultSet_IsEmpty(t *testing.T) {
+	// An INSERT succeeds without data
+	resultSet := newResultSet(&graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED})
+	assert.True(t, resultSet.IsSucceeded())
+	assert.True(t, resultSet.IsEmpty())
+
+	// A query finding nothing has columns but no row
+	resultSet = newResultSet(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data:      &nebula.DataSet{ColumnNames: [][]byte{[]byte("name")}},
+	})
+	assert.True(t, resultSet.IsSucceeded())
+	assert.True(t, resultSet.IsEmpty())
+	assert.Equal(t, []string{"name"}, resultSet.GetColNames())
+}
+
+func TestResultSet_GetColTypes(t *testing.T) {
+	null := nebula.NullType___NULL__
+	resultSet := newResultSet(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("name"), []byte("age"), []byte("nothing")},
+			Rows: []*nebula.Row{
+				{Values: []*nebula.Value{{NVal: &null}, intValue(10), {NVal: &null}}},
+				{Values: []*nebula.Value{strValue("Tom"), intValue(11), {NVal: &null}}},
+			},
+		},
+	})
+	assert.Equal(t, []ColumnType{
+		{Name: "name", Type: "string"},
+		{Name: "age", Type: "int"},
+		{Name: "nothing", Type: "unknown"},
+	}, resultSet.GetColTypes())
+	assert.Empty(t, newResultSet(&graph.ExecutionResponse{}).GetColTypes())
+}
+
+func TestResultSet_GetWarnings(t *testing.T) {
+	assert.Equal(t, []string{}, newResultSet(genResp()).GetWarnings())
+
+	resp := genResp()
+	resp.Comment = []byte("`GO FROM ... OVER *' is deprecated\n\n  The result is truncated to 10000 rows \n")
+	assert.Equal(t, []string{
+		"`GO FROM ... OVER *' is deprecated",
+		"The result is truncated to 10000 rows",
+	}, newResultSet(resp).GetWarnings())
+}
+
+func TestResultSet_ForEach(t *testing.T) {
+	resultSet := newResultSet(genResp())
+	var ages []int64
+	err := resultSet.ForEach(func(record *Record) error {
+		age, err := record.GetValueByColName("age")
+		if err != nil {
+			return err
+		}
+		i, err := age.AsInt()
+		ages = append(ages, i)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{10, 11}, ages)
+
+	// The walk stops at the first error
+	stop := errors.New("stop")
+	calls := 0
+	err = resultSet.ForEach(func(record *Record) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestResultSet_Select(t *testing.T) {
+	resp := genResp()
+	resultSet := newResultSet(resp)
+	resultSet.vidType = VIDTypeInt64
+
+	selected, err := resultSet.Select("age", "name")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"age", "name"}, selected.GetColNames())
+		assert.Equal(t, 2, selected.GetRowSize())
+		assert.Equal(t, VIDTypeInt64, selected.vidType)
+		assert.Equal(t, resp.GetLatencyInUs(), selected.GetResponse().GetLatencyInUs())
+		record, _ := selected.GetRowValuesByIndex(1)
+		age, _ := record.GetValueByColName("age")
+		assert.Equal(t, "11", age.String())
+		// The values are shared, the original result is not changed
+		assert.Same(t, resp.Data.Rows[1].Values[1], selected.getRows()[1].Values[0])
+		assert.Equal(t, []string{"name", "age"}, resultSet.GetColNames())
+	}
+	selected, err = resultSet.Select()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, selected.GetColSize())
+		assert.Equal(t, 2, selected.GetRowSize())
+	}
+
+	_, err = resultSet.Select("name", "gender")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "gender")
+	}
+}
+
+func TestResultSet_Columns(t *testing.T) {
+	resultSet := newResultSet(genResp())
+	resultSet.vidType = VIDTypeInt64
+	columns, err := resultSet.Columns()
+	if assert.NoError(t, err) && assert.Len(t, columns, 2) && assert.Len(t, columns["age"], 2) {
+		assert.Equal(t, "Bob", columns["name"][0].String())
+		assert.Equal(t, "Tom", columns["name"][1].String())
+		age, err := columns["age"][1].AsInt()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(11), age)
+		assert.Equal(t, VIDTypeInt64, columns["age"][0].vidType)
+	}
+
+	// The columns of a result without rows are empty
+	resp := genResp()
+	resp.Data.Rows = nil
+	columns, err = newResultSet(resp).Columns()
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string][]ValueWrapper{"name": {}, "age": {}}, columns)
+	}
+
+	resp = genResp()
+	resp.Data.Rows[1].Values = resp.Data.Rows[1].Values[:1]
+	_, err = newResultSet(resp).Columns()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "column age is missing from row 1")
+	}
+	resp = genResp()
+	resp.Data.ColumnNames[1] = []byte("name")
+	_, err = newResultSet(resp).Columns()
+	assert.Error(t, err)
+}
+
+func TestResultSet_MalformedValue(t *testing.T) {
+	// A path with a step missing its destination, decoded from a corrupt response
+	badPath := &nebula.Value{PVal: &nebula.Path{
+		Src:   &nebula.Vertex{Vid: nebula.VertexID("Bob")},
+		Steps: []*nebula.Step{{Name: []byte("follow")}},
+	}}
+	resultSet := newResultSet(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("age"), []byte("path"), []byte("missing")},
+			Rows: []*nebula.Row{
+				{Values: []*nebula.Value{intValue(10), badPath, nil}},
+				{Values: []*nebula.Value{intValue(11), intValue(1), intValue(2)}},
+			},
+		},
+	})
+	assert.Equal(t, "path", resultSet.GetColTypes()[1].Type)
+
+	record, err := resultSet.GetRowValuesByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := record.GetValueByColName("path")
+	if assert.NoError(t, err) {
+		_, err = path.AsPath()
+		assert.Error(t, err)
+	}
+	_, err = record.GetValueByColName("missing")
+	assert.EqualError(t, err, "Failed to get value, the value at index 2 is missing from the response")
+	// The other columns and rows are not affected
+	age, err := record.GetValueByColName("age")
+	if assert.NoError(t, err) {
+		i, _ := age.AsInt()
+		assert.Equal(t, int64(10), i)
+	}
+	var ages []int64
+	assert.NoError(t, resultSet.ForEach(func(record *Record) error {
+		age, err := record.GetValueByIndex(0)
+		if err != nil {
+			return err
+		}
+		i, err := age.AsInt()
+		ages = append(ages, i)
+		return err
+	}))
+	assert.Equal(t, []int64{10, 11}, ages)
+}
+
+func ExampleResultSet_ForEach() {
+	resultSet := newResultSet(genResp())
+	err := resultSet.ForEach(func(record *Record) error {
+		name, err := record.GetValueByColName("name")
+		if err != nil {
+			return err
+		}
+		s, err := name.AsString()
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// Bob
+	// Tom
+}
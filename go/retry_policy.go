@@ -0,0 +1,130 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// RetryPolicy decides how a query is retried when graphd returns a retriable error code.
+// The zero value disables the retry.
+type RetryPolicy struct {
+	// The max times to execute a query, including the first one
+	// 0 or 1 value means the query is not retried
+	MaxAttempts int
+	// The time to wait before the first retry
+	InitialBackoff time.Duration
+	// The factor the backoff is multiplied by after every retry, 0 value means 2
+	Multiplier float64
+	// The fraction of the backoff randomly added or subtracted, in range [0, 1]
+	Jitter float64
+	// The error codes which are retried, nil value means the codes returned when graphd fails to reach storaged:
+	// E_DISCONNECTED, E_FAIL_TO_CONNECT and E_RPC_FAILURE
+	RetriableCodes []graph.ErrorCode
+	// Tells if a query failing with the error code is retried, it takes precedence over RetriableCodes,
+	// e.g. for the codes of a newer graphd this package does not know. nil value means RetriableCodes is used.
+	// DefaultRetryableError is the default set, extend it with
+	//
+	//	func(code int32) bool { return nebula.DefaultRetryableError(code) || code == -8 }
+	RetryableErrorFunc func(code int32) bool
+}
+
+// The error codes retried by default
+var defaultRetriableCodes = []graph.ErrorCode{
+	graph.ErrorCode_E_DISCONNECTED,
+	graph.ErrorCode_E_FAIL_TO_CONNECT,
+	graph.ErrorCode_E_RPC_FAILURE,
+}
+
+// Validate the policy
+func (policy *RetryPolicy) validate(log Logger) {
+	if policy.MaxAttempts < 0 {
+		policy.MaxAttempts = 0
+		log.Warn("Invalid RetryPolicy.MaxAttempts value, the default value of 0 has been applied")
+	}
+	if policy.InitialBackoff < 0 {
+		policy.InitialBackoff = 0 * time.Millisecond
+		log.Warn("Invalid RetryPolicy.InitialBackoff value, the default value of 0 second has been applied")
+	}
+	if policy.Multiplier < 0 {
+		policy.Multiplier = 0
+		log.Warn("Invalid RetryPolicy.Multiplier value, the default value of 2 has been applied")
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		policy.Jitter = 0
+		log.Warn("Invalid RetryPolicy.Jitter value, the default value of 0 has been applied")
+	}
+}
+
+// DefaultRetryableError tells if the error code is one of those retried by default, returned when graphd fails
+// to reach storaged: E_DISCONNECTED, E_FAIL_TO_CONNECT and E_RPC_FAILURE
+func DefaultRetryableError(code int32) bool {
+	for _, c := range defaultRetriableCodes {
+		if int32(c) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if a query failing with the code should be retried
+func (policy RetryPolicy) isRetriable(code graph.ErrorCode) bool {
+	if policy.RetryableErrorFunc != nil {
+		return policy.RetryableErrorFunc(int32(code))
+	}
+	codes := policy.RetriableCodes
+	if codes == nil {
+		codes = defaultRetriableCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the time to wait before the given retry, starting from 1
+func (policy RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	backoff := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(retry-1))
+	if policy.Jitter > 0 {
+		backoff += backoff * policy.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// Execute the query until it succeeds, fails with an error code which is not retriable, or runs out of attempts
+func (policy RetryPolicy) execute(ctx context.Context, log Logger,
+	execute func() (*graph.ExecutionResponse, error)) (*graph.ExecutionResponse, error) {
+	resp, err := execute()
+	for retry := 1; retry < policy.MaxAttempts; retry++ {
+		if err != nil || !policy.isRetriable(resp.GetErrorCode()) {
+			break
+		}
+		backoff := policy.backoff(retry)
+		log.Warn(fmt.Sprintf("Query failed with error code %s, retry %d in %s", resp.GetErrorCode(), retry, backoff))
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, fmt.Errorf("Execution is aborted: %w", ctx.Err())
+		case <-timer.C:
+		}
+		resp, err = execute()
+	}
+	return resp, err
+}
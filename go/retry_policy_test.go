@@ -0,0 +1,119 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, Multiplier: 3}
+	assert.Equal(t, 10*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 90*time.Millisecond, policy.backoff(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		backoff := policy.backoff(1)
+		assert.True(t, backoff >= 5*time.Millisecond && backoff <= 15*time.Millisecond)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	conf := GetDefaultConf()
+	conf.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	// Succeed after retrying twice
+	service.QueueErrorCodes(graph.ErrorCode_E_RPC_FAILURE, graph.ErrorCode_E_RPC_FAILURE)
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.Len(t, service.Statements(), 3)
+
+	// Give up after MaxAttempts
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_E_RPC_FAILURE, graph.ErrorCode_E_RPC_FAILURE, graph.ErrorCode_E_RPC_FAILURE)
+	resp, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_E_RPC_FAILURE, resp.GetErrorCode())
+	assert.Len(t, service.Statements(), 3)
+
+	// A syntax error is not retried
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_E_SYNTAX_ERROR)
+	resp, err = session.Execute("YIEL 1")
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, resp.GetErrorCode())
+	assert.Len(t, service.Statements(), 1)
+}
+
+func TestRetryPolicy_RetryableErrorFunc(t *testing.T) {
+	assert.True(t, DefaultRetryableError(int32(graph.ErrorCode_E_RPC_FAILURE)))
+	assert.False(t, DefaultRetryableError(int32(graph.ErrorCode_E_SYNTAX_ERROR)))
+
+	// The function takes precedence over the codes
+	policy := RetryPolicy{
+		RetriableCodes: []graph.ErrorCode{graph.ErrorCode_E_EXECUTION_ERROR},
+		RetryableErrorFunc: func(code int32) bool {
+			return DefaultRetryableError(code) || code == -100
+		},
+	}
+	assert.True(t, policy.isRetriable(graph.ErrorCode_E_DISCONNECTED))
+	assert.True(t, policy.isRetriable(graph.ErrorCode(-100)))
+	assert.False(t, policy.isRetriable(graph.ErrorCode_E_EXECUTION_ERROR))
+
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.RetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryableErrorFunc: func(code int32) bool {
+		return code == int32(graph.ErrorCode_E_EXECUTION_ERROR)
+	}}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_E_EXECUTION_ERROR)
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.Len(t, service.Statements(), 2)
+	// A default code is not retried any more
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_E_RPC_FAILURE)
+	resp, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_E_RPC_FAILURE, resp.GetErrorCode())
+	assert.Len(t, service.Statements(), 1)
+}
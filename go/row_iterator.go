@@ -0,0 +1,164 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const defaultIterPageSize = 1000
+
+// RowIterator iterates the rows of a query page by page, so only one page is kept in memory.
+// graphd of this version has no server-side cursor, every page is fetched by executing the query again
+// with "| LIMIT offset, size" appended. The pages are not taken from a snapshot, so rows may be skipped
+// or repeated if the data changes during the iteration, and the query must return the rows in a stable
+// order, e.g. with ORDER BY.
+//
+//	iter, err := session.ExecuteIter("GO FROM \"a\" OVER follow YIELD follow._dst AS dst | ORDER BY $-.dst")
+//	for iter.Next() {
+//		record := iter.Record()
+//	}
+//	err = iter.Err()
+type RowIterator struct {
+	session  *Session
+	stmt     string
+	pageSize int
+	// Offset of the next page
+	offset   int
+	page     *ResultSet
+	colNames []string
+	// Index of the current row in page
+	index  int
+	record *Record
+	err    error
+}
+
+// ExecuteIter executes a query and returns an iterator over its rows, fetching PoolConfig.IterPageSize rows at a time.
+// The first page is fetched before returning. The statement must be one a LIMIT clause could be piped to.
+func (session *Session) ExecuteIter(stmt string) (*RowIterator, error) {
+	// The LIMIT clause appended to an empty statement would be sent otherwise
+	if isEmptyStatement(stmt) {
+		return nil, fmt.Errorf("Failed to execute: %w", ErrEmptyStatement)
+	}
+	pageSize := session.connPool.conf.IterPageSize
+	if pageSize <= 0 {
+		pageSize = defaultIterPageSize
+	}
+	iter := &RowIterator{
+		session:  session,
+		stmt:     trimStatementEnd(stmt),
+		pageSize: pageSize,
+	}
+	if err := iter.fetch(); err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// Remove the whitespaces, semicolons and comments at the end of the statement, so a trailing line comment
+// could not swallow the clause appended to it. The ones inside a literal or a quoted label are kept.
+func trimStatementEnd(stmt string) string {
+	end := 0
+	var quote byte
+	for i := 0; i < len(stmt); i++ {
+		c := stmt[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+				end = i + 1
+			}
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case strings.HasPrefix(stmt[i:], "/*"):
+			next := strings.Index(stmt[i+2:], "*/")
+			if next < 0 {
+				return stmt[:end]
+			}
+			i += next + 3
+		case c == '#' || strings.HasPrefix(stmt[i:], "//") || strings.HasPrefix(stmt[i:], "--"):
+			next := strings.IndexByte(stmt[i:], '\n')
+			if next < 0 {
+				return stmt[:end]
+			}
+			i += next
+		case c == ';' || unicode.IsSpace(rune(c)):
+		default:
+			end = i + 1
+		}
+	}
+	// The literal or the quoted label is not closed, graphd reports the error of the statement
+	if quote != 0 {
+		return strings.TrimSpace(stmt)
+	}
+	return stmt[:end]
+}
+
+// Fetch the page at offset
+func (iter *RowIterator) fetch() error {
+	resp, err := iter.session.Execute(fmt.Sprintf("%s | LIMIT %d, %d", iter.stmt, iter.offset, iter.pageSize))
+	if err != nil {
+		return err
+	}
+	if err := CheckResponse(resp.GetResponse()); err != nil {
+		return err
+	}
+	iter.page = resp
+	iter.colNames = resp.GetColNames()
+	iter.index = 0
+	iter.offset += resp.GetRowSize()
+	return nil
+}
+
+// Next moves to the next row, fetching the next page if the current one is consumed.
+// It returns false at the end of the rows or on an error, which is returned by Err.
+func (iter *RowIterator) Next() bool {
+	if iter.err != nil || iter.page == nil {
+		return false
+	}
+	if iter.index >= iter.page.GetRowSize() {
+		// A short page is the last one
+		if iter.page.GetRowSize() < iter.pageSize {
+			iter.page = nil
+			return false
+		}
+		if iter.err = iter.fetch(); iter.err != nil {
+			return false
+		}
+		if iter.page.GetRowSize() == 0 {
+			iter.page = nil
+			return false
+		}
+	}
+	iter.record, iter.err = iter.page.GetRowValuesByIndex(iter.index)
+	if iter.err != nil {
+		return false
+	}
+	iter.index++
+	return true
+}
+
+// Record returns the current row, it is valid after Next returns true
+func (iter *RowIterator) Record() *Record {
+	return iter.record
+}
+
+// GetColNames returns the names of all columns
+func (iter *RowIterator) GetColNames() []string {
+	return iter.colNames
+}
+
+// Err returns the error stopping the iteration, nil if all rows are iterated
+func (iter *RowIterator) Err() error {
+	return iter.err
+}
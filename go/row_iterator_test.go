@@ -0,0 +1,100 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestSession_ExecuteIter(t *testing.T) {
+	// A query returning the numbers from 0 to 24
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		var offset, size int
+		if _, err := fmt.Sscanf(stmt, "YIELD n | LIMIT %d, %d", &offset, &size); err != nil {
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SYNTAX_ERROR}, nil
+		}
+		var rows []*nebula.Row
+		for i := offset; i < offset+size && i < 25; i++ {
+			rows = append(rows, &nebula.Row{Values: []*nebula.Value{intValue(int64(i))}})
+		}
+		return &graph.ExecutionResponse{
+			ErrorCode: graph.ErrorCode_SUCCEEDED,
+			Data:      &nebula.DataSet{ColumnNames: [][]byte{[]byte("n")}, Rows: rows},
+		}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	for _, c := range []struct{ pageSize, fetches int }{{10, 3}, {25, 2}} {
+		conf := GetDefaultConf()
+		conf.IterPageSize = c.pageSize
+		pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := pool.GetSession("root", "nebula")
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.ResetStatements()
+		iter, err := session.ExecuteIter("YIELD n;")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []string{"n"}, iter.GetColNames())
+		var numbers []int64
+		for iter.Next() {
+			value, err := iter.Record().GetValueByColName("n")
+			if assert.NoError(t, err) {
+				n, _ := value.AsInt()
+				numbers = append(numbers, n)
+			}
+		}
+		assert.NoError(t, iter.Err())
+		assert.Len(t, numbers, 25)
+		assert.Equal(t, int64(24), numbers[24])
+		// A full last page needs one more fetch to find out the end
+		assert.Len(t, service.Statements(), c.fetches)
+		assert.Equal(t, fmt.Sprintf("YIELD n | LIMIT 0, %d", c.pageSize), service.Statements()[0])
+
+		// A trailing comment would swallow the LIMIT clause
+		service.ResetStatements()
+		_, err = session.ExecuteIter("YIELD n; -- all of them")
+		if assert.NoError(t, err) {
+			assert.Equal(t, fmt.Sprintf("YIELD n | LIMIT 0, %d", c.pageSize), service.Statements()[0])
+		}
+
+		_, err = session.ExecuteIter("YIELD")
+		assert.Error(t, err)
+		session.Release()
+		pool.Close()
+	}
+}
+
+func TestTrimStatementEnd(t *testing.T) {
+	for stmt, trimmed := range map[string]string{
+		"YIELD n;":                      "YIELD n",
+		" YIELD n ; ;\n":                " YIELD n",
+		"YIELD n # all":                 "YIELD n",
+		"YIELD n; // all\n-- of them\n": "YIELD n",
+		"YIELD n /* all; */":            "YIELD n",
+		"YIELD \"a;#b\" AS s;":          "YIELD \"a;#b\" AS s",
+		"YIELD `a -- b`":                "YIELD `a -- b`",
+		"YIELD /* c */ n /* unclosed":   "YIELD /* c */ n",
+		"YIELD \"unclosed; ":            "YIELD \"unclosed;",
+	} {
+		assert.Equal(t, trimmed, trimStatementEnd(stmt), stmt)
+	}
+}
@@ -0,0 +1,327 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// ExecuteAndScan executes the statement and scans the result into dest, see ResultSet.Scan.
+// A failure on the server side is returned as an *ExecutionError.
+func (session *Session) ExecuteAndScan(stmt string, dest interface{}) error {
+	resultSet, err := session.Execute(stmt)
+	if err != nil {
+		return err
+	}
+	if err := CheckResponse(resultSet.GetResponse()); err != nil {
+		return err
+	}
+	return resultSet.Scan(dest)
+}
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	valueWrapperType = reflect.TypeOf(ValueWrapper{})
+	nodeType         = reflect.TypeOf(Node{})
+	relationshipType = reflect.TypeOf(Relationship{})
+	pathType         = reflect.TypeOf(PathWrapper{})
+)
+
+// Scan stores the rows into dest, a pointer to a struct for a result of exactly one row,
+// or a pointer to a slice of structs or of pointers to structs for any number of rows.
+//
+// The columns are mapped to the exported fields by the tag, e.g. `nebula:"name"`, a field with the tag
+// `nebula:"-"` is skipped. A tagged field fails the scan if the result has no such column, an untagged field
+// is set from the column of its name, compared case-insensitively, if there is one. The columns without
+// a field are ignored. The values are converted to the types of the fields:
+//   - bool, the integer types if the int fits, float32 and float64, string and []byte
+//   - time.Time for date, time and datetime, see ValueWrapper.AsDate
+//   - Node, Relationship and PathWrapper, or pointers to them, for vertex, edge and path
+//   - a slice for list and set, and a map with string keys for map, whose elements are converted the same way
+//   - ValueWrapper to keep the value as it is, interface{} for the native value as ResultSet.AsMaps converts it
+//
+// A null or empty value could only be scanned into a pointer, which is set to nil, or an interface{}.
+// The error tells the row, the column and the field of the first value which could not be converted.
+func (res ResultSet) Scan(dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Failed to scan, dest must be a non-nil pointer, got %T", dest)
+	}
+	rv = rv.Elem()
+	rows := res.getRows()
+	switch {
+	case rv.Kind() == reflect.Struct && !isValueStruct(rv.Type()):
+		if len(rows) != 1 {
+			return fmt.Errorf("Failed to scan, the result has %d rows, scan into a slice for a result of other than one row", len(rows))
+		}
+		fields, err := res.scanFields(rv.Type())
+		if err != nil {
+			return err
+		}
+		return res.scanRow(rv, rows[0], 0, fields)
+	case rv.Kind() == reflect.Slice:
+		elemType := rv.Type().Elem()
+		structType := elemType
+		if elemType.Kind() == reflect.Ptr {
+			structType = elemType.Elem()
+		}
+		if structType.Kind() != reflect.Struct || isValueStruct(structType) {
+			return fmt.Errorf("Failed to scan, dest must point to a struct or a slice of structs, got %T", dest)
+		}
+		fields, err := res.scanFields(structType)
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(rv.Type(), 0, len(rows))
+		for i, row := range rows {
+			elem := reflect.New(structType)
+			if err := res.scanRow(elem.Elem(), row, i, fields); err != nil {
+				return err
+			}
+			if elemType.Kind() == reflect.Ptr {
+				slice = reflect.Append(slice, elem)
+			} else {
+				slice = reflect.Append(slice, elem.Elem())
+			}
+		}
+		rv.Set(slice)
+		return nil
+	default:
+		return fmt.Errorf("Failed to scan, dest must point to a struct or a slice of structs, got %T", dest)
+	}
+}
+
+// Check if the struct type is one a single value converts to, not a row
+func isValueStruct(t reflect.Type) bool {
+	return t == timeType || t == valueWrapperType || t == nodeType || t == relationshipType || t == pathType
+}
+
+// A field of the destination struct and the index of its column
+type scanField struct {
+	index  []int
+	name   string
+	column int
+}
+
+// Map the columns to the fields of the struct type, a tagged field must have its column
+func (res ResultSet) scanFields(t reflect.Type) ([]scanField, error) {
+	var fields []scanField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// Unexported fields could not be set
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("nebula")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			column, ok := res.colNameIndexMap[tag]
+			if !ok {
+				return nil, fmt.Errorf("Failed to scan, the result has no column %s for field %s of %s", tag, field.Name, t)
+			}
+			fields = append(fields, scanField{index: field.Index, name: field.Name, column: column})
+			continue
+		}
+		for j, name := range res.columnNames {
+			if strings.EqualFold(name, field.Name) {
+				fields = append(fields, scanField{index: field.Index, name: field.Name, column: j})
+				break
+			}
+		}
+	}
+	return fields, nil
+}
+
+func (res ResultSet) scanRow(rv reflect.Value, row *nebula.Row, index int, fields []scanField) error {
+	values := row.GetValues()
+	for _, field := range fields {
+		var value *nebula.Value
+		if field.column < len(values) {
+			value = values[field.column]
+		}
+		if err := scanValue(rv.FieldByIndex(field.index), ValueWrapper{value: value, vidType: res.vidType, utf8Policy: res.utf8Policy}); err != nil {
+			return fmt.Errorf("Failed to scan row %d, column %s into field %s: %s",
+				index, res.columnNames[field.column], field.name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Convert the value to the type of dest and store it
+func scanValue(dest reflect.Value, valWrap ValueWrapper) error {
+	value := valWrap.value
+	isNull := value == nil || value.IsSetNVal()
+	t := dest.Type()
+	switch {
+	case t == valueWrapperType:
+		dest.Set(reflect.ValueOf(valWrap))
+		return nil
+	case t.Kind() == reflect.Interface && t.NumMethod() == 0:
+		native, err := nativeValue(valWrap)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			dest.Set(reflect.Zero(t))
+		} else {
+			dest.Set(reflect.ValueOf(native))
+		}
+		return nil
+	case t.Kind() == reflect.Ptr:
+		if isNull {
+			dest.Set(reflect.Zero(t))
+			return nil
+		}
+		elem := reflect.New(t.Elem())
+		if err := scanValue(elem.Elem(), valWrap); err != nil {
+			return err
+		}
+		dest.Set(elem)
+		return nil
+	case isNull:
+		return fmt.Errorf("cannot store %s into %s, use a pointer for a nullable column", valWrap.GetType(), t)
+	}
+
+	mismatch := fmt.Errorf("cannot convert %s to %s", valWrap.GetType(), t)
+	switch t {
+	case timeType:
+		var tm time.Time
+		var err error
+		switch {
+		case value.IsSetDVal():
+			tm, err = valWrap.AsDate()
+		case value.IsSetTVal():
+			tm, err = valWrap.AsTime()
+		case value.IsSetDtVal():
+			tm, err = valWrap.AsDateTime()
+		default:
+			return mismatch
+		}
+		if err != nil {
+			return err
+		}
+		dest.Set(reflect.ValueOf(tm))
+		return nil
+	case nodeType:
+		if !value.IsSetVVal() {
+			return mismatch
+		}
+		node, err := valWrap.AsNode()
+		if err != nil {
+			return err
+		}
+		dest.Set(reflect.ValueOf(*node))
+		return nil
+	case relationshipType:
+		if !value.IsSetEVal() {
+			return mismatch
+		}
+		relationship, err := valWrap.AsRelationship()
+		if err != nil {
+			return err
+		}
+		dest.Set(reflect.ValueOf(*relationship))
+		return nil
+	case pathType:
+		if !value.IsSetPVal() {
+			return mismatch
+		}
+		path, err := valWrap.AsPath()
+		if err != nil {
+			return err
+		}
+		dest.Set(reflect.ValueOf(*path))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if !value.IsSetBVal() {
+			return mismatch
+		}
+		dest.SetBool(value.GetBVal())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !value.IsSetIVal() {
+			return mismatch
+		}
+		if dest.OverflowInt(value.GetIVal()) {
+			return fmt.Errorf("%d overflows %s", value.GetIVal(), t)
+		}
+		dest.SetInt(value.GetIVal())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !value.IsSetIVal() {
+			return mismatch
+		}
+		if i := value.GetIVal(); i < 0 || dest.OverflowUint(uint64(i)) {
+			return fmt.Errorf("%d overflows %s", i, t)
+		}
+		dest.SetUint(uint64(value.GetIVal()))
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case value.IsSetFVal():
+			dest.SetFloat(value.GetFVal())
+		case value.IsSetIVal():
+			dest.SetFloat(float64(value.GetIVal()))
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		if !value.IsSetSVal() {
+			return mismatch
+		}
+		s, err := valWrap.AsString()
+		if err != nil {
+			return err
+		}
+		dest.SetString(s)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && value.IsSetSVal() {
+			dest.SetBytes(append([]byte(nil), value.GetSVal()...))
+			return nil
+		}
+		var elems []*nebula.Value
+		switch {
+		case value.IsSetLVal():
+			elems = value.GetLVal().GetValues()
+		case value.IsSetUVal():
+			elems = value.GetUVal().GetValues()
+		default:
+			return mismatch
+		}
+		slice := reflect.MakeSlice(t, len(elems), len(elems))
+		for i, elem := range elems {
+			if err := scanValue(slice.Index(i), ValueWrapper{value: elem, vidType: valWrap.vidType, utf8Policy: valWrap.utf8Policy}); err != nil {
+				return fmt.Errorf("element %d: %s", i, err.Error())
+			}
+		}
+		dest.Set(slice)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || !value.IsSetMVal() {
+			return mismatch
+		}
+		kvs := value.GetMVal().GetKvs()
+		m := reflect.MakeMapWithSize(t, len(kvs))
+		for key, kv := range kvs {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := scanValue(elem, ValueWrapper{value: kv, vidType: valWrap.vidType, utf8Policy: valWrap.utf8Policy}); err != nil {
+				return fmt.Errorf("key %s: %s", key, err.Error())
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		dest.Set(m)
+	default:
+		return mismatch
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+type person struct {
+	Name    string `nebula:"name"`
+	Age     int8
+	Ignored string `nebula:"-"`
+	hidden  int
+}
+
+func TestResultSet_Scan(t *testing.T) {
+	resultSet := newResultSet(genResp())
+
+	var people []person
+	if assert.NoError(t, resultSet.Scan(&people)) {
+		assert.Equal(t, []person{{Name: "Bob", Age: 10}, {Name: "Tom", Age: 11}}, people)
+	}
+	var pointers []*person
+	if assert.NoError(t, resultSet.Scan(&pointers)) && assert.Len(t, pointers, 2) {
+		assert.Equal(t, person{Name: "Tom", Age: 11}, *pointers[1])
+	}
+
+	// A single struct needs exactly one row
+	var one person
+	assert.Error(t, resultSet.Scan(&one))
+	resp := genResp()
+	resp.Data.Rows = resp.Data.Rows[:1]
+	if assert.NoError(t, newResultSet(resp).Scan(&one)) {
+		assert.Equal(t, person{Name: "Bob", Age: 10}, one)
+	}
+	resp.Data.Rows = nil
+	assert.Error(t, newResultSet(resp).Scan(&one))
+	if assert.NoError(t, newResultSet(resp).Scan(&people)) {
+		assert.Empty(t, people)
+	}
+
+	// A tagged field without its column
+	var missing []struct {
+		Gender string `nebula:"gender"`
+	}
+	err := resultSet.Scan(&missing)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no column gender")
+	}
+	// A type mismatch
+	var mismatched []struct {
+		Name int `nebula:"name"`
+	}
+	err = resultSet.Scan(&mismatched)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "column name into field Name")
+	}
+	// An overflow
+	var small []struct {
+		Age uint8 `nebula:"age"`
+	}
+	assert.NoError(t, resultSet.Scan(&small))
+	resp = genResp()
+	big := int64(300)
+	resp.Data.Rows[1].Values[1] = &nebula.Value{IVal: &big}
+	err = newResultSet(resp).Scan(&small)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "row 1")
+	}
+
+	// Not a pointer to a struct or a slice of structs
+	assert.Error(t, resultSet.Scan(people))
+	assert.Error(t, resultSet.Scan(&[]int{}))
+	assert.Error(t, resultSet.Scan((*person)(nil)))
+}
+
+func TestResultSet_ScanValues(t *testing.T) {
+	null := nebula.NullType___NULL__
+	f := 1.5
+	b := true
+	resultSet := newResultSet(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("v"), []byte("null"), []byte("list"), []byte("map"),
+				[]byte("date"), []byte("float"), []byte("bool")},
+			Rows: []*nebula.Row{{Values: []*nebula.Value{
+				{VVal: genVertex("Bob", "person")},
+				{NVal: &null},
+				{LVal: &nebula.List{Values: []*nebula.Value{intValue(1), intValue(2)}}},
+				{MVal: &nebula.Map{Kvs: map[string]*nebula.Value{"a": intValue(1)}}},
+				{DVal: &nebula.Date{Year: 2020, Month: 10, Day: 1}},
+				{FVal: &f},
+				{BVal: &b},
+			}}},
+		},
+	})
+
+	var row struct {
+		V     Node             `nebula:"v"`
+		Null  *int64           `nebula:"null"`
+		Any   interface{}      `nebula:"null"`
+		List  []int            `nebula:"list"`
+		Map   map[string]int64 `nebula:"map"`
+		Date  *ValueWrapper    `nebula:"date"`
+		Float float32          `nebula:"float"`
+		Bool  bool             `nebula:"bool"`
+	}
+	if assert.NoError(t, resultSet.Scan(&row)) {
+		vid, _ := row.V.GetID().AsString()
+		assert.Equal(t, "Bob", vid)
+		assert.Nil(t, row.Null)
+		assert.Nil(t, row.Any)
+		assert.Equal(t, []int{1, 2}, row.List)
+		assert.Equal(t, map[string]int64{"a": 1}, row.Map)
+		assert.True(t, row.Date.IsDate())
+		assert.Equal(t, float32(1.5), row.Float)
+		assert.True(t, row.Bool)
+	}
+
+	// A null could not be stored into a value
+	var notNull struct {
+		Null int64 `nebula:"null"`
+	}
+	err := resultSet.Scan(&notNull)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "use a pointer")
+	}
+}
+
+func TestSession_ExecuteAndScan(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		if stmt != "YIELD 10 AS age" {
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SYNTAX_ERROR, ErrorMsg: []byte("syntax error")}, nil
+		}
+		return &graph.ExecutionResponse{
+			ErrorCode: graph.ErrorCode_SUCCEEDED,
+			Data: &nebula.DataSet{
+				ColumnNames: [][]byte{[]byte("age")},
+				Rows:        []*nebula.Row{{Values: []*nebula.Value{intValue(10)}}},
+			},
+		}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	var result struct {
+		Age int64 `nebula:"age"`
+	}
+	if assert.NoError(t, session.ExecuteAndScan("YIELD 10 AS age", &result)) {
+		assert.Equal(t, int64(10), result.Age)
+	}
+	err = session.ExecuteAndScan("YIELD", &result)
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, execErr.ErrorCode)
+	}
+}
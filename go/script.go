@@ -0,0 +1,230 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// The default time to wait for a schema change to be visible, graphd picks it up with the heartbeat to metad
+	defaultSchemaWaitTimeout = 30 * time.Second
+	// The default interval between two checks of a schema change
+	defaultSchemaPollInterval = 500 * time.Millisecond
+)
+
+// ScriptOptions are the options of Session.ExecuteScript
+type ScriptOptions struct {
+	// Stop at the first statement which fails, the statements after it are not executed
+	StopOnError bool
+	// Wait after a statement creating or dropping a space, a tag, an edge type or an index until the change
+	// is visible to the session, since graphd applies the schema changes asynchronously, e.g. an INSERT right
+	// after a CREATE TAG fails until graphd refreshes its schema. The change is checked as ExecuteDDL does.
+	WaitForSchema bool
+	// The max time to wait for a schema change, 0 means 30s. The statement fails if the change is not visible by then.
+	SchemaWaitTimeout time.Duration
+	// The interval between two checks of a schema change, 0 means 500ms
+	SchemaPollInterval time.Duration
+}
+
+func (opts ScriptOptions) getSchemaWaitTimeout() time.Duration {
+	if opts.SchemaWaitTimeout <= 0 {
+		return defaultSchemaWaitTimeout
+	}
+	return opts.SchemaWaitTimeout
+}
+
+func (opts ScriptOptions) getSchemaPollInterval() time.Duration {
+	if opts.SchemaPollInterval <= 0 {
+		return defaultSchemaPollInterval
+	}
+	return opts.SchemaPollInterval
+}
+
+// StatementOutcome is the outcome of a statement of Session.ExecuteScript
+type StatementOutcome struct {
+	Statement string
+	// Whether the statement was sent, false for the ones after a failure with StopOnError or once ctx is done
+	Executed bool
+	// The result of the statement, nil if it was not executed or could not be sent
+	Result *ResultSet
+	// The error of the statement, an *ExecutionError if it failed on the server side,
+	// or the error of waiting for its schema change. nil if it succeeded or was not executed.
+	Err error
+	// How long waiting for the schema change took, 0 if there was nothing to wait for
+	SchemaWait time.Duration
+}
+
+// ExecuteScript executes the statements in order, e.g. the DDL statements of a schema migration file,
+// and returns the outcome of every one of them, in the order of the statements.
+// Unless opts.StopOnError is set, all statements are executed even if some fail.
+// The returned error wraps the error of the first statement which failed, nil if all of them succeeded.
+// Use IF NOT EXISTS and IF EXISTS in the statements so the script could run again.
+func (session *Session) ExecuteScript(statements []string, opts ScriptOptions) ([]StatementOutcome, error) {
+	return session.ExecuteScriptWithContext(context.Background(), statements, opts)
+}
+
+// ExecuteScriptWithContext is like ExecuteScript, but no statement is executed and no schema change is waited for
+// once ctx is done, the returned error wraps ctx.Err() then.
+func (session *Session) ExecuteScriptWithContext(ctx context.Context, statements []string, opts ScriptOptions) ([]StatementOutcome, error) {
+	outcomes := make([]StatementOutcome, len(statements))
+	var firstErr error
+	for i, stmt := range statements {
+		outcomes[i].Statement = stmt
+		if err := ctx.Err(); err != nil {
+			return outcomes, fmt.Errorf("Failed to execute the script, %d of %d statements are executed: %w", i, len(statements), err)
+		}
+		if firstErr != nil && opts.StopOnError {
+			continue
+		}
+		outcome := &outcomes[i]
+		outcome.Executed = true
+		outcome.Result, outcome.Err = session.ExecuteWithContext(ctx, stmt)
+		if outcome.Err == nil {
+			outcome.Err = CheckResponse(outcome.Result.GetResponse())
+		}
+		if outcome.Err == nil && opts.WaitForSchema {
+			if probe, created := session.schemaProbe(stmt); probe != "" {
+				start := time.Now()
+				outcome.Err = session.waitForSchema(ctx, probe, created, opts)
+				outcome.SchemaWait = time.Since(start)
+			}
+		}
+		if outcome.Err != nil && ctx.Err() != nil {
+			return outcomes, fmt.Errorf("Failed to execute the script, %d of %d statements are executed: %w", i, len(statements), outcome.Err)
+		}
+		if outcome.Err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Failed to execute statement %d of the script: %w", i, outcome.Err)
+		}
+	}
+	return outcomes, firstErr
+}
+
+// ExecuteDDL executes a statement changing the schema and waits until the change is visible to the session,
+// up to waitTimeout, 0 means 30s, in place of a sleep after it. graphd applies the schema changes asynchronously,
+// it picks them up with its heartbeat to metad, e.g. an INSERT right after a CREATE TAG fails until then.
+//
+// After the statement succeeds, a check is executed every 500ms: a created tag or edge type is visible once
+// EXPLAIN FETCH PROP ON it passes the validation of graphd, which uses the schema graphd has, a created space
+// or index once DESCRIBE finds it, and a dropped one once the same check fails. Only the statements creating or
+// dropping a space, a tag, an edge type or an index are waited for, the others, e.g. ALTER, return once executed.
+//
+// It returns the result of the statement, with an *ExecutionError if it failed on the server side, or an error
+// if the change is not visible after waitTimeout.
+func (session *Session) ExecuteDDL(stmt string, waitTimeout time.Duration) (*ResultSet, error) {
+	return session.ExecuteDDLWithContext(context.Background(), stmt, waitTimeout)
+}
+
+// ExecuteDDLWithContext is like ExecuteDDL, but it stops waiting once ctx is done, the error wraps ctx.Err() then
+func (session *Session) ExecuteDDLWithContext(ctx context.Context, stmt string, waitTimeout time.Duration) (*ResultSet, error) {
+	resp, err := session.ExecuteWithContext(ctx, stmt)
+	if err == nil {
+		err = CheckResponse(resp.GetResponse())
+	}
+	if err != nil {
+		return resp, err
+	}
+	if probe, created := session.schemaProbe(stmt); probe != "" {
+		err = session.waitForSchema(ctx, probe, created, ScriptOptions{SchemaWaitTimeout: waitTimeout})
+	}
+	return resp, err
+}
+
+// Return the statement checking the schema change of a DDL statement, and whether it succeeds once a created object
+// is visible, or fails once a dropped one is gone. The statement is empty if there is nothing to wait for.
+func (session *Session) schemaProbe(stmt string) (string, bool) {
+	parts := splitStatement(stmt)
+	if len(parts) != 1 {
+		return "", false
+	}
+	verb, rest := nextWord(parts[0])
+	verb = strings.ToUpper(verb)
+	if verb != "CREATE" && verb != "DROP" {
+		return "", false
+	}
+	kind, rest := nextWord(rest)
+	kind = strings.ToUpper(kind)
+	switch kind {
+	case "SPACE":
+	case "TAG", "EDGE":
+		if word, after := nextWord(rest); strings.ToUpper(word) == "INDEX" {
+			kind, rest = kind+" INDEX", after
+		}
+	default:
+		return "", false
+	}
+	name, rest := nextWord(rest)
+	if strings.ToUpper(name) == "IF" {
+		if verb == "CREATE" {
+			_, rest = nextWord(rest)
+		}
+		_, rest = nextWord(rest)
+		name, _ = nextWord(rest)
+	}
+	if name == "" {
+		return "", false
+	}
+
+	created := verb == "CREATE"
+	session.mu.Lock()
+	vid, _ := session.vidType.literal(0)
+	session.mu.Unlock()
+	switch kind {
+	case "TAG":
+		return fmt.Sprintf("EXPLAIN FETCH PROP ON %s %s", name, vid), created
+	case "EDGE":
+		return fmt.Sprintf("EXPLAIN FETCH PROP ON %s %s->%s", name, vid, vid), created
+	default:
+		return fmt.Sprintf("DESCRIBE %s %s", kind, name), created
+	}
+}
+
+// Return the next word of a statement, a label quoted with backquotes is kept with them, and the rest
+func nextWord(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	if strings.HasPrefix(s, "`") {
+		if end := strings.IndexByte(s[1:], '`'); end >= 0 {
+			return s[:end+2], s[end+2:]
+		}
+		return s, ""
+	}
+	end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' })
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:]
+}
+
+// Execute the probe until it succeeds if created is set, or fails otherwise, up to opts.SchemaWaitTimeout
+func (session *Session) waitForSchema(ctx context.Context, probe string, created bool, opts ScriptOptions) error {
+	timeout := opts.getSchemaWaitTimeout()
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := session.executeWithContext(ctx, probe)
+		// The failures on the server side come with their result
+		if err != nil && resp == nil {
+			return fmt.Errorf("Failed to wait for the schema change, probe: %s, error: %w", probe, err)
+		}
+		if succeeded := err == nil && resp.IsSucceeded(); succeeded == created {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("Failed to wait for the schema change, it is not visible after %s, probe: %s", timeout, probe)
+		}
+		timer := time.NewTimer(opts.getSchemaPollInterval())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("Failed to wait for the schema change, probe: %s: %w", probe, ctx.Err())
+		}
+	}
+}
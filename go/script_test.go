@@ -0,0 +1,203 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestSession_ExecuteScript(t *testing.T) {
+	// A created tag is visible after two checks, a dropped one after one, an edge index never.
+	// The handlers run with the lock of the service held.
+	service := testutil.NewFakeGraphService()
+	visible, pending := false, 0
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		switch {
+		case strings.HasPrefix(stmt, "CREATE TAG"):
+			pending = 2
+		case strings.HasPrefix(stmt, "DROP TAG"):
+			pending = 1
+		case strings.HasPrefix(stmt, "EXPLAIN FETCH PROP ON"):
+			if pending > 0 {
+				pending--
+			} else {
+				visible = !strings.Contains(stmt, "gone")
+			}
+			if !visible {
+				return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR, ErrorMsg: []byte("TagNotFound")}, nil
+			}
+		case strings.HasPrefix(stmt, "DESCRIBE EDGE INDEX"):
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR, ErrorMsg: []byte("Index not found")}, nil
+		case strings.HasPrefix(stmt, "INSERT") && !visible:
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR, ErrorMsg: []byte("No schema found")}, nil
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	// Every statement waits for the tag it created
+	opts := ScriptOptions{StopOnError: true, WaitForSchema: true, SchemaPollInterval: 10 * time.Millisecond}
+	outcomes, err := session.ExecuteScript([]string{
+		"CREATE TAG IF NOT EXISTS person(name string)",
+		`INSERT VERTEX person(name) VALUES "Bob":("Bob")`,
+	}, opts)
+	assert.NoError(t, err)
+	if assert.Len(t, outcomes, 2) {
+		assert.True(t, outcomes[0].Executed)
+		assert.NoError(t, outcomes[0].Err)
+		assert.True(t, outcomes[0].SchemaWait >= 20*time.Millisecond)
+		assert.True(t, outcomes[1].Result.IsSucceeded())
+		assert.Zero(t, outcomes[1].SchemaWait)
+	}
+	assert.Equal(t, []string{
+		"CREATE TAG IF NOT EXISTS person(name string)",
+		`EXPLAIN FETCH PROP ON person "0"`,
+		`EXPLAIN FETCH PROP ON person "0"`,
+		`EXPLAIN FETCH PROP ON person "0"`,
+		`INSERT VERTEX person(name) VALUES "Bob":("Bob")`,
+	}, service.Statements())
+
+	// A dropped one is waited for until it is gone, the statements after a failure are not executed
+	service.ResetStatements()
+	outcomes, err = session.ExecuteScript([]string{"DROP TAG IF EXISTS `gone`", "INSERT VERTEX gone", "SHOW TAGS"}, opts)
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SEMANTIC_ERROR, execErr.ErrorCode)
+		assert.Contains(t, err.Error(), "statement 1 of the script")
+	}
+	if assert.Len(t, outcomes, 3) {
+		assert.NoError(t, outcomes[0].Err)
+		assert.True(t, errors.As(outcomes[1].Err, &execErr))
+		assert.False(t, outcomes[2].Executed)
+		assert.Equal(t, "SHOW TAGS", outcomes[2].Statement)
+	}
+	assert.Equal(t, []string{"DROP TAG IF EXISTS `gone`", "EXPLAIN FETCH PROP ON `gone` \"0\"",
+		"EXPLAIN FETCH PROP ON `gone` \"0\"", "INSERT VERTEX gone"}, service.Statements())
+
+	// Without StopOnError every statement is executed, the error is the one of the first failure
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_E_EXECUTION_ERROR, graph.ErrorCode_SUCCEEDED, graph.ErrorCode_E_SYNTAX_ERROR)
+	outcomes, err = session.ExecuteScript([]string{"CREATE SPACE nba", "SHOW SPACES", "SHOW TAG"}, ScriptOptions{WaitForSchema: true})
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_EXECUTION_ERROR, execErr.ErrorCode)
+	}
+	if assert.Len(t, outcomes, 3) {
+		assert.Error(t, outcomes[0].Err)
+		assert.NoError(t, outcomes[1].Err)
+		assert.Error(t, outcomes[2].Err)
+		assert.True(t, outcomes[2].Executed)
+	}
+	assert.Len(t, service.Statements(), 3)
+
+	// The change is not visible in time
+	opts.SchemaWaitTimeout = 15 * time.Millisecond
+	outcomes, err = session.ExecuteScript([]string{"CREATE EDGE INDEX like_index ON like()", "SHOW TAGS"}, opts)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not visible after 15ms, probe: DESCRIBE EDGE INDEX like_index")
+	}
+	if assert.Len(t, outcomes, 2) {
+		assert.True(t, outcomes[0].Result.IsSucceeded())
+		assert.False(t, outcomes[1].Executed)
+	}
+}
+
+func TestSession_ExecuteDDL(t *testing.T) {
+	// A created tag is visible after one check, an edge index never
+	service := testutil.NewFakeGraphService()
+	checks := 0
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		switch {
+		case strings.HasPrefix(stmt, "EXPLAIN"):
+			checks++
+			if checks == 1 {
+				return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SEMANTIC_ERROR, ErrorMsg: []byte("TagNotFound")}, nil
+			}
+		case strings.HasPrefix(stmt, "DESCRIBE"):
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR, ErrorMsg: []byte("Index not found")}, nil
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	start := time.Now()
+	resp, err := session.ExecuteDDL("CREATE TAG person(name string)", time.Second)
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+	}
+	assert.True(t, time.Since(start) >= defaultSchemaPollInterval)
+	assert.Equal(t, 2, checks)
+
+	// A statement changing no schema this could check returns once executed
+	service.ResetStatements()
+	_, err = session.ExecuteDDL("ALTER TAG person ADD (age int)", time.Second)
+	assert.NoError(t, err)
+	assert.Len(t, service.Statements(), 1)
+
+	// The statement fails
+	service.QueueErrorCodes(graph.ErrorCode_E_EXECUTION_ERROR)
+	resp, err = session.ExecuteDDL("CREATE TAG person(name string)", time.Second)
+	var execErr *ExecutionError
+	assert.True(t, errors.As(err, &execErr))
+	assert.False(t, resp.IsSucceeded())
+
+	// The change is not visible in time
+	resp, err = session.ExecuteDDL("CREATE EDGE INDEX like_index ON like()", 10*time.Millisecond)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not visible after 10ms")
+		assert.False(t, errors.As(err, &execErr))
+	}
+	assert.True(t, resp.IsSucceeded())
+}
+
+func TestSession_SchemaProbe(t *testing.T) {
+	session := &Session{vidType: VIDTypeInt64}
+	for stmt, probe := range map[string]string{
+		"create tag t(a int)":                          "EXPLAIN FETCH PROP ON t 0",
+		"CREATE EDGE IF NOT EXISTS e()":                "EXPLAIN FETCH PROP ON e 0->0",
+		"CREATE TAG INDEX `i` ON t(a)":                 "DESCRIBE TAG INDEX `i`",
+		"DROP EDGE INDEX IF EXISTS i":                  "DESCRIBE EDGE INDEX i",
+		"CREATE SPACE IF NOT EXISTS s(vid_type=INT64)": "DESCRIBE SPACE s",
+		"DROP TAG if_tag":                              "EXPLAIN FETCH PROP ON if_tag 0",
+		"ALTER TAG t ADD (b int)":                      "",
+		"INSERT VERTEX t(a) VALUES 1:(1)":              "",
+		"CREATE TAG a(); CREATE TAG b()":               "",
+	} {
+		actual, created := session.schemaProbe(stmt)
+		assert.Equal(t, probe, actual, stmt)
+		assert.Equal(t, probe != "" && !strings.HasPrefix(stmt, "DROP"), created, stmt)
+	}
+}
@@ -7,52 +7,736 @@
 package nebula
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 
-	"github.com/facebook/fbthrift/thrift/lib/go/thrift"
 	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
 )
 
+// Session holds an authenticated session ID and the connection it was created on.
+// A session is obtained by ConnectionPool.GetSession and must be released by Release.
 type Session struct {
 	sessionID  int64
 	connection *connection
 	connPool   *ConnectionPool
 	log        Logger
+	mu         sync.Mutex
+	// Set once graphd answers E_SESSION_INVALID, the session needs not to be signed out then
+	invalid bool
+	// The VID type of the current space
+	vidType VIDType
+	// The credentials and the last space reported by graphd, used to sign in again if the session expires.
+	// They are only kept in memory, the password is zeroed when the session is released.
+	username string
+	password []byte
+	space    string
+	// Set once the transport is reopened or moved to another connection while executing a statement
+	reconnected bool
+	// Set by GetPinnedSession, the session is never moved to another connection nor replaced by a new one
+	pinned bool
+	// The space and its VID type Reset switches back to
+	defaultSpace   string
+	defaultVIDType VIDType
+	// The response of the last sign-in, kept for AuthResponse
+	authResp *graph.AuthResponse
+	// Added by RegisterInterceptor, in registration order
+	interceptors []Interceptor
+	// Number of the calls to graphd running on the session or waiting for it, read by ConnectionPool.Close
+	calls int32
 }
 
-// unsupported
-// func (session *Session) ExecuteJson(stmt string) (*graph.ExecutionResponse, error) {
-// 	return session.graph.ExecuteJson(session.sessionID, []byte(stmt))
-// }
+// ExecuteJson executes a query and returns the raw result in JSON format.
+// ErrUnsupportedByServer is returned if graphd does not support the RPC.
+// The returned bytes are decoded from the response without another copy, they belong to the caller
+// and are never reused by the client. Use ExecuteJsonTo not to hold a large result in memory.
+func (session *Session) ExecuteJson(stmt string) ([]byte, error) {
+	stmt = session.trimStatement(stmt)
+	if isEmptyStatement(stmt) {
+		return nil, fmt.Errorf("Failed to execute: %w", ErrEmptyStatement)
+	}
+	defer session.lockForCall()()
+	if session.connection == nil {
+		return nil, fmt.Errorf("Faied to execute: Session has been released")
+	}
+	if err := session.connPool.acquireQuerySlot(context.Background()); err != nil {
+		return nil, err
+	}
+	defer session.connPool.releaseQuerySlot()
+	if session.connection.isBroken() {
+		if err := session.connection.reopen(); err != nil {
+			return nil, err
+		}
+	}
+	return session.connection.executeJson(session.sessionID, stmt)
+}
 
-// Execute a query
-func (session *Session) Execute(stmt string) (*graph.ExecutionResponse, error) {
+// ExecuteJsonTo executes a query and writes the raw result in JSON format to w, e.g. an http.ResponseWriter.
+// With the binary protocol the result is copied to w while it is read from the transport, so it is never
+// held in memory as a whole, with another protocol or the header transport it is decoded first as ExecuteJson does.
+// Part of the result may have been written when an error is returned. If writing to w fails,
+// the connection is reopened for the next statement since the rest of the response is left unread.
+func (session *Session) ExecuteJsonTo(stmt string, w io.Writer) error {
+	stmt = session.trimStatement(stmt)
+	if isEmptyStatement(stmt) {
+		return fmt.Errorf("Failed to execute: %w", ErrEmptyStatement)
+	}
+	defer session.lockForCall()()
+	if session.connection == nil {
+		return fmt.Errorf("Faied to execute: Session has been released")
+	}
+	if err := session.connPool.acquireQuerySlot(context.Background()); err != nil {
+		return err
+	}
+	defer session.connPool.releaseQuerySlot()
+	if session.connection.isBroken() {
+		if err := session.connection.reopen(); err != nil {
+			return err
+		}
+	}
+	return session.connection.executeJsonTo(session.sessionID, stmt, w)
+}
+
+// Execute a query.
+// If the transport breaks while it runs, it is retried only if IdempotencyClassifier classifies it as
+// idempotent, by default a read-only statement, see ExecuteIdempotent for the writes safe to retry.
+// The error matches ErrNoSpaceSelected if graphd rejects the query since the session is in no space,
+// and is a *PermissionError if the user lacks the privilege for it, the result set is returned with them.
+// Other failures on the server side are only reported by the result set.
+func (session *Session) Execute(stmt string) (*ResultSet, error) {
+	return session.ExecuteWithContext(context.Background(), stmt)
+}
+
+// ExecuteWithTimeout executes a query with another socket timeout than PoolConfig.ExecTimeOut,
+// e.g. for a traversal known to be slow. The timeout is only for this query, it is restored
+// afterwards even if the query fails.
+func (session *Session) ExecuteWithTimeout(stmt string, timeout time.Duration) (*ResultSet, error) {
+	return session.ExecuteWithContext(withExecTimeout(context.Background(), timeout), stmt)
+}
+
+// Key of the socket timeout of a query in the context
+type execTimeoutKey struct{}
+
+// Return a context carrying the socket timeout of the query, 0 or negative value is ignored
+func withExecTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	if timeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, execTimeoutKey{}, timeout)
+}
+
+// Return the socket timeout carried by ctx, or def if there is none
+func execTimeoutFromContext(ctx context.Context, def time.Duration) time.Duration {
+	if timeout, ok := ctx.Value(execTimeoutKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return def
+}
+
+// ExecuteWithContext executes a query which is aborted when ctx is cancelled or its deadline is exceeded.
+// The returned error wraps ctx.Err() in that case, so errors.Is(err, context.Canceled) could be used.
+// Aborting only closes the transport, graphd keeps running the query unless KillQueryOnCancel is set.
+// If ctx carries a trace ID set by WithTraceID, it is sent in a comment in front of the statement,
+// so is the metadata of PoolConfig.StatementComment.
+// ErrEmptyStatement is returned without a round trip if the statement is empty.
+// A trailing semicolon is removed first if TrimTrailingSemicolon is set.
+// The statement goes through the interceptors of the session first, see RegisterInterceptor.
+func (session *Session) ExecuteWithContext(ctx context.Context, stmt string) (*ResultSet, error) {
+	return session.intercept(stmt, func(stmt string) (*ResultSet, error) {
+		if _, ok := cacheableStatement(ctx); ok {
+			// Cache the statement an interceptor may have rewritten
+			return session.executeWithContext(context.WithValue(ctx, cacheableKey{}, stmt), stmt)
+		}
+		return session.executeWithContext(ctx, stmt)
+	})
+}
+
+// ExecuteWithContext without the interceptors, for the statements the client executes itself
+func (session *Session) executeWithContext(ctx context.Context, stmt string) (*ResultSet, error) {
+	stmt = session.trimStatement(stmt)
+	if isEmptyStatement(stmt) {
+		return nil, fmt.Errorf("Failed to execute: %w", ErrEmptyStatement)
+	}
+	ctx = withIdempotent(ctx, session.isIdempotent(ctx, stmt))
+	return session.executeStatement(ctx, session.commentStatement(ctx, stmt))
+}
+
+// ExecuteReader reads the whole statement from r and executes it as Execute does, e.g. a large query stored
+// in a file. The reader is consumed to its end before anything is sent, and it is never closed.
+// An error of the reader matches ErrStatementRead, while the errors of the execution are those of Execute.
+func (session *Session) ExecuteReader(r io.Reader) (*ResultSet, error) {
+	stmt, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, &kindError{kind: ErrStatementRead, msg: fmt.Sprintf("Failed to read the statement, error: %s", err.Error()), err: err}
+	}
+	return session.Execute(string(stmt))
+}
+
+// ExecuteIdempotent executes a statement which is safe to execute more than once, e.g. an UPSERT or
+// an INSERT overwriting the same properties. Unlike Execute, it is retried after a transport failure
+// even if IdempotencyClassifier classifies it as a write.
+func (session *Session) ExecuteIdempotent(stmt string) (*ResultSet, error) {
+	return session.ExecuteWithContext(withIdempotent(context.Background(), true), stmt)
+}
+
+// ExecuteRaw sends the statement exactly as it is given, without any check or change on the client side:
+// an empty statement is sent to graphd, and no trace ID is added. It is for advanced uses, e.g. sending
+// payloads of several statements verbatim, the errors the checks would catch are reported by graphd instead.
+// The retries and reconnections of the pool still apply.
+func (session *Session) ExecuteRaw(stmt string) (*ResultSet, error) {
+	ctx := context.Background()
+	return session.executeStatement(withIdempotent(ctx, session.isIdempotent(ctx, stmt)), stmt)
+}
+
+// Execute the statement as it is, with the retries and reconnections enabled in the config
+func (session *Session) executeStatement(ctx context.Context, stmt string) (*ResultSet, error) {
+	defer session.lockForCall()()
+	cache := session.connPool.resultCache
+	cachedStmt, cacheable := cacheableStatement(ctx)
+	cacheable = cacheable && cache != nil && IsReadOnlyStatement(cachedStmt)
+	key := resultCacheKey{username: session.username, space: session.space, stmt: cachedStmt}
+	if cacheable {
+		if resp := cache.get(key); resp != nil {
+			resultSet := newResultSet(resp)
+			resultSet.vidType = session.vidType
+			resultSet.utf8Policy = session.connPool.conf.UTF8Policy
+			return resultSet, nil
+		}
+	}
+	start := time.Now()
+	session.reconnected = false
+	resp, err := session.connPool.conf.RetryPolicy.execute(ctx, session.log, func() (*graph.ExecutionResponse, error) {
+		return session.execute(ctx, stmt)
+	})
+	// graphd may have restarted if the transport broke, sign in again with the kept credentials then
+	if !session.pinned && (session.connPool.conf.AutoReconnectSession || session.reconnected) && isSessionExpired(resp, err) {
+		if authErr := session.signInAgain(ctx); authErr != nil {
+			session.invalid = true
+			session.connPool.metrics.ObserveExecute(time.Since(start), authErr)
+			return nil, session.statementError(authErr, stmt)
+		}
+		resp, err = session.execute(ctx, stmt)
+	}
+	if !session.pinned && session.connPool.conf.RetryOnLeaderChange && err == nil && isLeaderChanged(resp) {
+		host := session.connection.severAddress
+		session.log.Warn(fmt.Sprintf("Host %s:%d reports the leader has changed, retry on another host", host.Host, host.Port))
+		session.connPool.skipHost(host)
+		if _err := session.reConnect(); _err != nil {
+			session.log.Error(fmt.Sprintf("Failed to reconnect, %s", _err.Error()))
+		} else {
+			resp, err = session.execute(ctx, stmt)
+		}
+	}
+	if err == nil && resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED && len(resp.GetSpaceName()) > 0 {
+		session.space = string(resp.GetSpaceName())
+	}
+	if cache != nil {
+		if cacheable && err == nil && resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED && session.space == key.space {
+			cache.put(key, resp)
+		}
+		// The write may have been applied even if it failed
+		if session.connPool.conf.ResultCacheInvalidateOnWrite && !IsReadOnlyStatement(stmt) {
+			cache.invalidateSpace(key.space)
+			if session.space != key.space {
+				cache.invalidateSpace(session.space)
+			}
+		}
+	}
+	if err == nil {
+		if resp.GetErrorCode() == graph.ErrorCode_E_SESSION_INVALID {
+			session.invalid = true
+		}
+		session.connPool.metrics.ObserveExecute(time.Since(start), CheckResponse(resp))
+		err = noSpaceSelectedError(resp)
+		if err == nil {
+			err = permissionError(resp, stmt)
+		}
+	} else {
+		session.connPool.metrics.ObserveExecute(time.Since(start), err)
+	}
+	err = session.statementError(err, stmt)
+	if resp == nil {
+		return nil, err
+	}
+	resultSet := newResultSet(resp)
+	resultSet.vidType = session.vidType
+	resultSet.utf8Policy = session.connPool.conf.UTF8Policy
+	return resultSet, err
+}
+
+// Add the statement to the error if IncludeStatementInError is set
+func (session *Session) statementError(err error, stmt string) error {
+	if !session.connPool.conf.IncludeStatementInError {
+		return err
+	}
+	return withStatement(err, stmt, session.connPool.conf.getStatementInErrorMaxLen())
+}
+
+// Remove the trailing semicolon of the statement if TrimTrailingSemicolon is set
+func (session *Session) trimStatement(stmt string) string {
+	if !session.connPool.conf.TrimTrailingSemicolon {
+		return stmt
+	}
+	return trimTrailingSemicolon(stmt)
+}
+
+// Remove a single semicolon at the end of the statement with the whitespaces around it,
+// a semicolon inside a literal, a quoted label or a comment is kept
+func trimTrailingSemicolon(stmt string) string {
+	trimmed := strings.TrimRightFunc(stmt, unicode.IsSpace)
+	if !strings.HasSuffix(trimmed, ";") {
+		return stmt
+	}
+	last := len(trimmed) - 1
+	var quote byte
+	for i := 0; i < last; i++ {
+		c := trimmed[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case strings.HasPrefix(trimmed[i:], "/*"):
+			end := strings.Index(trimmed[i+2:], "*/")
+			if end < 0 {
+				return stmt
+			}
+			i += end + 3
+		case c == '#' || strings.HasPrefix(trimmed[i:], "//") || strings.HasPrefix(trimmed[i:], "--"):
+			end := strings.IndexByte(trimmed[i:], '\n')
+			if end < 0 {
+				return stmt
+			}
+			i += end
+		}
+	}
+	// The semicolon is inside a literal or a quoted label which is not closed
+	if quote != 0 {
+		return stmt
+	}
+	return strings.TrimRightFunc(trimmed[:last], unicode.IsSpace)
+}
+
+// Check if the statement is empty or only has whitespaces, graphd would report a syntax error for it
+func isEmptyStatement(stmt string) bool {
+	return strings.TrimSpace(stmt) == ""
+}
+
+// Send the query, reconnect and retry if the transport is broken
+func (session *Session) execute(ctx context.Context, stmt string) (*graph.ExecutionResponse, error) {
 	if session.connection == nil {
 		return nil, fmt.Errorf("Faied to execute: Session has been released")
 	}
-	resp, err := session.connection.execute(session.sessionID, stmt)
+	if err := session.connPool.acquireQuerySlot(ctx); err != nil {
+		return nil, err
+	}
+	defer session.connPool.releaseQuerySlot()
+	// A previous query failed with a fatal error, e.g. a timeout whose late response is still on the way
+	if session.connection.isBroken() {
+		session.reconnected = true
+		if err := session.connection.reopen(); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := session.connection.executeWithContext(ctx, session.sessionID, stmt)
 	if err == nil {
 		return resp, nil
 	}
-	// Reconnect only if the tranport is closed
-	if err, ok := err.(thrift.TransportException); ok && err.TypeID() == thrift.END_OF_FILE {
-		_err := session.reConnect()
-		if _err != nil {
-			session.connPool.log.Error(fmt.Sprintf("Failed to reconnect, %s \n", _err.Error()))
-			return nil, _err
+	// Do not retry if the caller gave up or the pool is closed
+	if ctx.Err() != nil || session.connPool.isClosed() {
+		if ctx.Err() != nil && session.connPool.conf.KillQueryOnCancel {
+			go session.connPool.killQuery(session.sessionID, stmt)
+		}
+		return nil, err
+	}
+	if !isTransportClosed(err) {
+		session.log.Error(fmt.Sprintf("Error info: %s", err.Error()))
+		return resp, err
+	}
+	// graphd may have executed the statement before the transport broke, a write could be applied twice
+	if idempotent, _ := ctx.Value(idempotentKey{}).(bool); !idempotent {
+		session.log.Warn(fmt.Sprintf("The transport is broken, the statement is not retried since it may not be idempotent, %s",
+			err.Error()))
+		// Reopen the transport before the next statement
+		session.connection.setBroken()
+		return nil, err
+	}
+	// Reopen the transport to the same host and retry
+	for i := 0; i < session.connPool.conf.MaxRetries; i++ {
+		session.reconnected = true
+		if _err := session.connection.reopen(); _err != nil {
+			session.log.Warn(fmt.Sprintf("Failed to reopen transport to host: %s, port: %d, %s",
+				session.connection.severAddress.Host, session.connection.severAddress.Port, _err.Error()))
+			break
 		}
-		session.connPool.log.Info(fmt.Sprintf("Successfully reconnect to host: %s, port: %d \n",
-			session.connection.severAddress.Host, session.connection.severAddress.Port))
-		// Execute with the new connetion
+		resp, err = session.connection.executeWithContext(ctx, session.sessionID, stmt)
+		if err == nil {
+			return checkSession(resp)
+		}
+		if ctx.Err() != nil || !isTransportClosed(err) {
+			return resp, err
+		}
+	}
+	// A pinned session stays on its connection, it is reopened for the next statement
+	if session.pinned {
+		session.connection.setBroken()
+		return nil, err
+	}
+	// Reconnect to another connection of the pool
+	session.reconnected = true
+	_err := session.reConnect()
+	if _err != nil {
+		session.log.Error(fmt.Sprintf("Failed to reconnect, %s \n", _err.Error()))
+		return nil, _err
+	}
+	session.log.Info(fmt.Sprintf("Successfully reconnect to host: %s, port: %d \n",
+		session.connection.severAddress.Host, session.connection.severAddress.Port))
+	// Execute with the new connetion
+	resp, err = session.connection.executeWithContext(ctx, session.sessionID, stmt)
+	if err != nil {
+		return nil, err
+	}
+	return checkSession(resp)
+}
+
+// Check if graphd could not find the session, e.g. it expired on the server side
+func isSessionExpired(resp *graph.ExecutionResponse, err error) bool {
+	if err != nil {
+		return errors.Is(err, ErrSessionInvalid)
+	}
+	code := resp.GetErrorCode()
+	return code == graph.ErrorCode_E_SESSION_INVALID || code == graph.ErrorCode_E_SESSION_TIMEOUT
+}
+
+// Check if the statement failed because the leader it needs has changed,
+// graphd of this version reports it in the error message of an execution error
+func isLeaderChanged(resp *graph.ExecutionResponse) bool {
+	return resp.GetErrorCode() == graph.ErrorCode_E_EXECUTION_ERROR &&
+		strings.Contains(strings.ToLower(string(resp.GetErrorMsg())), "leader")
+}
+
+// Sign in again on the same connection and switch the new session to the space of the expired one.
+// The returned error matches ErrSessionInvalid, and ErrAuthFailed if graphd rejects the user.
+func (session *Session) signInAgain(ctx context.Context) error {
+	if session.connection == nil {
+		return fmt.Errorf("Faied to execute: Session has been released")
+	}
+	resp, err := session.connection.authenticateWithContext(ctx, session.username, string(session.password))
+	if err != nil {
+		return &kindError{
+			kind: ErrSessionInvalid,
+			msg:  fmt.Sprintf("Failed to sign in again after the session expired, %s", err.Error()),
+			err:  err,
+		}
+	}
+	session.log.Info(fmt.Sprintf("Session %d expired, signed in again as session %d", session.sessionID, resp.GetSessionID()))
+	session.sessionID = resp.GetSessionID()
+	session.authResp = resp
+	session.invalid = false
+	if session.space != "" {
+		useResp, err := session.connection.execute(session.sessionID, "USE "+EscapeLabel(session.space))
+		if err == nil {
+			err = CheckResponse(useResp)
+		}
+		if err != nil {
+			return &kindError{
+				kind: ErrSessionInvalid,
+				msg:  fmt.Sprintf("Failed to use space %s after the session expired, %s", session.space, err.Error()),
+				err:  err,
+			}
+		}
+	}
+	for _, stmt := range session.connPool.conf.OnConnect {
 		resp, err := session.connection.execute(session.sessionID, stmt)
+		if err == nil {
+			err = CheckResponse(resp)
+		}
 		if err != nil {
-			return nil, err
+			return &kindError{
+				kind: ErrSessionInvalid,
+				msg:  fmt.Sprintf("Failed to execute the OnConnect statement %q after the session expired, %s", stmt, err.Error()),
+				err:  err,
+			}
 		}
-		return resp, nil
 	}
-	// Reconnect fail
-	session.connPool.log.Error(fmt.Sprintf("Error info: %s", err.Error()))
-	return resp, err
+	return nil
+}
+
+// Execute the OnConnect statements of the pool, stopping at the first one which fails
+func (session *Session) runOnConnect(ctx context.Context) error {
+	for _, stmt := range session.connPool.conf.OnConnect {
+		resp, err := session.executeWithContext(ctx, stmt)
+		if err == nil {
+			err = CheckResponse(resp.GetResponse())
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to execute the OnConnect statement %q: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// Return ErrSessionInvalid if graphd could not find the session after reconnection.
+// The response is returned as well so the caller could inspect the error message.
+func checkSession(resp *graph.ExecutionResponse) (*graph.ExecutionResponse, error) {
+	if resp.GetErrorCode() == graph.ErrorCode_E_SESSION_INVALID {
+		return resp, ErrSessionInvalid
+	}
+	return resp, nil
+}
+
+// ExecuteBatch executes the statements one by one on the session and returns their results in order.
+// If continueOnError is false, it stops at the first statement failing on the server side and returns
+// an *ExecutionError with the results so far, including the failed one.
+// Otherwise all statements are executed and the caller should check every result.
+// It always stops if a statement could not be sent, e.g. the transport is broken.
+func (session *Session) ExecuteBatch(stmts []string, continueOnError bool) ([]*ResultSet, error) {
+	return session.ExecuteBatchWithContext(context.Background(), stmts, continueOnError)
+}
+
+// ExecuteBatchWithContext is like ExecuteBatch, but no statement is sent once ctx is done, and the running one
+// is aborted as ExecuteWithContext does. The results of the statements executed so far are returned with
+// an error wrapping ctx.Err() then, so len(results) tells how many statements have run, e.g. to resume
+// a long import from there.
+func (session *Session) ExecuteBatchWithContext(ctx context.Context, stmts []string, continueOnError bool) ([]*ResultSet, error) {
+	results := make([]*ResultSet, 0, len(stmts))
+	for i, stmt := range stmts {
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("Failed to execute the batch, %d of %d statements are executed: %w", i, len(stmts), err)
+		}
+		resp, err := session.ExecuteWithContext(ctx, stmt)
+		// The failures on the server side come with their result
+		if err != nil && resp == nil {
+			if ctx.Err() != nil {
+				return results, fmt.Errorf("Failed to execute the batch, %d of %d statements are executed: %w", i, len(stmts), err)
+			}
+			return results, fmt.Errorf("Failed to execute statement %d of the batch: %w", i, err)
+		}
+		results = append(results, resp)
+		if err == nil {
+			err = CheckResponse(resp.GetResponse())
+		}
+		if err != nil && !continueOnError {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Validate checks the statement without executing it, by sending it under EXPLAIN, which only parses
+// and plans it. An *ExecutionError is returned for a syntax or semantic error, e.g. an unknown tag.
+// A nil error does not mean the statement would succeed, runtime errors like a missing vertex or
+// a storage failure are only found by executing it.
+// A statement starting with EXPLAIN is sent as is, PROFILE, which executes the statement, is replaced by EXPLAIN.
+func (session *Session) Validate(stmt string) error {
+	if isEmptyStatement(stmt) {
+		return fmt.Errorf("Failed to validate: %w", ErrEmptyStatement)
+	}
+	resp, err := session.Execute(explainStatement(stmt))
+	if err != nil {
+		return err
+	}
+	return CheckResponse(resp.GetResponse())
+}
+
+// Return the statement prefixed with EXPLAIN, unless it is explained or profiled already
+func explainStatement(stmt string) string {
+	trimmed := strings.TrimLeftFunc(stmt, unicode.IsSpace)
+	end := strings.IndexFunc(trimmed, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(trimmed)
+	}
+	switch strings.ToUpper(trimmed[:end]) {
+	case "EXPLAIN":
+		return stmt
+	case "PROFILE":
+		return "EXPLAIN" + trimmed[end:]
+	default:
+		return "EXPLAIN " + stmt
+	}
+}
+
+// WithRawClient calls fn with the thrift client of the connection of the session and the session ID,
+// to call the RPCs the client does not wrap yet, e.g. ExecuteJsonWithParameter of a newer graphd.
+// It is an advanced and unstable API, it may change once the RPCs are wrapped:
+// the client must not be used after fn returns, the other queries of the session wait for fn,
+// and the calls made in fn are not retried, reconnected, limited by MaxConcurrentQueries or QueriesPerSecond, or observed by the metrics.
+// The connection is discarded when it is released if fn returns a fatal transport error.
+func (session *Session) WithRawClient(fn func(client *graph.GraphServiceClient, sessionID int64) error) error {
+	defer session.lockForCall()()
+	if session.connection == nil {
+		return fmt.Errorf("Failed to call: Session has been released")
+	}
+	return session.connection.withRawClient(func(client *graph.GraphServiceClient) error {
+		return fn(client, session.sessionID)
+	})
+}
+
+// ExecuteWithParameter executes a query with $name placeholders bound to params.
+// See parametersToValues for the supported Go types, a placeholder whose name is not in params is sent as is.
+// The graph service of this version has no RPC to send parameters, so the values are written into
+// the statement as properly quoted literals before it is sent.
+func (session *Session) ExecuteWithParameter(stmt string, params map[string]interface{}) (*ResultSet, error) {
+	values, err := parametersToValues(params)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderParameters(stmt, values)
+	if err != nil {
+		return nil, err
+	}
+	return session.Execute(rendered)
+}
+
+// ExecuteParameterizedBatch executes the statement once per parameter set, e.g. an INSERT of many vertices,
+// with the sets bound as ExecuteWithParameter does. Up to PoolConfig.ParameterizedBatchSize rendered statements
+// are joined by semicolons and sent in one request, which graphd executes in order up to the first one failing.
+// As graphd does not tell which one failed then, every set of the request is given its error, though the
+// ones before the failed one have been applied. A set which could not be bound is given its own error.
+// After a request could not be sent, e.g. the transport is broken, the remaining sets are not sent and
+// are given that error as well. The returned error is nil if all sets succeeded, a *BatchError otherwise.
+func (session *Session) ExecuteParameterizedBatch(stmt string, paramSets []map[string]interface{}) error {
+	tmpl := parseTemplate(stmt)
+	batchSize := session.connPool.conf.getParameterizedBatchSize()
+	errs := make([]error, len(paramSets))
+	failed := false
+	// The error of the request which could not be sent
+	var sendErr error
+	// The indexes and the rendered statements of the sets to send in the next request
+	var pending []int
+	var stmts []string
+	send := func() {
+		if len(pending) == 0 {
+			return
+		}
+		var err error
+		if sendErr != nil {
+			err = fmt.Errorf("Failed to execute, an earlier request could not be sent: %w", sendErr)
+		} else if resp, execErr := session.Execute(strings.Join(stmts, "; ")); execErr != nil {
+			sendErr, err = execErr, execErr
+		} else {
+			err = CheckResponse(resp.GetResponse())
+		}
+		if err != nil {
+			failed = true
+			for _, i := range pending {
+				errs[i] = err
+			}
+		}
+		pending, stmts = pending[:0], stmts[:0]
+	}
+	for i, params := range paramSets {
+		values, err := parametersToValues(params)
+		var rendered string
+		if err == nil {
+			rendered, err = tmpl.render(values)
+		}
+		if err != nil {
+			failed = true
+			errs[i] = err
+			continue
+		}
+		pending = append(pending, i)
+		stmts = append(stmts, trimTrailingSemicolon(rendered))
+		if len(pending) == batchSize {
+			send()
+		}
+	}
+	send()
+	if failed {
+		return &BatchError{Errors: errs}
+	}
+	return nil
+}
+
+// SetVIDType sets the VID type of the current space, it should be called after switching to
+// a space whose VID type differs from PoolConfig.VIDType.
+func (session *Session) SetVIDType(vidType VIDType) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.vidType = vidType
+}
+
+// Return the address of the graphd serving the session, the zero value if the session has been released.
+// It could change after a query if the session reconnects to another host, e.g. the host is down.
+func (session *Session) GetHostAddress() HostAddress {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.connection == nil {
+		return HostAddress{}
+	}
+	return session.connection.severAddress
+}
+
+// AuthResponse returns the response of graphd to the sign-in of the session, e.g. to read the fields of
+// a newer graphd the client does not wrap yet. It is the response of the last sign-in if the session signed in
+// again, see AutoReconnectSession. It is an advanced and unstable API, it may change once the fields are wrapped.
+// The response is shared with the session and must not be modified.
+func (session *Session) AuthResponse() *graph.AuthResponse {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.authResp
+}
+
+// Reset restores the state a query may have changed, so the session could be reused for unrelated requests:
+//   - the current space is switched back to the default one, PoolConfig.SpaceName, or
+//     SessionPoolConfig.SpaceName for a session of a SessionPool
+//   - the VID type is set back to the one of the default space
+//   - the OnConnect statements of PoolConfig are executed again, so the parameters they set are restored
+//
+// Without a default space, the current space is kept since a session could not leave a space.
+// The parameters of ExecuteWithParameter are rendered into the statements on the client side,
+// so there is nothing else to clear.
+func (session *Session) Reset() error {
+	session.mu.Lock()
+	space, defaultSpace := session.space, session.defaultSpace
+	session.vidType = session.defaultVIDType
+	session.mu.Unlock()
+	if defaultSpace != "" && space != defaultSpace {
+		if err := session.useSpace(context.Background(), defaultSpace); err != nil {
+			return err
+		}
+	}
+	return session.runOnConnect(context.Background())
+}
+
+// Set the space and its VID type Reset switches back to
+func (session *Session) setDefaultSpace(space string, vidType VIDType) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.defaultSpace = space
+	session.defaultVIDType = vidType
+	session.vidType = vidType
+}
+
+// Switch the session to the given space, fail if the space could not be used
+func (session *Session) useSpace(ctx context.Context, space string) error {
+	resp, err := session.executeWithContext(ctx, "USE "+EscapeLabel(space))
+	if resp == nil {
+		return useSpaceError(space, nil, err)
+	}
+	err = useSpaceError(space, resp.GetResponse(), nil)
+	if validSpaces := session.connPool.validSpaces; validSpaces != nil {
+		if err == nil {
+			validSpaces.put(session.username, space)
+		} else if errors.Is(err, ErrSpaceNotFound) {
+			validSpaces.invalidate(space)
+		}
+	}
+	return err
 }
 
 func (session *Session) reConnect() error {
@@ -68,18 +752,68 @@ func (session *Session) reConnect() error {
 	return nil
 }
 
+// IsConnected tells if the transport of the session is open, without a round trip to graphd.
+// It is false once the session is released, or an RPC on the transport has failed with a fatal error,
+// the next statement reopens it then. A connection closed by graphd is only found by the next RPC.
+func (session *Session) IsConnected() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.connection != nil && session.connection.isOpen()
+}
+
+// Return true if graphd does not recognize the session any more
+func (session *Session) isInvalid() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.invalid
+}
+
+// Lock the session for a call to graphd, the returned func unlocks it.
+// The call is counted while it waits for the lock and until it returns.
+func (session *Session) lockForCall() func() {
+	atomic.AddInt32(&session.calls, 1)
+	session.mu.Lock()
+	return func() {
+		session.mu.Unlock()
+		atomic.AddInt32(&session.calls, -1)
+	}
+}
+
+// Return true if a call to graphd is running on the session or waiting for it
+func (session *Session) isBusy() bool {
+	return atomic.LoadInt32(&session.calls) > 0
+}
+
+// Return true if the session has been released, e.g. by ConnectionPool.SignoutAll
+func (session *Session) isReleased() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.connection == nil
+}
+
 // Logout and release connetion hold by session
+// Calling Release more than once is safe, the session is only signed out the first time.
 func (session *Session) Release() {
+	session.mu.Lock()
+	defer session.mu.Unlock()
 	if session.connection == nil {
-		session.connPool.log.Warn("Session has been released")
+		session.log.Warn("Session has been released")
 		return
 	}
-	if err := session.connection.signOut(session.sessionID); err != nil {
-		session.connPool.log.Warn(fmt.Sprintf("Sign out failed, %s", err.Error()))
+	// graphd does not know an invalid session any more
+	if !session.invalid {
+		if err := session.connection.signOut(session.sessionID); err != nil {
+			session.log.Warn(fmt.Sprintf("Sign out failed, %s", err.Error()))
+		}
 	}
 	// Release connection to pool
 	session.connPool.release(session.connection)
+	session.connPool.removeSession(session)
 	session.connection = nil
+	for i := range session.password {
+		session.password[i] = 0
+	}
+	session.username, session.password = "", nil
 }
 
 func IsError(resp *graph.ExecutionResponse) bool {
@@ -0,0 +1,333 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SessionPoolConfig struct {
+	// The user to sign in all sessions of the pool
+	// If it is empty, the sessions are signed in with PoolConfig.CredentialProvider of the connection pool
+	Username string
+	Password string
+	// The space of the sessions used by Execute, and by GetSession if no space is given
+	// Empty value means PoolConfig.SpaceName of the connection pool
+	SpaceName string
+	// The VID type of SpaceName, used only if SpaceName is set
+	VIDType VIDType
+	// The max sessions of the pool in all spaces, 0 value means the default value of 10
+	MaxSize int
+	// The time a session could stay idle in the pool before it is signed out
+	// 0 value means the session will not expire
+	IdleTime time.Duration
+	// Sign in a session and switch it to SpaceName in NewSessionPool, so it fails at once if the space
+	// does not exist or the user has no access to it, matching ErrSpaceNotFound or ErrNoPermission.
+	// The session is kept in the pool. Leave it unset to create the first session on the first query.
+	// It is skipped if a session of the user has been switched to the space within PoolConfig.ValidSpaceCacheTTL.
+	ValidateSpace bool
+	// The policy to sign in again when graphd reports it has too many sessions, ErrSessionLimitReached,
+	// e.g. while the sessions of other clients are being freed. Its RetriableCodes and RetryableErrorFunc are ignored.
+	// The zero value means GetSession fails at once.
+	SessionLimitRetryPolicy RetryPolicy
+}
+
+// SessionPool keeps authenticated sessions for a fixed user, so queries could be executed without
+// signing in every time. The idle sessions are kept by space, GetSession returns a session already in
+// the requested space if there is one.
+// If a query switches the session to another space, the session is switched back before it is reused.
+type SessionPool struct {
+	conf     SessionPoolConfig
+	connPool *ConnectionPool
+	log      Logger
+	mu       sync.Mutex
+	// Idle sessions of all spaces, the least recently used one is at the front
+	idleSessions list.List
+	// Number of sessions created and not signed out yet
+	size   int
+	closed bool
+}
+
+// An idle session, its space and the time it was put back
+type idleSession struct {
+	session  *Session
+	space    string
+	lastUsed time.Time
+}
+
+// NewSessionPool creates a session pool on top of connPool, no session is created until a query is executed.
+func NewSessionPool(connPool *ConnectionPool, conf SessionPoolConfig) (*SessionPool, error) {
+	if connPool == nil {
+		return nil, fmt.Errorf("Failed to create session pool: no connection pool")
+	}
+	if conf.Username == "" && connPool.conf.CredentialProvider == nil {
+		return nil, fmt.Errorf("Failed to create session pool: no username or CredentialProvider")
+	}
+	if conf.MaxSize < 0 {
+		connPool.log.Warn("Invalid MaxSize value, the default value of 10 has been applied")
+	}
+	if conf.MaxSize <= 0 {
+		conf.MaxSize = 10
+	}
+	if conf.SpaceName == "" {
+		conf.SpaceName = connPool.conf.SpaceName
+		conf.VIDType = connPool.conf.VIDType
+	}
+	if conf.IdleTime < 0 {
+		conf.IdleTime = 0 * time.Millisecond
+		connPool.log.Warn("Invalid IdleTime value, the default value of 0 second has been applied")
+	}
+	conf.SessionLimitRetryPolicy.validate(connPool.log)
+	pool := &SessionPool{conf: conf, connPool: connPool, log: connPool.log}
+	if conf.ValidateSpace && !connPool.isValidSpace(conf.Username, conf.SpaceName) {
+		session, err := pool.GetSession("")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create session pool: %w", err)
+		}
+		pool.ReturnSession(session)
+	}
+	return pool, nil
+}
+
+// Execute a query with an idle session of the pool, a new session is created if there is none.
+// The session is signed out instead of being reused if the query fails with an error.
+func (pool *SessionPool) Execute(stmt string) (*ResultSet, error) {
+	session, err := pool.GetSession("")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := session.Execute(stmt)
+	if err != nil || session.isInvalid() {
+		pool.releaseSession(session)
+		return resp, err
+	}
+	pool.ReturnSession(session)
+	return resp, nil
+}
+
+// GetSession takes an idle session in the space, SessionPoolConfig.SpaceName if space is empty.
+// If there is none, a new session is created, or the least recently used idle session of another
+// space is switched to the space if the pool is full.
+// The session must be given back with ReturnSession instead of being released.
+func (pool *SessionPool) GetSession(space string) (*Session, error) {
+	if space == "" {
+		space = pool.conf.SpaceName
+	}
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return nil, fmt.Errorf("Failed to get session: %w", ErrPoolClosed)
+	}
+	pool.dropReleased()
+	expired := pool.takeExpired()
+	var session *Session
+	switchSpace := false
+	if ele := pool.findIdle(space); ele != nil {
+		session = pool.idleSessions.Remove(ele).(*idleSession).session
+	} else if pool.size < pool.conf.MaxSize {
+		// Reserve the slot before creating the session out of the lock
+		pool.size++
+	} else if ele := pool.idleSessions.Front(); ele != nil && space != "" {
+		session = pool.idleSessions.Remove(ele).(*idleSession).session
+		switchSpace = true
+	} else {
+		pool.mu.Unlock()
+		pool.signOut(expired)
+		return nil, fmt.Errorf("Failed to get session: the number of sessions has reached MaxSize %d", pool.conf.MaxSize)
+	}
+	pool.mu.Unlock()
+	pool.signOut(expired)
+
+	if switchSpace {
+		if err := pool.switchSpace(session, space); err != nil {
+			pool.releaseSession(session)
+			return nil, err
+		}
+		pool.connPool.watchLeak(session)
+		return session, nil
+	}
+	if session != nil {
+		pool.connPool.watchLeak(session)
+		return session, nil
+	}
+	session, err := pool.newSession(space)
+	if err != nil {
+		pool.mu.Lock()
+		pool.size--
+		pool.mu.Unlock()
+		return nil, err
+	}
+	return session, nil
+}
+
+// ReturnSession gives a session obtained from GetSession back to the pool.
+// The session is reset to its space first, and signed out if that fails or graphd has invalidated it.
+func (pool *SessionPool) ReturnSession(session *Session) {
+	if session.isInvalid() {
+		pool.releaseSession(session)
+		return
+	}
+	// Restore the space if a query switched it
+	if err := session.Reset(); err != nil {
+		pool.log.Warn(fmt.Sprintf("Failed to reset the session, %s", err.Error()))
+		pool.releaseSession(session)
+		return
+	}
+	pool.putSession(session)
+}
+
+// Find the most recently used idle session in the space, must be called with the lock held
+func (pool *SessionPool) findIdle(space string) *list.Element {
+	for ele := pool.idleSessions.Back(); ele != nil; ele = ele.Prev() {
+		if ele.Value.(*idleSession).space == space {
+			return ele
+		}
+	}
+	return nil
+}
+
+// Switch a session to the space and make it the space Reset switches back to
+func (pool *SessionPool) switchSpace(session *Session, space string) error {
+	if err := session.useSpace(context.Background(), space); err != nil {
+		return err
+	}
+	session.setDefaultSpace(space, pool.vidTypeOf(space))
+	return nil
+}
+
+// Return the VID type of the space, only the VID types of the configured spaces are known
+func (pool *SessionPool) vidTypeOf(space string) VIDType {
+	if space == pool.conf.SpaceName {
+		return pool.conf.VIDType
+	}
+	if space == pool.connPool.conf.SpaceName {
+		return pool.connPool.conf.VIDType
+	}
+	return VIDTypeString
+}
+
+// Sign in a new session and switch it to the space.
+// Signing in is retried by SessionLimitRetryPolicy while graphd reports it has too many sessions.
+func (pool *SessionPool) newSession(space string) (*Session, error) {
+	policy := pool.conf.SessionLimitRetryPolicy
+	session, err := pool.signIn()
+	for retry := 1; retry < policy.MaxAttempts && errors.Is(err, ErrSessionLimitReached); retry++ {
+		backoff := policy.backoff(retry)
+		pool.log.Warn(fmt.Sprintf("Failed to sign in since graphd has too many sessions, retry %d in %s", retry, backoff))
+		time.Sleep(backoff)
+		pool.mu.Lock()
+		closed := pool.closed
+		pool.mu.Unlock()
+		if closed {
+			return nil, fmt.Errorf("Failed to get session: %w", ErrPoolClosed)
+		}
+		session, err = pool.signIn()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if space != "" {
+		if err := pool.switchSpace(session, space); err != nil {
+			session.Release()
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// Sign in a session of the user, or of the credentials of the connection pool if there is no user
+func (pool *SessionPool) signIn() (*Session, error) {
+	if pool.conf.Username == "" {
+		return pool.connPool.GetSessionFromProvider()
+	}
+	return pool.connPool.GetSession(pool.conf.Username, pool.conf.Password)
+}
+
+// Put a session back to the idle list
+func (pool *SessionPool) putSession(session *Session) {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		pool.releaseSession(session)
+		return
+	}
+	session.mu.Lock()
+	space := session.defaultSpace
+	session.mu.Unlock()
+	pool.connPool.unwatchLeak(session)
+	pool.idleSessions.PushBack(&idleSession{session: session, space: space, lastUsed: time.Now()})
+	pool.mu.Unlock()
+}
+
+// Remove the sessions which have been idle longer than IdleTime, must be called with the lock held
+func (pool *SessionPool) takeExpired() []*Session {
+	if pool.conf.IdleTime == 0 {
+		return nil
+	}
+	var expired []*Session
+	for ele := pool.idleSessions.Front(); ele != nil; ele = pool.idleSessions.Front() {
+		idle := ele.Value.(*idleSession)
+		if time.Since(idle.lastUsed) < pool.conf.IdleTime {
+			break
+		}
+		pool.idleSessions.Remove(ele)
+		expired = append(expired, idle.session)
+	}
+	return expired
+}
+
+// Drop the idle sessions released by ConnectionPool.SignoutAll, must be called with the lock held
+func (pool *SessionPool) dropReleased() {
+	for ele := pool.idleSessions.Front(); ele != nil; {
+		next := ele.Next()
+		if ele.Value.(*idleSession).session.isReleased() {
+			pool.idleSessions.Remove(ele)
+			pool.size--
+		}
+		ele = next
+	}
+}
+
+// Sign out the sessions and free their slots
+func (pool *SessionPool) signOut(sessions []*Session) {
+	for _, session := range sessions {
+		pool.releaseSession(session)
+	}
+}
+
+// Sign out a session which is not in the idle list
+func (pool *SessionPool) releaseSession(session *Session) {
+	session.Release()
+	pool.mu.Lock()
+	pool.size--
+	pool.mu.Unlock()
+}
+
+// Return the number of idle sessions
+func (pool *SessionPool) getIdleSessionCount() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.idleSessions.Len()
+}
+
+// Close signs out all idle sessions, the sessions in use are signed out once their queries finish.
+// Calling Close more than once is safe.
+func (pool *SessionPool) Close() {
+	pool.mu.Lock()
+	pool.closed = true
+	var sessions []*Session
+	for pool.idleSessions.Len() > 0 {
+		sessions = append(sessions, pool.idleSessions.Remove(pool.idleSessions.Front()).(*idleSession).session)
+	}
+	pool.mu.Unlock()
+	pool.signOut(sessions)
+}
@@ -0,0 +1,305 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func newTestSessionPool(t *testing.T, conf SessionPoolConfig) (*testutil.FakeGraphService, *SessionPool, func()) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	connPool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	sessionPool, err := NewSessionPool(connPool, conf)
+	if err != nil {
+		connPool.Close()
+		stop()
+		t.Fatal(err)
+	}
+	return service, sessionPool, func() {
+		sessionPool.Close()
+		connPool.Close()
+		stop()
+	}
+}
+
+func TestSessionPool(t *testing.T) {
+	service, pool, cleanup := newTestSessionPool(t, SessionPoolConfig{
+		Username:  "root",
+		Password:  "nebula",
+		SpaceName: "nba",
+	})
+	defer cleanup()
+
+	resp, err := pool.Execute("YIELD 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, resp.IsSucceeded())
+	assert.Equal(t, "nba", resp.GetSpaceName())
+
+	// The session is reused
+	_, err = pool.Execute("YIELD 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pool.getIdleSessionCount())
+	assert.Equal(t, 1, service.SessionCount())
+	assert.Equal(t, []string{"USE nba", "YIELD 1", "YIELD 2"}, service.Statements())
+
+	pool.Close()
+	// Signout is a oneway RPC, the server handles it asynchronously
+	assert.Eventually(t, func() bool { return service.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+	_, err = pool.Execute("YIELD 1")
+	assert.True(t, errors.Is(err, ErrPoolClosed))
+}
+
+func TestSessionPool_IdleTime(t *testing.T) {
+	service, pool, cleanup := newTestSessionPool(t, SessionPoolConfig{
+		Username: "root",
+		Password: "nebula",
+		IdleTime: 50 * time.Millisecond,
+	})
+	defer cleanup()
+
+	_, err := pool.Execute("YIELD 1")
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	// The expired session is signed out and a new one is created
+	_, err = pool.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return service.SessionCount() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, pool.getIdleSessionCount())
+}
+
+func TestSessionPool_Errors(t *testing.T) {
+	_, pool, cleanup := newTestSessionPool(t, SessionPoolConfig{
+		Username: "root",
+		Password: "wrong",
+	})
+	defer cleanup()
+	_, err := pool.Execute("YIELD 1")
+	assert.Error(t, err)
+
+	_, pool, cleanupSpace := newTestSessionPool(t, SessionPoolConfig{
+		Username:  "root",
+		Password:  "nebula",
+		SpaceName: "not_exist",
+	})
+	defer cleanupSpace()
+	_, err = pool.Execute("YIELD 1")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Failed to use space not_exist")
+	}
+	assert.Equal(t, 0, pool.getIdleSessionCount())
+}
+
+func TestSessionPool_SessionLimitRetryPolicy(t *testing.T) {
+	// graphd allows a single session, the handlers run with the lock of the service held
+	service := testutil.NewFakeGraphService()
+	open, nextID := 0, int64(1)
+	service.AuthenticateHandler = func(username, password string) *graph.AuthResponse {
+		if open >= 1 {
+			return &graph.AuthResponse{
+				ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR,
+				ErrorMsg:  []byte("Create Session failed: Too many sessions created from 127.0.0.1 by user root. the threshold is 1."),
+			}
+		}
+		open++
+		sessionID := nextID
+		nextID++
+		return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &sessionID}
+	}
+	service.SignoutHandler = func(sessionID int64) {
+		open--
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	connPool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connPool.Close()
+	held, err := connPool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// It fails at once by default
+	pool, err := NewSessionPool(connPool, SessionPoolConfig{Username: "root", Password: "nebula"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	_, err = pool.GetSession("")
+	assert.True(t, errors.Is(err, ErrSessionLimitReached))
+	assert.False(t, errors.Is(err, ErrAuthFailed))
+
+	// It is retried until the held session is freed
+	retryPool, err := NewSessionPool(connPool, SessionPoolConfig{
+		Username:                "root",
+		Password:                "nebula",
+		SessionLimitRetryPolicy: RetryPolicy{MaxAttempts: 10, InitialBackoff: 20 * time.Millisecond, Multiplier: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer retryPool.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		held.Release()
+	}()
+	start := time.Now()
+	session, err := retryPool.GetSession("")
+	if assert.NoError(t, err) {
+		assert.True(t, time.Since(start) >= 40*time.Millisecond)
+		resp, err := session.Execute("YIELD 1")
+		assert.NoError(t, err)
+		assert.True(t, resp.IsSucceeded())
+		retryPool.ReturnSession(session)
+	}
+
+	// It gives up after MaxAttempts
+	noFreePool, err := NewSessionPool(connPool, SessionPoolConfig{
+		Username:                "root",
+		Password:                "nebula",
+		SessionLimitRetryPolicy: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer noFreePool.Close()
+	_, err = noFreePool.GetSession("")
+	assert.True(t, errors.Is(err, ErrSessionLimitReached))
+}
+
+func TestSessionPool_GetSession(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.Spaces = []string{"nba", "test", "other"}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	connPool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connPool.Close()
+	pool, err := NewSessionPool(connPool, SessionPoolConfig{
+		Username:  "root",
+		Password:  "nebula",
+		SpaceName: "nba",
+		MaxSize:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	nbaSession, err := pool.GetSession("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSession, err := pool.GetSession("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := testSession.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "test", resp.GetSpaceName())
+	pool.ReturnSession(nbaSession)
+	pool.ReturnSession(testSession)
+
+	// Every space gets its own idle session back
+	session, err := pool.GetSession("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testSession, session)
+	// A query moving the session to another space does not change the space it is kept in
+	_, err = session.Execute("USE nba")
+	assert.NoError(t, err)
+	pool.ReturnSession(session)
+	session, err = pool.GetSession("nba")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, nbaSession, session)
+	pool.ReturnSession(session)
+	assert.Equal(t, 2, service.SessionCount())
+
+	// The pool is full, the least recently used idle session is moved to the other space
+	service.ResetStatements()
+	session, err = pool.GetSession("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testSession, session)
+	assert.Equal(t, []string{"USE other"}, service.Statements())
+	pool.ReturnSession(session)
+	assert.Equal(t, 2, service.SessionCount())
+	assert.Equal(t, 2, pool.getIdleSessionCount())
+
+	// Nothing is idle and the pool is full
+	first, err := pool.GetSession("nba")
+	assert.NoError(t, err)
+	second, err := pool.GetSession("nba")
+	assert.NoError(t, err)
+	_, err = pool.GetSession("nba")
+	assert.Error(t, err)
+	pool.ReturnSession(first)
+	pool.ReturnSession(second)
+}
+
+func TestSessionPool_ValidateSpace(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	connPool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connPool.Close()
+	conf := SessionPoolConfig{Username: "root", Password: "nebula", SpaceName: "not_exist", ValidateSpace: true}
+
+	_, err = NewSessionPool(connPool, conf)
+	assert.True(t, errors.Is(err, ErrSpaceNotFound))
+	service.QueueErrorCodes(graph.ErrorCode_E_BAD_PERMISSION)
+	conf.SpaceName = "nba"
+	_, err = NewSessionPool(connPool, conf)
+	assert.True(t, errors.Is(err, ErrNoPermission))
+	assert.Eventually(t, func() bool { return service.SessionCount() == 0 }, time.Second, 10*time.Millisecond)
+
+	// The validated session is kept for the first query
+	pool, err := NewSessionPool(connPool, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	assert.Equal(t, 1, pool.getIdleSessionCount())
+	_, err = pool.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, service.SessionCount())
+
+	// Nothing is sent in NewSessionPool without the validation
+	conf.SpaceName = "not_exist"
+	conf.ValidateSpace = false
+	lazyPool, err := NewSessionPool(connPool, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lazyPool.Close()
+	_, err = lazyPool.Execute("YIELD 1")
+	assert.True(t, errors.Is(err, ErrSpaceNotFound))
+}
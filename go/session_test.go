@@ -0,0 +1,854 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestSession_ExecuteWithContext(t *testing.T) {
+	listener, host := startSilentServer(t)
+	defer listener.Close()
+
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &Session{sessionID: 1, connection: conn, connPool: pool, log: nebulaLog}
+
+	// The server never answers, the query must be aborted by the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = session.ExecuteWithContext(ctx, "YIELD 1")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second)
+
+	// A cancelled context fails without sending the query
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	_, err = session.ExecuteWithContext(cancelled, "YIELD 1")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestSession_Release(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// The session is dropped by graphd, e.g. after graphd restarted
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Signout(session.sessionID)
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_E_SESSION_INVALID, resp.GetErrorCode())
+	session.Release()
+	session.Release()
+	assert.Equal(t, HostAddress{}, session.GetHostAddress())
+
+	// A session which was never signed in
+	conn, err := pool.GetConnection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	session = &Session{connection: conn, connPool: pool, log: nebulaLog}
+	session.Release()
+
+	// Wait for a signout sent after the ones above
+	session, err = pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Release()
+	assert.Eventually(t, func() bool { return service.SignoutCount() >= 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, service.SignoutCount())
+}
+
+func TestSession_ExecuteBatch(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	stmts := []string{"USE nba", "INSERT VERTEX 1", "INSERT VERTEX 2"}
+
+	// Stop at the failed statement
+	service.QueueErrorCodes(graph.ErrorCode_SUCCEEDED, graph.ErrorCode_E_EXECUTION_ERROR)
+	results, err := session.ExecuteBatch(stmts, false)
+	var execErr *ExecutionError
+	assert.True(t, errors.As(err, &execErr))
+	assert.Len(t, results, 2)
+
+	// Execute all statements
+	service.QueueErrorCodes(graph.ErrorCode_SUCCEEDED, graph.ErrorCode_E_EXECUTION_ERROR)
+	results, err = session.ExecuteBatch(stmts, true)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.False(t, results[1].IsSucceeded())
+		assert.True(t, results[2].IsSucceeded())
+	}
+}
+
+func TestSession_ExecuteBatchWithContext(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		time.Sleep(150 * time.Millisecond)
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	stmts := []string{"INSERT VERTEX 1", "INSERT VERTEX 2", "INSERT VERTEX 3", "INSERT VERTEX 4", "INSERT VERTEX 5"}
+
+	// The deadline passes while the third statement is running
+	ctx, cancel := context.WithTimeout(context.Background(), 375*time.Millisecond)
+	defer cancel()
+	results, err := session.ExecuteBatchWithContext(ctx, stmts, false)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "2 of 5 statements are executed")
+	assert.Len(t, results, 2)
+	assert.Equal(t, stmts[:3], service.Statements())
+
+	// Nothing is sent once ctx is done
+	service.ResetStatements()
+	results, err = session.ExecuteBatchWithContext(ctx, stmts, false)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Empty(t, results)
+	assert.Empty(t, service.Statements())
+}
+
+func TestSession_AutoReconnectSession(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	conf := GetDefaultConf()
+	conf.AutoReconnectSession = true
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	_, err = session.Execute("USE nba")
+	assert.NoError(t, err)
+	oldSessionID := session.sessionID
+	if authResp := session.AuthResponse(); assert.NotNil(t, authResp) {
+		assert.Equal(t, graph.ErrorCode_SUCCEEDED, authResp.GetErrorCode())
+		assert.Equal(t, oldSessionID, authResp.GetSessionID())
+	}
+
+	// The statement is retried with a new session in the same space
+	service.ExpireSessions()
+	service.ResetStatements()
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.Equal(t, "nba", resp.GetSpaceName())
+	assert.NotEqual(t, oldSessionID, session.sessionID)
+	assert.Equal(t, session.sessionID, session.AuthResponse().GetSessionID())
+	assert.Equal(t, []string{"USE nba", "YIELD 1"}, service.Statements())
+
+	// So is it if graphd reports the session timed out
+	service.QueueErrorCodes(graph.ErrorCode_E_SESSION_TIMEOUT)
+	timedOutSessionID := session.sessionID
+	resp, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.NotEqual(t, timedOutSessionID, session.sessionID)
+
+	// A typed error is returned if signing in again fails
+	service.ExpireSessions()
+	session.password = []byte("changed")
+	_, err = session.Execute("YIELD 1")
+	assert.True(t, errors.Is(err, ErrSessionInvalid))
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+}
+
+func TestSession_SignInAfterReconnect(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	var mu sync.Mutex
+	breakNext := false
+	conf := GetDefaultConf()
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return &breakingConn{Conn: conn, breakNext: &breakNext, mu: &mu}, nil
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = session.Execute("USE nba")
+	assert.NoError(t, err)
+	oldSessionID := session.sessionID
+
+	// graphd restarts, the transport breaks and the sessions are gone
+	mu.Lock()
+	breakNext = true
+	mu.Unlock()
+	service.ExpireSessions()
+	resp, err := session.Execute("YIELD 1")
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+		assert.Equal(t, "nba", resp.GetSpaceName())
+	}
+	assert.NotEqual(t, oldSessionID, session.sessionID)
+
+	// Without a reconnection, an expired session is reported as it is
+	service.ExpireSessions()
+	resp, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_E_SESSION_INVALID, resp.GetErrorCode())
+
+	// The credentials are dropped once the session is released
+	password := session.password
+	session.Release()
+	assert.Equal(t, make([]byte, len("nebula")), password)
+	assert.Nil(t, session.password)
+	assert.Equal(t, "", session.username)
+}
+
+func TestPool_GetPinnedSession(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	var mu sync.Mutex
+	breakNext := false
+	conf := GetDefaultConf()
+	conf.MaxRetries = 0
+	conf.AutoReconnectSession = true
+	conf.Dialer = func(ctx context.Context, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return &breakingConn{Conn: conn, breakNext: &breakNext, mu: &mu}, nil
+	}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	breakTransport := func() {
+		mu.Lock()
+		breakNext = true
+		mu.Unlock()
+	}
+
+	// A session moves to another connection when its transport breaks
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := session.connection
+	breakTransport()
+	_, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, conn != session.connection)
+	session.Release()
+
+	// A pinned one does not
+	pinned, err := pool.GetPinnedSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pinned.Release()
+	conn, sessionID := pinned.connection, pinned.sessionID
+	breakTransport()
+	_, err = pinned.Execute("YIELD 1")
+	assert.True(t, errors.Is(err, ErrTransportClosed))
+	assert.True(t, conn == pinned.connection)
+	// Its connection is reopened for the next statement
+	resp, err := pinned.Execute("YIELD 1")
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+	}
+	assert.True(t, conn == pinned.connection)
+
+	// Nor is it signed in again
+	service.ExpireSessions()
+	resp, err = pinned.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_E_SESSION_INVALID, resp.GetErrorCode())
+	assert.Equal(t, sessionID, pinned.sessionID)
+}
+
+func TestSession_IsConnected(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ExecTimeOut = 200 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, session.IsConnected())
+
+	// A timed out query breaks the transport, the next one reopens it
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		// The fake service is locked while sleeping, the next query waits for the rest of the sleep
+		if stmt == "slow" {
+			time.Sleep(300 * time.Millisecond)
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	_, err = session.Execute("slow")
+	assert.True(t, errors.Is(err, ErrTimeout))
+	assert.False(t, session.IsConnected())
+	_, err = session.Execute("fast")
+	assert.NoError(t, err)
+	assert.True(t, session.IsConnected())
+
+	session.Release()
+	assert.False(t, session.IsConnected())
+}
+
+// A graph service whose leader has moved, it fails every statement but USE
+type leaderChangedService struct {
+	*testutil.FakeGraphService
+}
+
+func (s leaderChangedService) Execute(sessionID int64, stmt []byte) (*graph.ExecutionResponse, error) {
+	if strings.HasPrefix(string(stmt), "USE") {
+		return s.FakeGraphService.Execute(sessionID, stmt)
+	}
+	return &graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR,
+		ErrorMsg:  []byte("Storage Error: The leader has changed"),
+	}, nil
+}
+
+func TestSession_RetryOnLeaderChange(t *testing.T) {
+	// Both hosts share the sessions
+	service := testutil.NewFakeGraphService()
+	stopOld, oldLeader := startFakeServer(t, leaderChangedService{service})
+	defer stopOld()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+
+	conf := GetDefaultConf()
+	conf.RetryOnLeaderChange = true
+	pool, err := NewConnectionPool([]HostAddress{oldLeader, host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	assert.Equal(t, oldLeader, session.GetHostAddress())
+
+	resp, err := session.Execute("SUBMIT JOB COMPACT")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.Equal(t, host, session.GetHostAddress())
+	// The old leader is skipped for new sessions too
+	other, err := pool.GetSession("root", "nebula")
+	if assert.NoError(t, err) {
+		assert.Equal(t, host, other.connection.severAddress)
+		other.Release()
+	}
+}
+
+func TestSession_Reset(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	// Nothing to restore without a default space
+	assert.NoError(t, session.Reset())
+	assert.Empty(t, service.Statements())
+
+	session.setDefaultSpace("nba", VIDTypeInt64)
+	session.SetVIDType(VIDTypeString)
+	assert.NoError(t, session.Reset())
+	assert.Equal(t, VIDTypeInt64, session.vidType)
+	assert.Equal(t, []string{"USE nba"}, service.Statements())
+	// The session is in the default space already
+	assert.NoError(t, session.Reset())
+	assert.Len(t, service.Statements(), 1)
+
+	session.setDefaultSpace("not_exist", VIDTypeString)
+	assert.Error(t, session.Reset())
+}
+
+func TestSession_OnConnect(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.SpaceName = "nba"
+	conf.AutoReconnectSession = true
+	conf.OnConnect = []string{"SET a = 1", "SET b = 2"}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	assert.Equal(t, []string{"USE nba", "SET a = 1", "SET b = 2"}, service.Statements())
+
+	// They are executed again by Reset and after signing in again
+	service.ResetStatements()
+	assert.NoError(t, session.Reset())
+	assert.Equal(t, []string{"SET a = 1", "SET b = 2"}, service.Statements())
+	service.ExpireSessions()
+	service.ResetStatements()
+	_, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"USE nba", "SET a = 1", "SET b = 2", "YIELD 1"}, service.Statements())
+
+	// Getting a session fails if any of them fails, the session is signed out
+	service.ResetStatements()
+	service.QueueErrorCodes(graph.ErrorCode_SUCCEEDED, graph.ErrorCode_SUCCEEDED, graph.ErrorCode_E_SYNTAX_ERROR)
+	_, err = pool.GetSession("root", "nebula")
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, execErr.ErrorCode)
+		assert.Contains(t, err.Error(), "SET b = 2")
+	}
+	assert.Eventually(t, func() bool { return service.SessionCount() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestSession_ExecuteWithTimeout(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		time.Sleep(200 * time.Millisecond)
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.ExecTimeOut = 100 * time.Millisecond
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	resp, err := session.ExecuteWithTimeout("GO 10 STEPS FROM 1 OVER follow", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	// The exec timeout is restored for the next query
+	_, err = session.Execute("GO 10 STEPS FROM 1 OVER follow")
+	if assert.Error(t, err) {
+		assert.True(t, isTimeout(err))
+	}
+}
+
+func TestSession_MaxConcurrentQueries(t *testing.T) {
+	started := make(chan struct{}, 1)
+	finish := make(chan struct{})
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		if stmt == "slow" {
+			started <- struct{}{}
+			<-finish
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.MaxConcurrentQueries = 1
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	slowSession, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slowSession.Release()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := slowSession.Execute("slow")
+		done <- err
+	}()
+	<-started
+	// The only slot is taken by the slow query
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = session.ExecuteWithContext(ctx, "YIELD 1")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// The waiting query runs once the slot is freed
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(finish)
+	}()
+	resp, err := session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	assert.NoError(t, <-done)
+	// The query giving up is never sent
+	assert.Equal(t, []string{"slow", "YIELD 1"}, service.Statements())
+}
+
+func TestSession_EmptyStatement(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	for _, stmt := range []string{"", " \t\n"} {
+		_, err := session.Execute(stmt)
+		assert.True(t, errors.Is(err, ErrEmptyStatement))
+		_, err = session.ExecuteJson(stmt)
+		assert.True(t, errors.Is(err, ErrEmptyStatement))
+		_, err = session.ExecuteIter(stmt)
+		assert.True(t, errors.Is(err, ErrEmptyStatement))
+	}
+	assert.Empty(t, service.Statements())
+
+	// Sent as is in the raw mode
+	resp, err := session.ExecuteRaw(" \t\n")
+	assert.NoError(t, err)
+	assert.True(t, resp.IsSucceeded())
+	_, err = session.ExecuteRaw("YIELD 1; YIELD 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{" \t\n", "YIELD 1; YIELD 2"}, service.Statements())
+}
+
+func TestSession_ExecuteReader(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	stmt := "YIELD " + strings.Repeat("1 + ", 100000) + "1"
+	resp, err := session.ExecuteReader(strings.NewReader(stmt))
+	if assert.NoError(t, err) {
+		assert.True(t, resp.IsSucceeded())
+	}
+	assert.Equal(t, []string{stmt}, service.Statements())
+
+	// Nothing is sent if the reader fails midway
+	service.ResetStatements()
+	readErr := errors.New("disk failure")
+	r, w := io.Pipe()
+	go func() {
+		w.Write([]byte("YIELD"))
+		w.CloseWithError(readErr)
+	}()
+	_, err = session.ExecuteReader(r)
+	assert.True(t, errors.Is(err, ErrStatementRead))
+	assert.True(t, errors.Is(err, readErr))
+	assert.Empty(t, service.Statements())
+
+	_, err = session.ExecuteReader(strings.NewReader(""))
+	assert.True(t, errors.Is(err, ErrEmptyStatement))
+	assert.False(t, errors.Is(err, ErrStatementRead))
+}
+
+func TestTrimTrailingSemicolon(t *testing.T) {
+	cases := map[string]string{
+		"YIELD 1":                              "YIELD 1",
+		"YIELD 1;":                             "YIELD 1",
+		"YIELD 1 ; \n":                         "YIELD 1",
+		"YIELD 1;;":                            "YIELD 1;",
+		"YIELD 1; YIELD 2;":                    "YIELD 1; YIELD 2",
+		`YIELD "a;"`:                           `YIELD "a;"`,
+		`YIELD "a;";`:                          `YIELD "a;"`,
+		`YIELD "a\";`:                          `YIELD "a\";`,
+		"YIELD 'a;":                            "YIELD 'a;",
+		"YIELD 1 AS `b;`;":                     "YIELD 1 AS `b;`",
+		"YIELD 1 # a;":                         "YIELD 1 # a;",
+		"YIELD 1 # a;\n;":                      "YIELD 1 # a;",
+		"YIELD 1 /* a; */;":                    "YIELD 1 /* a; */",
+		"YIELD 1 /* a;":                        "YIELD 1 /* a;",
+		" ; ":                                  "",
+		"INSERT VERTEX t(p) VALUES 1:(\"x\");": "INSERT VERTEX t(p) VALUES 1:(\"x\")",
+	}
+	for stmt, expected := range cases {
+		assert.Equal(t, expected, trimTrailingSemicolon(stmt), stmt)
+	}
+}
+
+func TestSession_TrimTrailingSemicolon(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.TrimTrailingSemicolon = true
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	_, err = session.Execute(`YIELD "a;";`)
+	assert.NoError(t, err)
+	_, err = session.ExecuteRaw("YIELD 1;")
+	assert.NoError(t, err)
+	// Nothing is left to send
+	_, err = session.Execute(" ; ")
+	assert.True(t, errors.Is(err, ErrEmptyStatement))
+	assert.Equal(t, []string{`YIELD "a;"`, "YIELD 1;"}, service.Statements())
+}
+
+// A writer failing after n bytes
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		written := w.n
+		w.n = 0
+		return written, fmt.Errorf("client went away")
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestSession_ExecuteJsonTo(t *testing.T) {
+	large := []byte(`{"results":[{"data":[` + strings.Repeat(`{"row":[1]},`, 50000) + `{"row":[1]}]}]}`)
+	service := testutil.NewFakeGraphService()
+	service.ExecuteJsonHandler = func(sessionID int64, stmt string) ([]byte, error) {
+		if stmt == "large" {
+			return large, nil
+		}
+		return []byte(stmt), nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	var buf bytes.Buffer
+	assert.NoError(t, session.ExecuteJsonTo("large", &buf))
+	assert.Equal(t, large, buf.Bytes())
+	buf.Reset()
+	assert.NoError(t, session.ExecuteJsonTo(`{"a":1}`, &buf))
+	assert.Equal(t, `{"a":1}`, buf.String())
+	assert.True(t, errors.Is(session.ExecuteJsonTo(" ", &buf), ErrEmptyStatement))
+
+	// The rest of the response is dropped with the transport if the writer fails
+	err = session.ExecuteJsonTo("large", &failingWriter{n: 1024})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "client went away")
+	}
+	assert.True(t, session.connection.isBroken())
+	buf.Reset()
+	assert.NoError(t, session.ExecuteJsonTo(`{"b":2}`, &buf))
+	assert.Equal(t, `{"b":2}`, buf.String())
+	// ExecuteJson reopens it as well
+	assert.Error(t, session.ExecuteJsonTo("large", &failingWriter{n: 1024}))
+	jsonResp, err := session.ExecuteJson(`{"c":3}`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"c":3}`, string(jsonResp))
+	}
+
+	// The result is decoded as a whole with another protocol
+	conf := GetDefaultConf()
+	conf.Protocol = ProtocolCompact
+	dialed := 0
+	conf.Dialer = pipeDialer(service, ProtocolCompact, TransportBuffered, &dialed)
+	compactPool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compactPool.Close()
+	compactSession, err := compactPool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compactSession.Release()
+	buf.Reset()
+	assert.NoError(t, compactSession.ExecuteJsonTo("large", &buf))
+	assert.Equal(t, large, buf.Bytes())
+}
+
+func TestSession_Validate(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	assert.NoError(t, session.Validate("DROP SPACE nba"))
+	assert.NoError(t, session.Validate(" explain GO FROM 1 OVER follow"))
+	assert.NoError(t, session.Validate("PROFILE FORMAT=\"row\" YIELD 1"))
+	service.QueueErrorCodes(graph.ErrorCode_E_SYNTAX_ERROR)
+	err = session.Validate("YIELD")
+	var execErr *ExecutionError
+	if assert.True(t, errors.As(err, &execErr)) {
+		assert.Equal(t, graph.ErrorCode_E_SYNTAX_ERROR, execErr.ErrorCode)
+	}
+	assert.True(t, errors.Is(session.Validate(" "), ErrEmptyStatement))
+	assert.Equal(t, []string{
+		"EXPLAIN DROP SPACE nba",
+		" explain GO FROM 1 OVER follow",
+		"EXPLAIN FORMAT=\"row\" YIELD 1",
+		"EXPLAIN YIELD",
+	}, service.Statements())
+}
+
+func TestSession_WithRawClient(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp *graph.ExecutionResponse
+	err = session.WithRawClient(func(client *graph.GraphServiceClient, sessionID int64) error {
+		assert.Equal(t, session.sessionID, sessionID)
+		var err error
+		resp, err = client.Execute(sessionID, []byte("YIELD 1"))
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, graph.ErrorCode_SUCCEEDED, resp.GetErrorCode())
+	assert.Equal(t, []string{"YIELD 1"}, service.Statements())
+	// The error of fn is returned as is
+	fnErr := errors.New("unsupported")
+	assert.Equal(t, fnErr, session.WithRawClient(func(*graph.GraphServiceClient, int64) error { return fnErr }))
+
+	session.Release()
+	err = session.WithRawClient(func(*graph.GraphServiceClient, int64) error { return nil })
+	assert.EqualError(t, err, "Failed to call: Session has been released")
+}
@@ -0,0 +1,92 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"sync"
+	"time"
+)
+
+// The max spaces kept by the cache of the valid spaces
+const validSpaceCacheSize = 1024
+
+// The spaces are valid by user, users may be granted different spaces
+type validSpaceKey struct {
+	username string
+	space    string
+}
+
+// The spaces which sessions of the pool have been switched to recently, so SessionPoolConfig.ValidateSpace
+// does not sign in a session to validate them again, see PoolConfig.ValidSpaceCacheTTL
+type validSpaceCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	// The time each space expires at
+	entries map[validSpaceKey]time.Time
+	// Replaced in tests
+	now func() time.Time
+}
+
+func newValidSpaceCache(ttl time.Duration) *validSpaceCache {
+	return &validSpaceCache{ttl: ttl, entries: make(map[validSpaceKey]time.Time), now: time.Now}
+}
+
+// Check if the space is known to be valid for the user
+func (c *validSpaceCache) contains(username, space string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := validSpaceKey{username: username, space: space}
+	expiresAt, ok := c.entries[key]
+	if ok && !c.now().Before(expiresAt) {
+		delete(c.entries, key)
+		return false
+	}
+	return ok
+}
+
+// Record the space as valid for the user, the expired spaces and then the one expiring first are evicted
+// if the cache is full
+func (c *validSpaceCache) put(username, space string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	key := validSpaceKey{username: username, space: space}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= validSpaceCacheSize {
+		var oldest validSpaceKey
+		var oldestAt time.Time
+		for k, expiresAt := range c.entries {
+			if !now.Before(expiresAt) {
+				delete(c.entries, k)
+			} else if oldestAt.IsZero() || expiresAt.Before(oldestAt) {
+				oldest, oldestAt = k, expiresAt
+			}
+		}
+		if len(c.entries) >= validSpaceCacheSize {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[key] = now.Add(c.ttl)
+}
+
+// Forget the space for every user, e.g. once it is not found
+func (c *validSpaceCache) invalidate(space string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.space == space {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Check if a session of the user has been switched to the space recently, always false if the cache is disabled
+func (pool *ConnectionPool) isValidSpace(username, space string) bool {
+	if pool.validSpaces == nil || username == "" || space == "" {
+		return false
+	}
+	return pool.validSpaces.contains(username, space)
+}
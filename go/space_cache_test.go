@@ -0,0 +1,98 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestValidSpaceCache(t *testing.T) {
+	cache := newValidSpaceCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.put("root", "nba")
+	assert.True(t, cache.contains("root", "nba"))
+	// The spaces are valid by user
+	assert.False(t, cache.contains("guest", "nba"))
+
+	now = now.Add(time.Minute)
+	assert.False(t, cache.contains("root", "nba"))
+	assert.Empty(t, cache.entries)
+
+	cache.put("root", "nba")
+	cache.put("guest", "nba")
+	cache.invalidate("nba")
+	assert.Empty(t, cache.entries)
+
+	// The one expiring first is evicted once the cache is full
+	for i := 0; i < validSpaceCacheSize; i++ {
+		now = now.Add(time.Millisecond)
+		cache.put("root", fmt.Sprintf("space%d", i))
+	}
+	cache.put("root", "nba")
+	assert.Len(t, cache.entries, validSpaceCacheSize)
+	assert.False(t, cache.contains("root", "space0"))
+	assert.True(t, cache.contains("root", "space1"))
+	assert.True(t, cache.contains("root", "nba"))
+}
+
+func TestSessionPool_ValidSpaceCache(t *testing.T) {
+	var uses, dropped int32
+	service := testutil.NewFakeGraphService()
+	service.ExecuteHandler = func(sessionID int64, stmt string) (*graph.ExecutionResponse, error) {
+		if stmt != "USE nba" {
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED}, nil
+		}
+		atomic.AddInt32(&uses, 1)
+		if atomic.LoadInt32(&dropped) == 1 {
+			return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR, ErrorMsg: []byte("SpaceNotFound")}, nil
+		}
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SpaceName: []byte("nba")}, nil
+	}
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	connPool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connPool.Close()
+	conf := SessionPoolConfig{Username: "root", Password: "nebula", SpaceName: "nba", ValidateSpace: true}
+
+	first, err := NewSessionPool(connPool, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&uses))
+
+	// The space is not validated again
+	second, err := NewSessionPool(connPool, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&uses))
+	assert.Equal(t, 0, second.getIdleSessionCount())
+
+	// The space is forgotten once it is not found
+	atomic.StoreInt32(&dropped, 1)
+	_, err = second.Execute("YIELD 1")
+	assert.True(t, errors.Is(err, ErrSpaceNotFound))
+	_, err = NewSessionPool(connPool, conf)
+	assert.True(t, errors.Is(err, ErrSpaceNotFound))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&uses))
+}
@@ -0,0 +1,112 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// GetDefaultSSLConfig builds a TLS config from the CA certificate, the client certificate
+// and the client private key files.
+// certPath and privateKeyPath could be empty if graphd does not verify the client.
+func GetDefaultSSLConfig(rootCAPath, certPath, privateKeyPath string) (*tls.Config, error) {
+	sslConfig, err := loadRootCA(rootCAPath)
+	if err != nil {
+		return nil, err
+	}
+	if certPath == "" && privateKeyPath == "" {
+		return sslConfig, nil
+	}
+	cert, err := loadKeyPair(certPath, privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	sslConfig.Certificates = []tls.Certificate{cert}
+	return sslConfig, nil
+}
+
+// GetReloadingSSLConfig is like GetDefaultSSLConfig, but the client certificate and key files are read
+// again by the TLS handshake of a new connection once they are modified, so a renewed certificate is used
+// without recreating the pool. If the new files could not be loaded, e.g. they are being written,
+// the last certificate loaded is used. The CA certificate is read once, see PoolConfig.SslConfigProvider
+// to renew it too.
+func GetReloadingSSLConfig(rootCAPath, certPath, privateKeyPath string) (*tls.Config, error) {
+	sslConfig, err := loadRootCA(rootCAPath)
+	if err != nil {
+		return nil, err
+	}
+	reloader := &certReloader{certPath: certPath, keyPath: privateKeyPath}
+	if _, err := reloader.getCertificate(); err != nil {
+		return nil, err
+	}
+	sslConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return reloader.getCertificate()
+	}
+	return sslConfig, nil
+}
+
+// Loads a key pair again when the modification time of its files changes
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+func (r *certReloader) getCertificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	certInfo, certErr := os.Stat(r.certPath)
+	keyInfo, keyErr := os.Stat(r.keyPath)
+	if r.cert != nil && certErr == nil && keyErr == nil &&
+		certInfo.ModTime().Equal(r.certTime) && keyInfo.ModTime().Equal(r.keyTime) {
+		return r.cert, nil
+	}
+	cert, err := loadKeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert = &cert
+	if certErr == nil && keyErr == nil {
+		r.certTime, r.keyTime = certInfo.ModTime(), keyInfo.ModTime()
+	}
+	return r.cert, nil
+}
+
+// Return a TLS config trusting the CA certificate file
+func loadRootCA(rootCAPath string) (*tls.Config, error) {
+	rootCA, err := ioutil.ReadFile(rootCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CA certificate %s, error: %s", rootCAPath, err.Error())
+	}
+	rootCAPool := x509.NewCertPool()
+	if ok := rootCAPool.AppendCertsFromPEM(rootCA); !ok {
+		return nil, fmt.Errorf("Failed to parse CA certificate %s", rootCAPath)
+	}
+	return &tls.Config{RootCAs: rootCAPool}, nil
+}
+
+func loadKeyPair(certPath, privateKeyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, privateKeyPath)
+	if err != nil {
+		return cert, fmt.Errorf("Failed to load client certificate %s and key %s, error: %s",
+			certPath, privateKeyPath, err.Error())
+	}
+	return cert, nil
+}
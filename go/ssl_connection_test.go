@@ -0,0 +1,234 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Generate a self-signed certificate for 127.0.0.1 and write it into dir
+func writeSelfSignedCert(t *testing.T, dir string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key, %s", err.Error())
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nebula-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate, %s", err.Error())
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key, %s", err.Error())
+	}
+	certPath = filepath.Join(dir, "test.crt")
+	keyPath = filepath.Join(dir, "test.key")
+	ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certPath, keyPath
+}
+
+func TestSslConnection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nebula-ssl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeSelfSignedCert(t, dir)
+
+	serverCert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// Finish the handshake and keep the connection open
+			conn.(*tls.Conn).Handshake()
+			defer conn.Close()
+		}
+	}()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	sslConfig, err := GetDefaultSSLConfig(certPath, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := GetDefaultConf()
+	conf.TimeOut = time.Second
+	conf.SslConfig = sslConfig
+	conn := newConnection(HostAddress{Host: "127.0.0.1", Port: port})
+	err = conn.open(conn.severAddress, conf)
+	if assert.NoError(t, err) {
+		assert.True(t, conn.graph.Transport.IsOpen())
+		conn.close()
+	}
+
+	// A CA that did not sign the server certificate must fail the handshake
+	otherDir, err := ioutil.TempDir("", "nebula-ssl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(otherDir)
+	otherCertPath, _ := writeSelfSignedCert(t, otherDir)
+	badConfig, err := GetDefaultSSLConfig(otherCertPath, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = newConnection(HostAddress{Host: "127.0.0.1", Port: port})
+	conf.SslConfig = badConfig
+	err = conn.open(conn.severAddress, conf)
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "TLS"))
+	}
+}
+
+func TestSslConnection_Rotation(t *testing.T) {
+	serverDir, err := ioutil.TempDir("", "nebula-ssl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(serverDir)
+	clientDir, err := ioutil.TempDir("", "nebula-ssl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(clientDir)
+	serverCertPath, serverKeyPath := writeSelfSignedCert(t, serverDir)
+	certPath, keyPath := writeSelfSignedCert(t, clientDir)
+
+	serverCert, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The certificate every client connects with
+	peers := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				peers <- tlsConn.ConnectionState().PeerCertificates[0].Raw
+			}
+		}
+	}()
+	host := HostAddress{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port}
+	loadedCert := func() []byte {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Certificate[0]
+	}
+
+	sslConfig, err := GetReloadingSSLConfig(serverCertPath, certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := GetDefaultConf()
+	conf.TimeOut = time.Second
+	conf.SslConfig = sslConfig
+	first := newConnection(host)
+	if err := first.open(host, conf); err != nil {
+		t.Fatal(err)
+	}
+	defer first.close()
+	oldCert := loadedCert()
+	assert.Equal(t, oldCert, <-peers)
+
+	// The certificate is renewed, the new connection uses it and the first one stays open
+	writeSelfSignedCert(t, clientDir)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(certPath, later, later)
+	os.Chtimes(keyPath, later, later)
+	second := newConnection(host)
+	if err := second.open(host, conf); err != nil {
+		t.Fatal(err)
+	}
+	defer second.close()
+	newCert := loadedCert()
+	assert.NotEqual(t, oldCert, newCert)
+	assert.Equal(t, newCert, <-peers)
+	assert.True(t, first.graph.Transport.IsOpen())
+
+	// A broken file keeps the last certificate
+	ioutil.WriteFile(keyPath, []byte("being written"), 0600)
+	third := newConnection(host)
+	if err := third.open(host, conf); err != nil {
+		t.Fatal(err)
+	}
+	third.close()
+	assert.Equal(t, newCert, <-peers)
+
+	// The provider is called for every connection
+	calls := 0
+	conf.SslConfigProvider = func() (*tls.Config, error) {
+		calls++
+		if calls > 1 {
+			return nil, fmt.Errorf("vault is down")
+		}
+		return sslConfig, nil
+	}
+	conn := newConnection(host)
+	if err := conn.open(host, conf); err != nil {
+		t.Fatal(err)
+	}
+	conn.close()
+	<-peers
+	err = newConnection(host).open(host, conf)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Failed to get the TLS config, error: vault is down")
+	}
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,55 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package testutil_test
+
+import (
+	"fmt"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func Example() {
+	service := testutil.NewFakeGraphService()
+	server, err := testutil.StartServer(service)
+	if err != nil {
+		panic(err)
+	}
+	defer server.Stop()
+
+	pool, err := nebula.NewConnectionPool(
+		[]nebula.HostAddress{{Host: server.Host, Port: server.Port}}, nebula.GetDefaultConf(), nil)
+	if err != nil {
+		panic(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		panic(err)
+	}
+	defer session.Release()
+
+	// Script a failure of the next query
+	service.QueueErrorCodes(graph.ErrorCode_E_SYNTAX_ERROR)
+	resp, err := session.Execute("YIELD")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.GetErrorCode())
+
+	resp, err = session.Execute("USE nba")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.GetSpaceName())
+	fmt.Println(service.Statements())
+	// Output:
+	// E_SYNTAX_ERROR
+	// nba
+	// [YIELD USE nba]
+}
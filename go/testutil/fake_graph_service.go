@@ -0,0 +1,202 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+// Package testutil provides an in-memory graph service, so code using the client could be tested
+// without a Nebula cluster.
+package testutil
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift"
+
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+// FakeGraphService is an in-memory graph service accepting root/nebula, knowing the space nba by default,
+// and answering every other statement with an empty result.
+// The default behaviors could be replaced by setting the handlers before the server is started.
+type FakeGraphService struct {
+	// Answer Authenticate instead of the default behavior if it is not nil
+	AuthenticateHandler func(username, password string) *graph.AuthResponse
+	// Answer Execute instead of the default behavior if it is not nil, the statement is recorded anyway
+	ExecuteHandler func(sessionID int64, stmt string) (*graph.ExecutionResponse, error)
+	// Answer ExecuteJson instead of the empty object if it is not nil
+	ExecuteJsonHandler func(sessionID int64, stmt string) ([]byte, error)
+	// Called on every Signout after the session is removed
+	SignoutHandler func(sessionID int64)
+	// The spaces USE switches to, only nba if it is empty
+	Spaces []string
+
+	mu            sync.Mutex
+	nextSessionID int64
+	// Current space of every signed in session
+	sessions map[int64]string
+	stmts    []string
+	// Number of Signout calls
+	signouts int
+	// Error codes returned by the next queries, one per query
+	errorCodes []graph.ErrorCode
+}
+
+// NewFakeGraphService creates a service without any session, session IDs are given out from 100
+func NewFakeGraphService() *FakeGraphService {
+	return &FakeGraphService{nextSessionID: 100, sessions: make(map[int64]string)}
+}
+
+func (s *FakeGraphService) Authenticate(username []byte, password []byte) (*graph.AuthResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.AuthenticateHandler != nil {
+		resp := s.AuthenticateHandler(string(username), string(password))
+		if resp.GetErrorCode() == graph.ErrorCode_SUCCEEDED {
+			s.sessions[resp.GetSessionID()] = ""
+		}
+		return resp, nil
+	}
+	if string(username) != "root" || string(password) != "nebula" {
+		return &graph.AuthResponse{
+			ErrorCode: graph.ErrorCode_E_BAD_USERNAME_PASSWORD,
+			ErrorMsg:  []byte("Bad username/password"),
+		}, nil
+	}
+	sessionID := s.nextSessionID
+	s.nextSessionID++
+	s.sessions[sessionID] = ""
+	return &graph.AuthResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SessionID: &sessionID}, nil
+}
+
+func (s *FakeGraphService) Signout(sessionID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signouts++
+	delete(s.sessions, sessionID)
+	if s.SignoutHandler != nil {
+		s.SignoutHandler(sessionID)
+	}
+	return nil
+}
+
+func (s *FakeGraphService) Execute(sessionID int64, stmt []byte) (*graph.ExecutionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	space, ok := s.sessions[sessionID]
+	if !ok {
+		return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_E_SESSION_INVALID}, nil
+	}
+	s.stmts = append(s.stmts, string(stmt))
+	if len(s.errorCodes) > 0 {
+		code := s.errorCodes[0]
+		s.errorCodes = s.errorCodes[1:]
+		return &graph.ExecutionResponse{ErrorCode: code, ErrorMsg: []byte(code.String())}, nil
+	}
+	if s.ExecuteHandler != nil {
+		return s.ExecuteHandler(sessionID, string(stmt))
+	}
+	if fields := strings.Fields(string(stmt)); len(fields) == 2 && strings.ToUpper(fields[0]) == "USE" {
+		if !s.hasSpace(fields[1]) {
+			return &graph.ExecutionResponse{
+				ErrorCode: graph.ErrorCode_E_EXECUTION_ERROR,
+				ErrorMsg:  []byte("SpaceNotFound"),
+			}, nil
+		}
+		space = fields[1]
+		s.sessions[sessionID] = space
+	}
+	return &graph.ExecutionResponse{ErrorCode: graph.ErrorCode_SUCCEEDED, SpaceName: []byte(space)}, nil
+}
+
+// Whether USE could switch to the space, must be called with the lock held
+func (s *FakeGraphService) hasSpace(space string) bool {
+	if len(s.Spaces) == 0 {
+		return space == "nba"
+	}
+	for _, known := range s.Spaces {
+		if known == space {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FakeGraphService) ExecuteJson(sessionID int64, stmt []byte) ([]byte, error) {
+	if s.ExecuteJsonHandler != nil {
+		return s.ExecuteJsonHandler(sessionID, string(stmt))
+	}
+	return []byte("{}"), nil
+}
+
+// QueueErrorCodes makes the next queries fail with the given error codes, one per query
+func (s *FakeGraphService) QueueErrorCodes(codes ...graph.ErrorCode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorCodes = append(s.errorCodes, codes...)
+}
+
+// Statements returns the statements executed by the signed in sessions, in order
+func (s *FakeGraphService) Statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.stmts...)
+}
+
+// ResetStatements forgets the executed statements
+func (s *FakeGraphService) ResetStatements() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stmts = nil
+}
+
+// ExpireSessions drops all sessions as graphd does when they stay idle too long,
+// the next queries of them are answered with E_SESSION_INVALID
+func (s *FakeGraphService) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[int64]string)
+}
+
+// SignoutCount returns the number of Signout calls.
+// Signout is a oneway RPC, so the count may lag behind Session.Release.
+func (s *FakeGraphService) SignoutCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signouts
+}
+
+// SessionCount returns the number of signed in sessions
+func (s *FakeGraphService) SessionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Server serves a graph service on a local port
+type Server struct {
+	Host   string
+	Port   int
+	server *thrift.SimpleServer
+}
+
+// StartServer serves the handler with the binary protocol on a random local port until Stop is called
+func StartServer(handler graph.GraphService) (*Server, error) {
+	sock, err := thrift.NewServerSocket("127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := thrift.NewSimpleServer(graph.NewGraphServiceProcessor(handler), sock)
+	if err := server.Listen(); err != nil {
+		return nil, err
+	}
+	go server.AcceptLoop()
+	return &Server{Host: "127.0.0.1", Port: sock.Addr().(*net.TCPAddr).Port, server: server}, nil
+}
+
+// Stop closes the listener, the connections being served are closed once their clients disconnect
+func (s *Server) Stop() {
+	s.server.Stop()
+}
@@ -0,0 +1,111 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+type traceIDKey struct{}
+
+// WithTraceID returns a context carrying the trace ID. Session.ExecuteWithContext puts it in front of
+// the statement as the comment /* traceID=<id> */, so the query could be found in the logs of graphd.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID set by WithTraceID, empty if there is none
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// Prefix the statement with the trace ID of ctx, the statement is returned as is if there is none
+func tagStatement(ctx context.Context, stmt string) string {
+	traceID := TraceIDFromContext(ctx)
+	if traceID == "" {
+		return stmt
+	}
+	// The comment must not be closed by the trace ID
+	traceID = strings.Replace(traceID, "*/", "* /", -1)
+	return "/* traceID=" + traceID + " */ " + stmt
+}
+
+type statementMetadataKey struct{}
+
+type noStatementCommentKey struct{}
+
+// WithStatementMetadata returns a context carrying metadata, e.g. {"requestID": id}, which is sent in a comment
+// in front of the statements executed with it, with the one of PoolConfig.StatementComment. It is merged with
+// the metadata ctx carries already, the given values win on the same key.
+func WithStatementMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	merged := make(map[string]string)
+	for key, value := range statementMetadata(ctx) {
+		merged[key] = value
+	}
+	for key, value := range metadata {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, statementMetadataKey{}, merged)
+}
+
+// Return the metadata set by WithStatementMetadata, nil if there is none
+func statementMetadata(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(statementMetadataKey{}).(map[string]string)
+	return metadata
+}
+
+// WithoutStatementComment returns a context leaving out every comment in front of the statements executed with it,
+// the one of PoolConfig.StatementComment and the trace ID of WithTraceID, e.g. for a statement the comments break
+func WithoutStatementComment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noStatementCommentKey{}, true)
+}
+
+// Prefix the statement with the trace ID and the comment of PoolConfig.StatementComment,
+// unless ctx is set by WithoutStatementComment
+func (session *Session) commentStatement(ctx context.Context, stmt string) string {
+	if disabled, _ := ctx.Value(noStatementCommentKey{}).(bool); disabled {
+		return stmt
+	}
+	stmt = tagStatement(ctx, stmt)
+	poolMetadata := session.connPool.conf.StatementComment
+	callMetadata := statementMetadata(ctx)
+	if len(poolMetadata) == 0 && len(callMetadata) == 0 {
+		return stmt
+	}
+	metadata := make(map[string]string, len(poolMetadata)+len(callMetadata))
+	for key, value := range poolMetadata {
+		metadata[key] = value
+	}
+	for key, value := range callMetadata {
+		metadata[key] = value
+	}
+	format := session.connPool.conf.StatementCommentFormat
+	if format == nil {
+		format = formatStatementComment
+	}
+	return format(metadata) + " " + stmt
+}
+
+// Render the metadata as /* key=value key=value */ sorted by key, the comment must not be closed by the metadata
+func formatStatementComment(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("/*")
+	for _, key := range keys {
+		b.WriteString(" ")
+		b.WriteString(strings.Replace(key+"="+metadata[key], "*/", "* /", -1))
+	}
+	b.WriteString(" */")
+	return b.String()
+}
@@ -0,0 +1,106 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vesoft-inc/nebula-clients/go/testutil"
+)
+
+func TestTagStatement(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "YIELD 1", tagStatement(ctx, "YIELD 1"))
+	assert.Equal(t, "", TraceIDFromContext(ctx))
+
+	ctx = WithTraceID(ctx, "abc")
+	assert.Equal(t, "abc", TraceIDFromContext(ctx))
+	assert.Equal(t, "/* traceID=abc */ YIELD 1", tagStatement(ctx, "YIELD 1"))
+	assert.Equal(t, "/* traceID=a* /b */ YIELD 1", tagStatement(WithTraceID(ctx, "a*/b"), "YIELD 1"))
+}
+
+func TestSession_TraceID(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	pool, err := NewConnectionPool([]HostAddress{host}, GetDefaultConf(), nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	_, err = session.ExecuteWithContext(WithTraceID(context.Background(), "4bf92f35"), "YIELD 1")
+	assert.NoError(t, err)
+	_, err = session.Execute("YIELD 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/* traceID=4bf92f35 */ YIELD 1", "YIELD 2"}, service.Statements())
+}
+
+func TestSession_StatementComment(t *testing.T) {
+	service := testutil.NewFakeGraphService()
+	stop, host := startFakeServer(t, service)
+	defer stop()
+	conf := GetDefaultConf()
+	conf.StatementComment = map[string]string{"app": "billing", "version": "1.4.2"}
+	pool, err := NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err := pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+
+	ctx := WithStatementMetadata(context.Background(), map[string]string{"requestID": "r1", "version": "*/2"})
+	for _, execCtx := range []context.Context{
+		context.Background(),
+		WithTraceID(ctx, "abc"),
+		WithStatementMetadata(ctx, map[string]string{"requestID": "r2"}),
+		WithoutStatementComment(WithTraceID(ctx, "abc")),
+	} {
+		_, err = session.ExecuteWithContext(execCtx, "YIELD 1")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{
+		"/* app=billing version=1.4.2 */ YIELD 1",
+		"/* app=billing requestID=r1 version=* /2 */ /* traceID=abc */ YIELD 1",
+		"/* app=billing requestID=r2 version=* /2 */ YIELD 1",
+		"YIELD 1",
+	}, service.Statements())
+
+	// The format of the comment, the metadata of a call is sent without StatementComment as well
+	conf = GetDefaultConf()
+	conf.StatementCommentFormat = func(metadata map[string]string) string {
+		return "/* request_id: " + metadata["requestID"] + " */"
+	}
+	pool, err = NewConnectionPool([]HostAddress{host}, conf, nebulaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	session, err = pool.GetSession("root", "nebula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Release()
+	service.ResetStatements()
+	_, err = session.Execute("YIELD 1")
+	assert.NoError(t, err)
+	_, err = session.ExecuteWithContext(ctx, "YIELD 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"YIELD 1", "/* request_id: r1 */ YIELD 2"}, service.Statements())
+}
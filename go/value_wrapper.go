@@ -0,0 +1,332 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// ValueWrapper wraps a nebula value and provides typed accessors
+type ValueWrapper struct {
+	value *nebula.Value
+	// The VID type of the space, used to decode the IDs of vertices
+	vidType VIDType
+	// How AsString handles invalid UTF-8, see PoolConfig.UTF8Policy
+	utf8Policy UTF8Policy
+}
+
+// UTF8Policy tells how ValueWrapper.AsString handles a string value which is not valid UTF-8,
+// e.g. binary data stored as a string. AsBytes always returns the raw bytes.
+type UTF8Policy int
+
+const (
+	// UTF8Lenient returns the bytes as they are, the string may hold invalid UTF-8
+	UTF8Lenient UTF8Policy = iota
+	// UTF8Strict returns an error telling the offset of the first invalid byte
+	UTF8Strict
+	// UTF8Replace replaces every run of invalid bytes with the replacement character U+FFFD
+	UTF8Replace
+)
+
+func (policy UTF8Policy) String() string {
+	switch policy {
+	case UTF8Lenient:
+		return "lenient"
+	case UTF8Strict:
+		return "strict"
+	case UTF8Replace:
+		return "replace"
+	default:
+		return fmt.Sprintf("UTF8Policy(%d)", int(policy))
+	}
+}
+
+// Convert the bytes of a string value to a string by the policy
+func (policy UTF8Policy) toString(b []byte) (string, error) {
+	if policy == UTF8Lenient || utf8.Valid(b) {
+		return string(b), nil
+	}
+	if policy == UTF8Replace {
+		return strings.ToValidUTF8(string(b), string(utf8.RuneError)), nil
+	}
+	offset := 0
+	for offset < len(b) {
+		r, size := utf8.DecodeRune(b[offset:])
+		if r == utf8.RuneError && size <= 1 {
+			break
+		}
+		offset += size
+	}
+	return "", fmt.Errorf("Failed to convert value string to string, it is not valid UTF-8 at byte %d, use AsBytes to read the raw bytes", offset)
+}
+
+func newValueWrapper(value *nebula.Value) *ValueWrapper {
+	return &ValueWrapper{value: value}
+}
+
+func (valWrap ValueWrapper) IsEmpty() bool {
+	return valWrap.GetType() == "empty"
+}
+
+func (valWrap ValueWrapper) IsNull() bool {
+	return valWrap.value.IsSetNVal()
+}
+
+func (valWrap ValueWrapper) IsBool() bool {
+	return valWrap.value.IsSetBVal()
+}
+
+func (valWrap ValueWrapper) IsInt() bool {
+	return valWrap.value.IsSetIVal()
+}
+
+func (valWrap ValueWrapper) IsFloat() bool {
+	return valWrap.value.IsSetFVal()
+}
+
+func (valWrap ValueWrapper) IsString() bool {
+	return valWrap.value.IsSetSVal()
+}
+
+func (valWrap ValueWrapper) IsDate() bool {
+	return valWrap.value.IsSetDVal()
+}
+
+func (valWrap ValueWrapper) IsTime() bool {
+	return valWrap.value.IsSetTVal()
+}
+
+func (valWrap ValueWrapper) IsDateTime() bool {
+	return valWrap.value.IsSetDtVal()
+}
+
+func (valWrap ValueWrapper) IsVertex() bool {
+	return valWrap.value.IsSetVVal()
+}
+
+func (valWrap ValueWrapper) IsEdge() bool {
+	return valWrap.value.IsSetEVal()
+}
+
+func (valWrap ValueWrapper) IsPath() bool {
+	return valWrap.value.IsSetPVal()
+}
+
+func (valWrap ValueWrapper) IsList() bool {
+	return valWrap.value.IsSetLVal()
+}
+
+func (valWrap ValueWrapper) IsSet() bool {
+	return valWrap.value.IsSetUVal()
+}
+
+func (valWrap ValueWrapper) IsMap() bool {
+	return valWrap.value.IsSetMVal()
+}
+
+// Return the value as a bool, an error is returned if the value is not a bool
+func (valWrap ValueWrapper) AsBool() (bool, error) {
+	if valWrap.value.IsSetBVal() {
+		return valWrap.value.GetBVal(), nil
+	}
+	return false, fmt.Errorf("Failed to convert value %s to bool", valWrap.GetType())
+}
+
+// Return the value as an int64, an error is returned if the value is not an int
+func (valWrap ValueWrapper) AsInt() (int64, error) {
+	if valWrap.value.IsSetIVal() {
+		return valWrap.value.GetIVal(), nil
+	}
+	return -1, fmt.Errorf("Failed to convert value %s to int", valWrap.GetType())
+}
+
+// Return the value as a float64, an error is returned if the value is not a float
+func (valWrap ValueWrapper) AsFloat() (float64, error) {
+	if valWrap.value.IsSetFVal() {
+		return valWrap.value.GetFVal(), nil
+	}
+	return -1, fmt.Errorf("Failed to convert value %s to float", valWrap.GetType())
+}
+
+// Return the value as a string, an error is returned if the value is not a string.
+// A string which is not valid UTF-8 is handled by PoolConfig.UTF8Policy, see AsBytes for the raw bytes.
+func (valWrap ValueWrapper) AsString() (string, error) {
+	if valWrap.value.IsSetSVal() {
+		return valWrap.utf8Policy.toString(valWrap.value.GetSVal())
+	}
+	return "", fmt.Errorf("Failed to convert value %s to string", valWrap.GetType())
+}
+
+// Return a copy of the bytes of the value, an error is returned if the value is not a string
+func (valWrap ValueWrapper) AsBytes() ([]byte, error) {
+	if valWrap.value.IsSetSVal() {
+		return append([]byte(nil), valWrap.value.GetSVal()...), nil
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to bytes", valWrap.GetType())
+}
+
+// Temporal values are stored and returned by graphd in UTC without a time zone,
+// so the accessors below return time.Time in UTC. Use t.In(loc) to show them in a local time zone,
+// instead of re-interpreting the fields as local time.
+
+// Return the value as a time.Time at midnight UTC of the date, an error is returned if the value is not a date
+func (valWrap ValueWrapper) AsDate() (time.Time, error) {
+	if valWrap.value.IsSetDVal() {
+		d := valWrap.value.GetDVal()
+		return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("Failed to convert value %s to date", valWrap.GetType())
+}
+
+// Return the value as a time.Time on January 1, year 0 UTC, an error is returned if the value is not a time
+func (valWrap ValueWrapper) AsTime() (time.Time, error) {
+	if valWrap.value.IsSetTVal() {
+		t := valWrap.value.GetTVal()
+		return time.Date(0, time.January, 1, int(t.Hour), int(t.Minute), int(t.Sec),
+			int(t.Microsec)*int(time.Microsecond), time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("Failed to convert value %s to time", valWrap.GetType())
+}
+
+// Return the value as a time.Time in UTC, an error is returned if the value is not a datetime
+func (valWrap ValueWrapper) AsDateTime() (time.Time, error) {
+	if valWrap.value.IsSetDtVal() {
+		dt := valWrap.value.GetDtVal()
+		return time.Date(int(dt.Year), time.Month(dt.Month), int(dt.Day), int(dt.Hour), int(dt.Minute), int(dt.Sec),
+			int(dt.Microsec)*int(time.Microsecond), time.UTC), nil
+	}
+	return time.Time{}, fmt.Errorf("Failed to convert value %s to datetime", valWrap.GetType())
+}
+
+// Return the value as a Node, an error is returned if the value is not a vertex
+func (valWrap ValueWrapper) AsNode() (*Node, error) {
+	if valWrap.value.IsSetVVal() {
+		return genNode(valWrap.value.GetVVal(), valWrap.vidType, valWrap.utf8Policy)
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to Node", valWrap.GetType())
+}
+
+// Return the value as a Relationship, an error is returned if the value is not an edge
+func (valWrap ValueWrapper) AsRelationship() (*Relationship, error) {
+	if valWrap.value.IsSetEVal() {
+		return genRelationship(valWrap.value.GetEVal(), valWrap.vidType, valWrap.utf8Policy)
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to Relationship", valWrap.GetType())
+}
+
+// Return the value as a PathWrapper, an error is returned if the value is not a path
+func (valWrap ValueWrapper) AsPath() (*PathWrapper, error) {
+	if valWrap.value.IsSetPVal() {
+		return genPathWrapper(valWrap.value.GetPVal(), valWrap.vidType, valWrap.utf8Policy)
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to PathWrapper", valWrap.GetType())
+}
+
+// Return the elements of the list in order, an error is returned if the value is not a list
+func (valWrap ValueWrapper) AsList() ([]ValueWrapper, error) {
+	if valWrap.value.IsSetLVal() {
+		return wrapValues(valWrap.value.GetLVal().GetValues(), valWrap.vidType, valWrap.utf8Policy), nil
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to list", valWrap.GetType())
+}
+
+// Return the elements of the set, an error is returned if the value is not a set.
+// Sets are unordered in nebula, the order of the elements should not be relied on.
+func (valWrap ValueWrapper) AsSet() ([]ValueWrapper, error) {
+	if valWrap.value.IsSetUVal() {
+		return wrapValues(valWrap.value.GetUVal().GetValues(), valWrap.vidType, valWrap.utf8Policy), nil
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to set", valWrap.GetType())
+}
+
+// Return the entries of the map, an error is returned if the value is not a map
+func (valWrap ValueWrapper) AsMap() (map[string]ValueWrapper, error) {
+	if valWrap.value.IsSetMVal() {
+		kvs := valWrap.value.GetMVal().GetKvs()
+		result := make(map[string]ValueWrapper, len(kvs))
+		for key, value := range kvs {
+			result[key] = ValueWrapper{value: value, vidType: valWrap.vidType, utf8Policy: valWrap.utf8Policy}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("Failed to convert value %s to map", valWrap.GetType())
+}
+
+// Return the value as a geography in WKT, e.g. POINT(1 2).
+// The Value of this protocol version has no geography member, graphd of this version could not return one,
+// so an error matching ErrUnsupportedType is always returned.
+func (valWrap ValueWrapper) AsGeography() (string, error) {
+	return "", fmt.Errorf("Failed to convert value %s to geography: %w", valWrap.GetType(), ErrUnsupportedType)
+}
+
+// Return the type of the value in nebula
+func (valWrap ValueWrapper) GetType() string {
+	value := valWrap.value
+	switch {
+	case value == nil:
+		return "empty"
+	case value.IsSetNVal():
+		return "null"
+	case value.IsSetBVal():
+		return "bool"
+	case value.IsSetIVal():
+		return "int"
+	case value.IsSetFVal():
+		return "float"
+	case value.IsSetSVal():
+		return "string"
+	case value.IsSetDVal():
+		return "date"
+	case value.IsSetTVal():
+		return "time"
+	case value.IsSetDtVal():
+		return "datetime"
+	case value.IsSetVVal():
+		return "vertex"
+	case value.IsSetEVal():
+		return "edge"
+	case value.IsSetPVal():
+		return "path"
+	case value.IsSetLVal():
+		return "list"
+	case value.IsSetMVal():
+		return "map"
+	case value.IsSetUVal():
+		return "set"
+	case value.IsSetGVal():
+		return "dataset"
+	default:
+		return "empty"
+	}
+}
+
+// Return a readable form of the value
+func (valWrap ValueWrapper) String() string {
+	value := valWrap.value
+	switch {
+	case value == nil:
+		return ""
+	case value.IsSetNVal():
+		return value.GetNVal().String()
+	case value.IsSetBVal():
+		return strconv.FormatBool(value.GetBVal())
+	case value.IsSetIVal():
+		return strconv.FormatInt(value.GetIVal(), 10)
+	case value.IsSetFVal():
+		return strconv.FormatFloat(value.GetFVal(), 'g', -1, 64)
+	case value.IsSetSVal():
+		return string(value.GetSVal())
+	default:
+		return value.String()
+	}
+}
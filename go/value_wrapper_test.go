@@ -0,0 +1,221 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+	graph "github.com/vesoft-inc/nebula-clients/go/nebula/graph"
+)
+
+func TestValueWrapper_Primitive(t *testing.T) {
+	b := true
+	f := 1.5
+	null := nebula.NullType___NULL__
+
+	boolWrap := newValueWrapper(&nebula.Value{BVal: &b})
+	val, err := boolWrap.AsBool()
+	assert.NoError(t, err)
+	assert.True(t, val)
+	_, err = boolWrap.AsInt()
+	assert.EqualError(t, err, "Failed to convert value bool to int")
+
+	intWrap := newValueWrapper(intValue(7))
+	i, err := intWrap.AsInt()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), i)
+	_, err = intWrap.AsFloat()
+	assert.Error(t, err)
+
+	floatWrap := newValueWrapper(&nebula.Value{FVal: &f})
+	fl, err := floatWrap.AsFloat()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, fl)
+	_, err = floatWrap.AsString()
+	assert.EqualError(t, err, "Failed to convert value float to string")
+
+	strWrap := newValueWrapper(strValue("Bob"))
+	s, err := strWrap.AsString()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob", s)
+	_, err = strWrap.AsBool()
+	assert.Error(t, err)
+
+	// Binary data is not mangled by the string conversion
+	binary := []byte{0xff, 0xfe, 0x00, 'a', 0xc3}
+	value, err := toValue(binary)
+	assert.NoError(t, err)
+	binWrap := newValueWrapper(value)
+	raw, err := binWrap.AsBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, binary, raw)
+	s, err = binWrap.AsString()
+	assert.NoError(t, err)
+	assert.Equal(t, string(binary), s)
+	raw[0] = 0
+	assert.Equal(t, byte(0xff), value.GetSVal()[0])
+	_, err = floatWrap.AsBytes()
+	assert.EqualError(t, err, "Failed to convert value float to bytes")
+
+	nullWrap := newValueWrapper(&nebula.Value{NVal: &null})
+	assert.True(t, nullWrap.IsNull())
+	assert.False(t, strWrap.IsNull())
+	assert.Equal(t, "null", nullWrap.GetType())
+
+	// No value of this protocol version is a geography
+	_, err = strWrap.AsGeography()
+	assert.True(t, errors.Is(err, ErrUnsupportedType))
+	assert.EqualError(t, err, "Failed to convert value string to geography: Unsupported type")
+}
+
+func TestValueWrapper_Temporal(t *testing.T) {
+	dateWrap := newValueWrapper(&nebula.Value{DVal: &nebula.Date{Year: 2020, Month: 12, Day: 31}})
+	assert.True(t, dateWrap.IsDate())
+	date, err := dateWrap.AsDate()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC), date)
+	_, err = dateWrap.AsDateTime()
+	assert.EqualError(t, err, "Failed to convert value date to datetime")
+
+	timeWrap := newValueWrapper(&nebula.Value{TVal: &nebula.Time{Hour: 13, Minute: 4, Sec: 5, Microsec: 6}})
+	tm, err := timeWrap.AsTime()
+	assert.NoError(t, err)
+	assert.Equal(t, "13:04:05.000006", tm.Format("15:04:05.000000"))
+
+	dtWrap := newValueWrapper(&nebula.Value{DtVal: &nebula.DateTime{
+		Year: 2020, Month: 2, Day: 29, Hour: 23, Minute: 59, Sec: 59, Microsec: 1000,
+	}})
+	dt, err := dtWrap.AsDateTime()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 2, 29, 23, 59, 59, int(time.Millisecond), time.UTC), dt)
+	assert.Equal(t, time.UTC, dt.Location())
+	_, err = dtWrap.AsDate()
+	assert.Error(t, err)
+}
+
+func TestValueWrapper_Collections(t *testing.T) {
+	// [{name: "Tim", age: 42}, {name: "Tony"}]
+	list := &nebula.Value{LVal: &nebula.List{Values: []*nebula.Value{
+		{MVal: &nebula.Map{Kvs: map[string]*nebula.Value{"name": strValue("Tim"), "age": intValue(42)}}},
+		{MVal: &nebula.Map{Kvs: map[string]*nebula.Value{"name": strValue("Tony")}}},
+	}}}
+	listWrap := newValueWrapper(list)
+	assert.True(t, listWrap.IsList())
+	elems, err := listWrap.AsList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, elem := range elems {
+		assert.True(t, elem.IsMap())
+		m, err := elem.AsMap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, err := m["name"].AsString()
+		assert.NoError(t, err)
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Tim", "Tony"}, names)
+	m, err := elems[0].AsMap()
+	assert.NoError(t, err)
+	age, err := m["age"].AsInt()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), age)
+
+	set := newValueWrapper(&nebula.Value{UVal: &nebula.Set{Values: []*nebula.Value{intValue(1), intValue(2)}}})
+	assert.True(t, set.IsSet())
+	elems, err = set.AsSet()
+	assert.NoError(t, err)
+	var ints []int64
+	for _, elem := range elems {
+		i, err := elem.AsInt()
+		assert.NoError(t, err)
+		ints = append(ints, i)
+	}
+	assert.ElementsMatch(t, []int64{1, 2}, ints)
+
+	_, err = set.AsList()
+	assert.EqualError(t, err, "Failed to convert value set to list")
+	_, err = listWrap.AsSet()
+	assert.EqualError(t, err, "Failed to convert value list to set")
+	_, err = listWrap.AsMap()
+	assert.EqualError(t, err, "Failed to convert value list to map")
+}
+
+func TestValueWrapper_UTF8Policy(t *testing.T) {
+	invalid := &nebula.Value{SVal: []byte("ab\xffc\xfe\xfd")}
+
+	// Lenient by default
+	s, err := newValueWrapper(invalid).AsString()
+	assert.NoError(t, err)
+	assert.Equal(t, "ab\xffc\xfe\xfd", s)
+
+	_, err = ValueWrapper{value: invalid, utf8Policy: UTF8Strict}.AsString()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not valid UTF-8 at byte 2")
+	}
+	s, err = ValueWrapper{value: invalid, utf8Policy: UTF8Replace}.AsString()
+	assert.NoError(t, err)
+	assert.Equal(t, "ab�c�", s)
+	// The raw bytes are always available, and valid strings are not changed
+	b, err := ValueWrapper{value: invalid, utf8Policy: UTF8Strict}.AsBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ab\xffc\xfe\xfd"), b)
+	s, err = ValueWrapper{value: strValue("héllo"), utf8Policy: UTF8Strict}.AsString()
+	assert.NoError(t, err)
+	assert.Equal(t, "héllo", s)
+
+	// The policy is kept by the nested values and the results
+	list := &nebula.Value{LVal: &nebula.List{Values: []*nebula.Value{invalid}}}
+	elems, err := ValueWrapper{value: list, utf8Policy: UTF8Strict}.AsList()
+	if assert.NoError(t, err) {
+		_, err = elems[0].AsString()
+		assert.Error(t, err)
+	}
+	resultSet := newResultSet(&graph.ExecutionResponse{
+		ErrorCode: graph.ErrorCode_SUCCEEDED,
+		Data: &nebula.DataSet{
+			ColumnNames: [][]byte{[]byte("name")},
+			Rows:        []*nebula.Row{{Values: []*nebula.Value{invalid}}},
+		},
+	})
+	resultSet.utf8Policy = UTF8Strict
+	record, err := resultSet.GetRowValuesByIndex(0)
+	if assert.NoError(t, err) {
+		value, err := record.GetValueByColName("name")
+		assert.NoError(t, err)
+		_, err = value.AsString()
+		assert.Error(t, err)
+	}
+	_, err = resultSet.AsMaps()
+	assert.Error(t, err)
+	var rows []struct {
+		Name string `nebula:"name"`
+	}
+	assert.Error(t, resultSet.Scan(&rows))
+	selected, err := resultSet.Select("name")
+	if assert.NoError(t, err) {
+		assert.Equal(t, UTF8Strict, selected.utf8Policy)
+	}
+	_, err = json.Marshal(resultSet)
+	assert.Error(t, err)
+	resultSet.utf8Policy = UTF8Replace
+	if assert.NoError(t, resultSet.Scan(&rows)) {
+		assert.Equal(t, "ab�c�", rows[0].Name)
+	}
+	encoded, err := json.Marshal(resultSet)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"columns":["name"],"rows":[["ab�c�"]]}`, string(encoded))
+	}
+}
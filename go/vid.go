@@ -0,0 +1,119 @@
+/* Copyright (c) 2020 vesoft inc. All rights reserved.
+ *
+ * This source code is licensed under Apache 2.0 License,
+ * attached with Common Clause Condition 1.0, found in the LICENSES directory.
+ */
+
+package nebula
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	nebula "github.com/vesoft-inc/nebula-clients/go/nebula"
+)
+
+// VIDType is the type of the vertex IDs of a space, which is set by vid_type when the space is created
+type VIDType int
+
+const (
+	// FIXED_STRING, the default type of vertex IDs
+	VIDTypeString VIDType = iota
+	// INT64
+	VIDTypeInt64
+)
+
+// Render a vertex ID as a nGQL literal.
+// String IDs are quoted, integers given for them are quoted too. Int64 IDs are left bare,
+// strings given for them must be decimal integers.
+func (vidType VIDType) literal(vid interface{}) (string, error) {
+	switch v := vid.(type) {
+	case string:
+		if vidType == VIDTypeInt64 {
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				return "", fmt.Errorf("Failed to build query: VID %s is not an int64", EscapeString(v))
+			}
+			return v, nil
+		}
+		return EscapeString(v), nil
+	case []byte:
+		return vidType.literal(string(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		// Only checks the range, a VID is written without the parentheses of a negative parameter
+		if _, err := toLiteral(v); err != nil {
+			return "", err
+		}
+		literal := fmt.Sprint(v)
+		if vidType == VIDTypeString {
+			return EscapeString(literal), nil
+		}
+		return literal, nil
+	default:
+		return "", fmt.Errorf("Failed to build query: VID must be a string or an integer, got %T", vid)
+	}
+}
+
+// Render a vertex ID as literal does, a string ID is checked with ValidateVID if maxLen is set
+func (vidType VIDType) checkedLiteral(vid interface{}, maxLen int) (string, error) {
+	literal, err := vidType.literal(vid)
+	if err != nil || vidType != VIDTypeString || maxLen <= 0 {
+		return literal, err
+	}
+	var s string
+	switch v := vid.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if err := ValidateVID(s, vidType, maxLen); err != nil {
+		return "", fmt.Errorf("Failed to build query: %s", err.Error())
+	}
+	return literal, nil
+}
+
+// HashVID returns a deterministic ID derived from s, e.g. a business key, for a space of FIXED_STRING VIDs.
+// It is the hex encoding of the first 16 bytes of the SHA-256 of s, so it always has 32 characters
+// and fits FIXED_STRING(32) or longer.
+func HashVID(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ValidateVID checks the vertex ID fits the space before it is sent, graphd reports a less clear error otherwise.
+// A string ID must not be empty nor longer than maxLen bytes, the N of the FIXED_STRING(N) of the space,
+// 0 value of maxLen means the length is not checked. An int64 ID must be a decimal integer in the range of int64.
+func ValidateVID(vid string, vidType VIDType, maxLen int) error {
+	switch vidType {
+	case VIDTypeInt64:
+		if _, err := strconv.ParseInt(vid, 10, 64); err != nil {
+			if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+				return fmt.Errorf("Invalid VID %s: out of the range of int64", vid)
+			}
+			return fmt.Errorf("Invalid VID %s: not an int64", EscapeString(vid))
+		}
+	default:
+		if vid == "" {
+			return fmt.Errorf("Invalid VID: empty string")
+		}
+		if maxLen > 0 && len(vid) > maxLen {
+			return fmt.Errorf("Invalid VID %s: %d bytes exceed the FIXED_STRING(%d) of the space",
+				EscapeString(vid), len(vid), maxLen)
+		}
+	}
+	return nil
+}
+
+// Convert a vertex ID returned by graphd to a value, int64 IDs are sent as 8 bytes in little endian
+func (vidType VIDType) toValue(vid nebula.VertexID) *nebula.Value {
+	if vidType == VIDTypeInt64 && len(vid) == 8 {
+		i := int64(binary.LittleEndian.Uint64(vid))
+		return &nebula.Value{IVal: &i}
+	}
+	return &nebula.Value{SVal: vid}
+}